@@ -6,14 +6,24 @@ import (
 	"os"
 
 	"github.com/lwmacct/251203-vm-metrics/internal/command"
+	"github.com/lwmacct/251203-vm-metrics/internal/command/configcmd"
 	"github.com/lwmacct/251203-vm-metrics/internal/command/export"
 	importcmd "github.com/lwmacct/251203-vm-metrics/internal/command/import"
 	"github.com/lwmacct/251203-vm-metrics/internal/command/query"
+	"github.com/lwmacct/251203-vm-metrics/internal/command/serve"
 	"github.com/lwmacct/251203-vm-metrics/internal/version"
 	"github.com/urfave/cli/v3"
 )
 
 func main() {
+	if err := newApp().Run(context.Background(), os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newApp 构建命令树并绑定环境变量
+func newApp() *cli.Command {
 	app := &cli.Command{
 		Name:    "vm-metrics",
 		Usage:   "VictoriaMetrics 统一命令行工具",
@@ -22,17 +32,17 @@ func main() {
 			queryCommand(),
 			exportCommand(),
 			importCommand(),
+			configcmd.Command,
+			serve.Command,
 			version.Command,
 		},
 		Flags: command.BaseFlags(),
 	}
 	// 动态添加 completion 命令
-	app.Commands = append(app.Commands, command.NewCompletionCommand(app))
-
-	if err := app.Run(context.Background(), os.Args); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
+	app.Commands = append(app.Commands, command.NewCompletionCommand(app), command.NewCompleteCommand())
+	// 为所有 flag 绑定环境变量，如 --server-url → VM_METRICS_SERVER_URL
+	command.BindEnvVars(app)
+	return app
 }
 
 // queryCommand 包装 query 命令
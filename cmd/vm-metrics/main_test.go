@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/urfave/cli/v3"
+)
+
+// TestEnvVarsUnique 不同的 flag 不能绑定同一个环境变量；
+// 只有对应配置项的 flag（及 --config）可以在多个命令中共用变量，它们在各命令中含义相同
+func TestEnvVarsUnique(t *testing.T) {
+	shared := config.ConfigFlagNames()
+	shared["config"] = true
+	owners := make(map[string]string) // 环境变量 → 首个绑定的 flag
+	var walk func(cmd *cli.Command, path string)
+	walk = func(cmd *cli.Command, path string) {
+		for _, f := range cmd.Flags {
+			env, ok := f.(cli.DocGenerationFlag)
+			if !ok {
+				continue
+			}
+			name := f.Names()[0]
+			for _, v := range env.GetEnvVars() {
+				owner, seen := owners[v]
+				if !seen {
+					owners[v] = path + " --" + name
+				} else if !shared[name] || !strings.HasSuffix(owner, " --"+name) {
+					t.Errorf("%s --%s 与 %s 共用环境变量 %s", path, name, owner, v)
+				}
+			}
+		}
+		for _, sub := range cmd.Commands {
+			walk(sub, path+" "+sub.Name)
+		}
+	}
+	walk(newApp(), "vm-metrics")
+	if len(owners) == 0 {
+		t.Fatal("没有绑定任何环境变量")
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/lwmacct/251203-vm-metrics/internal/command"
 	app "github.com/lwmacct/251203-vm-metrics/internal/command/query"
 )
 
 func main() {
+	command.BindEnvVars(app.Command)
 	if err := app.Command.Run(context.Background(), os.Args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,259 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// cgroupCPUStats cpu.stat 中输出的字段，usec 字段转换为秒
+var cgroupCPUStats = []struct {
+	field, name, help string
+	seconds           bool
+}{
+	{"usage_usec", "cgroup_cpu_usage_seconds_total", "cgroup 占用的 CPU 时间", true},
+	{"user_usec", "cgroup_cpu_user_seconds_total", "cgroup 在用户态占用的 CPU 时间", true},
+	{"system_usec", "cgroup_cpu_system_seconds_total", "cgroup 在内核态占用的 CPU 时间", true},
+	{"nr_periods", "cgroup_cpu_periods_total", "经过的 CPU 限额周期数", false},
+	{"nr_throttled", "cgroup_cpu_throttled_periods_total", "因超出 CPU 限额被限流的周期数", false},
+	{"throttled_usec", "cgroup_cpu_throttled_seconds_total", "因超出 CPU 限额被限流的时间", true},
+}
+
+// cgroupIOStats io.stat 中的字段对应的指标和方向
+var cgroupIOStats = map[string]struct {
+	bytes bool
+	op    string
+}{
+	"rbytes": {true, "read"},
+	"wbytes": {true, "write"},
+	"dbytes": {true, "discard"},
+	"rios":   {false, "read"},
+	"wios":   {false, "write"},
+	"dios":   {false, "discard"},
+}
+
+// Cgroup 遍历 cgroup v2 层级，输出各 cgroup 的 CPU、内存、I/O 和进程数
+//
+// 只遍历到 max_depth 层 (根为 0 层)，避免容器和 systemd 单元很多时时间序列过多。
+// 每个 cgroup 带 cgroup (相对根的路径) 和 name 两个标签：路径匹配 name_pattern 时 name 为按 name_replacement 展开的结果
+// (如从 docker-<id>.scope 中取出容器 ID)，否则为路径的最后一段。
+// 控制器未启用时对应的文件不存在，跳过这些指标
+type Cgroup struct {
+	root            string
+	sysPath         string
+	maxDepth        int
+	namePattern     *regexp.Regexp // 为 nil 时 name 为路径的最后一段
+	nameReplacement string
+}
+
+// NewCgroup 创建 cgroup 采集器，root 为空时使用 <sysPath>/fs/cgroup，
+// 混合模式下 cgroup v2 挂载在 fs/cgroup/unified，自动识别
+func NewCgroup(sysPath string, cfg config.CgroupCollectorConfig) (*Cgroup, error) {
+	c := &Cgroup{root: cfg.Root, sysPath: sysPath, maxDepth: cfg.MaxDepth, nameReplacement: cfg.NameReplacement}
+	if c.root == "" {
+		c.root = filepath.Join(sysPath, "fs", "cgroup")
+		if _, err := os.Stat(filepath.Join(c.root, "cgroup.controllers")); err != nil {
+			if unified := filepath.Join(c.root, "unified"); isDir(unified) {
+				c.root = unified
+			}
+		}
+	}
+	var err error
+	if c.namePattern, err = compilePattern("cgroup name_pattern", cfg.NamePattern); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name 实现 metrics.Collector
+func (c *Cgroup) Name() string {
+	return "cgroup"
+}
+
+// Collect 实现 metrics.Collector
+func (c *Cgroup) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	if _, err := os.Stat(filepath.Join(c.root, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("cgroup v2 not mounted at %s: %w", c.root, err)
+	}
+
+	cpu := make([]*metrics.Family, len(cgroupCPUStats))
+	for i, s := range cgroupCPUStats {
+		cpu[i] = metrics.NewFamily(s.name, s.help, metrics.Counter)
+	}
+	memory := metrics.NewFamily("cgroup_memory_usage_bytes", "cgroup 当前使用的内存 (memory.current)", metrics.Gauge)
+	peak := metrics.NewFamily("cgroup_memory_peak_bytes", "cgroup 内存用量的峰值 (memory.peak，内核 5.19 起提供)", metrics.Gauge)
+	limit := metrics.NewFamily("cgroup_memory_limit_bytes", "cgroup 的内存上限 (memory.max)，未限制时不输出", metrics.Gauge)
+	ioBytes := metrics.NewFamily("cgroup_io_bytes_total", "cgroup 在各设备上读写的字节数", metrics.Counter)
+	ioOps := metrics.NewFamily("cgroup_io_ops_total", "cgroup 在各设备上完成的读写请求数", metrics.Counter)
+	pids := metrics.NewFamily("cgroup_pids", "cgroup 中的进程和线程数 (pids.current)", metrics.Gauge)
+	pidsLimit := metrics.NewFamily("cgroup_pids_limit", "cgroup 的进程和线程数上限 (pids.max)，未限制时不输出", metrics.Gauge)
+	devices := make(map[string]string)
+
+	err := filepath.WalkDir(c.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 遍历期间被删除的 cgroup
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(c.root, path)
+		if err != nil {
+			return err
+		}
+		depth := 0
+		if rel != "." {
+			depth = strings.Count(rel, string(filepath.Separator)) + 1
+		}
+		if depth > c.maxDepth {
+			return filepath.SkipDir
+		}
+
+		cgroup := "/" + filepath.ToSlash(rel)
+		if rel == "." {
+			cgroup = "/"
+		}
+		labels := []string{"cgroup", cgroup, "name", c.name(cgroup)}
+
+		stats := readKeyValues(filepath.Join(path, "cpu.stat"))
+		for i, s := range cgroupCPUStats {
+			if v, ok := stats[s.field]; ok {
+				if s.seconds {
+					v /= 1e6
+				}
+				cpu[i].Add(v, labels...)
+			}
+		}
+		if v, ok := readSysUint(filepath.Join(path, "memory.current")); ok {
+			memory.Add(v, labels...)
+		}
+		if v, ok := readSysUint(filepath.Join(path, "memory.peak")); ok {
+			peak.Add(v, labels...)
+		}
+		if v, ok := readSysUint(filepath.Join(path, "memory.max")); ok {
+			limit.Add(v, labels...)
+		}
+		if v, ok := readSysUint(filepath.Join(path, "pids.current")); ok {
+			pids.Add(v, labels...)
+		}
+		if v, ok := readSysUint(filepath.Join(path, "pids.max")); ok {
+			pidsLimit.Add(v, labels...)
+		}
+		c.ioStat(filepath.Join(path, "io.stat"), labels, devices, ioBytes, ioOps)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk cgroup hierarchy: %w", err)
+	}
+
+	return append(cpu, memory, peak, limit, ioBytes, ioOps, pids, pidsLimit), nil
+}
+
+// name 计算 cgroup 的 name 标签
+func (c *Cgroup) name(cgroup string) string {
+	if c.namePattern != nil {
+		if m := c.namePattern.FindStringSubmatchIndex(cgroup); m != nil {
+			return string(c.namePattern.ExpandString(nil, c.nameReplacement, cgroup, m))
+		}
+	}
+	return filepath.Base(cgroup)
+}
+
+// ioStat 读取 io.stat，每行为一个设备：
+//
+//	8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+//
+// 设备号通过 /sys/dev/block 解析为设备名，解析失败时保留 major:minor
+func (c *Cgroup) ioStat(path string, labels []string, devices map[string]string, ioBytes, ioOps *metrics.Family) {
+	lines, err := readLines(path)
+	if err != nil {
+		return
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		device := blockDevice(c.sysPath, fields[0], devices)
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			stat, known := cgroupIOStats[key]
+			if !ok || !known {
+				continue
+			}
+			v, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			f := ioOps
+			if stat.bytes {
+				f = ioBytes
+			}
+			f.Add(float64(v), slices.Concat(labels, []string{"device", device, "op", stat.op})...)
+		}
+	}
+}
+
+// blockDevice 通过 <sysPath>/dev/block 将块设备号 (major:minor) 解析为设备名，解析失败时返回设备号。
+// 结果缓存在 cache 中，避免一次采集中重复读取
+func blockDevice(sysPath, majorMinor string, cache map[string]string) string {
+	if device, ok := cache[majorMinor]; ok {
+		return device
+	}
+	device := majorMinor
+	if target, err := os.Readlink(filepath.Join(sysPath, "dev", "block", majorMinor)); err == nil {
+		device = filepath.Base(target)
+	}
+	cache[majorMinor] = device
+	return device
+}
+
+// readKeyValues 读取每行为 "键 值" 的文件 (如 cpu.stat)，读取失败时返回 nil
+func readKeyValues(path string) map[string]float64 {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil
+	}
+	values := make(map[string]float64, len(lines))
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+			values[key] = float64(v)
+		}
+	}
+	return values
+}
+
+// readSysUint 读取只包含一个无符号整数的文件，文件不存在或内容为 max (未限制) 时返回 false
+func readSysUint(path string) (float64, bool) {
+	v, err := strconv.ParseUint(readSysString(path), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return float64(v), true
+}
+
+// isDir 判断路径是否为目录
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+const testContainerID = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestCgroupCollect(t *testing.T) {
+	sys := t.TempDir()
+	scope := "fs/cgroup/unified/system.slice/docker-" + testContainerID + ".scope/"
+	writeProc(t, sys, map[string]string{
+		// 混合模式，cgroup v2 挂载在 unified
+		"fs/cgroup/unified/cgroup.controllers":                  "cpu io memory pids\n",
+		"fs/cgroup/unified/cpu.stat":                            "usage_usec 9000000\nuser_usec 6000000\nsystem_usec 3000000\n",
+		"fs/cgroup/unified/system.slice/cpu.stat":               "usage_usec 5000000\n",
+		"fs/cgroup/unified/system.slice/memory.current":         "104857600\n",
+		"fs/cgroup/unified/system.slice/memory.max":             "max\n",
+		scope + "cpu.stat":                                      "usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\nnr_periods 100\nnr_throttled 7\nthrottled_usec 250000\n",
+		scope + "memory.current":                                "52428800\n",
+		scope + "memory.peak":                                   "62914560\n",
+		scope + "memory.max":                                    "268435456\n",
+		scope + "pids.current":                                  "12\n",
+		scope + "pids.max":                                      "max\n",
+		scope + "io.stat":                                       "8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n253:1 rbytes=10 wbytes=0 rios=1 wios=0\n",
+		scope + "nested/cpu.stat":                               "usage_usec 1\n",
+		"fs/cgroup/unified/user.slice/user-1000.slice/cpu.stat": "usage_usec 42\n",
+	})
+	if err := os.MkdirAll(filepath.Join(sys, "dev/block"), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	if err := os.Symlink("../../devices/pci0000:00/block/sda", filepath.Join(sys, "dev/block/8:0")); err != nil {
+		t.Fatalf("创建符号链接失败: %v", err)
+	}
+
+	cfg := config.DefaultConfig().Collector.Cgroup
+	cfg.MaxDepth = 2
+	c, err := NewCgroup(sys, cfg)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+
+	container := []string{"cgroup", "/system.slice/docker-" + testContainerID + ".scope", "name", "0123456789ab"}
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"cgroup_cpu_usage_seconds_total", []string{"cgroup", "/", "name", "/"}, 9},
+		{"cgroup_cpu_usage_seconds_total", []string{"cgroup", "/system.slice", "name", "system.slice"}, 5},
+		{"cgroup_memory_usage_bytes", []string{"cgroup", "/system.slice", "name", "system.slice"}, 104857600},
+		{"cgroup_cpu_user_seconds_total", container, 1},
+		{"cgroup_cpu_throttled_periods_total", container, 7},
+		{"cgroup_cpu_throttled_seconds_total", container, 0.25},
+		{"cgroup_memory_peak_bytes", container, 62914560},
+		{"cgroup_memory_limit_bytes", container, 268435456},
+		{"cgroup_pids", container, 12},
+		{"cgroup_io_bytes_total", append(container[:4:4], "device", "sda", "op", "write"), 8192},
+		{"cgroup_io_ops_total", append(container[:4:4], "device", "253:1", "op", "read"), 1},
+		{"cgroup_cpu_usage_seconds_total", []string{"cgroup", "/user.slice/user-1000.slice", "name", "user-1000.slice"}, 0.000042},
+	} {
+		if v, ok := findMetric(families, tt.name, tt.labels...); !ok || v != tt.want {
+			t.Errorf("%s%v = %v, %v, 期望 %v", tt.name, tt.labels, v, ok, tt.want)
+		}
+	}
+	if _, ok := findMetric(families, "cgroup_memory_limit_bytes", "cgroup", "/system.slice", "name", "system.slice"); ok {
+		t.Error("memory.max 为 max 时不应输出上限")
+	}
+	if _, ok := findMetric(families, "cgroup_pids_limit", container...); ok {
+		t.Error("pids.max 为 max 时不应输出上限")
+	}
+	if _, ok := findMetric(families, "cgroup_cpu_usage_seconds_total", "cgroup", "/system.slice/docker-"+testContainerID+".scope/nested", "name", "nested"); ok {
+		t.Error("超过 max_depth 的 cgroup 不应输出")
+	}
+}
+
+func TestCgroupNotMounted(t *testing.T) {
+	c, err := NewCgroup(t.TempDir(), config.CgroupCollectorConfig{MaxDepth: 1})
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	if _, err := c.Collect(context.Background()); err == nil {
+		t.Error("未挂载 cgroup v2 时应返回错误")
+	}
+	if _, err := NewCgroup("", config.CgroupCollectorConfig{NamePattern: "("}); err == nil {
+		t.Error("无效的正则表达式应返回错误")
+	}
+}
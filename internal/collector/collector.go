@@ -0,0 +1,230 @@
+// Package collector 提供主机指标采集器
+//
+// 各采集器从 /proc、/sys 等内核接口或容器运行时的 API 读取数据，实现 metrics.Collector，由 serve 命令注册到注册表。
+// /proc、/sys 和根文件系统的位置可配置，便于在容器中挂载宿主机的目录后采集宿主机指标。
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// userHZ /proc/stat 中时间的单位 (USER_HZ)，Linux 在所有主流架构上固定为 100
+const userHZ = 100
+
+// Enabled 按配置创建启用的采集器
+func Enabled(cfg config.CollectorConfig) ([]metrics.Collector, error) {
+	var collectors []metrics.Collector
+	if cfg.CPU.Enabled {
+		collectors = append(collectors, NewCPU(cfg.ProcPath, cfg.CPU))
+	}
+	if cfg.Memory.Enabled {
+		collectors = append(collectors, NewMemory(cfg.ProcPath, cfg.Memory))
+	}
+	if cfg.Diskstats.Enabled {
+		diskstats, err := NewDiskstats(cfg.ProcPath, cfg.Diskstats)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, diskstats)
+	}
+	if cfg.Filesystem.Enabled {
+		filesystem, err := NewFilesystem(cfg.ProcPath, cfg.RootfsPath, cfg.Filesystem)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, filesystem)
+	}
+	if cfg.Netdev.Enabled {
+		netdev, err := NewNetdev(cfg.ProcPath, cfg.SysPath, cfg.Netdev)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, netdev)
+	}
+	if cfg.Netstat.Enabled {
+		netstat, err := NewNetstat(cfg.ProcPath, cfg.Netstat)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, netstat)
+	}
+	if cfg.Process.Enabled {
+		process, err := NewProcess(cfg.ProcPath, cfg.Process)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, process)
+	}
+	if cfg.Cgroup.Enabled {
+		cgroup, err := NewCgroup(cfg.SysPath, cfg.Cgroup)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, cgroup)
+	}
+	if cfg.Container.Enabled {
+		container, err := NewContainer(cfg.ProcPath, cfg.SysPath, cfg.Container)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, container)
+	}
+	if cfg.GPU.Enabled {
+		collectors = append(collectors, NewGPU(cfg.ProcPath, cfg.GPU))
+	}
+	if cfg.Hwmon.Enabled {
+		hwmon, err := NewHwmon(cfg.SysPath, cfg.Hwmon)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, hwmon)
+	}
+	if cfg.SMART.Enabled {
+		collectors = append(collectors, NewSMART(cfg.SMART))
+	}
+	if cfg.NVMe.Enabled {
+		collectors = append(collectors, NewNVMe(cfg.SysPath, cfg.NVMe))
+	}
+	if cfg.Systemd.Enabled {
+		systemd, err := NewSystemd(cfg.Systemd)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, systemd)
+	}
+	if cfg.Kernel.Enabled {
+		collectors = append(collectors, NewKernel(cfg.ProcPath, cfg.RootfsPath, cfg.Kernel))
+	}
+	if cfg.NTP.Enabled {
+		collectors = append(collectors, NewNTP(cfg.NTP))
+	}
+	if cfg.Probe.Enabled {
+		probe, err := NewProbe(cfg.Probe)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, probe)
+	}
+	if cfg.Database.Enabled {
+		database, err := NewDatabase(cfg.Database)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, database)
+	}
+	return collectors, nil
+}
+
+// readLines 读取文件的所有行
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return lines, nil
+}
+
+// addUint 解析无符号整数并添加到指标族
+func addUint(f *metrics.Family, s string, labels ...string) error {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	f.Add(float64(v), labels...)
+	return nil
+}
+
+// procFile 返回 /proc 下的文件路径
+func procFile(procPath string, name ...string) string {
+	return filepath.Join(append([]string{procPath}, name...)...)
+}
+
+// compilePattern 编译过滤用的正则表达式，为空时返回 nil 表示不过滤
+func compilePattern(key, expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile %s: %w", key, err)
+	}
+	return re, nil
+}
+
+// matchFilter 判断 s 是否匹配 include (为 nil 时不限制) 且不匹配 exclude (为 nil 时不排除)
+func matchFilter(include, exclude *regexp.Regexp, s string) bool {
+	return (include == nil || include.MatchString(s)) && (exclude == nil || !exclude.MatchString(s))
+}
+
+// pressureWindows PSI 滑动平均的字段和对应的时间窗口
+var pressureWindows = []struct{ field, window string }{
+	{"avg10", "10s"},
+	{"avg60", "60s"},
+	{"avg300", "300s"},
+}
+
+// collectPressure 读取 /proc/pressure/<resource>，每行形如
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//
+// 滑动平均为百分比，转换为 0-1 的比例；total 为累计停顿的微秒数，转换为秒。
+// 内核未启用 PSI 时文件不存在，返回 nil
+func collectPressure(procPath, resource, help string) ([]*metrics.Family, error) {
+	lines, err := readLines(procFile(procPath, "pressure", resource))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ratio := metrics.NewFamily(resource+"_pressure_ratio", help+"的时间占比的滑动平均 (PSI)", metrics.Gauge)
+	stalled := metrics.NewFamily(resource+"_pressure_stalled_seconds_total", help+"的累计时间 (PSI)", metrics.Counter)
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		kind := fields[0]
+		values := make(map[string]string, len(fields)-1)
+		for _, field := range fields[1:] {
+			if k, v, ok := strings.Cut(field, "="); ok {
+				values[k] = v
+			}
+		}
+		for _, w := range pressureWindows {
+			v, err := strconv.ParseFloat(values[w.field], 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse pressure %s %s: %w", kind, w.field, err)
+			}
+			ratio.Add(v/100, "kind", kind, "window", w.window)
+		}
+		total, err := strconv.ParseFloat(values["total"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse pressure %s total: %w", kind, err)
+		}
+		stalled.Add(total/1e6, "kind", kind)
+	}
+	return []*metrics.Family{ratio, stalled}, nil
+}
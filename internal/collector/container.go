@@ -0,0 +1,251 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// 容器运行时
+const (
+	ContainerRuntimeDocker     = "docker"
+	ContainerRuntimeContainerd = "containerd"
+)
+
+// containerStates container_state 输出的状态，docker 和 containerd 的状态都映射到其中之一
+var containerStates = []string{"created", "running", "paused", "restarting", "removing", "exited", "dead", "unknown"}
+
+// containerRuntime 容器运行时的 API 客户端
+type containerRuntime interface {
+	// list 列出容器，all 为 false 时只返回运行中 (含暂停) 的容器
+	list(ctx context.Context, all bool) ([]*containerInfo, error)
+}
+
+// containerInfo 容器的元数据和资源用量
+type containerInfo struct {
+	id          string
+	name        string
+	image       string
+	namespace   string // containerd 的命名空间，docker 为空
+	labels      map[string]string
+	state       string // containerStates 之一
+	restarts    float64
+	hasRestarts bool            // containerd 没有重启次数
+	stats       *containerStats // 未运行或读取失败时为 nil
+}
+
+// containerStats 容器的资源用量，时间单位为秒
+type containerStats struct {
+	cpuUsage         float64
+	cpuUser          float64
+	cpuSystem        float64
+	throttledPeriods float64
+	throttledSeconds float64
+	memoryUsage      float64
+	memoryWorkingSet float64 // 内存用量减去非活跃的文件缓存，与 kubelet 的 working set 一致
+	memoryLimit      float64 // 为 0 时未限制
+	pids             float64
+	networks         []netDevStats // 按 /proc/net/dev 的列顺序，只填写收发的字节、包、错误和丢弃数
+	blkio            []containerBlkio
+}
+
+// containerBlkio 容器在一个块设备上某个方向的字节数或请求数
+type containerBlkio struct {
+	device string // major:minor
+	op     string // read、write 或 discard
+	bytes  bool   // 为 false 时 value 为请求数
+	value  float64
+}
+
+// Container 通过 Docker Engine API 或 containerd 的 gRPC API 采集容器的状态和资源用量
+//
+// 每个容器带 id (长 ID 取前 12 位) 和 name 两个标签，container_info 额外输出镜像、
+// containerd 命名空间和 label_include 匹配的容器标签，用于在查询时关联镜像和编排信息。
+// 块设备号通过 /sys/dev/block 解析为设备名
+type Container struct {
+	runtime      containerRuntime
+	all          bool
+	labelInclude *regexp.Regexp // 为 nil 时不输出容器标签
+	sysPath      string
+	timeout      time.Duration
+}
+
+// NewContainer 创建容器运行时采集器，运行时或 API 地址无效时返回错误。
+// 创建时不连接运行时，运行时不可用时采集失败
+func NewContainer(procPath, sysPath string, cfg config.ContainerCollectorConfig) (*Container, error) {
+	c := &Container{all: cfg.All, sysPath: sysPath, timeout: cfg.Timeout}
+	var err error
+	if c.labelInclude, err = compilePattern("container label_include", cfg.LabelInclude); err != nil {
+		return nil, err
+	}
+	switch cfg.Runtime {
+	case ContainerRuntimeDocker, "":
+		c.runtime, err = newDocker(cfg.Endpoint)
+	case ContainerRuntimeContainerd:
+		c.runtime, err = newContainerd(cfg.Endpoint, cfg.Namespace, procPath)
+	default:
+		err = fmt.Errorf("unsupported container runtime: %s", cfg.Runtime)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name 实现 metrics.Collector
+func (c *Container) Name() string {
+	return "container"
+}
+
+// Collect 实现 metrics.Collector
+func (c *Container) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	containers, err := c.runtime.list(ctx, c.all)
+	if err != nil {
+		return nil, err
+	}
+
+	info := metrics.NewFamily("container_info", "容器信息，值恒为 1", metrics.Gauge)
+	state := metrics.NewFamily("container_state", "容器是否处于该状态", metrics.Gauge)
+	restarts := metrics.NewFamily("container_restarts_total", "容器被重启策略重启的次数 (仅 docker)", metrics.Counter)
+	cpuUsage := metrics.NewFamily("container_cpu_usage_seconds_total", "容器占用的 CPU 时间", metrics.Counter)
+	cpuUser := metrics.NewFamily("container_cpu_user_seconds_total", "容器在用户态占用的 CPU 时间", metrics.Counter)
+	cpuSystem := metrics.NewFamily("container_cpu_system_seconds_total", "容器在内核态占用的 CPU 时间", metrics.Counter)
+	throttledPeriods := metrics.NewFamily("container_cpu_throttled_periods_total", "容器因超出 CPU 限额被限流的周期数", metrics.Counter)
+	throttledSeconds := metrics.NewFamily("container_cpu_throttled_seconds_total", "容器因超出 CPU 限额被限流的时间", metrics.Counter)
+	memoryUsage := metrics.NewFamily("container_memory_usage_bytes", "容器使用的内存，含文件缓存", metrics.Gauge)
+	workingSet := metrics.NewFamily("container_memory_working_set_bytes", "容器的工作集内存 (内存用量减去非活跃的文件缓存)", metrics.Gauge)
+	memoryLimit := metrics.NewFamily("container_memory_limit_bytes", "容器的内存上限，未限制时不输出", metrics.Gauge)
+	pids := metrics.NewFamily("container_pids", "容器中的进程和线程数", metrics.Gauge)
+	networkBytes := metrics.NewFamily("container_network_bytes_total", "容器网卡收发的字节数", metrics.Counter)
+	networkPackets := metrics.NewFamily("container_network_packets_total", "容器网卡收发的包数", metrics.Counter)
+	networkErrors := metrics.NewFamily("container_network_errors_total", "容器网卡的收发错误数", metrics.Counter)
+	networkDrops := metrics.NewFamily("container_network_drops_total", "容器网卡丢弃的包数", metrics.Counter)
+	blkioBytes := metrics.NewFamily("container_blkio_bytes_total", "容器在各块设备上读写的字节数", metrics.Counter)
+	blkioOps := metrics.NewFamily("container_blkio_ops_total", "容器在各块设备上完成的读写请求数", metrics.Counter)
+	devices := make(map[string]string)
+
+	for _, ct := range containers {
+		labels := []string{"id", ct.id, "name", ct.name}
+		info.Add(1, c.infoLabels(ct)...)
+		for _, s := range containerStates {
+			state.Add(boolValue(ct.state == s), slices.Concat(labels, []string{"state", s})...)
+		}
+		if ct.hasRestarts {
+			restarts.Add(ct.restarts, labels...)
+		}
+
+		s := ct.stats
+		if s == nil {
+			continue
+		}
+		cpuUsage.Add(s.cpuUsage, labels...)
+		cpuUser.Add(s.cpuUser, labels...)
+		cpuSystem.Add(s.cpuSystem, labels...)
+		throttledPeriods.Add(s.throttledPeriods, labels...)
+		throttledSeconds.Add(s.throttledSeconds, labels...)
+		memoryUsage.Add(s.memoryUsage, labels...)
+		workingSet.Add(s.memoryWorkingSet, labels...)
+		if s.memoryLimit > 0 {
+			memoryLimit.Add(s.memoryLimit, labels...)
+		}
+		pids.Add(s.pids, labels...)
+		for _, n := range s.networks {
+			for d, direction := range netDirectionNames {
+				v := n.values[d*netFields:]
+				l := slices.Concat(labels, []string{"interface", n.device, "direction", direction})
+				networkBytes.Add(v[netBytes], l...)
+				networkPackets.Add(v[netPackets], l...)
+				networkErrors.Add(v[netErrors], l...)
+				networkDrops.Add(v[netDrops], l...)
+			}
+		}
+		for _, b := range s.blkio {
+			l := slices.Concat(labels, []string{"device", blockDevice(c.sysPath, b.device, devices), "op", b.op})
+			f := blkioOps
+			if b.bytes {
+				f = blkioBytes
+			}
+			f.Add(b.value, l...)
+		}
+	}
+
+	return []*metrics.Family{
+		info, state, restarts,
+		cpuUsage, cpuUser, cpuSystem, throttledPeriods, throttledSeconds,
+		memoryUsage, workingSet, memoryLimit, pids,
+		networkBytes, networkPackets, networkErrors, networkDrops,
+		blkioBytes, blkioOps,
+	}, nil
+}
+
+// infoLabels 返回 container_info 的标签，容器标签按名称排序，名称中的非法字符替换为下划线
+func (c *Container) infoLabels(ct *containerInfo) []string {
+	labels := []string{"id", ct.id, "name", ct.name, "image", ct.image}
+	if ct.namespace != "" {
+		labels = append(labels, "namespace", ct.namespace)
+	}
+	if c.labelInclude == nil {
+		return labels
+	}
+	for _, key := range slices.Sorted(maps.Keys(ct.labels)) {
+		if c.labelInclude.MatchString(key) {
+			labels = append(labels, "label_"+labelName(key), ct.labels[key])
+		}
+	}
+	return labels
+}
+
+// appendBlkio 添加块设备读写计数，op 不区分大小写，只保留 read、write 和 discard
+func appendBlkio(blkio []containerBlkio, major, minor uint64, op string, bytes bool, value uint64) []containerBlkio {
+	op = strings.ToLower(op)
+	if op != "read" && op != "write" && op != "discard" {
+		return blkio
+	}
+	return append(blkio, containerBlkio{device: fmt.Sprintf("%d:%d", major, minor), op: op, bytes: bytes, value: float64(value)})
+}
+
+// workingSet 计算工作集内存：内存用量减去非活跃的文件缓存
+func workingSet(usage, inactiveFile uint64) float64 {
+	if inactiveFile >= usage {
+		return 0
+	}
+	return float64(usage - inactiveFile)
+}
+
+// memoryLimit 内存上限，未限制时 cgroup 返回接近 2^64 的值，此时返回 0
+func memoryLimit(limit uint64) float64 {
+	if limit >= 1<<62 {
+		return 0
+	}
+	return float64(limit)
+}
+
+// labelName 将容器标签的名称 (如 com.docker.compose.service) 转换为合法的指标标签名
+func labelName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// shortID 将 64 位十六进制的容器 ID 截断为 12 位，与 docker ps 一致，其他 ID 原样返回
+func shortID(id string) string {
+	if len(id) == 64 && strings.Trim(id, "0123456789abcdef") == "" {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,307 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cgroup2stats "github.com/containerd/cgroups/v3/cgroup2/stats"
+	containersapi "github.com/containerd/containerd/api/services/containers/v1"
+	namespacesapi "github.com/containerd/containerd/api/services/namespaces/v1"
+	tasksapi "github.com/containerd/containerd/api/services/tasks/v1"
+	"github.com/containerd/containerd/api/types"
+	"github.com/containerd/containerd/api/types/task"
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const testDockerStats = `{
+  "cpu_stats": {
+    "cpu_usage": {"total_usage": 2500000000, "usage_in_kernelmode": 500000000, "usage_in_usermode": 2000000000},
+    "throttling_data": {"periods": 100, "throttled_periods": 3, "throttled_time": 150000000}
+  },
+  "memory_stats": {"usage": 104857600, "limit": 8000000000, "stats": {"inactive_file": 4857600}},
+  "pids_stats": {"current": 7},
+  "networks": {
+    "eth0": {"rx_bytes": 1000, "rx_packets": 10, "rx_errors": 0, "rx_dropped": 1, "tx_bytes": 2000, "tx_packets": 20, "tx_errors": 2, "tx_dropped": 0}
+  },
+  "blkio_stats": {
+    "io_service_bytes_recursive": [
+      {"major": 8, "minor": 0, "op": "read", "value": 4096},
+      {"major": 8, "minor": 0, "op": "write", "value": 8192}
+    ],
+    "io_serviced_recursive": null
+  }
+}`
+
+// testDockerServer 模拟 Docker Engine API，web 运行中，db 已退出
+func testDockerServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /containers/json", func(w http.ResponseWriter, r *http.Request) {
+		running := `{"Id": "` + testContainerID + `", "Names": ["/web"], "Image": "nginx:1.27", "State": "running",
+		  "Labels": {"com.docker.compose.service": "web", "maintainer": "nginx"}}`
+		if r.URL.Query().Get("all") != "true" {
+			w.Write([]byte("[" + running + "]"))
+			return
+		}
+		w.Write([]byte("[" + running + `, {"Id": "db", "Names": ["/db"], "Image": "postgres:17", "State": "exited", "Labels": {}}]`))
+	})
+	mux.HandleFunc("GET /containers/{id}/json", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "db" {
+			w.Write([]byte(`{"RestartCount": 5, "HostConfig": {"Memory": 0}}`))
+			return
+		}
+		w.Write([]byte(`{"RestartCount": 2, "HostConfig": {"Memory": 268435456}}`))
+	})
+	mux.HandleFunc("GET /containers/{id}/stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("stream") != "false" {
+			t.Errorf("stats 请求应不使用流式响应: %s", r.URL)
+		}
+		w.Write([]byte(testDockerStats))
+	})
+	srv := httptest.NewUnstartedServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestContainerDocker(t *testing.T) {
+	srv := testDockerServer(t)
+	// unix socket 路径不能太长，不使用 t.TempDir
+	dir, err := os.MkdirTemp("", "docker")
+	if err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("监听 unix socket 失败: %v", err)
+	}
+	srv.Listener = l
+	srv.Start()
+
+	sys := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(sys, "dev/block"), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	if err := os.Symlink("../../devices/pci0000:00/block/sda", filepath.Join(sys, "dev/block/8:0")); err != nil {
+		t.Fatalf("创建符号链接失败: %v", err)
+	}
+
+	cfg := config.DefaultConfig().Collector.Container
+	cfg.Endpoint = "unix://" + socket
+	cfg.LabelInclude = `^com\.docker\.compose\.`
+	c, err := NewContainer("/proc", sys, cfg)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+
+	web := []string{"id", "0123456789ab", "name", "web"}
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"container_info", append(web[:4:4], "image", "nginx:1.27", "label_com_docker_compose_service", "web"), 1},
+		{"container_state", append(web[:4:4], "state", "running"), 1},
+		{"container_state", append(web[:4:4], "state", "exited"), 0},
+		{"container_restarts_total", web, 2},
+		{"container_cpu_usage_seconds_total", web, 2.5},
+		{"container_cpu_system_seconds_total", web, 0.5},
+		{"container_cpu_throttled_seconds_total", web, 0.15},
+		{"container_memory_usage_bytes", web, 104857600},
+		{"container_memory_working_set_bytes", web, 100000000},
+		{"container_memory_limit_bytes", web, 268435456},
+		{"container_pids", web, 7},
+		{"container_network_bytes_total", append(web[:4:4], "interface", "eth0", "direction", "tx"), 2000},
+		{"container_network_drops_total", append(web[:4:4], "interface", "eth0", "direction", "rx"), 1},
+		{"container_blkio_bytes_total", append(web[:4:4], "device", "sda", "op", "write"), 8192},
+	} {
+		if v, ok := findMetric(families, tt.name, tt.labels...); !ok || v != tt.want {
+			t.Errorf("%s%v = %v, %v, 期望 %v", tt.name, tt.labels, v, ok, tt.want)
+		}
+	}
+	if _, ok := findMetric(families, "container_state", "id", "db", "name", "db", "state", "exited"); ok {
+		t.Error("未开启 all 时不应输出已退出的容器")
+	}
+
+	// 开启 all 后已退出的容器只有信息、状态和重启次数
+	c.all = true
+	if families, err = c.Collect(context.Background()); err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	db := []string{"id", "db", "name", "db"}
+	if v, ok := findMetric(families, "container_state", append(db, "state", "exited")...); !ok || v != 1 {
+		t.Errorf("db 的 exited 状态 = %v, %v, 期望 1", v, ok)
+	}
+	if v, ok := findMetric(families, "container_restarts_total", db...); !ok || v != 5 {
+		t.Errorf("db 的重启次数 = %v, %v, 期望 5", v, ok)
+	}
+	if _, ok := findMetric(families, "container_cpu_usage_seconds_total", db...); ok {
+		t.Error("已退出的容器不应输出资源用量")
+	}
+}
+
+func TestContainerDockerEndpoint(t *testing.T) {
+	srv := testDockerServer(t)
+	srv.Start()
+	c, err := NewContainer("/proc", "/sys", config.ContainerCollectorConfig{Runtime: "docker", Endpoint: srv.URL})
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("通过 http 地址采集失败: %v", err)
+	}
+	if v, ok := findMetric(families, "container_pids", "id", "0123456789ab", "name", "web"); !ok || v != 7 {
+		t.Errorf("container_pids = %v, %v, 期望 7", v, ok)
+	}
+
+	for _, endpoint := range []string{"ftp://docker", "/var/run/docker.sock"} {
+		if _, err := NewContainer("/proc", "/sys", config.ContainerCollectorConfig{Runtime: "docker", Endpoint: endpoint}); err == nil {
+			t.Errorf("不支持的 API 地址 %q 应返回错误", endpoint)
+		}
+	}
+	if _, err := NewContainer("/proc", "/sys", config.ContainerCollectorConfig{Runtime: "podman"}); err == nil {
+		t.Error("不支持的运行时应返回错误")
+	}
+}
+
+// 模拟 containerd 的命名空间、容器和任务服务，default 命名空间中 app 运行中，job 已退出
+type (
+	fakeNamespaces struct {
+		namespacesapi.UnimplementedNamespacesServer
+	}
+	fakeContainers struct {
+		containersapi.UnimplementedContainersServer
+	}
+	fakeTasks struct {
+		tasksapi.UnimplementedTasksServer
+		t *testing.T
+	}
+)
+
+// requestNamespace 返回请求的 containerd 命名空间
+func requestNamespace(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ns := md.Get("containerd-namespace"); len(ns) > 0 {
+		return ns[0]
+	}
+	return ""
+}
+
+func (fakeNamespaces) List(context.Context, *namespacesapi.ListNamespacesRequest) (*namespacesapi.ListNamespacesResponse, error) {
+	return &namespacesapi.ListNamespacesResponse{Namespaces: []*namespacesapi.Namespace{{Name: "default"}, {Name: "empty"}}}, nil
+}
+
+func (fakeContainers) List(ctx context.Context, _ *containersapi.ListContainersRequest) (*containersapi.ListContainersResponse, error) {
+	if requestNamespace(ctx) != "default" {
+		return &containersapi.ListContainersResponse{}, nil
+	}
+	return &containersapi.ListContainersResponse{Containers: []*containersapi.Container{
+		{ID: "app", Image: "docker.io/library/redis:7", Labels: map[string]string{"nerdctl/name": "redis"}},
+		{ID: "job", Image: "docker.io/library/busybox:latest"},
+	}}, nil
+}
+
+func (fakeTasks) List(ctx context.Context, _ *tasksapi.ListTasksRequest) (*tasksapi.ListTasksResponse, error) {
+	if requestNamespace(ctx) != "default" {
+		return &tasksapi.ListTasksResponse{}, nil
+	}
+	return &tasksapi.ListTasksResponse{Tasks: []*task.Process{
+		{ID: "app", Pid: 4242, Status: task.Status_RUNNING},
+		{ID: "job", Pid: 0, Status: task.Status_STOPPED},
+	}}, nil
+}
+
+func (f fakeTasks) Metrics(ctx context.Context, _ *tasksapi.MetricsRequest) (*tasksapi.MetricsResponse, error) {
+	if requestNamespace(ctx) != "default" {
+		return &tasksapi.MetricsResponse{}, nil
+	}
+	data, err := anypb.New(&cgroup2stats.Metrics{
+		Pids:   &cgroup2stats.PidsStat{Current: 4},
+		CPU:    &cgroup2stats.CPUStat{UsageUsec: 3000000, UserUsec: 2000000, SystemUsec: 1000000, NrThrottled: 9, ThrottledUsec: 500000},
+		Memory: &cgroup2stats.MemoryStat{Usage: 50000000, UsageLimit: 1<<64 - 1, InactiveFile: 10000000},
+		Io:     &cgroup2stats.IOStat{Usage: []*cgroup2stats.IOEntry{{Major: 253, Minor: 1, Rbytes: 100, Wbytes: 200, Rios: 1, Wios: 2}}},
+	})
+	if err != nil {
+		f.t.Errorf("编码统计失败: %v", err)
+	}
+	return &tasksapi.MetricsResponse{Metrics: []*types.Metric{{ID: "app", Data: data}}}, nil
+}
+
+func TestContainerContainerd(t *testing.T) {
+	dir, err := os.MkdirTemp("", "containerd")
+	if err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "containerd.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("监听 unix socket 失败: %v", err)
+	}
+	srv := grpc.NewServer()
+	namespacesapi.RegisterNamespacesServer(srv, fakeNamespaces{})
+	containersapi.RegisterContainersServer(srv, fakeContainers{})
+	tasksapi.RegisterTasksServer(srv, fakeTasks{t: t})
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+
+	proc := writeProc(t, "", map[string]string{
+		"4242/net/dev": "Inter-|   Receive                                                |  Transmit\n" +
+			" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n" +
+			"    lo:     100       1    0    0    0     0          0         0      100       1    0    0    0     0       0          0\n" +
+			"  eth0:    5000      50    0    3    0     0          0         0     6000      60    0    0    0     0       0          0\n",
+	})
+	c, err := NewContainer(proc, t.TempDir(), config.ContainerCollectorConfig{Runtime: "containerd", Endpoint: "unix://" + socket, All: true})
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+
+	app := []string{"id", "app", "name", "redis"}
+	job := []string{"id", "job", "name", "job"}
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"container_info", append(app[:4:4], "image", "docker.io/library/redis:7", "namespace", "default"), 1},
+		{"container_state", append(app[:4:4], "state", "running"), 1},
+		{"container_state", append(job[:4:4], "state", "exited"), 1},
+		{"container_cpu_usage_seconds_total", app, 3},
+		{"container_cpu_throttled_periods_total", app, 9},
+		{"container_memory_working_set_bytes", app, 40000000},
+		{"container_pids", app, 4},
+		{"container_blkio_ops_total", append(app[:4:4], "device", "253:1", "op", "write"), 2},
+		{"container_network_bytes_total", append(app[:4:4], "interface", "eth0", "direction", "rx"), 5000},
+	} {
+		if v, ok := findMetric(families, tt.name, tt.labels...); !ok || v != tt.want {
+			t.Errorf("%s%v = %v, %v, 期望 %v", tt.name, tt.labels, v, ok, tt.want)
+		}
+	}
+	if _, ok := findMetric(families, "container_memory_limit_bytes", app...); ok {
+		t.Error("未限制内存时不应输出内存上限")
+	}
+	if _, ok := findMetric(families, "container_restarts_total", app...); ok {
+		t.Error("containerd 不应输出重启次数")
+	}
+	if _, ok := findMetric(families, "container_network_bytes_total", append(app[:4:4], "interface", "lo", "direction", "rx")...); ok {
+		t.Error("不应输出 lo 网卡")
+	}
+}
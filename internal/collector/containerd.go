@@ -0,0 +1,205 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	cgroup1stats "github.com/containerd/cgroups/v3/cgroup1/stats"
+	cgroup2stats "github.com/containerd/cgroups/v3/cgroup2/stats"
+	containersapi "github.com/containerd/containerd/api/services/containers/v1"
+	namespacesapi "github.com/containerd/containerd/api/services/namespaces/v1"
+	tasksapi "github.com/containerd/containerd/api/services/tasks/v1"
+	"github.com/containerd/containerd/api/types/task"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// containerdDefaultEndpoint containerd 的默认 socket
+const containerdDefaultEndpoint = "/run/containerd/containerd.sock"
+
+// containerdNameLabels 作为容器名称的标签，依次查找，都没有时使用容器 ID
+var containerdNameLabels = []string{"io.kubernetes.container.name", "nerdctl/name"}
+
+// containerdStateNames containerd 的任务状态到 containerStates 的映射，没有任务的容器为 created
+var containerdStateNames = map[task.Status]string{
+	task.Status_UNKNOWN: "unknown",
+	task.Status_CREATED: "created",
+	task.Status_RUNNING: "running",
+	task.Status_STOPPED: "exited",
+	task.Status_PAUSED:  "paused",
+	task.Status_PAUSING: "paused",
+}
+
+// containerd containerd gRPC API 客户端
+//
+// 资源用量来自 Tasks.Metrics 返回的 cgroup v1 或 v2 统计，
+// containerd 不提供网络计数，从 <proc>/<任务 pid>/net/dev 读取容器网络命名空间中的网卡
+type containerd struct {
+	containers containersapi.ContainersClient
+	tasks      tasksapi.TasksClient
+	namespaces namespacesapi.NamespacesClient
+	namespace  string // 为空时采集所有命名空间
+	procPath   string
+}
+
+// newContainerd 创建 containerd 客户端，endpoint 为 socket 路径，可带 unix:// 前缀
+func newContainerd(endpoint, namespace, procPath string) (*containerd, error) {
+	if endpoint == "" {
+		endpoint = containerdDefaultEndpoint
+	}
+	conn, err := grpc.NewClient("unix://"+strings.TrimPrefix(endpoint, "unix://"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create containerd client: %w", err)
+	}
+	return &containerd{
+		containers: containersapi.NewContainersClient(conn),
+		tasks:      tasksapi.NewTasksClient(conn),
+		namespaces: namespacesapi.NewNamespacesClient(conn),
+		namespace:  namespace,
+		procPath:   procPath,
+	}, nil
+}
+
+// list 实现 containerRuntime
+func (c *containerd) list(ctx context.Context, all bool) ([]*containerInfo, error) {
+	namespaces := []string{c.namespace}
+	if c.namespace == "" {
+		resp, err := c.namespaces.List(ctx, &namespacesapi.ListNamespacesRequest{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list containerd namespaces: %w", err)
+		}
+		namespaces = namespaces[:0]
+		for _, ns := range resp.Namespaces {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+
+	var containers []*containerInfo
+	for _, ns := range namespaces {
+		list, err := c.listNamespace(metadata.AppendToOutgoingContext(ctx, "containerd-namespace", ns), ns, all)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, list...)
+	}
+	return containers, nil
+}
+
+// listNamespace 列出一个命名空间中的容器，并从任务读取状态和资源用量
+func (c *containerd) listNamespace(ctx context.Context, ns string, all bool) ([]*containerInfo, error) {
+	containers, err := c.containers.List(ctx, &containersapi.ListContainersRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containerd containers in %s: %w", ns, err)
+	}
+	tasks, err := c.tasks.List(ctx, &tasksapi.ListTasksRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containerd tasks in %s: %w", ns, err)
+	}
+	processes := make(map[string]*task.Process, len(tasks.Tasks))
+	for _, t := range tasks.Tasks {
+		processes[t.ID] = t
+	}
+	// 不带过滤条件时返回命名空间中所有任务的统计
+	resp, err := c.tasks.Metrics(ctx, &tasksapi.MetricsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get containerd task metrics in %s: %w", ns, err)
+	}
+	stats := make(map[string]*anypb.Any, len(resp.Metrics))
+	for _, m := range resp.Metrics {
+		stats[m.ID] = m.Data
+	}
+
+	var out []*containerInfo
+	for _, ctr := range containers.Containers {
+		ct := &containerInfo{
+			id:        shortID(ctr.ID),
+			name:      ctr.ID,
+			image:     ctr.Image,
+			namespace: ns,
+			labels:    ctr.Labels,
+			state:     "created",
+		}
+		for _, label := range containerdNameLabels {
+			if name := ctr.Labels[label]; name != "" {
+				ct.name = name
+				break
+			}
+		}
+		p := processes[ctr.ID]
+		if p != nil {
+			ct.state = containerdStateNames[p.Status]
+		}
+		if !all && ct.state != "running" && ct.state != "paused" {
+			continue
+		}
+		if p != nil && (ct.state == "running" || ct.state == "paused") {
+			ct.stats = c.stats(stats[ctr.ID], p.Pid)
+		}
+		out = append(out, ct)
+	}
+	return out, nil
+}
+
+// stats 解析任务的 cgroup 统计，并读取网络命名空间中的网卡计数，统计格式无法识别时返回 nil
+func (c *containerd) stats(data *anypb.Any, pid uint32) *containerStats {
+	if data == nil {
+		return nil
+	}
+	msg, err := data.UnmarshalNew()
+	if err != nil {
+		return nil
+	}
+	s := &containerStats{}
+	switch m := msg.(type) {
+	case *cgroup2stats.Metrics:
+		cpu, memory := m.GetCPU(), m.GetMemory()
+		s.cpuUsage = float64(cpu.GetUsageUsec()) / 1e6
+		s.cpuUser = float64(cpu.GetUserUsec()) / 1e6
+		s.cpuSystem = float64(cpu.GetSystemUsec()) / 1e6
+		s.throttledPeriods = float64(cpu.GetNrThrottled())
+		s.throttledSeconds = float64(cpu.GetThrottledUsec()) / 1e6
+		s.memoryUsage = float64(memory.GetUsage())
+		s.memoryWorkingSet = workingSet(memory.GetUsage(), memory.GetInactiveFile())
+		s.memoryLimit = memoryLimit(memory.GetUsageLimit())
+		s.pids = float64(m.GetPids().GetCurrent())
+		for _, e := range m.GetIo().GetUsage() {
+			s.blkio = appendBlkio(s.blkio, e.Major, e.Minor, "read", true, e.Rbytes)
+			s.blkio = appendBlkio(s.blkio, e.Major, e.Minor, "write", true, e.Wbytes)
+			s.blkio = appendBlkio(s.blkio, e.Major, e.Minor, "read", false, e.Rios)
+			s.blkio = appendBlkio(s.blkio, e.Major, e.Minor, "write", false, e.Wios)
+		}
+	case *cgroup1stats.Metrics:
+		cpu, memory := m.GetCPU(), m.GetMemory()
+		s.cpuUsage = float64(cpu.GetUsage().GetTotal()) / 1e9
+		s.cpuUser = float64(cpu.GetUsage().GetUser()) / 1e9
+		s.cpuSystem = float64(cpu.GetUsage().GetKernel()) / 1e9
+		s.throttledPeriods = float64(cpu.GetThrottling().GetThrottledPeriods())
+		s.throttledSeconds = float64(cpu.GetThrottling().GetThrottledTime()) / 1e9
+		s.memoryUsage = float64(memory.GetUsage().GetUsage())
+		s.memoryWorkingSet = workingSet(memory.GetUsage().GetUsage(), memory.GetTotalInactiveFile())
+		s.memoryLimit = memoryLimit(memory.GetUsage().GetLimit())
+		s.pids = float64(m.GetPids().GetCurrent())
+		for _, e := range m.GetBlkio().GetIoServiceBytesRecursive() {
+			s.blkio = appendBlkio(s.blkio, e.Major, e.Minor, e.Op, true, e.Value)
+		}
+		for _, e := range m.GetBlkio().GetIoServicedRecursive() {
+			s.blkio = appendBlkio(s.blkio, e.Major, e.Minor, e.Op, false, e.Value)
+		}
+	default:
+		return nil
+	}
+
+	// 任务已退出或无权限读取时不输出网络计数
+	if networks, err := readNetDev(procFile(c.procPath, strconv.FormatUint(uint64(pid), 10), "net", "dev")); err == nil {
+		for _, n := range networks {
+			if n.device != "lo" {
+				s.networks = append(s.networks, n)
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// cpuModes /proc/stat 中 cpu 行前 8 列对应的模式，之后的 guest 和 guest_nice 已计入 user 和 nice，不再单独输出
+var cpuModes = []string{"user", "nice", "system", "idle", "iowait", "irq", "softirq", "steal"}
+
+// CPU 从 /proc/stat 采集 CPU 时间、上下文切换等，从 /proc/pressure/cpu 采集 PSI
+//
+// 利用率和 steal 占比为与上一次采集之间的平均值，首次采集只记录基线；
+// 多个导出器共享注册表时，间隔取决于最近一次采集的时间
+type CPU struct {
+	procPath string
+	cfg      config.CPUCollectorConfig
+
+	mu       sync.Mutex
+	previous map[string][]float64 // 各 CPU 上次采集的时间，单位秒
+}
+
+// NewCPU 创建 CPU 采集器
+func NewCPU(procPath string, cfg config.CPUCollectorConfig) *CPU {
+	return &CPU{procPath: procPath, cfg: cfg, previous: make(map[string][]float64)}
+}
+
+// Name 实现 metrics.Collector
+func (c *CPU) Name() string {
+	return "cpu"
+}
+
+// Collect 实现 metrics.Collector
+func (c *CPU) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	lines, err := readLines(procFile(c.procPath, "stat"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cpu stat: %w", err)
+	}
+
+	seconds := metrics.NewFamily("cpu_seconds_total", "CPU 在各模式下花费的时间", metrics.Counter)
+	utilization := metrics.NewFamily("cpu_utilization_ratio", "CPU 非空闲 (不含 iowait) 时间的占比", metrics.Gauge)
+	steal := metrics.NewFamily("cpu_steal_ratio", "虚拟机中 CPU 被宿主机挪用的时间占比", metrics.Gauge)
+	ctxt := metrics.NewFamily("context_switches_total", "上下文切换次数", metrics.Counter)
+	intr := metrics.NewFamily("interrupts_total", "中断次数", metrics.Counter)
+	forks := metrics.NewFamily("forks_total", "创建的进程和线程数", metrics.Counter)
+	running := metrics.NewFamily("procs_running", "处于可运行状态的线程数", metrics.Gauge)
+	blocked := metrics.NewFamily("procs_blocked", "等待 I/O 完成而阻塞的线程数", metrics.Gauge)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch key := fields[0]; {
+		case strings.HasPrefix(key, "cpu"):
+			// 按核心输出时跳过汇总行，否则只输出汇总行
+			id := strings.TrimPrefix(key, "cpu")
+			if (id != "") != c.cfg.PerCore {
+				continue
+			}
+			times, err := parseCPUTimes(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", key, err)
+			}
+			var labels []string
+			if id != "" {
+				labels = []string{"cpu", id}
+			}
+			for i, mode := range cpuModes {
+				seconds.Add(times[i], append(labels, "mode", mode)...)
+			}
+			if busy, stolen, ok := c.ratios(key, times); ok {
+				utilization.Add(busy, labels...)
+				steal.Add(stolen, labels...)
+			}
+		case key == "ctxt":
+			if err := addUint(ctxt, fields[1]); err != nil {
+				return nil, fmt.Errorf("failed to parse ctxt: %w", err)
+			}
+		case key == "intr":
+			if err := addUint(intr, fields[1]); err != nil {
+				return nil, fmt.Errorf("failed to parse intr: %w", err)
+			}
+		case key == "processes":
+			if err := addUint(forks, fields[1]); err != nil {
+				return nil, fmt.Errorf("failed to parse processes: %w", err)
+			}
+		case key == "procs_running":
+			if err := addUint(running, fields[1]); err != nil {
+				return nil, fmt.Errorf("failed to parse procs_running: %w", err)
+			}
+		case key == "procs_blocked":
+			if err := addUint(blocked, fields[1]); err != nil {
+				return nil, fmt.Errorf("failed to parse procs_blocked: %w", err)
+			}
+		}
+	}
+
+	families := []*metrics.Family{seconds, utilization, steal, ctxt, intr, forks, running, blocked}
+	if c.cfg.PSI {
+		pressure, err := collectPressure(c.procPath, "cpu", "线程等待 CPU")
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, pressure...)
+	}
+	return families, nil
+}
+
+// ratios 根据与上次采集的差值计算利用率和 steal 占比，调用方需持有锁
+// 没有基线或时间没有前进时返回 false，时间没有前进时保留原基线
+func (c *CPU) ratios(key string, times []float64) (busy, steal float64, ok bool) {
+	prev, found := c.previous[key]
+	if !found {
+		c.previous[key] = times
+		return 0, 0, false
+	}
+	var total float64
+	for i := range times {
+		total += times[i] - prev[i]
+	}
+	if total <= 0 {
+		return 0, 0, false
+	}
+	c.previous[key] = times
+	idle := times[3] - prev[3] + times[4] - prev[4] // idle + iowait
+	return 1 - idle/total, (times[7] - prev[7]) / total, true
+}
+
+// parseCPUTimes 解析 cpu 行中各模式的时间，转换为秒；旧内核缺少的列按 0 处理
+func parseCPUTimes(fields []string) ([]float64, error) {
+	times := make([]float64, len(cpuModes))
+	for i := range min(len(fields), len(cpuModes)) {
+		v, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		times[i] = float64(v) / userHZ
+	}
+	return times, nil
+}
@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// writeProc 在临时目录中写入 proc 文件，返回该目录
+func writeProc(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+	if dir == "" {
+		dir = t.TempDir()
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("写入 %s 失败: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// findMetric 按名称和标签查找样本
+func findMetric(families []*metrics.Family, name string, labels ...string) (float64, bool) {
+	for _, f := range families {
+		if f.Name != metrics.Namespace+"_"+name {
+			continue
+		}
+	next:
+		for _, m := range f.Metrics {
+			if len(m.Labels)*2 != len(labels) {
+				continue
+			}
+			for i, l := range m.Labels {
+				if l.Name != labels[2*i] || l.Value != labels[2*i+1] {
+					continue next
+				}
+			}
+			return m.Value, true
+		}
+	}
+	return 0, false
+}
+
+const testStat = `cpu  400 0 200 1200 100 0 0 100 0 0
+cpu0 200 0 100 600 50 0 0 50 0 0
+cpu1 200 0 100 600 50 0 0 50 0 0
+intr 12345 1 2 3
+ctxt 67890
+btime 1700000000
+processes 4321
+procs_running 3
+procs_blocked 1
+`
+
+const testPressure = `some avg10=1.50 avg60=0.80 avg300=0.20 total=2500000
+full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+`
+
+func TestCPUCollect(t *testing.T) {
+	dir := writeProc(t, "", map[string]string{"stat": testStat, "pressure/cpu": testPressure})
+	c := NewCPU(dir, config.DefaultConfig().Collector.CPU)
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if v, ok := findMetric(families, "cpu_seconds_total", "cpu", "1", "mode", "steal"); !ok || v != 0.5 {
+		t.Errorf("cpu1 steal 时间 = %v, %v, 期望 0.5", v, ok)
+	}
+	if _, ok := findMetric(families, "cpu_seconds_total", "mode", "user"); ok {
+		t.Error("按核心输出时不应包含汇总行")
+	}
+	if _, ok := findMetric(families, "cpu_utilization_ratio", "cpu", "0"); ok {
+		t.Error("首次采集不应输出利用率")
+	}
+	for name, want := range map[string]float64{"context_switches_total": 67890, "interrupts_total": 12345, "forks_total": 4321, "procs_running": 3, "procs_blocked": 1} {
+		if v, ok := findMetric(families, name); !ok || v != want {
+			t.Errorf("%s = %v, %v, 期望 %v", name, v, ok, want)
+		}
+	}
+	if v, ok := findMetric(families, "cpu_pressure_ratio", "kind", "some", "window", "10s"); !ok || v != 0.015 {
+		t.Errorf("PSI some avg10 = %v, %v, 期望 0.015", v, ok)
+	}
+	if v, ok := findMetric(families, "cpu_pressure_stalled_seconds_total", "kind", "some"); !ok || v != 2.5 {
+		t.Errorf("PSI some total = %v, %v, 期望 2.5", v, ok)
+	}
+
+	// cpu0 增加 user 60、idle 20、iowait 10、steal 10，共 100 个 tick
+	writeProc(t, dir, map[string]string{"stat": `cpu  460 0 200 1220 110 0 0 110 0 0
+cpu0 260 0 100 620 60 0 0 60 0 0
+cpu1 200 0 100 600 50 0 0 50 0 0
+`})
+	if families, err = c.Collect(context.Background()); err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if v, ok := findMetric(families, "cpu_utilization_ratio", "cpu", "0"); !ok || math.Abs(v-0.7) > 1e-9 {
+		t.Errorf("cpu0 利用率 = %v, %v, 期望 0.7", v, ok)
+	}
+	if v, ok := findMetric(families, "cpu_steal_ratio", "cpu", "0"); !ok || math.Abs(v-0.1) > 1e-9 {
+		t.Errorf("cpu0 steal 占比 = %v, %v, 期望 0.1", v, ok)
+	}
+	// cpu1 的时间没有前进，不输出占比
+	if _, ok := findMetric(families, "cpu_utilization_ratio", "cpu", "1"); ok {
+		t.Error("时间没有前进时不应输出利用率")
+	}
+}
+
+func TestCPUCollectSummary(t *testing.T) {
+	// 未启用 PSI 的内核没有 /proc/pressure
+	dir := writeProc(t, "", map[string]string{"stat": testStat})
+	c := NewCPU(dir, config.CPUCollectorConfig{Enabled: true, PSI: true})
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("缺少 PSI 文件时不应失败: %v", err)
+	}
+	if v, ok := findMetric(families, "cpu_seconds_total", "mode", "idle"); !ok || v != 12 {
+		t.Errorf("汇总 idle 时间 = %v, %v, 期望 12", v, ok)
+	}
+	if _, ok := findMetric(families, "cpu_seconds_total", "cpu", "0", "mode", "idle"); ok {
+		t.Error("关闭按核心输出时不应包含各核心")
+	}
+	if _, ok := findMetric(families, "cpu_pressure_ratio", "kind", "some", "window", "10s"); ok {
+		t.Error("缺少 PSI 文件时不应输出压力指标")
+	}
+
+	if _, err := NewCPU(t.TempDir(), config.CPUCollectorConfig{}).Collect(context.Background()); err == nil {
+		t.Error("缺少 /proc/stat 时应返回错误")
+	}
+}
@@ -0,0 +1,207 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// diskSectorSize /proc/diskstats 中扇区数的单位，与设备实际的扇区大小无关
+const diskSectorSize = 512
+
+// diskLatencyBuckets 延迟直方图的桶上界，单位秒，覆盖 NVMe 到机械盘的常见范围
+var diskLatencyBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// /proc/diskstats 中设备名之后各列的下标
+const (
+	diskReads          = 0  // 完成的读请求数
+	diskSectorsRead    = 2  // 读取的扇区数
+	diskReadTime       = 3  // 读请求耗时 (毫秒)
+	diskWrites         = 4  // 完成的写请求数
+	diskSectorsWritten = 6  // 写入的扇区数
+	diskWriteTime      = 7  // 写请求耗时 (毫秒)
+	diskInProgress     = 8  // 正在进行的请求数
+	diskIOTime         = 9  // 有请求在进行的时间 (毫秒)，即 io ticks
+	diskWeightedTime   = 10 // 各请求耗时之和 (毫秒)，可据此计算平均队列深度
+	diskFields         = 11
+)
+
+// diskOps 读写两个方向的请求数和耗时所在的列
+var diskOps = []struct {
+	op                   string
+	count, sectors, time int
+}{
+	{"read", diskReads, diskSectorsRead, diskReadTime},
+	{"write", diskWrites, diskSectorsWritten, diskWriteTime},
+}
+
+// Diskstats 从 /proc/diskstats 采集块设备的 I/O 统计
+//
+// 除累计值外，IOPS、吞吐量、平均队列深度和利用率为与上一次采集之间的平均值，首次采集只记录基线。
+// diskstats 只提供请求耗时之和，无法得到单个请求的延迟，因此延迟直方图是近似的：
+// 每个采集间隔内的请求都按该间隔的平均延迟计入对应的桶，采集间隔越短越接近真实分布
+type Diskstats struct {
+	procPath string
+	include  *regexp.Regexp // 为 nil 时不限制
+	exclude  *regexp.Regexp // 为 nil 时不排除
+	now      func() time.Time
+
+	mu       sync.Mutex
+	previous map[string][]float64               // 各设备上次采集的计数
+	lastTime time.Time                          // 上次采集的时间
+	latency  map[string]*metrics.HistogramValue // 各设备各方向的延迟直方图
+}
+
+// NewDiskstats 创建磁盘 I/O 采集器，设备过滤的正则表达式无效时返回错误
+func NewDiskstats(procPath string, cfg config.DiskstatsCollectorConfig) (*Diskstats, error) {
+	c := &Diskstats{
+		procPath: procPath,
+		now:      time.Now,
+		previous: make(map[string][]float64),
+		latency:  make(map[string]*metrics.HistogramValue),
+	}
+	var err error
+	if c.include, err = compilePattern("diskstats device_include", cfg.DeviceInclude); err != nil {
+		return nil, err
+	}
+	if c.exclude, err = compilePattern("diskstats device_exclude", cfg.DeviceExclude); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name 实现 metrics.Collector
+func (c *Diskstats) Name() string {
+	return "diskstats"
+}
+
+// Collect 实现 metrics.Collector
+func (c *Diskstats) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	path := procFile(c.procPath, "diskstats")
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read diskstats: %w", err)
+	}
+
+	ops := metrics.NewFamily("disk_ops_total", "完成的读写请求数", metrics.Counter)
+	transferred := metrics.NewFamily("disk_bytes_total", "读写的字节数", metrics.Counter)
+	opTime := metrics.NewFamily("disk_op_time_seconds_total", "读写请求的耗时之和", metrics.Counter)
+	ioTime := metrics.NewFamily("disk_io_time_seconds_total", "设备上有请求在进行的时间", metrics.Counter)
+	weighted := metrics.NewFamily("disk_io_time_weighted_seconds_total", "所有请求的耗时之和，包括排队时间", metrics.Counter)
+	inProgress := metrics.NewFamily("disk_io_now", "正在进行的请求数", metrics.Gauge)
+	iops := metrics.NewFamily("disk_iops", "每秒完成的读写请求数", metrics.Gauge)
+	throughput := metrics.NewFamily("disk_throughput_bytes_per_second", "每秒读写的字节数", metrics.Gauge)
+	queue := metrics.NewFamily("disk_queue_depth", "平均队列深度", metrics.Gauge)
+	utilization := metrics.NewFamily("disk_utilization_ratio", "设备上有请求在进行的时间占比", metrics.Gauge)
+	latency := metrics.NewFamily("disk_op_latency_seconds", "读写请求的延迟 (按采集间隔的平均延迟近似)", metrics.Histogram)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	elapsed := now.Sub(c.lastTime).Seconds()
+	c.lastTime = now
+	current := make(map[string][]float64)
+	var devices []string
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 3+diskFields {
+			continue
+		}
+		device := fields[2]
+		if !matchFilter(c.include, c.exclude, device) {
+			continue
+		}
+		values := make([]float64, diskFields)
+		for i := range values {
+			v, err := strconv.ParseUint(fields[3+i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s in %s: %w", device, path, err)
+			}
+			values[i] = float64(v)
+		}
+		current[device] = values
+		devices = append(devices, device)
+
+		for _, o := range diskOps {
+			ops.Add(values[o.count], "device", device, "op", o.op)
+			transferred.Add(values[o.sectors]*diskSectorSize, "device", device, "op", o.op)
+			opTime.Add(values[o.time]/1000, "device", device, "op", o.op)
+		}
+		ioTime.Add(values[diskIOTime]/1000, "device", device)
+		weighted.Add(values[diskWeightedTime]/1000, "device", device)
+		inProgress.Add(values[diskInProgress], "device", device)
+
+		prev, ok := c.previous[device]
+		if !ok || elapsed <= 0 || decreased(prev, values) {
+			continue
+		}
+		for _, o := range diskOps {
+			count := values[o.count] - prev[o.count]
+			iops.Add(count/elapsed, "device", device, "op", o.op)
+			throughput.Add((values[o.sectors]-prev[o.sectors])*diskSectorSize/elapsed, "device", device, "op", o.op)
+			c.observe(device, o.op, count, (values[o.time]-prev[o.time])/1000)
+		}
+		queue.Add((values[diskWeightedTime]-prev[diskWeightedTime])/1000/elapsed, "device", device)
+		utilization.Add(min((values[diskIOTime]-prev[diskIOTime])/1000/elapsed, 1), "device", device)
+	}
+	c.previous = current
+
+	for _, device := range devices {
+		for _, o := range diskOps {
+			if h, ok := c.latency[device+"\xff"+o.op]; ok {
+				// 桶在下次采集时会被修改，输出副本
+				snapshot := *h
+				snapshot.Buckets = slices.Clone(h.Buckets)
+				latency.AddHistogram(snapshot, "device", device, "op", o.op)
+			}
+		}
+	}
+	for key := range c.latency {
+		if device, _, _ := strings.Cut(key, "\xff"); current[device] == nil {
+			delete(c.latency, key) // 设备已移除
+		}
+	}
+	return []*metrics.Family{ops, transferred, opTime, ioTime, weighted, inProgress, iops, throughput, queue, utilization, latency}, nil
+}
+
+// observe 将一个采集间隔内的 count 个请求按平均延迟计入直方图，调用方需持有锁
+func (c *Diskstats) observe(device, op string, count, seconds float64) {
+	key := device + "\xff" + op
+	h, ok := c.latency[key]
+	if !ok {
+		h = &metrics.HistogramValue{Buckets: make([]metrics.Bucket, len(diskLatencyBuckets))}
+		for i, bound := range diskLatencyBuckets {
+			h.Buckets[i].UpperBound = bound
+		}
+		c.latency[key] = h
+	}
+	if count <= 0 {
+		return
+	}
+	avg := seconds / count
+	for i := range h.Buckets {
+		if avg <= h.Buckets[i].UpperBound {
+			h.Buckets[i].Count += count
+		}
+	}
+	h.Count += count
+	h.Sum += seconds
+}
+
+// decreased 判断计数是否变小 (设备被替换或计数回绕)，此时重新记录基线
+func decreased(prev, current []float64) bool {
+	for i := range current {
+		if i != diskInProgress && current[i] < prev[i] {
+			return true
+		}
+	}
+	return false
+}
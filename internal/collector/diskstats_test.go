@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+const testDiskstats = `   7       0 loop0 10 0 20 5 0 0 0 0 0 5 5 0 0 0 0 0 0
+   8       0 sda 1000 0 8000 2000 500 0 4000 1000 2 3000 3000 0 0 0 0
+   8       1 sda1 900 0 7000 1800 400 0 3000 900 0 2500 2700 0 0 0 0
+ 259       0 nvme0n1 100 0 800 10 50 0 400 5 0 20 15
+`
+
+func TestDiskstatsCollect(t *testing.T) {
+	dir := writeProc(t, "", map[string]string{"diskstats": testDiskstats})
+	now := time.Unix(1700000000, 0)
+	c, err := NewDiskstats(dir, config.DefaultConfig().Collector.Diskstats)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	c.now = func() time.Time { return now }
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if v, ok := findMetric(families, "disk_bytes_total", "device", "sda", "op", "write"); !ok || v != 4000*512 {
+		t.Errorf("sda 写入字节数 = %v, %v", v, ok)
+	}
+	if v, ok := findMetric(families, "disk_io_now", "device", "sda"); !ok || v != 2 {
+		t.Errorf("sda 队列中的请求数 = %v, %v", v, ok)
+	}
+	for _, device := range []string{"loop0", "sda1"} {
+		if _, ok := findMetric(families, "disk_ops_total", "device", device, "op", "read"); ok {
+			t.Errorf("%s 应被默认规则排除", device)
+		}
+	}
+	if _, ok := findMetric(families, "disk_iops", "device", "sda", "op", "read"); ok {
+		t.Error("首次采集不应输出 IOPS")
+	}
+
+	// 10 秒内 sda 完成 100 个读请求，耗时 500ms；io ticks 增加 5s，加权耗时增加 20s
+	writeProc(t, dir, map[string]string{"diskstats": `   8       0 sda 1100 0 8800 2500 500 0 4000 1000 1 8000 23000 0 0 0 0
+ 259       0 nvme0n1 100 0 800 10 50 0 400 5 0 20 15
+`})
+	now = now.Add(10 * time.Second)
+	if families, err = c.Collect(context.Background()); err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"disk_iops", []string{"device", "sda", "op", "read"}, 10},
+		{"disk_iops", []string{"device", "sda", "op", "write"}, 0},
+		{"disk_throughput_bytes_per_second", []string{"device", "sda", "op", "read"}, 800 * 512 / 10},
+		{"disk_queue_depth", []string{"device", "sda"}, 2},
+		{"disk_utilization_ratio", []string{"device", "sda"}, 0.5},
+	} {
+		if v, ok := findMetric(families, tt.name, tt.labels...); !ok || v != tt.want {
+			t.Errorf("%s%v = %v, %v, 期望 %v", tt.name, tt.labels, v, ok, tt.want)
+		}
+	}
+
+	// 平均延迟 5ms，100 个请求都计入上界 >= 0.005 的桶
+	h := findHistogram(families, "disk_op_latency_seconds", "device", "sda", "op", "read")
+	if h == nil {
+		t.Fatal("缺少 sda 读延迟直方图")
+	}
+	if h.Count != 100 || h.Sum != 0.5 {
+		t.Errorf("直方图计数 = %v, 总和 = %v, 期望 100, 0.5", h.Count, h.Sum)
+	}
+	for _, b := range h.Buckets {
+		want := 0.0
+		if b.UpperBound >= 0.005 {
+			want = 100
+		}
+		if b.Count != want {
+			t.Errorf("桶 le=%v 计数 = %v, 期望 %v", b.UpperBound, b.Count, want)
+		}
+	}
+}
+
+func TestDiskstatsFilter(t *testing.T) {
+	dir := writeProc(t, "", map[string]string{"diskstats": testDiskstats})
+	c, err := NewDiskstats(dir, config.DiskstatsCollectorConfig{Enabled: true, DeviceInclude: "^sd", DeviceExclude: "1$"})
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	for device, want := range map[string]bool{"sda": true, "sda1": false, "nvme0n1": false, "loop0": false} {
+		if _, ok := findMetric(families, "disk_ops_total", "device", device, "op", "read"); ok != want {
+			t.Errorf("设备 %s 是否采集 = %v, 期望 %v", device, ok, want)
+		}
+	}
+
+	if _, err := NewDiskstats(dir, config.DiskstatsCollectorConfig{DeviceExclude: "("}); err == nil {
+		t.Error("无效的正则表达式应返回错误")
+	}
+}
+
+// findHistogram 按名称和标签查找直方图
+func findHistogram(families []*metrics.Family, name string, labels ...string) *metrics.HistogramValue {
+	for _, f := range families {
+		if f.Name != metrics.Namespace+"_"+name {
+			continue
+		}
+	next:
+		for _, m := range f.Metrics {
+			if len(m.Labels)*2 != len(labels) {
+				continue
+			}
+			for i, l := range m.Labels {
+				if l.Name != labels[2*i] || l.Value != labels[2*i+1] {
+					continue next
+				}
+			}
+			return m.Histogram
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// dockerDefaultEndpoint docker 的默认 API 地址
+const dockerDefaultEndpoint = "unix:///var/run/docker.sock"
+
+// dockerConcurrency 同时查询容器详情和资源用量的最大请求数
+const dockerConcurrency = 8
+
+// dockerStateNames docker 的容器状态 (State) 到 containerStates 的映射，未列出的为 unknown
+var dockerStateNames = map[string]string{
+	"created":    "created",
+	"running":    "running",
+	"paused":     "paused",
+	"restarting": "restarting",
+	"removing":   "removing",
+	"exited":     "exited",
+	"dead":       "dead",
+}
+
+// dockerContainer GET /containers/json 返回的容器
+type dockerContainer struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	Labels map[string]string `json:"Labels"`
+	State  string            `json:"State"`
+}
+
+// dockerInspect GET /containers/{id}/json 中用到的字段
+type dockerInspect struct {
+	RestartCount int `json:"RestartCount"`
+	HostConfig   struct {
+		Memory int64 `json:"Memory"` // 为 0 时未限制
+	} `json:"HostConfig"`
+}
+
+// dockerStats GET /containers/{id}/stats 中用到的字段，CPU 时间单位为纳秒。
+// cgroup v1 和 v2 下 memory_stats.stats 的字段不同，blkio 的 op 大小写也不同
+type dockerStats struct {
+	CPUStats struct {
+		CPUUsage struct {
+			TotalUsage        uint64 `json:"total_usage"`
+			UsageInKernelmode uint64 `json:"usage_in_kernelmode"`
+			UsageInUsermode   uint64 `json:"usage_in_usermode"`
+		} `json:"cpu_usage"`
+		ThrottlingData struct {
+			Periods          uint64 `json:"periods"`
+			ThrottledPeriods uint64 `json:"throttled_periods"`
+			ThrottledTime    uint64 `json:"throttled_time"`
+		} `json:"throttling_data"`
+	} `json:"cpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	PidsStats struct {
+		Current uint64 `json:"current"`
+	} `json:"pids_stats"`
+	Networks   map[string]dockerNetwork `json:"networks"`
+	BlkioStats struct {
+		IoServiceBytesRecursive []dockerBlkio `json:"io_service_bytes_recursive"`
+		IoServicedRecursive     []dockerBlkio `json:"io_serviced_recursive"`
+	} `json:"blkio_stats"`
+}
+
+// dockerNetwork 容器一个网卡的收发计数
+type dockerNetwork struct {
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	TxErrors  uint64 `json:"tx_errors"`
+	TxDropped uint64 `json:"tx_dropped"`
+}
+
+// dockerBlkio 块设备读写计数，op 为 Read、Write、Discard、Sync、Async、Total (cgroup v1) 或 read、write (cgroup v2)
+type dockerBlkio struct {
+	Major uint64 `json:"major"`
+	Minor uint64 `json:"minor"`
+	Op    string `json:"op"`
+	Value uint64 `json:"value"`
+}
+
+// docker Docker Engine API 客户端，也可用于 Podman 等兼容 Docker API 的运行时
+type docker struct {
+	client *resty.Client
+}
+
+// newDocker 创建 Docker Engine API 客户端，endpoint 为 unix://、tcp://、http:// 或 https:// 地址
+func newDocker(endpoint string) (*docker, error) {
+	if endpoint == "" {
+		endpoint = dockerDefaultEndpoint
+	}
+	client := resty.New().
+		SetHeader("Accept", "application/json").
+		SetDisableWarn(true)
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse docker endpoint: %w", err)
+	}
+	switch u.Scheme {
+	case "unix":
+		path := u.Path
+		client.SetTransport(&http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		})
+		client.SetBaseURL("http://docker")
+	case "tcp":
+		client.SetBaseURL("http://" + u.Host)
+	case "http", "https":
+		client.SetBaseURL(strings.TrimSuffix(endpoint, "/"))
+	default:
+		return nil, fmt.Errorf("unsupported docker endpoint %q (expected unix://, tcp://, http:// or https://)", endpoint)
+	}
+	return &docker{client: client}, nil
+}
+
+// list 实现 containerRuntime，并发查询各容器的详情和资源用量，
+// 查询期间被删除的容器只输出列表中的信息
+func (d *docker) list(ctx context.Context, all bool) ([]*containerInfo, error) {
+	var list []dockerContainer
+	if err := d.get(ctx, "/containers/json?all="+strconv.FormatBool(all), &list); err != nil {
+		return nil, err
+	}
+
+	containers := make([]*containerInfo, len(list))
+	sem := make(chan struct{}, dockerConcurrency)
+	var wg sync.WaitGroup
+	for i, c := range list {
+		ct := &containerInfo{
+			id:     shortID(c.ID),
+			image:  c.Image,
+			labels: c.Labels,
+			state:  dockerStateNames[c.State],
+		}
+		if len(c.Names) > 0 {
+			ct.name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		if ct.state == "" {
+			ct.state = "unknown"
+		}
+		containers[i] = ct
+
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			d.inspect(ctx, c.ID, ct)
+		})
+	}
+	wg.Wait()
+	return containers, ctx.Err()
+}
+
+// inspect 查询容器的重启次数、内存上限，容器运行中时查询资源用量
+func (d *docker) inspect(ctx context.Context, id string, ct *containerInfo) {
+	var inspect dockerInspect
+	if err := d.get(ctx, "/containers/"+id+"/json", &inspect); err != nil {
+		return
+	}
+	ct.restarts, ct.hasRestarts = float64(inspect.RestartCount), true
+	if ct.state != "running" && ct.state != "paused" {
+		return
+	}
+
+	// one-shot 不等待第二次采样，只返回一次读数
+	var stats dockerStats
+	if err := d.get(ctx, "/containers/"+id+"/stats?stream=false&one-shot=true", &stats); err != nil {
+		return
+	}
+	cpu := stats.CPUStats
+	s := &containerStats{
+		cpuUsage:         float64(cpu.CPUUsage.TotalUsage) / 1e9,
+		cpuUser:          float64(cpu.CPUUsage.UsageInUsermode) / 1e9,
+		cpuSystem:        float64(cpu.CPUUsage.UsageInKernelmode) / 1e9,
+		throttledPeriods: float64(cpu.ThrottlingData.ThrottledPeriods),
+		throttledSeconds: float64(cpu.ThrottlingData.ThrottledTime) / 1e9,
+		memoryUsage:      float64(stats.MemoryStats.Usage),
+		memoryLimit:      float64(inspect.HostConfig.Memory),
+		pids:             float64(stats.PidsStats.Current),
+	}
+	// cgroup v2 为 inactive_file，v1 为 total_inactive_file
+	inactive, ok := stats.MemoryStats.Stats["inactive_file"]
+	if !ok {
+		inactive = stats.MemoryStats.Stats["total_inactive_file"]
+	}
+	s.memoryWorkingSet = workingSet(stats.MemoryStats.Usage, inactive)
+
+	for _, name := range slices.Sorted(maps.Keys(stats.Networks)) {
+		n := stats.Networks[name]
+		values := make([]float64, netFields*netDirections)
+		for d, v := range [netDirections][4]uint64{
+			{n.RxBytes, n.RxPackets, n.RxErrors, n.RxDropped},
+			{n.TxBytes, n.TxPackets, n.TxErrors, n.TxDropped},
+		} {
+			values[d*netFields+netBytes] = float64(v[0])
+			values[d*netFields+netPackets] = float64(v[1])
+			values[d*netFields+netErrors] = float64(v[2])
+			values[d*netFields+netDrops] = float64(v[3])
+		}
+		s.networks = append(s.networks, netDevStats{name, values})
+	}
+	for _, e := range stats.BlkioStats.IoServiceBytesRecursive {
+		s.blkio = appendBlkio(s.blkio, e.Major, e.Minor, e.Op, true, e.Value)
+	}
+	// cgroup v2 下 docker 不填写 io_serviced_recursive，只有字节数
+	for _, e := range stats.BlkioStats.IoServicedRecursive {
+		s.blkio = appendBlkio(s.blkio, e.Major, e.Minor, e.Op, false, e.Value)
+	}
+	ct.stats = s
+}
+
+// get 请求 Docker Engine API 并解析 JSON 响应
+func (d *docker) get(ctx context.Context, path string, v any) error {
+	resp, err := d.client.R().SetContext(ctx).Get(path)
+	if err != nil {
+		return fmt.Errorf("docker api request failed: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("docker api %s: %s: %s", path, resp.Status(), strings.TrimSpace(resp.String()))
+	}
+	if err := json.Unmarshal(resp.Body(), v); err != nil {
+		return fmt.Errorf("failed to decode docker api response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// ethtool ioctl 的命令和常量，见 linux/ethtool.h 和 linux/sockios.h
+const (
+	siocEthtool      = 0x8946
+	ethtoolGStrings  = 0x1b
+	ethtoolGStats    = 0x1d
+	ethtoolGSSetInfo = 0x37
+	ethSSStats       = 1  // 统计项名称所在的字符串集
+	ethGStringLen    = 32 // 每个名称占用的字节数
+)
+
+// ifreq 对应 struct ifreq，ifr_data 指向 ethtool 命令的缓冲区
+type ifreq struct {
+	name [syscall.IFNAMSIZ]byte
+	data unsafe.Pointer
+	_    [16]byte // 补齐 union 的大小
+}
+
+// ethtoolStats 通过 ETHTOOL_GSTATS 读取网卡驱动提供的统计项，返回名称到值的映射。
+// 驱动不支持 (如 lo 和大多数虚拟网卡) 时返回 nil
+func ethtoolStats(device string) (map[string]uint64, error) {
+	if len(device) >= syscall.IFNAMSIZ {
+		return nil, fmt.Errorf("interface name %q too long", device)
+	}
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ethtool socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	// struct ethtool_sset_info { cmd; reserved; sset_mask; data[] }
+	info := make([]byte, 20)
+	binary.NativeEndian.PutUint32(info[0:], ethtoolGSSetInfo)
+	binary.NativeEndian.PutUint64(info[8:], 1<<ethSSStats)
+	if err := ethtoolIoctl(fd, device, info); err != nil {
+		if errors.Is(err, syscall.EOPNOTSUPP) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get ethtool stats count of %s: %w", device, err)
+	}
+	if binary.NativeEndian.Uint64(info[8:])&(1<<ethSSStats) == 0 {
+		return nil, nil
+	}
+	n := int(binary.NativeEndian.Uint32(info[16:]))
+	if n == 0 {
+		return nil, nil
+	}
+
+	// struct ethtool_gstrings { cmd; string_set; len; data[] }
+	names := make([]byte, 12+n*ethGStringLen)
+	binary.NativeEndian.PutUint32(names[0:], ethtoolGStrings)
+	binary.NativeEndian.PutUint32(names[4:], ethSSStats)
+	binary.NativeEndian.PutUint32(names[8:], uint32(n))
+	if err := ethtoolIoctl(fd, device, names); err != nil {
+		return nil, fmt.Errorf("failed to get ethtool stats names of %s: %w", device, err)
+	}
+
+	// struct ethtool_stats { cmd; n_stats; data[] }
+	values := make([]byte, 8+n*8)
+	binary.NativeEndian.PutUint32(values[0:], ethtoolGStats)
+	binary.NativeEndian.PutUint32(values[4:], uint32(n))
+	if err := ethtoolIoctl(fd, device, values); err != nil {
+		return nil, fmt.Errorf("failed to get ethtool stats of %s: %w", device, err)
+	}
+
+	stats := make(map[string]uint64, n)
+	for i := range n {
+		name := names[12+i*ethGStringLen : 12+(i+1)*ethGStringLen]
+		if end := strings.IndexByte(string(name), 0); end >= 0 {
+			name = name[:end]
+		}
+		stats[string(name)] = binary.NativeEndian.Uint64(values[8+i*8:])
+	}
+	return stats, nil
+}
+
+// ethtoolIoctl 对网卡执行 SIOCETHTOOL，data 为以命令号开头的 ethtool 结构体
+func ethtoolIoctl(fd int, device string, data []byte) error {
+	var ifr ifreq
+	copy(ifr.name[:], device)
+	ifr.data = unsafe.Pointer(&data[0])
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), siocEthtool, uintptr(unsafe.Pointer(&ifr)))
+	runtime.KeepAlive(data)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
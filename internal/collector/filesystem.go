@@ -0,0 +1,250 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// mount /proc/mounts 中的一个挂载点
+type mount struct {
+	device, mountPoint, fsType string
+	readOnly                   bool
+}
+
+// fsStats statfs 返回的容量和 inode 数
+type fsStats struct {
+	size, free, avail  float64 // 字节
+	inodes, inodesFree float64
+}
+
+// fsResult 一次 statfs 调用的结果
+type fsResult struct {
+	stats fsStats
+	err   error
+}
+
+// Filesystem 对 /proc/1/mounts 中的挂载点调用 statfs，采集容量和 inode 用量
+//
+// 同一挂载点被多次挂载时只保留最后一次 (即实际可见的那次)。
+// 各挂载点的 statfs 并发执行，整体受 statfs_timeout 限制：
+// 超时的挂载点 (如失联的 NFS) 输出 filesystem_device_error 为 1，并在调用返回前不再对其调用 statfs，
+// 避免挂起的 goroutine 越积越多
+type Filesystem struct {
+	procPath                     string
+	rootfsPath                   string
+	fsTypeInclude, fsTypeExclude *regexp.Regexp
+	mountInclude, mountExclude   *regexp.Regexp
+	timeout                      time.Duration
+	statfs                       func(path string) (fsStats, error) // 测试时替换
+
+	mu    sync.Mutex
+	stuck map[string]struct{} // statfs 尚未返回的挂载点
+}
+
+// NewFilesystem 创建文件系统采集器，过滤用的正则表达式无效时返回错误
+//
+// rootfsPath 为宿主机根文件系统的挂载目录，挂载点路径相对于它解析
+func NewFilesystem(procPath, rootfsPath string, cfg config.FilesystemCollectorConfig) (*Filesystem, error) {
+	c := &Filesystem{
+		procPath:   procPath,
+		rootfsPath: rootfsPath,
+		timeout:    cfg.StatfsTimeout,
+		statfs:     statfs,
+		stuck:      make(map[string]struct{}),
+	}
+	var err error
+	if c.fsTypeInclude, err = compilePattern("filesystem fs_type_include", cfg.FSTypeInclude); err != nil {
+		return nil, err
+	}
+	if c.fsTypeExclude, err = compilePattern("filesystem fs_type_exclude", cfg.FSTypeExclude); err != nil {
+		return nil, err
+	}
+	if c.mountInclude, err = compilePattern("filesystem mount_point_include", cfg.MountPointInclude); err != nil {
+		return nil, err
+	}
+	if c.mountExclude, err = compilePattern("filesystem mount_point_exclude", cfg.MountPointExclude); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name 实现 metrics.Collector
+func (c *Filesystem) Name() string {
+	return "filesystem"
+}
+
+// Collect 实现 metrics.Collector
+func (c *Filesystem) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	mounts, err := c.mounts()
+	if err != nil {
+		return nil, err
+	}
+
+	size := metrics.NewFamily("filesystem_size_bytes", "文件系统的总容量", metrics.Gauge)
+	used := metrics.NewFamily("filesystem_used_bytes", "文件系统已使用的容量", metrics.Gauge)
+	free := metrics.NewFamily("filesystem_free_bytes", "文件系统的空闲容量，包括为 root 保留的部分", metrics.Gauge)
+	avail := metrics.NewFamily("filesystem_avail_bytes", "非 root 用户可用的容量", metrics.Gauge)
+	inodes := metrics.NewFamily("filesystem_inodes", "文件系统的 inode 总数", metrics.Gauge)
+	inodesFree := metrics.NewFamily("filesystem_inodes_free", "文件系统的空闲 inode 数", metrics.Gauge)
+	readOnly := metrics.NewFamily("filesystem_readonly", "文件系统是否以只读方式挂载", metrics.Gauge)
+	deviceError := metrics.NewFamily("filesystem_device_error", "statfs 是否失败或超时，为 1 时不输出该挂载点的容量指标", metrics.Gauge)
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	results := make([]chan fsResult, len(mounts))
+	c.mu.Lock()
+	for i, m := range mounts {
+		if _, ok := c.stuck[m.mountPoint]; ok {
+			continue
+		}
+		results[i] = make(chan fsResult, 1)
+		go func(ch chan<- fsResult) {
+			stats, err := c.statfs(filepath.Join(c.rootfsPath, m.mountPoint))
+			ch <- fsResult{stats, err}
+			c.mu.Lock()
+			delete(c.stuck, m.mountPoint)
+			c.mu.Unlock()
+		}(results[i])
+	}
+	c.mu.Unlock()
+
+	for i, m := range mounts {
+		labels := []string{"device", m.device, "mountpoint", m.mountPoint, "fstype", m.fsType}
+		readOnly.Add(boolValue(m.readOnly), labels...)
+
+		r, ok := c.wait(ctx, m, results[i])
+		if !ok || r.err != nil {
+			if r.err != nil {
+				slog.Debug("Failed to statfs", "mountpoint", m.mountPoint, "error", r.err)
+			}
+			deviceError.Add(1, labels...)
+			continue
+		}
+		deviceError.Add(0, labels...)
+		size.Add(r.stats.size, labels...)
+		used.Add(r.stats.size-r.stats.free, labels...)
+		free.Add(r.stats.free, labels...)
+		avail.Add(r.stats.avail, labels...)
+		inodes.Add(r.stats.inodes, labels...)
+		inodesFree.Add(r.stats.inodesFree, labels...)
+	}
+	return []*metrics.Family{size, used, free, avail, inodes, inodesFree, readOnly, deviceError}, nil
+}
+
+// wait 等待挂载点的 statfs 结果，ch 为 nil 表示上次调用仍未返回。
+// 超时后将挂载点标记为挂起，由仍在运行的 goroutine 在返回时清除标记
+func (c *Filesystem) wait(ctx context.Context, m mount, ch chan fsResult) (fsResult, bool) {
+	if ch == nil {
+		return fsResult{}, false
+	}
+	select {
+	case r := <-ch:
+		return r, true
+	case <-ctx.Done():
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 持有锁时再检查一次，避免 goroutine 在超时之后、加锁之前返回而永远留下标记
+	select {
+	case r := <-ch:
+		return r, true
+	default:
+	}
+	c.stuck[m.mountPoint] = struct{}{}
+	slog.Warn("Filesystem statfs timed out, skipping until it returns", "mountpoint", m.mountPoint, "timeout", c.timeout)
+	return fsResult{}, false
+}
+
+// mounts 读取并过滤挂载点。优先读取 1 号进程的挂载表，使容器中挂载宿主机 /proc 时看到宿主机的挂载点
+func (c *Filesystem) mounts() ([]mount, error) {
+	lines, err := readLines(procFile(c.procPath, "1", "mounts"))
+	if errors.Is(err, fs.ErrNotExist) {
+		lines, err = readLines(procFile(c.procPath, "mounts"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mounts: %w", err)
+	}
+
+	var mounts []mount
+	for _, line := range lines {
+		// 设备 挂载点 类型 选项 dump pass
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		m := mount{
+			device:     unescapeMount(fields[0]),
+			mountPoint: unescapeMount(fields[1]),
+			fsType:     fields[2],
+			readOnly:   slices.Contains(strings.Split(fields[3], ","), "ro"),
+		}
+		if !matchFilter(c.fsTypeInclude, c.fsTypeExclude, m.fsType) || !matchFilter(c.mountInclude, c.mountExclude, m.mountPoint) {
+			continue
+		}
+		if i := slices.IndexFunc(mounts, func(o mount) bool { return o.mountPoint == m.mountPoint }); i >= 0 {
+			mounts = slices.Delete(mounts, i, i+1)
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
+
+// unescapeMount 还原挂载表中转义为 \ooo 八进制的空格、制表符、换行和反斜杠
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1]) && isOctal(s[i+2]) && isOctal(s[i+3]) {
+			sb.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// isOctal 判断是否为八进制数字
+func isOctal(b byte) bool {
+	return b >= '0' && b <= '7'
+}
+
+// statfs 调用 statfs(2)
+func statfs(path string) (fsStats, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return fsStats{}, err
+	}
+	bsize := float64(st.Bsize)
+	return fsStats{
+		size:       float64(st.Blocks) * bsize,
+		free:       float64(st.Bfree) * bsize,
+		avail:      float64(st.Bavail) * bsize,
+		inodes:     float64(st.Files),
+		inodesFree: float64(st.Ffree),
+	}, nil
+}
+
+// boolValue 将布尔值转换为 0 或 1
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+const testMounts = `/dev/sda1 / ext4 rw,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+tmpfs /run tmpfs rw,nosuid,nodev 0 0
+/dev/sdb1 /mnt/backup\040disk ext4 ro,relatime 0 0
+/dev/sdc1 /data xfs rw,relatime 0 0
+/dev/sdd1 /data xfs rw,relatime 0 0
+nas:/export /mnt/nfs nfs4 rw,relatime 0 0
+`
+
+func TestUnescapeMount(t *testing.T) {
+	tests := map[string]string{
+		`/mnt/backup\040disk`: "/mnt/backup disk",
+		`/a\011b\134c`:        "/a\tb\\c",
+		`/plain`:              "/plain",
+		`/bad\04`:             `/bad\04`,
+	}
+	for in, want := range tests {
+		if got := unescapeMount(in); got != want {
+			t.Errorf("unescapeMount(%q) = %q, 期望 %q", in, got, want)
+		}
+	}
+}
+
+func TestFilesystemCollect(t *testing.T) {
+	dir := writeProc(t, "", map[string]string{"1/mounts": testMounts})
+	cfg := config.DefaultConfig().Collector.Filesystem
+	cfg.StatfsTimeout = 50 * time.Millisecond
+	c, err := NewFilesystem(dir, "/host", cfg)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+
+	// /mnt/nfs 的 statfs 挂起直到 release 关闭
+	release := make(chan struct{})
+	var nfsCalls atomic.Int32
+	c.statfs = func(path string) (fsStats, error) {
+		switch path {
+		case "/host/mnt/nfs":
+			nfsCalls.Add(1)
+			<-release
+		case "/host/run":
+			return fsStats{}, errors.New("permission denied")
+		}
+		return fsStats{size: 1000, free: 300, avail: 200, inodes: 100, inodesFree: 40}, nil
+	}
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	root := []string{"device", "/dev/sda1", "mountpoint", "/", "fstype", "ext4"}
+	for name, want := range map[string]float64{
+		"filesystem_size_bytes":   1000,
+		"filesystem_used_bytes":   700,
+		"filesystem_avail_bytes":  200,
+		"filesystem_inodes_free":  40,
+		"filesystem_readonly":     0,
+		"filesystem_device_error": 0,
+	} {
+		if v, ok := findMetric(families, name, root...); !ok || v != want {
+			t.Errorf("根目录 %s = %v, %v, 期望 %v", name, v, ok, want)
+		}
+	}
+	if v, ok := findMetric(families, "filesystem_readonly", "device", "/dev/sdb1", "mountpoint", "/mnt/backup disk", "fstype", "ext4"); !ok || v != 1 {
+		t.Errorf("只读挂载点 = %v, %v, 期望 1", v, ok)
+	}
+	if _, ok := findMetric(families, "filesystem_size_bytes", "device", "proc", "mountpoint", "/proc", "fstype", "proc"); ok {
+		t.Error("/proc 应被默认规则排除")
+	}
+	// 同一挂载点只保留最后一次挂载
+	if _, ok := findMetric(families, "filesystem_size_bytes", "device", "/dev/sdc1", "mountpoint", "/data", "fstype", "xfs"); ok {
+		t.Error("被覆盖的挂载不应输出")
+	}
+	if _, ok := findMetric(families, "filesystem_size_bytes", "device", "/dev/sdd1", "mountpoint", "/data", "fstype", "xfs"); !ok {
+		t.Error("缺少 /data 最后一次挂载的指标")
+	}
+	run := []string{"device", "tmpfs", "mountpoint", "/run", "fstype", "tmpfs"}
+	if v, ok := findMetric(families, "filesystem_device_error", run...); !ok || v != 1 {
+		t.Errorf("statfs 失败时 device_error = %v, %v, 期望 1", v, ok)
+	}
+	nfs := []string{"device", "nas:/export", "mountpoint", "/mnt/nfs", "fstype", "nfs4"}
+	if v, ok := findMetric(families, "filesystem_device_error", nfs...); !ok || v != 1 {
+		t.Errorf("statfs 超时时 device_error = %v, %v, 期望 1", v, ok)
+	}
+	if _, ok := findMetric(families, "filesystem_size_bytes", nfs...); ok {
+		t.Error("statfs 超时时不应输出容量")
+	}
+
+	// 挂起的调用返回前不再调用 statfs，也不等待超时
+	start := time.Now()
+	if families, err = c.Collect(context.Background()); err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= cfg.StatfsTimeout {
+		t.Errorf("跳过挂起的挂载点时不应等待超时，耗时 %v", elapsed)
+	}
+	if n := nfsCalls.Load(); n != 1 {
+		t.Errorf("挂起期间 statfs 调用次数 = %d, 期望 1", n)
+	}
+	if v, ok := findMetric(families, "filesystem_device_error", nfs...); !ok || v != 1 {
+		t.Errorf("挂起期间 device_error = %v, %v, 期望 1", v, ok)
+	}
+
+	// 调用返回后恢复采集
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.mu.Lock()
+		_, stuck := c.stuck["/mnt/nfs"]
+		c.mu.Unlock()
+		if !stuck {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("statfs 返回后未清除挂起标记")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if families, err = c.Collect(context.Background()); err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if v, ok := findMetric(families, "filesystem_size_bytes", nfs...); !ok || v != 1000 {
+		t.Errorf("恢复后 /mnt/nfs 容量 = %v, %v, 期望 1000", v, ok)
+	}
+}
+
+func TestFilesystemFilter(t *testing.T) {
+	dir := writeProc(t, "", map[string]string{"mounts": testMounts})
+	c, err := NewFilesystem(dir, "/", config.FilesystemCollectorConfig{
+		Enabled:           true,
+		FSTypeInclude:     "^(ext4|xfs)$",
+		MountPointExclude: "^/mnt/",
+		StatfsTimeout:     time.Second,
+	})
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	c.statfs = func(string) (fsStats, error) { return fsStats{size: 1}, nil }
+
+	// 没有 1/mounts 时回退到 /proc/mounts
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	var mountPoints []string
+	for _, f := range families {
+		if f.Name != metrics.Namespace+"_filesystem_size_bytes" {
+			continue
+		}
+		for _, m := range f.Metrics {
+			for _, l := range m.Labels {
+				if l.Name == "mountpoint" {
+					mountPoints = append(mountPoints, l.Value)
+				}
+			}
+		}
+	}
+	if len(mountPoints) != 2 || mountPoints[0] != "/" || mountPoints[1] != "/data" {
+		t.Errorf("采集的挂载点 = %v, 期望 [/ /data]", mountPoints)
+	}
+
+	if _, err := NewFilesystem(dir, "/", config.FilesystemCollectorConfig{FSTypeExclude: "("}); err == nil {
+		t.Error("无效的正则表达式应返回错误")
+	}
+}
@@ -0,0 +1,181 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// nvmlLibrary 已初始化的 NVML，实现见 gpu_nvml.go (需要 nvml 构建标签)
+type nvmlLibrary interface {
+	// driverVersion 返回驱动版本，读取失败时返回空字符串
+	driverVersion() string
+	// devices 按序号返回所有 GPU 的读数，无法访问的 GPU (如已从总线上掉线) 跳过
+	devices() ([]*gpuDevice, error)
+}
+
+// gpuDevice 一块 GPU 的读数，设备不支持的读数为 NaN
+type gpuDevice struct {
+	index             int
+	uuid              string
+	name              string
+	utilization       float64 // 0-1
+	memoryUtilization float64 // 显存读写繁忙的时间占比，0-1
+	memoryTotal       float64
+	memoryUsed        float64
+	temperature       float64 // 摄氏度
+	power             float64 // 瓦
+	powerLimit        float64 // 瓦
+	smClock           float64 // 赫兹
+	memoryClock       float64 // 赫兹
+	processes         []gpuProcess
+}
+
+// gpuProcess 使用 GPU 的进程，计算和图形进程都包括在内
+type gpuProcess struct {
+	pid        int
+	memoryUsed float64
+	hasMemory  bool // 部分环境 (如开启 MIG 时) 不提供进程的显存用量
+}
+
+// GPU 通过 NVML 采集 NVIDIA GPU 的利用率、显存、温度、功耗、时钟和各进程的显存用量
+//
+// NVML 在首次采集时加载，动态库不存在、驱动未加载或构建时未启用 nvml 标签时只输出 gpu_nvml_up 为 0，
+// 之后每次采集重试，不返回错误，因此可以在有无 GPU 的主机上使用同一份配置。
+// 每块 GPU 带 gpu (序号) 和 uuid 两个标签，型号和驱动版本在 gpu_info 中
+type GPU struct {
+	procPath  string
+	library   string
+	devices   []string // 为空时采集所有 GPU
+	processes bool
+	load      func(path string) (nvmlLibrary, error)
+
+	mu     sync.Mutex
+	nvml   nvmlLibrary // 为 nil 时尚未加载成功
+	warned bool        // 已输出过 NVML 不可用的警告
+}
+
+// NewGPU 创建 NVIDIA GPU 采集器，创建时不加载 NVML
+func NewGPU(procPath string, cfg config.GPUCollectorConfig) *GPU {
+	return &GPU{
+		procPath:  procPath,
+		library:   cfg.Library,
+		devices:   cfg.Devices,
+		processes: cfg.Processes,
+		load:      loadNVML,
+	}
+}
+
+// Name 实现 metrics.Collector
+func (c *GPU) Name() string {
+	return "gpu"
+}
+
+// Collect 实现 metrics.Collector
+func (c *GPU) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	up := metrics.NewFamily("gpu_nvml_up", "NVML 是否可用，不可用时不输出其他 GPU 指标", metrics.Gauge)
+	nvml := c.nvmlLibrary()
+	if nvml == nil {
+		up.Add(0)
+		return []*metrics.Family{up}, nil
+	}
+	up.Add(1)
+
+	devices, err := nvml.devices()
+	if err != nil {
+		return nil, err
+	}
+
+	info := metrics.NewFamily("gpu_info", "GPU 信息，值恒为 1", metrics.Gauge)
+	utilization := metrics.NewFamily("gpu_utilization_ratio", "上一个采样周期内 GPU 有内核在执行的时间占比", metrics.Gauge)
+	memoryUtilization := metrics.NewFamily("gpu_memory_utilization_ratio", "上一个采样周期内显存在读写的时间占比", metrics.Gauge)
+	memoryTotal := metrics.NewFamily("gpu_memory_total_bytes", "显存总量", metrics.Gauge)
+	memoryUsed := metrics.NewFamily("gpu_memory_used_bytes", "已使用的显存", metrics.Gauge)
+	temperature := metrics.NewFamily("gpu_temperature_celsius", "GPU 核心温度", metrics.Gauge)
+	power := metrics.NewFamily("gpu_power_watts", "GPU 当前的功耗", metrics.Gauge)
+	powerLimit := metrics.NewFamily("gpu_power_limit_watts", "GPU 实际生效的功耗上限", metrics.Gauge)
+	smClock := metrics.NewFamily("gpu_sm_clock_hertz", "SM 的当前时钟频率", metrics.Gauge)
+	memoryClock := metrics.NewFamily("gpu_memory_clock_hertz", "显存的当前时钟频率", metrics.Gauge)
+	processMemory := metrics.NewFamily("gpu_process_memory_used_bytes", "各进程占用的显存", metrics.Gauge)
+	driver := nvml.driverVersion()
+
+	for _, d := range devices {
+		if !c.selected(d) {
+			continue
+		}
+		labels := []string{"gpu", strconv.Itoa(d.index), "uuid", d.uuid}
+		info.Add(1, slices.Concat(labels, []string{"name", d.name, "driver_version", driver})...)
+		addValid(utilization, d.utilization, labels...)
+		addValid(memoryUtilization, d.memoryUtilization, labels...)
+		addValid(memoryTotal, d.memoryTotal, labels...)
+		addValid(memoryUsed, d.memoryUsed, labels...)
+		addValid(temperature, d.temperature, labels...)
+		addValid(power, d.power, labels...)
+		addValid(powerLimit, d.powerLimit, labels...)
+		addValid(smClock, d.smClock, labels...)
+		addValid(memoryClock, d.memoryClock, labels...)
+		if !c.processes {
+			continue
+		}
+		for _, p := range d.processes {
+			if !p.hasMemory {
+				continue
+			}
+			pid := strconv.Itoa(p.pid)
+			// 容器中的进程在宿主机 proc 中可见，进程已退出时 name 为空
+			name := readSysString(procFile(c.procPath, pid, "comm"))
+			processMemory.Add(p.memoryUsed, slices.Concat(labels, []string{"pid", pid, "name", name})...)
+		}
+	}
+
+	return []*metrics.Family{
+		up, info, utilization, memoryUtilization, memoryTotal, memoryUsed,
+		temperature, power, powerLimit, smClock, memoryClock, processMemory,
+	}, nil
+}
+
+// nvmlLibrary 返回已加载的 NVML，尚未加载时尝试加载，失败时返回 nil。
+// 第一次失败输出警告，之后的失败只输出调试日志，避免每次采集刷屏
+func (c *GPU) nvmlLibrary() nvmlLibrary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.nvml != nil {
+		return c.nvml
+	}
+	nvml, err := c.load(c.library)
+	if err != nil {
+		if !c.warned {
+			slog.Warn("NVML is unavailable, GPU metrics are disabled until it can be loaded", "error", err)
+			c.warned = true
+		} else {
+			slog.Debug("Failed to load NVML", "error", err)
+		}
+		return nil
+	}
+	c.nvml = nvml
+	return nvml
+}
+
+// selected 判断 GPU 是否在 devices 中 (按序号或 UUID，UUID 不区分大小写)，devices 为空时选择所有 GPU
+func (c *GPU) selected(d *gpuDevice) bool {
+	if len(c.devices) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(c.devices, func(s string) bool {
+		return s == strconv.Itoa(d.index) || strings.EqualFold(s, d.uuid)
+	})
+}
+
+// addValid 添加时间序列，值为 NaN (设备不支持该读数) 时跳过
+func addValid(f *metrics.Family, v float64, labels ...string) {
+	if !math.IsNaN(v) {
+		f.Add(v, labels...)
+	}
+}
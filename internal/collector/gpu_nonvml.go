@@ -0,0 +1,11 @@
+//go:build !nvml || !linux
+
+package collector
+
+import "errors"
+
+// loadNVML 构建时未启用 nvml 标签，NVML 始终不可用。
+// 启用后二进制会动态链接 libc，无法在 alpine 等 musl 系统上运行，因此默认不启用
+func loadNVML(string) (nvmlLibrary, error) {
+	return nil, errors.New("nvml support is not built in (rebuild with -tags nvml)")
+}
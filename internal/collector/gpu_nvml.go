@@ -0,0 +1,252 @@
+//go:build nvml && linux
+
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/ebitengine/purego"
+)
+
+// nvmlDefaultLibrary NVML 动态库的默认名称
+const nvmlDefaultLibrary = "libnvidia-ml.so.1"
+
+// NVML 的返回值和常量，见 nvml.h
+const (
+	nvmlSuccess                 = 0
+	nvmlErrorInsufficientSize   = 7
+	nvmlTemperatureGPU          = 0
+	nvmlClockSM                 = 1
+	nvmlClockMem                = 2
+	nvmlDeviceUUIDBufferSize    = 96
+	nvmlDeviceNameBufferSize    = 96
+	nvmlDriverVersionBufferSize = 80
+	nvmlValueNotAvailable       = math.MaxUint64
+)
+
+// nvmlUtilization nvmlUtilization_t，单位为百分比
+type nvmlUtilization struct {
+	gpu    uint32
+	memory uint32
+}
+
+// nvmlMemory nvmlMemory_t，单位为字节
+type nvmlMemory struct {
+	total uint64
+	free  uint64
+	used  uint64
+}
+
+// nvmlProcessInfo nvmlProcessInfo_v2_t (v3 接口使用同一结构)
+type nvmlProcessInfo struct {
+	pid               uint32
+	usedGpuMemory     uint64
+	gpuInstanceID     uint32
+	computeInstanceID uint32
+}
+
+// nvml 通过 purego 在运行时加载 libnvidia-ml，不需要 cgo。
+// 较新的函数 (如进程列表的 v3 版本) 在旧驱动中不存在时为 nil，对应的读数跳过
+type nvml struct {
+	errorString                       func(ret int32) string
+	systemGetDriverVersion            func(version *byte, length uint32) int32
+	deviceGetCount                    func(count *uint32) int32
+	deviceGetHandleByIndex            func(index uint32, device *uintptr) int32
+	deviceGetUUID                     func(device uintptr, uuid *byte, length uint32) int32
+	deviceGetName                     func(device uintptr, name *byte, length uint32) int32
+	deviceGetUtilizationRates         func(device uintptr, utilization *nvmlUtilization) int32
+	deviceGetMemoryInfo               func(device uintptr, memory *nvmlMemory) int32
+	deviceGetTemperature              func(device uintptr, sensor uint32, temp *uint32) int32
+	deviceGetPowerUsage               func(device uintptr, power *uint32) int32
+	deviceGetEnforcedPowerLimit       func(device uintptr, limit *uint32) int32
+	deviceGetClockInfo                func(device uintptr, clock uint32, mhz *uint32) int32
+	deviceGetComputeRunningProcesses  func(device uintptr, count *uint32, infos *nvmlProcessInfo) int32
+	deviceGetGraphicsRunningProcesses func(device uintptr, count *uint32, infos *nvmlProcessInfo) int32
+}
+
+// loadNVML 加载 NVML 动态库并初始化，path 为空时使用默认名称按系统的库搜索路径查找
+func loadNVML(path string) (nvmlLibrary, error) {
+	if path == "" {
+		path = nvmlDefaultLibrary
+	}
+	lib, err := purego.Dlopen(path, purego.RTLD_NOW|purego.RTLD_GLOBAL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+
+	l := &nvml{}
+	var initialize func() int32
+	for _, fn := range []struct {
+		ptr      any
+		name     string
+		optional bool
+	}{
+		{&initialize, "nvmlInit_v2", false},
+		{&l.errorString, "nvmlErrorString", false},
+		{&l.systemGetDriverVersion, "nvmlSystemGetDriverVersion", false},
+		{&l.deviceGetCount, "nvmlDeviceGetCount_v2", false},
+		{&l.deviceGetHandleByIndex, "nvmlDeviceGetHandleByIndex_v2", false},
+		{&l.deviceGetUUID, "nvmlDeviceGetUUID", false},
+		{&l.deviceGetName, "nvmlDeviceGetName", false},
+		{&l.deviceGetUtilizationRates, "nvmlDeviceGetUtilizationRates", false},
+		{&l.deviceGetMemoryInfo, "nvmlDeviceGetMemoryInfo", false},
+		{&l.deviceGetTemperature, "nvmlDeviceGetTemperature", false},
+		{&l.deviceGetPowerUsage, "nvmlDeviceGetPowerUsage", false},
+		{&l.deviceGetEnforcedPowerLimit, "nvmlDeviceGetEnforcedPowerLimit", false},
+		{&l.deviceGetClockInfo, "nvmlDeviceGetClockInfo", false},
+		// R510 起提供
+		{&l.deviceGetComputeRunningProcesses, "nvmlDeviceGetComputeRunningProcesses_v3", true},
+		{&l.deviceGetGraphicsRunningProcesses, "nvmlDeviceGetGraphicsRunningProcesses_v3", true},
+	} {
+		sym, err := purego.Dlsym(lib, fn.name)
+		if err != nil {
+			if fn.optional {
+				continue
+			}
+			return nil, fmt.Errorf("failed to find %s in %s: %w", fn.name, path, err)
+		}
+		purego.RegisterFunc(fn.ptr, sym)
+	}
+
+	if ret := initialize(); ret != nvmlSuccess {
+		return nil, l.error("nvmlInit", ret)
+	}
+	return l, nil
+}
+
+// driverVersion 实现 nvmlLibrary
+func (l *nvml) driverVersion() string {
+	buf := make([]byte, nvmlDriverVersionBufferSize)
+	if l.systemGetDriverVersion(&buf[0], uint32(len(buf))) != nvmlSuccess {
+		return ""
+	}
+	return cString(buf)
+}
+
+// devices 实现 nvmlLibrary
+func (l *nvml) devices() ([]*gpuDevice, error) {
+	var count uint32
+	if ret := l.deviceGetCount(&count); ret != nvmlSuccess {
+		return nil, l.error("nvmlDeviceGetCount", ret)
+	}
+	devices := make([]*gpuDevice, 0, count)
+	for i := range count {
+		var handle uintptr
+		if ret := l.deviceGetHandleByIndex(i, &handle); ret != nvmlSuccess {
+			slog.Debug("Failed to get GPU handle", "gpu", i, "error", l.error("nvmlDeviceGetHandleByIndex", ret))
+			continue
+		}
+		devices = append(devices, l.device(int(i), handle))
+	}
+	return devices, nil
+}
+
+// device 读取一块 GPU 的各项读数，不支持的读数为 NaN
+func (l *nvml) device(index int, handle uintptr) *gpuDevice {
+	nan := math.NaN()
+	d := &gpuDevice{
+		index:             index,
+		uuid:              l.deviceString(l.deviceGetUUID, handle, nvmlDeviceUUIDBufferSize),
+		name:              l.deviceString(l.deviceGetName, handle, nvmlDeviceNameBufferSize),
+		utilization:       nan,
+		memoryUtilization: nan,
+		memoryTotal:       nan,
+		memoryUsed:        nan,
+		temperature:       nan,
+		power:             nan,
+		powerLimit:        nan,
+		smClock:           nan,
+		memoryClock:       nan,
+	}
+
+	var utilization nvmlUtilization
+	if l.deviceGetUtilizationRates(handle, &utilization) == nvmlSuccess {
+		d.utilization = float64(utilization.gpu) / 100
+		d.memoryUtilization = float64(utilization.memory) / 100
+	}
+	var memory nvmlMemory
+	if l.deviceGetMemoryInfo(handle, &memory) == nvmlSuccess {
+		d.memoryTotal, d.memoryUsed = float64(memory.total), float64(memory.used)
+	}
+	var v uint32
+	if l.deviceGetTemperature(handle, nvmlTemperatureGPU, &v) == nvmlSuccess {
+		d.temperature = float64(v)
+	}
+	// 功耗单位为毫瓦，时钟单位为 MHz
+	if l.deviceGetPowerUsage(handle, &v) == nvmlSuccess {
+		d.power = float64(v) / 1000
+	}
+	if l.deviceGetEnforcedPowerLimit(handle, &v) == nvmlSuccess {
+		d.powerLimit = float64(v) / 1000
+	}
+	if l.deviceGetClockInfo(handle, nvmlClockSM, &v) == nvmlSuccess {
+		d.smClock = float64(v) * 1e6
+	}
+	if l.deviceGetClockInfo(handle, nvmlClockMem, &v) == nvmlSuccess {
+		d.memoryClock = float64(v) * 1e6
+	}
+
+	// 同时使用计算和图形接口的进程在两个列表中都出现，只保留一次
+	seen := make(map[int]bool)
+	for _, list := range [][]nvmlProcessInfo{
+		l.runningProcesses(l.deviceGetComputeRunningProcesses, handle),
+		l.runningProcesses(l.deviceGetGraphicsRunningProcesses, handle),
+	} {
+		for _, info := range list {
+			pid := int(info.pid)
+			if seen[pid] {
+				continue
+			}
+			seen[pid] = true
+			d.processes = append(d.processes, gpuProcess{
+				pid:        pid,
+				memoryUsed: float64(info.usedGpuMemory),
+				hasMemory:  info.usedGpuMemory != nvmlValueNotAvailable,
+			})
+		}
+	}
+	return d
+}
+
+// runningProcesses 读取 GPU 上的进程列表，先查询数量再分配缓冲区，函数不存在或调用失败时返回 nil
+func (l *nvml) runningProcesses(fn func(uintptr, *uint32, *nvmlProcessInfo) int32, handle uintptr) []nvmlProcessInfo {
+	if fn == nil {
+		return nil
+	}
+	var count uint32
+	if ret := fn(handle, &count, nil); ret != nvmlErrorInsufficientSize {
+		return nil
+	}
+	// 两次调用之间可能有新进程启动，多分配一些
+	infos := make([]nvmlProcessInfo, count+8)
+	count = uint32(len(infos))
+	if fn(handle, &count, &infos[0]) != nvmlSuccess {
+		return nil
+	}
+	return infos[:count]
+}
+
+// deviceString 调用返回字符串的设备函数，失败时返回空字符串
+func (l *nvml) deviceString(fn func(uintptr, *byte, uint32) int32, handle uintptr, size int) string {
+	buf := make([]byte, size)
+	if fn(handle, &buf[0], uint32(size)) != nvmlSuccess {
+		return ""
+	}
+	return cString(buf)
+}
+
+// error 将 NVML 返回值转换为错误
+func (l *nvml) error(fn string, ret int32) error {
+	return fmt.Errorf("%s failed: %s (%d)", fn, l.errorString(ret), ret)
+}
+
+// cString 返回以 NUL 结尾的 C 字符串的内容
+func cString(buf []byte) string {
+	if i := bytes.IndexByte(buf, 0); i >= 0 {
+		buf = buf[:i]
+	}
+	return string(buf)
+}
@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+// fakeNVML 固定返回 gpus 的 nvmlLibrary
+type fakeNVML struct {
+	gpus []*gpuDevice
+}
+
+func (f *fakeNVML) driverVersion() string {
+	return "550.54.15"
+}
+
+func (f *fakeNVML) devices() ([]*gpuDevice, error) {
+	return f.gpus, nil
+}
+
+func testGPUs() []*gpuDevice {
+	nan := math.NaN()
+	return []*gpuDevice{
+		{
+			index: 0, uuid: "GPU-aaaa", name: "NVIDIA A100-SXM4-40GB",
+			utilization: 0.87, memoryUtilization: 0.4,
+			memoryTotal: 42949672960, memoryUsed: 10737418240,
+			temperature: 61, power: 245.5, powerLimit: 400,
+			smClock: 1410e6, memoryClock: 1215e6,
+			processes: []gpuProcess{
+				{pid: 1234, memoryUsed: 8589934592, hasMemory: true},
+				{pid: 5678, hasMemory: false},
+			},
+		},
+		{
+			// 不支持功耗和时钟读数的设备
+			index: 1, uuid: "GPU-bbbb", name: "Tesla T4",
+			utilization: 0, memoryUtilization: 0,
+			memoryTotal: 16106127360, memoryUsed: 0,
+			temperature: 35, power: nan, powerLimit: nan,
+			smClock: nan, memoryClock: nan,
+		},
+	}
+}
+
+func TestGPUCollect(t *testing.T) {
+	proc := writeProc(t, "", map[string]string{"1234/comm": "python3\n"})
+	c := NewGPU(proc, config.DefaultConfig().Collector.GPU)
+	c.load = func(string) (nvmlLibrary, error) {
+		return &fakeNVML{gpus: testGPUs()}, nil
+	}
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+
+	gpu0 := []string{"gpu", "0", "uuid", "GPU-aaaa"}
+	gpu1 := []string{"gpu", "1", "uuid", "GPU-bbbb"}
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"gpu_nvml_up", nil, 1},
+		{"gpu_info", append(gpu0, "name", "NVIDIA A100-SXM4-40GB", "driver_version", "550.54.15"), 1},
+		{"gpu_utilization_ratio", gpu0, 0.87},
+		{"gpu_memory_utilization_ratio", gpu0, 0.4},
+		{"gpu_memory_total_bytes", gpu0, 42949672960},
+		{"gpu_memory_used_bytes", gpu0, 10737418240},
+		{"gpu_temperature_celsius", gpu0, 61},
+		{"gpu_power_watts", gpu0, 245.5},
+		{"gpu_power_limit_watts", gpu0, 400},
+		{"gpu_sm_clock_hertz", gpu0, 1410e6},
+		{"gpu_memory_clock_hertz", gpu0, 1215e6},
+		{"gpu_process_memory_used_bytes", append(gpu0, "pid", "1234", "name", "python3"), 8589934592},
+		{"gpu_temperature_celsius", gpu1, 35},
+		{"gpu_memory_used_bytes", gpu1, 0},
+	} {
+		got, ok := findMetric(families, tt.name, tt.labels...)
+		if !ok {
+			t.Errorf("缺少指标 %s%v", tt.name, tt.labels)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s%v = %v, 期望 %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+
+	// 不支持的读数和没有显存用量的进程不输出
+	for _, tt := range []struct {
+		name   string
+		labels []string
+	}{
+		{"gpu_power_watts", gpu1},
+		{"gpu_sm_clock_hertz", gpu1},
+		{"gpu_process_memory_used_bytes", append(gpu0, "pid", "5678", "name", "")},
+	} {
+		if _, ok := findMetric(families, tt.name, tt.labels...); ok {
+			t.Errorf("不应输出 %s%v", tt.name, tt.labels)
+		}
+	}
+}
+
+func TestGPUSelectDevices(t *testing.T) {
+	cfg := config.DefaultConfig().Collector.GPU
+	cfg.Devices = []string{"gpu-BBBB"}
+	cfg.Processes = false
+	c := NewGPU(t.TempDir(), cfg)
+	c.load = func(string) (nvmlLibrary, error) {
+		return &fakeNVML{gpus: testGPUs()}, nil
+	}
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if _, ok := findMetric(families, "gpu_temperature_celsius", "gpu", "1", "uuid", "GPU-bbbb"); !ok {
+		t.Error("按 UUID 选择的 GPU 缺少指标")
+	}
+	if _, ok := findMetric(families, "gpu_temperature_celsius", "gpu", "0", "uuid", "GPU-aaaa"); ok {
+		t.Error("未选择的 GPU 不应输出指标")
+	}
+
+	c.devices = []string{"0"}
+	families, err = c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if _, ok := findMetric(families, "gpu_temperature_celsius", "gpu", "0", "uuid", "GPU-aaaa"); !ok {
+		t.Error("按序号选择的 GPU 缺少指标")
+	}
+	if _, ok := findMetric(families, "gpu_process_memory_used_bytes", "gpu", "0", "uuid", "GPU-aaaa", "pid", "1234", "name", ""); ok {
+		t.Error("processes 为 false 时不应输出进程显存")
+	}
+}
+
+func TestGPUNVMLUnavailable(t *testing.T) {
+	c := NewGPU(t.TempDir(), config.DefaultConfig().Collector.GPU)
+	loads := 0
+	c.load = func(string) (nvmlLibrary, error) {
+		loads++
+		if loads == 1 {
+			return nil, errors.New("libnvidia-ml.so.1: cannot open shared object file")
+		}
+		return &fakeNVML{gpus: testGPUs()}, nil
+	}
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("NVML 不可用时不应返回错误: %v", err)
+	}
+	if len(families) != 1 {
+		t.Fatalf("NVML 不可用时应只输出 gpu_nvml_up，实际 %d 个指标族", len(families))
+	}
+	if got, _ := findMetric(families, "gpu_nvml_up"); got != 0 {
+		t.Errorf("gpu_nvml_up = %v, 期望 0", got)
+	}
+
+	// 下一次采集重试加载，成功后不再加载
+	for range 2 {
+		if families, err = c.Collect(context.Background()); err != nil {
+			t.Fatalf("采集失败: %v", err)
+		}
+	}
+	if got, _ := findMetric(families, "gpu_nvml_up"); got != 1 {
+		t.Errorf("重试后 gpu_nvml_up = %v, 期望 1", got)
+	}
+	if loads != 2 {
+		t.Errorf("加载了 %d 次, 期望 2", loads)
+	}
+}
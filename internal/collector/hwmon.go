@@ -0,0 +1,224 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// hwmonInputPattern hwmon 传感器读数的文件名，如 temp1_input、fan2_input、in0_input
+var hwmonInputPattern = regexp.MustCompile(`^(temp|fan|in)(\d+)_input$`)
+
+// hwmonAttr 传感器的一个属性文件 (<类型><序号>_<suffix>) 和对应的指标
+type hwmonAttr struct {
+	suffix, name, help string
+}
+
+// hwmonSensorTypes 各类传感器输出的读数和阈值，scale 为 sysfs 中的单位到输出单位的换算 (温度为毫摄氏度，电压为毫伏)
+var hwmonSensorTypes = map[string]struct {
+	scale float64
+	attrs []hwmonAttr // 第一个为读数 (input)，其余为阈值，芯片不提供的阈值不输出
+}{
+	"temp": {scale: 1000, attrs: []hwmonAttr{
+		{"input", "hwmon_temp_celsius", "传感器温度"},
+		{"max", "hwmon_temp_max_celsius", "传感器的高温告警阈值"},
+		{"crit", "hwmon_temp_crit_celsius", "传感器的临界温度"},
+	}},
+	"fan": {scale: 1, attrs: []hwmonAttr{
+		{"input", "hwmon_fan_rpm", "风扇转速 (每分钟转数)"},
+		{"min", "hwmon_fan_min_rpm", "风扇转速的下限告警阈值"},
+	}},
+	"in": {scale: 1000, attrs: []hwmonAttr{
+		{"input", "hwmon_voltage_volts", "电压"},
+		{"min", "hwmon_voltage_min_volts", "电压的下限告警阈值"},
+		{"max", "hwmon_voltage_max_volts", "电压的上限告警阈值"},
+	}},
+}
+
+// Hwmon 从 /sys/class/hwmon 采集温度、风扇转速和电压，
+// hwmon 下没有温度传感器且启用 thermal_zones 时从 /sys/class/thermal 读取温度
+//
+// 每个读数带 chip (芯片驱动的名称，如 coretemp)、device (芯片所属设备，如 coretemp.0，用于区分同名芯片)、
+// sensor (如 temp1) 和 label (芯片提供的标签，如 Package id 0，没有时与 sensor 相同) 四个标签。
+// 读取失败的传感器 (如未接风扇的接口返回 EIO) 跳过
+type Hwmon struct {
+	sysPath      string
+	include      *regexp.Regexp // 为 nil 时不限制
+	exclude      *regexp.Regexp // 为 nil 时不排除
+	thermalZones bool
+}
+
+// NewHwmon 创建硬件传感器采集器，芯片过滤的正则表达式无效时返回错误
+func NewHwmon(sysPath string, cfg config.HwmonCollectorConfig) (*Hwmon, error) {
+	c := &Hwmon{sysPath: sysPath, thermalZones: cfg.ThermalZones}
+	var err error
+	if c.include, err = compilePattern("hwmon chip_include", cfg.ChipInclude); err != nil {
+		return nil, err
+	}
+	if c.exclude, err = compilePattern("hwmon chip_exclude", cfg.ChipExclude); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name 实现 metrics.Collector
+func (c *Hwmon) Name() string {
+	return "hwmon"
+}
+
+// Collect 实现 metrics.Collector
+func (c *Hwmon) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	families := make(map[string][]*metrics.Family, len(hwmonSensorTypes))
+	var out []*metrics.Family
+	for _, typ := range []string{"temp", "fan", "in"} {
+		for _, attr := range hwmonSensorTypes[typ].attrs {
+			f := metrics.NewFamily(attr.name, attr.help, metrics.Gauge)
+			families[typ] = append(families[typ], f)
+			out = append(out, f)
+		}
+	}
+
+	// 虚拟机和容器中常常没有 hwmon
+	base := filepath.Join(c.sysPath, "class", "hwmon")
+	entries, err := os.ReadDir(base)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	temps := 0
+	for _, e := range entries {
+		dir := filepath.Join(base, e.Name())
+		chip := c.chipName(dir)
+		if !matchFilter(c.include, c.exclude, chip) {
+			continue
+		}
+		device := ""
+		if target, err := filepath.EvalSymlinks(filepath.Join(dir, "device")); err == nil {
+			device = filepath.Base(target)
+		}
+		temps += c.collectChip(dir, []string{"chip", chip, "device", device}, families)
+	}
+
+	if c.thermalZones && temps == 0 {
+		thermal, err := c.collectThermalZones()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, thermal)
+	}
+	return out, nil
+}
+
+// chipName 返回芯片的名称，旧内核中 name 在 device 目录下，都没有时使用 hwmon 目录名
+func (c *Hwmon) chipName(dir string) string {
+	if name := readSysString(filepath.Join(dir, "name")); name != "" {
+		return name
+	}
+	if name := readSysString(filepath.Join(dir, "device", "name")); name != "" {
+		return name
+	}
+	return filepath.Base(dir)
+}
+
+// collectChip 读取一个芯片的所有传感器，返回输出的温度读数个数。
+// 传感器文件通常在 hwmon 目录下，旧驱动放在 device 目录下
+func (c *Hwmon) collectChip(dir string, chipLabels []string, families map[string][]*metrics.Family) int {
+	sensors := hwmonInputs(dir)
+	if len(sensors) == 0 {
+		dir = filepath.Join(dir, "device")
+		sensors = hwmonInputs(dir)
+	}
+
+	temps := 0
+	for _, m := range sensors {
+		typ, sensor := m[1], m[1]+m[2]
+		// 传感器故障 (如热电偶断开) 时读数无意义
+		if readSysString(filepath.Join(dir, sensor+"_fault")) == "1" {
+			continue
+		}
+		input, ok := readSysInt(filepath.Join(dir, sensor+"_input"))
+		if !ok {
+			slog.Debug("Failed to read hwmon sensor", "chip", chipLabels[1], "sensor", sensor)
+			continue
+		}
+		label := readSysString(filepath.Join(dir, sensor+"_label"))
+		if label == "" {
+			label = sensor
+		}
+		labels := slices.Concat(chipLabels, []string{"sensor", sensor, "label", label})
+
+		t := hwmonSensorTypes[typ]
+		families[typ][0].Add(input/t.scale, labels...)
+		for i, attr := range t.attrs[1:] {
+			// 部分芯片的阈值为 0 表示未设置
+			if v, ok := readSysInt(filepath.Join(dir, sensor+"_"+attr.suffix)); ok && v != 0 {
+				families[typ][i+1].Add(v/t.scale, labels...)
+			}
+		}
+		if typ == "temp" {
+			temps++
+		}
+	}
+	return temps
+}
+
+// collectThermalZones 读取 /sys/class/thermal/thermal_zone<N> 的温度 (毫摄氏度)，
+// 带 zone (序号) 和 type (如 x86_pkg_temp、cpu-thermal) 两个标签
+func (c *Hwmon) collectThermalZones() (*metrics.Family, error) {
+	temp := metrics.NewFamily("thermal_zone_temp_celsius", "thermal zone 的温度，hwmon 下没有温度传感器时输出", metrics.Gauge)
+	base := filepath.Join(c.sysPath, "class", "thermal")
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return temp, nil
+		}
+		return nil, err
+	}
+	for _, e := range entries {
+		zone, ok := strings.CutPrefix(e.Name(), "thermal_zone")
+		if !ok {
+			continue
+		}
+		dir := filepath.Join(base, e.Name())
+		// 部分 zone 在设备休眠时读取返回错误
+		v, ok := readSysInt(filepath.Join(dir, "temp"))
+		if !ok {
+			continue
+		}
+		temp.Add(v/1000, "zone", zone, "type", readSysString(filepath.Join(dir, "type")))
+	}
+	return temp, nil
+}
+
+// hwmonInputs 列出目录下的传感器读数文件，返回 hwmonInputPattern 的匹配结果 (类型和序号)
+func hwmonInputs(dir string) [][]string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var sensors [][]string
+	for _, e := range entries {
+		if m := hwmonInputPattern.FindStringSubmatch(e.Name()); m != nil {
+			sensors = append(sensors, m)
+		}
+	}
+	return sensors
+}
+
+// readSysInt 读取只包含一个有符号整数的 sysfs 文件 (如低于零度的温度)，读取或解析失败时返回 false
+func readSysInt(path string) (float64, bool) {
+	v, err := strconv.ParseInt(readSysString(path), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return float64(v), true
+}
@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+func TestHwmonCollect(t *testing.T) {
+	sys := writeProc(t, "", map[string]string{
+		"devices/platform/coretemp.0/hwmon/hwmon0/name":        "coretemp\n",
+		"devices/platform/coretemp.0/hwmon/hwmon0/temp1_input": "45000\n",
+		"devices/platform/coretemp.0/hwmon/hwmon0/temp1_label": "Package id 0\n",
+		"devices/platform/coretemp.0/hwmon/hwmon0/temp1_max":   "80000\n",
+		"devices/platform/coretemp.0/hwmon/hwmon0/temp1_crit":  "100000\n",
+		"devices/platform/coretemp.0/hwmon/hwmon0/temp2_input": "-5500\n",
+		"devices/platform/coretemp.0/hwmon/hwmon0/temp2_max":   "0\n",
+		// 旧驱动的传感器文件在 device 目录下
+		"devices/platform/nct6775.656/name":           "nct6775\n",
+		"devices/platform/nct6775.656/fan1_input":     "1200\n",
+		"devices/platform/nct6775.656/fan1_min":       "300\n",
+		"devices/platform/nct6775.656/fan2_input":     "abc\n",
+		"devices/platform/nct6775.656/in0_input":      "1050\n",
+		"devices/platform/nct6775.656/in0_label":      "Vcore\n",
+		"devices/platform/nct6775.656/in0_min":        "800\n",
+		"devices/platform/nct6775.656/in0_max":        "1500\n",
+		"devices/platform/nct6775.656/temp3_input":    "127000\n",
+		"devices/platform/nct6775.656/temp3_fault":    "1\n",
+		"devices/pci0000:00/nvme0/hwmon2/name":        "nvme\n",
+		"devices/pci0000:00/nvme0/hwmon2/temp1_input": "38850\n",
+		"class/thermal/thermal_zone0/type":            "x86_pkg_temp\n",
+		"class/thermal/thermal_zone0/temp":            "46000\n",
+	})
+	for link, target := range map[string]string{
+		"class/hwmon/hwmon0":                               "../../devices/platform/coretemp.0/hwmon/hwmon0",
+		"devices/platform/coretemp.0/hwmon/hwmon0/device":  "../../../coretemp.0",
+		"class/hwmon/hwmon1":                               "../../devices/platform/nct6775.656/hwmon/hwmon1",
+		"devices/platform/nct6775.656/hwmon/hwmon1/device": "../../../nct6775.656",
+		"class/hwmon/hwmon2":                               "../../devices/pci0000:00/nvme0/hwmon2",
+		"devices/pci0000:00/nvme0/hwmon2/device":           "..",
+	} {
+		path := filepath.Join(sys, link)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+		if err := os.Symlink(target, path); err != nil {
+			t.Fatalf("创建符号链接失败: %v", err)
+		}
+	}
+
+	cfg := config.DefaultConfig().Collector.Hwmon
+	cfg.ChipExclude = "^nvme$"
+	c, err := NewHwmon(sys, cfg)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+
+	coretemp := []string{"chip", "coretemp", "device", "coretemp.0"}
+	nct := []string{"chip", "nct6775", "device", "nct6775.656"}
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"hwmon_temp_celsius", append(coretemp, "sensor", "temp1", "label", "Package id 0"), 45},
+		{"hwmon_temp_max_celsius", append(coretemp, "sensor", "temp1", "label", "Package id 0"), 80},
+		{"hwmon_temp_crit_celsius", append(coretemp, "sensor", "temp1", "label", "Package id 0"), 100},
+		{"hwmon_temp_celsius", append(coretemp, "sensor", "temp2", "label", "temp2"), -5.5},
+		{"hwmon_fan_rpm", append(nct, "sensor", "fan1", "label", "fan1"), 1200},
+		{"hwmon_fan_min_rpm", append(nct, "sensor", "fan1", "label", "fan1"), 300},
+		{"hwmon_voltage_volts", append(nct, "sensor", "in0", "label", "Vcore"), 1.05},
+		{"hwmon_voltage_min_volts", append(nct, "sensor", "in0", "label", "Vcore"), 0.8},
+		{"hwmon_voltage_max_volts", append(nct, "sensor", "in0", "label", "Vcore"), 1.5},
+	} {
+		got, ok := findMetric(families, tt.name, tt.labels...)
+		if !ok {
+			t.Errorf("缺少指标 %s%v", tt.name, tt.labels)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s%v = %v, 期望 %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+
+	// 阈值为 0、读数无效、传感器故障、被排除的芯片不输出；hwmon 下有温度时不读取 thermal zone
+	for _, tt := range []struct {
+		name   string
+		labels []string
+	}{
+		{"hwmon_temp_max_celsius", append(coretemp, "sensor", "temp2", "label", "temp2")},
+		{"hwmon_fan_rpm", append(nct, "sensor", "fan2", "label", "fan2")},
+		{"hwmon_temp_celsius", append(nct, "sensor", "temp3", "label", "temp3")},
+		{"hwmon_temp_celsius", []string{"chip", "nvme", "device", "nvme0", "sensor", "temp1", "label", "temp1"}},
+		{"thermal_zone_temp_celsius", []string{"zone", "0", "type", "x86_pkg_temp"}},
+	} {
+		if _, ok := findMetric(families, tt.name, tt.labels...); ok {
+			t.Errorf("不应输出 %s%v", tt.name, tt.labels)
+		}
+	}
+}
+
+func TestHwmonThermalZoneFallback(t *testing.T) {
+	sys := writeProc(t, "", map[string]string{
+		"class/thermal/thermal_zone0/type":   "cpu-thermal\n",
+		"class/thermal/thermal_zone0/temp":   "52300\n",
+		"class/thermal/thermal_zone1/type":   "gpu-thermal\n",
+		"class/thermal/thermal_zone1/temp":   "\n",
+		"class/thermal/cooling_device0/type": "Processor\n",
+	})
+
+	c, err := NewHwmon(sys, config.DefaultConfig().Collector.Hwmon)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("没有 hwmon 时不应返回错误: %v", err)
+	}
+	if got, ok := findMetric(families, "thermal_zone_temp_celsius", "zone", "0", "type", "cpu-thermal"); !ok || got != 52.3 {
+		t.Errorf("thermal_zone_temp_celsius = %v (存在: %v), 期望 52.3", got, ok)
+	}
+	if _, ok := findMetric(families, "thermal_zone_temp_celsius", "zone", "1", "type", "gpu-thermal"); ok {
+		t.Error("读数无效的 thermal zone 不应输出")
+	}
+
+	c.thermalZones = false
+	families, err = c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if _, ok := findMetric(families, "thermal_zone_temp_celsius", "zone", "0", "type", "cpu-thermal"); ok {
+		t.Error("关闭 thermal_zones 时不应输出 thermal zone 温度")
+	}
+}
@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// Kernel 采集内核和操作系统的版本、启动时间、熵池、文件描述符和连接跟踪表的用量，以及各中断号的中断次数
+//
+// 内核版本读取 /proc/sys/kernel，发行版读取根文件系统下的 /etc/os-release；
+// 连接跟踪表只在加载了 nf_conntrack 模块时输出
+type Kernel struct {
+	procPath   string
+	rootfsPath string
+	cfg        config.KernelCollectorConfig
+}
+
+// NewKernel 创建内核和操作系统采集器
+func NewKernel(procPath, rootfsPath string, cfg config.KernelCollectorConfig) *Kernel {
+	return &Kernel{procPath: procPath, rootfsPath: rootfsPath, cfg: cfg}
+}
+
+// Name 实现 metrics.Collector
+func (c *Kernel) Name() string {
+	return "kernel"
+}
+
+// Collect 实现 metrics.Collector
+func (c *Kernel) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	kernelInfo := metrics.NewFamily("kernel_info", "内核版本，值固定为 1", metrics.Gauge)
+	osInfo := metrics.NewFamily("os_info", "操作系统发行版 (/etc/os-release)，值固定为 1", metrics.Gauge)
+	bootTime := metrics.NewFamily("boot_time_seconds", "系统启动的 Unix 时间戳", metrics.Gauge)
+	entropy := metrics.NewFamily("entropy_available_bits", "熵池中可用的熵 (内核 5.18 起固定为 256)", metrics.Gauge)
+	entropyPool := metrics.NewFamily("entropy_pool_size_bits", "熵池的大小", metrics.Gauge)
+	fdAllocated := metrics.NewFamily("filefd_allocated", "已分配的文件描述符数", metrics.Gauge)
+	fdMaximum := metrics.NewFamily("filefd_maximum", "系统允许的最大文件描述符数 (fs.file-max)", metrics.Gauge)
+	conntrack := metrics.NewFamily("conntrack_entries", "连接跟踪表中的条目数", metrics.Gauge)
+	conntrackLimit := metrics.NewFamily("conntrack_entries_limit", "连接跟踪表的最大条目数 (net.netfilter.nf_conntrack_max)", metrics.Gauge)
+
+	release := readSysString(procFile(c.procPath, "sys", "kernel", "osrelease"))
+	version := readSysString(procFile(c.procPath, "sys", "kernel", "version"))
+	if release != "" {
+		kernelInfo.Add(1, "release", release, "version", version)
+	}
+	if release, ok := c.osRelease(); ok {
+		osInfo.Add(1, "id", release["ID"], "version_id", release["VERSION_ID"], "name", release["PRETTY_NAME"])
+	}
+
+	lines, err := readLines(procFile(c.procPath, "stat"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stat: %w", err)
+	}
+	for _, line := range lines {
+		if v, ok := strings.CutPrefix(line, "btime "); ok {
+			if err := addUint(bootTime, strings.TrimSpace(v)); err != nil {
+				return nil, fmt.Errorf("failed to parse btime: %w", err)
+			}
+			break
+		}
+	}
+
+	if v, ok := readSysUint(procFile(c.procPath, "sys", "kernel", "random", "entropy_avail")); ok {
+		entropy.Add(v)
+	}
+	if v, ok := readSysUint(procFile(c.procPath, "sys", "kernel", "random", "poolsize")); ok {
+		entropyPool.Add(v)
+	}
+
+	// file-nr 为已分配、已分配但未使用 (2.6 起固定为 0) 和最大的文件描述符数
+	if fields := strings.Fields(readSysString(procFile(c.procPath, "sys", "fs", "file-nr"))); len(fields) == 3 {
+		if err := addUint(fdAllocated, fields[0]); err != nil {
+			return nil, fmt.Errorf("failed to parse file-nr: %w", err)
+		}
+		if err := addUint(fdMaximum, fields[2]); err != nil {
+			return nil, fmt.Errorf("failed to parse file-nr: %w", err)
+		}
+	}
+
+	if v, ok := readSysUint(procFile(c.procPath, "sys", "net", "netfilter", "nf_conntrack_count")); ok {
+		conntrack.Add(v)
+	}
+	if v, ok := readSysUint(procFile(c.procPath, "sys", "net", "netfilter", "nf_conntrack_max")); ok {
+		conntrackLimit.Add(v)
+	}
+
+	families := []*metrics.Family{kernelInfo, osInfo, bootTime, entropy, entropyPool, fdAllocated, fdMaximum, conntrack, conntrackLimit}
+	if c.cfg.Interrupts {
+		interrupts, err := collectInterrupts(c.procPath)
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, interrupts)
+	}
+	return families, nil
+}
+
+// osRelease 读取 os-release，/etc/os-release 不存在时读取 /usr/lib/os-release
+func (c *Kernel) osRelease() (map[string]string, bool) {
+	for _, name := range []string{"etc/os-release", "usr/lib/os-release"} {
+		lines, err := readLines(filepath.Join(c.rootfsPath, name))
+		if err != nil {
+			continue
+		}
+		release := make(map[string]string)
+		for _, line := range lines {
+			key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+			if !ok || strings.HasPrefix(key, "#") {
+				continue
+			}
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			} else {
+				value = strings.Trim(value, `'`)
+			}
+			release[key] = value
+		}
+		return release, true
+	}
+	return nil, false
+}
+
+// collectInterrupts 读取 /proc/interrupts，输出各中断号在所有 CPU 上的中断次数之和，每行形如
+//
+//	 24:          1          0  IO-APIC   5-edge      ACPI:Ged
+//	NMI:          0          0   Non-maskable interrupts
+//
+// 数字中断号的 CPU 计数之后依次为中断控制器、硬件中断号和设备，其余中断号之后为说明，作为 devices 标签
+func collectInterrupts(procPath string) (*metrics.Family, error) {
+	lines, err := readLines(procFile(procPath, "interrupts"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read interrupts: %w", err)
+	}
+	interrupts := metrics.NewFamily("irq_interrupts_total", "各中断号在所有 CPU 上的中断次数", metrics.Counter)
+	if len(lines) == 0 {
+		return interrupts, nil
+	}
+	cpus := len(strings.Fields(lines[0]))
+	for _, line := range lines[1:] {
+		irq, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		irq = strings.TrimSpace(irq)
+		fields := strings.Fields(rest)
+		var total float64
+		n := 0
+		for ; n < min(cpus, len(fields)); n++ {
+			v, err := strconv.ParseUint(fields[n], 10, 64)
+			if err != nil {
+				break
+			}
+			total += float64(v)
+		}
+		var chip, devices string
+		if _, err := strconv.Atoi(irq); err == nil && len(fields)-n >= 2 {
+			chip = fields[n]
+			devices = strings.Join(fields[min(n+2, len(fields)):], " ")
+		} else {
+			devices = strings.Join(fields[n:], " ")
+		}
+		interrupts.Add(total, "irq", irq, "chip", chip, "devices", devices)
+	}
+	return interrupts, nil
+}
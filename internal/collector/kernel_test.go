@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+const testInterrupts = `           CPU0       CPU1
+  0:         36          0   IO-APIC   2-edge      timer
+ 16:        100         20   IO-APIC  16-fasteoi   ehci_hcd:usb1, i801_smbus
+ 42:          5          7   PCI-MSI 524288-edge      nvme0q0
+NMI:          1          2   Non-maskable interrupts
+LOC:      12345      23456   Local timer interrupts
+ERR:          0
+`
+
+func TestKernelCollect(t *testing.T) {
+	proc := writeProc(t, "", map[string]string{
+		"stat":                                 testStat + "btime 1700000000\n",
+		"interrupts":                           testInterrupts,
+		"sys/kernel/osrelease":                 "6.8.0-45-generic\n",
+		"sys/kernel/version":                   "#45-Ubuntu SMP PREEMPT_DYNAMIC Fri Aug 30 12:02:04 UTC 2024\n",
+		"sys/kernel/random/entropy_avail":      "256\n",
+		"sys/kernel/random/poolsize":           "256\n",
+		"sys/fs/file-nr":                       "2048\t0\t9223372036854775807\n",
+		"sys/net/netfilter/nf_conntrack_count": "120\n",
+		"sys/net/netfilter/nf_conntrack_max":   "262144\n",
+	})
+	rootfs := writeProc(t, "", map[string]string{
+		"etc/os-release": "# comment\nNAME=\"Ubuntu\"\nPRETTY_NAME=\"Ubuntu 24.04.1 LTS\"\nID=ubuntu\nVERSION_ID='24.04'\n",
+	})
+
+	c := NewKernel(proc, rootfs, config.DefaultConfig().Collector.Kernel)
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"kernel_info", []string{"release", "6.8.0-45-generic", "version", "#45-Ubuntu SMP PREEMPT_DYNAMIC Fri Aug 30 12:02:04 UTC 2024"}, 1},
+		{"os_info", []string{"id", "ubuntu", "version_id", "24.04", "name", "Ubuntu 24.04.1 LTS"}, 1},
+		{"boot_time_seconds", nil, 1700000000},
+		{"entropy_available_bits", nil, 256},
+		{"entropy_pool_size_bits", nil, 256},
+		{"filefd_allocated", nil, 2048},
+		{"filefd_maximum", nil, 9223372036854775807},
+		{"conntrack_entries", nil, 120},
+		{"conntrack_entries_limit", nil, 262144},
+		{"irq_interrupts_total", []string{"irq", "0", "chip", "IO-APIC", "devices", "timer"}, 36},
+		{"irq_interrupts_total", []string{"irq", "16", "chip", "IO-APIC", "devices", "ehci_hcd:usb1, i801_smbus"}, 120},
+		{"irq_interrupts_total", []string{"irq", "42", "chip", "PCI-MSI", "devices", "nvme0q0"}, 12},
+		{"irq_interrupts_total", []string{"irq", "LOC", "chip", "", "devices", "Local timer interrupts"}, 35801},
+		{"irq_interrupts_total", []string{"irq", "ERR", "chip", "", "devices", ""}, 0},
+	} {
+		got, ok := findMetric(families, tt.name, tt.labels...)
+		if !ok {
+			t.Errorf("缺少指标 %s%v", tt.name, tt.labels)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s%v = %v, 期望 %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestKernelOptionalFiles(t *testing.T) {
+	proc := writeProc(t, "", map[string]string{
+		"stat":                 testStat + "btime 1700000000\n",
+		"sys/kernel/osrelease": "6.1.0\n",
+	})
+	rootfs := writeProc(t, "", map[string]string{
+		"usr/lib/os-release": "ID=debian\nVERSION_ID=\"12\"\nPRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\n",
+	})
+	cfg := config.DefaultConfig().Collector.Kernel
+	cfg.Interrupts = false
+	families, err := NewKernel(proc, rootfs, cfg).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if _, ok := findMetric(families, "os_info", "id", "debian", "version_id", "12", "name", "Debian GNU/Linux 12 (bookworm)"); !ok {
+		t.Error("/etc/os-release 不存在时应读取 /usr/lib/os-release")
+	}
+	// 未加载 nf_conntrack 时不输出连接跟踪表，关闭 interrupts 时不读取 /proc/interrupts
+	for _, name := range []string{"conntrack_entries", "conntrack_entries_limit", "filefd_allocated"} {
+		if _, ok := findMetric(families, name); ok {
+			t.Errorf("文件不存在时不应输出 %s", name)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// memoryCompactFields 精简模式下输出的 /proc/meminfo 字段
+var memoryCompactFields = []string{"MemTotal", "MemFree", "MemAvailable", "Buffers", "Cached", "SwapTotal", "SwapFree"}
+
+// hugepageStates /proc/meminfo 中大页数量字段对应的状态
+var hugepageStates = map[string]string{
+	"HugePages_Total": "total",
+	"HugePages_Free":  "free",
+	"HugePages_Rsvd":  "reserved",
+	"HugePages_Surp":  "surplus",
+}
+
+// Memory 从 /proc/meminfo 采集内存用量，从 /proc/vmstat 采集换入换出，从 /proc/pressure/memory 采集 PSI
+//
+// meminfo 的每个字段输出为 memory_<字段>_bytes，字段名转换为 snake_case (如 Active(anon) 为 active_anon)；
+// 精简模式只输出总量、可用、缓存和交换区等常用字段，大页未配置时也不输出大页指标。
+// 换入换出速率为与上一次采集之间的平均值，首次采集只记录基线
+type Memory struct {
+	procPath string
+	cfg      config.MemoryCollectorConfig
+	pageSize float64
+	now      func() time.Time // 计算速率用的当前时间，测试时替换
+
+	mu       sync.Mutex
+	previous map[string]float64 // 上次采集的换入换出字节数
+	lastTime time.Time
+}
+
+// NewMemory 创建内存采集器
+func NewMemory(procPath string, cfg config.MemoryCollectorConfig) *Memory {
+	return &Memory{procPath: procPath, cfg: cfg, pageSize: float64(os.Getpagesize()), now: time.Now}
+}
+
+// Name 实现 metrics.Collector
+func (c *Memory) Name() string {
+	return "memory"
+}
+
+// Collect 实现 metrics.Collector
+func (c *Memory) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	families, err := c.meminfo()
+	if err != nil {
+		return nil, err
+	}
+	swap, err := c.swap()
+	if err != nil {
+		return nil, err
+	}
+	families = append(families, swap...)
+	if c.cfg.PSI {
+		pressure, err := collectPressure(c.procPath, "memory", "线程等待内存")
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, pressure...)
+	}
+	return families, nil
+}
+
+// meminfo 读取 /proc/meminfo，大页数量单独输出为 memory_hugepages
+func (c *Memory) meminfo() ([]*metrics.Family, error) {
+	path := procFile(c.procPath, "meminfo")
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read meminfo: %w", err)
+	}
+
+	var families []*metrics.Family
+	hugepages := metrics.NewFamily("memory_hugepages", "大页数量", metrics.Gauge)
+	hugepageSize := metrics.NewFamily("memory_hugepage_size_bytes", "大页大小", metrics.Gauge)
+	var hugepagesTotal float64
+	for _, line := range lines {
+		key, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s in %s: %w", key, path, err)
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			v *= 1024
+		}
+
+		switch {
+		case hugepageStates[key] != "":
+			hugepages.Add(v, "state", hugepageStates[key])
+			if key == "HugePages_Total" {
+				hugepagesTotal = v
+			}
+		case key == "Hugepagesize":
+			hugepageSize.Add(v)
+		case c.cfg.Compact && !slices.Contains(memoryCompactFields, key):
+		default:
+			f := metrics.NewFamily("memory_"+snakeCase(key)+"_bytes", "/proc/meminfo 中的 "+key, metrics.Gauge)
+			f.Add(v)
+			families = append(families, f)
+		}
+	}
+	if !c.cfg.Compact || hugepagesTotal > 0 {
+		families = append(families, hugepages, hugepageSize)
+	}
+	return families, nil
+}
+
+// swap 读取 /proc/vmstat 中的换入换出页数，输出累计字节数和速率
+func (c *Memory) swap() ([]*metrics.Family, error) {
+	lines, err := readLines(procFile(c.procPath, "vmstat"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vmstat: %w", err)
+	}
+
+	total := metrics.NewFamily("memory_swapped_bytes_total", "换入换出交换区的累计字节数", metrics.Counter)
+	rate := metrics.NewFamily("memory_swap_bytes_per_second", "换入换出交换区的速率", metrics.Gauge)
+	current := make(map[string]float64, 2)
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok || (key != "pswpin" && key != "pswpout") {
+			continue
+		}
+		pages, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", key, err)
+		}
+		direction := strings.TrimPrefix(key, "pswp")
+		current[direction] = float64(pages) * c.pageSize
+		total.Add(current[direction], "direction", direction)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if elapsed := now.Sub(c.lastTime).Seconds(); c.previous != nil && elapsed > 0 {
+		for _, direction := range []string{"in", "out"} {
+			if v, ok := current[direction]; ok && v >= c.previous[direction] {
+				rate.Add((v-c.previous[direction])/elapsed, "direction", direction)
+			}
+		}
+	}
+	c.previous, c.lastTime = current, now
+	return []*metrics.Family{total, rate}, nil
+}
+
+// snakeCase 将 /proc/meminfo 的字段名转换为 snake_case：
+// MemTotal 为 mem_total，Active(anon) 为 active_anon，SUnreclaim 为 s_unreclaim，DirectMap2M 为 direct_map2m
+func snakeCase(s string) string {
+	runes := []rune(s)
+	var sb strings.Builder
+	for i, r := range runes {
+		switch {
+		case r == '(' || r == '_':
+			if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "_") {
+				sb.WriteByte('_')
+			}
+			continue
+		case r == ')':
+			continue
+		case unicode.IsUpper(r) && i > 0:
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || (unicode.IsUpper(prev) && nextLower) {
+				sb.WriteByte('_')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
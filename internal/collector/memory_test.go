@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+const testMeminfo = `MemTotal:        8000000 kB
+MemFree:         1000000 kB
+MemAvailable:    5000000 kB
+Buffers:           90000 kB
+Cached:          4000000 kB
+Active(anon):         20 kB
+SUnreclaim:        33164 kB
+Committed_AS:     391616 kB
+DirectMap2M:     2072576 kB
+SwapTotal:       2000000 kB
+SwapFree:        1500000 kB
+HugePages_Total:      16
+HugePages_Free:       10
+HugePages_Rsvd:        2
+HugePages_Surp:        0
+Hugepagesize:       2048 kB
+`
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"MemTotal":      "mem_total",
+		"Active(anon)":  "active_anon",
+		"SUnreclaim":    "s_unreclaim",
+		"Committed_AS":  "committed_as",
+		"NFS_Unstable":  "nfs_unstable",
+		"DirectMap2M":   "direct_map2m",
+		"AnonHugePages": "anon_huge_pages",
+	}
+	for in, want := range tests {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, 期望 %q", in, got, want)
+		}
+	}
+}
+
+func TestMemoryCollect(t *testing.T) {
+	dir := writeProc(t, "", map[string]string{
+		"meminfo":         testMeminfo,
+		"vmstat":          "pgpgin 100\npswpin 10\npswpout 20\n",
+		"pressure/memory": testPressure,
+	})
+	now := time.Unix(1700000000, 0)
+	c := NewMemory(dir, config.DefaultConfig().Collector.Memory)
+	c.pageSize = 4096
+	c.now = func() time.Time { return now }
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	for name, want := range map[string]float64{
+		"memory_mem_total_bytes":     8000000 * 1024,
+		"memory_active_anon_bytes":   20 * 1024,
+		"memory_committed_as_bytes":  391616 * 1024,
+		"memory_hugepage_size_bytes": 2048 * 1024,
+	} {
+		if v, ok := findMetric(families, name); !ok || v != want {
+			t.Errorf("%s = %v, %v, 期望 %v", name, v, ok, want)
+		}
+	}
+	if v, ok := findMetric(families, "memory_hugepages", "state", "reserved"); !ok || v != 2 {
+		t.Errorf("保留的大页数 = %v, %v, 期望 2", v, ok)
+	}
+	if _, ok := findMetric(families, "memory_huge_pages_total_bytes"); ok {
+		t.Error("大页数量不应按字节输出")
+	}
+	if v, ok := findMetric(families, "memory_swapped_bytes_total", "direction", "out"); !ok || v != 20*4096 {
+		t.Errorf("换出字节数 = %v, %v", v, ok)
+	}
+	if _, ok := findMetric(families, "memory_swap_bytes_per_second", "direction", "in"); ok {
+		t.Error("首次采集不应输出速率")
+	}
+	if v, ok := findMetric(families, "memory_pressure_ratio", "kind", "some", "window", "60s"); !ok || v != 0.008 {
+		t.Errorf("PSI some avg60 = %v, %v", v, ok)
+	}
+
+	// 10 秒内换入 50 页
+	writeProc(t, dir, map[string]string{"vmstat": "pswpin 60\npswpout 20\n"})
+	now = now.Add(10 * time.Second)
+	if families, err = c.Collect(context.Background()); err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if v, ok := findMetric(families, "memory_swap_bytes_per_second", "direction", "in"); !ok || v != 50*4096/10 {
+		t.Errorf("换入速率 = %v, %v, 期望 %v", v, ok, 50*4096/10)
+	}
+	if v, ok := findMetric(families, "memory_swap_bytes_per_second", "direction", "out"); !ok || v != 0 {
+		t.Errorf("换出速率 = %v, %v, 期望 0", v, ok)
+	}
+}
+
+func TestMemoryCollectCompact(t *testing.T) {
+	dir := writeProc(t, "", map[string]string{
+		"meminfo": strings.Replace(testMeminfo, "HugePages_Total:      16", "HugePages_Total:       0", 1),
+		"vmstat":  "pswpin 10\npswpout 20\n",
+	})
+	c := NewMemory(dir, config.MemoryCollectorConfig{Enabled: true, Compact: true, PSI: true})
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if _, ok := findMetric(families, "memory_mem_available_bytes"); !ok {
+		t.Error("精简模式应包含 MemAvailable")
+	}
+	if _, ok := findMetric(families, "memory_active_anon_bytes"); ok {
+		t.Error("精简模式不应包含 Active(anon)")
+	}
+	if _, ok := findMetric(families, "memory_hugepages", "state", "total"); ok {
+		t.Error("精简模式下未配置大页时不应输出大页指标")
+	}
+	if _, ok := findMetric(families, "memory_pressure_ratio", "kind", "some", "window", "10s"); ok {
+		t.Error("缺少 PSI 文件时不应输出压力指标")
+	}
+}
@@ -0,0 +1,231 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// /proc/net/dev 中网卡名之后各列的下标，0-7 为接收，8-15 为发送
+const (
+	netBytes      = 0
+	netPackets    = 1
+	netErrors     = 2
+	netDrops      = 3
+	netFIFO       = 4
+	netFrame      = 5 // 接收方向为帧错误，发送方向为冲突
+	netCarrier    = 6 // 仅发送方向
+	netMulticast  = 7 // 仅接收方向
+	netFields     = 8
+	netDirections = 2
+)
+
+// netDirectionNames 两个方向的标签值，与 /proc/net/dev 的列顺序一致
+var netDirectionNames = [netDirections]string{"rx", "tx"}
+
+// queueStatPatterns 从 ethtool 统计项名称中识别队列号、方向和统计项，不同驱动的命名不同：
+// virtio_net/ixgbe 为 rx_queue_0_bytes，mlx5 为 rx0_bytes，i40e 为 rx-0.bytes，ena 为 queue_0_rx_bytes
+var queueStatPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(?P<direction>rx|tx)[_-]?(?:queue[_-])?(?P<queue>\d+)[_.-](?P<stat>\w+)$`),
+	regexp.MustCompile(`^queue[_-](?P<queue>\d+)[_-](?P<direction>rx|tx)[_-](?P<stat>\w+)$`),
+}
+
+// queueStats 队列统计项的名称到输出统计项的映射，其他统计项各驱动含义不一，不输出
+var queueStats = map[string]string{
+	"bytes":   "bytes",
+	"packets": "packets",
+	"drops":   "drops",
+	"dropped": "drops",
+	"errors":  "errors",
+	"errs":    "errors",
+}
+
+// Netdev 从 /proc/net/dev 采集网卡的收发计数，从 sysfs 读取速率、双工和运行状态，
+// 并通过 ethtool 读取网卡驱动提供的各硬件队列的计数
+type Netdev struct {
+	procPath string
+	sysPath  string
+	include  *regexp.Regexp // 为 nil 时不限制
+	exclude  *regexp.Regexp // 为 nil 时不排除
+	queues   bool
+	ethtool  func(device string) (map[string]uint64, error) // 测试时替换
+}
+
+// NewNetdev 创建网卡采集器，网卡过滤的正则表达式无效时返回错误
+func NewNetdev(procPath, sysPath string, cfg config.NetdevCollectorConfig) (*Netdev, error) {
+	c := &Netdev{procPath: procPath, sysPath: sysPath, queues: cfg.Queues, ethtool: ethtoolStats}
+	var err error
+	if c.include, err = compilePattern("netdev device_include", cfg.DeviceInclude); err != nil {
+		return nil, err
+	}
+	if c.exclude, err = compilePattern("netdev device_exclude", cfg.DeviceExclude); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name 实现 metrics.Collector
+func (c *Netdev) Name() string {
+	return "netdev"
+}
+
+// Collect 实现 metrics.Collector
+func (c *Netdev) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	devices, err := readNetDev(procFile(c.procPath, "net", "dev"))
+	if err != nil {
+		return nil, err
+	}
+
+	bytes := metrics.NewFamily("network_bytes_total", "收发的字节数", metrics.Counter)
+	packets := metrics.NewFamily("network_packets_total", "收发的包数", metrics.Counter)
+	errs := metrics.NewFamily("network_errors_total", "收发错误数", metrics.Counter)
+	drops := metrics.NewFamily("network_drops_total", "丢弃的包数", metrics.Counter)
+	fifo := metrics.NewFamily("network_fifo_errors_total", "FIFO 缓冲区错误数", metrics.Counter)
+	frame := metrics.NewFamily("network_frame_errors_total", "接收的帧错误数", metrics.Counter)
+	carrier := metrics.NewFamily("network_carrier_errors_total", "发送的载波错误数", metrics.Counter)
+	collisions := metrics.NewFamily("network_collisions_total", "发送冲突数", metrics.Counter)
+	multicast := metrics.NewFamily("network_multicast_packets_total", "接收的多播包数", metrics.Counter)
+	info := metrics.NewFamily("network_info", "网卡信息，值恒为 1", metrics.Gauge)
+	up := metrics.NewFamily("network_up", "网卡的运行状态 (operstate) 是否为 up", metrics.Gauge)
+	speed := metrics.NewFamily("network_speed_bytes", "网卡的协商速率 (字节每秒)，链路断开或虚拟网卡不输出", metrics.Gauge)
+	mtu := metrics.NewFamily("network_mtu_bytes", "网卡的 MTU", metrics.Gauge)
+	queueFamilies := map[string]*metrics.Family{
+		"bytes":   metrics.NewFamily("network_queue_bytes_total", "各硬件队列收发的字节数 (ethtool)", metrics.Counter),
+		"packets": metrics.NewFamily("network_queue_packets_total", "各硬件队列收发的包数 (ethtool)", metrics.Counter),
+		"drops":   metrics.NewFamily("network_queue_drops_total", "各硬件队列丢弃的包数 (ethtool)", metrics.Counter),
+		"errors":  metrics.NewFamily("network_queue_errors_total", "各硬件队列的错误数 (ethtool)", metrics.Counter),
+	}
+
+	for _, dev := range devices {
+		device, values := dev.device, dev.values
+		if !matchFilter(c.include, c.exclude, device) {
+			continue
+		}
+		for d, direction := range netDirectionNames {
+			v := values[d*netFields:]
+			bytes.Add(v[netBytes], "device", device, "direction", direction)
+			packets.Add(v[netPackets], "device", device, "direction", direction)
+			errs.Add(v[netErrors], "device", device, "direction", direction)
+			drops.Add(v[netDrops], "device", device, "direction", direction)
+			fifo.Add(v[netFIFO], "device", device, "direction", direction)
+		}
+		frame.Add(values[netFrame], "device", device)
+		multicast.Add(values[netMulticast], "device", device)
+		collisions.Add(values[netFields+netFrame], "device", device)
+		carrier.Add(values[netFields+netCarrier], "device", device)
+
+		c.sysInfo(device, info, up, speed, mtu)
+		if c.queues {
+			c.queueStats(device, queueFamilies)
+		}
+	}
+	return []*metrics.Family{
+		bytes, packets, errs, drops, fifo, frame, carrier, collisions, multicast,
+		queueFamilies["bytes"], queueFamilies["packets"], queueFamilies["drops"], queueFamilies["errors"],
+		info, up, speed, mtu,
+	}, nil
+}
+
+// sysInfo 从 /sys/class/net/<网卡> 读取地址、状态、速率和 MTU。
+// 虚拟网卡没有 duplex，链路断开时读取 speed 会返回 EINVAL，这些情况下跳过对应的值
+func (c *Netdev) sysInfo(device string, info, up, speed, mtu *metrics.Family) {
+	dir := filepath.Join(c.sysPath, "class", "net", device)
+	operstate := readSysString(filepath.Join(dir, "operstate"))
+	info.Add(1,
+		"device", device,
+		"address", readSysString(filepath.Join(dir, "address")),
+		"operstate", operstate,
+		"duplex", readSysString(filepath.Join(dir, "duplex")),
+	)
+	up.Add(boolValue(operstate == "up"), "device", device)
+	if mbps, err := strconv.ParseInt(readSysString(filepath.Join(dir, "speed")), 10, 64); err == nil && mbps > 0 {
+		speed.Add(float64(mbps)*1e6/8, "device", device)
+	}
+	if v, err := strconv.ParseUint(readSysString(filepath.Join(dir, "mtu")), 10, 64); err == nil {
+		mtu.Add(float64(v), "device", device)
+	}
+}
+
+// queueStats 读取网卡的 ethtool 统计项，将能识别的各队列计数添加到对应的指标族
+func (c *Netdev) queueStats(device string, families map[string]*metrics.Family) {
+	stats, err := c.ethtool(device)
+	if err != nil {
+		slog.Debug("Failed to read ethtool stats", "device", device, "error", err)
+		return
+	}
+	seen := make(map[string]bool)
+	for _, name := range slices.Sorted(maps.Keys(stats)) {
+		for _, re := range queueStatPatterns {
+			m := re.FindStringSubmatch(name)
+			if m == nil {
+				continue
+			}
+			queue := m[re.SubexpIndex("queue")]
+			direction := m[re.SubexpIndex("direction")]
+			stat, ok := queueStats[m[re.SubexpIndex("stat")]]
+			// 同一驱动可能用两种名称提供同一计数，只取第一个
+			key := queue + "\xff" + direction + "\xff" + stat
+			if ok && !seen[key] {
+				seen[key] = true
+				families[stat].Add(float64(stats[name]), "device", device, "queue", queue, "direction", direction)
+			}
+			break
+		}
+	}
+}
+
+// netDevStats /proc/net/dev 中一个网卡的各列计数
+type netDevStats struct {
+	device string
+	values []float64
+}
+
+// readNetDev 读取 /proc/net/dev (或 /proc/<pid>/net/dev)，按文件中的顺序返回各网卡的计数
+func readNetDev(path string) ([]netDevStats, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read net/dev: %w", err)
+	}
+	var devices []netDevStats
+	for _, line := range lines {
+		// 前两行为表头
+		device, rest, ok := strings.Cut(line, ":")
+		if !ok || strings.Contains(device, "|") {
+			continue
+		}
+		device = strings.TrimSpace(device)
+		fields := strings.Fields(rest)
+		if len(fields) < netFields*netDirections {
+			continue
+		}
+		values := make([]float64, len(fields))
+		for i, field := range fields {
+			v, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s in %s: %w", device, path, err)
+			}
+			values[i] = float64(v)
+		}
+		devices = append(devices, netDevStats{device, values})
+	}
+	return devices, nil
+}
+
+// readSysString 读取 sysfs 属性并去掉换行，读取失败时返回空字符串
+func readSysString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
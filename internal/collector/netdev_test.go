@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+const testNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+  eth0: 5000000    4000    3    2    1     4          0         7  2000000    3000    5    6    0     8       9          0
+veth1a2b:     100       1    0    0    0     0          0         0      100       1    0    0    0     0       0          0
+`
+
+func TestNetdevCollect(t *testing.T) {
+	proc := writeProc(t, "", map[string]string{"net/dev": testNetDev})
+	sys := writeProc(t, "", map[string]string{
+		"class/net/eth0/operstate": "up\n",
+		"class/net/eth0/address":   "52:54:00:12:34:56\n",
+		"class/net/eth0/duplex":    "full\n",
+		"class/net/eth0/speed":     "10000\n",
+		"class/net/eth0/mtu":       "1500\n",
+	})
+	c, err := NewNetdev(proc, sys, config.DefaultConfig().Collector.Netdev)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	c.ethtool = func(device string) (map[string]uint64, error) {
+		return map[string]uint64{
+			"rx_queue_0_bytes":   300,
+			"rx_queue_0_packets": 3,
+			"tx0_bytes":          400,
+			"rx-1.drops":         5,
+			"queue_1_tx_errs":    6,
+			"rx_queue_0_kicks":   99,
+			"rx_csum_offload":    1,
+		}, nil
+	}
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"network_bytes_total", []string{"device", "eth0", "direction", "rx"}, 5000000},
+		{"network_packets_total", []string{"device", "eth0", "direction", "tx"}, 3000},
+		{"network_errors_total", []string{"device", "eth0", "direction", "tx"}, 5},
+		{"network_drops_total", []string{"device", "eth0", "direction", "rx"}, 2},
+		{"network_frame_errors_total", []string{"device", "eth0"}, 4},
+		{"network_multicast_packets_total", []string{"device", "eth0"}, 7},
+		{"network_collisions_total", []string{"device", "eth0"}, 8},
+		{"network_carrier_errors_total", []string{"device", "eth0"}, 9},
+		{"network_info", []string{"device", "eth0", "address", "52:54:00:12:34:56", "operstate", "up", "duplex", "full"}, 1},
+		{"network_up", []string{"device", "eth0"}, 1},
+		{"network_speed_bytes", []string{"device", "eth0"}, 1.25e9},
+		{"network_mtu_bytes", []string{"device", "eth0"}, 1500},
+		{"network_queue_bytes_total", []string{"device", "eth0", "queue", "0", "direction", "rx"}, 300},
+		{"network_queue_packets_total", []string{"device", "eth0", "queue", "0", "direction", "rx"}, 3},
+		{"network_queue_bytes_total", []string{"device", "eth0", "queue", "0", "direction", "tx"}, 400},
+		{"network_queue_drops_total", []string{"device", "eth0", "queue", "1", "direction", "rx"}, 5},
+		{"network_queue_errors_total", []string{"device", "eth0", "queue", "1", "direction", "tx"}, 6},
+	} {
+		if v, ok := findMetric(families, tt.name, tt.labels...); !ok || v != tt.want {
+			t.Errorf("%s%v = %v, %v, 期望 %v", tt.name, tt.labels, v, ok, tt.want)
+		}
+	}
+	for _, device := range []string{"lo", "veth1a2b"} {
+		if _, ok := findMetric(families, "network_bytes_total", "device", device, "direction", "rx"); ok {
+			t.Errorf("%s 应被默认规则排除", device)
+		}
+	}
+}
+
+func TestNetdevWithoutSysfs(t *testing.T) {
+	proc := writeProc(t, "", map[string]string{"net/dev": testNetDev})
+	c, err := NewNetdev(proc, t.TempDir(), config.NetdevCollectorConfig{Enabled: true, DeviceInclude: "^lo$", Queues: true})
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	c.ethtool = func(string) (map[string]uint64, error) { return nil, errors.New("no such device") }
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("缺少 sysfs 或 ethtool 失败时不应失败: %v", err)
+	}
+	if v, ok := findMetric(families, "network_bytes_total", "device", "lo", "direction", "tx"); !ok || v != 1000 {
+		t.Errorf("lo 发送字节数 = %v, %v, 期望 1000", v, ok)
+	}
+	if _, ok := findMetric(families, "network_speed_bytes", "device", "lo"); ok {
+		t.Error("没有速率时不应输出 network_speed_bytes")
+	}
+	if v, ok := findMetric(families, "network_up", "device", "lo"); !ok || v != 0 {
+		t.Errorf("lo network_up = %v, %v, 期望 0", v, ok)
+	}
+
+	if _, err := NewNetdev(proc, "", config.NetdevCollectorConfig{DeviceInclude: "("}); err == nil {
+		t.Error("无效的正则表达式应返回错误")
+	}
+}
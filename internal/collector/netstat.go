@@ -0,0 +1,281 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// netstatGauges 协议计数中的瞬时值，其余字段均为累计计数
+var netstatGauges = map[string]bool{
+	"Ip_Forwarding":    true,
+	"Ip_DefaultTTL":    true,
+	"Tcp_RtoAlgorithm": true,
+	"Tcp_RtoMin":       true,
+	"Tcp_RtoMax":       true,
+	"Tcp_MaxConn":      true,
+	"Tcp_CurrEstab":    true,
+}
+
+// snmp6Protocols /proc/net/snmp6 中字段名的协议前缀
+var snmp6Protocols = []string{"Ip6", "Icmp6", "UdpLite6", "Udp6"}
+
+// tcpStates /proc/net/tcp 中 st 列的十六进制值对应的连接状态，见 include/net/tcp_states.h
+var tcpStates = []string{
+	1:  "established",
+	2:  "syn_sent",
+	3:  "syn_recv",
+	4:  "fin_wait1",
+	5:  "fin_wait2",
+	6:  "time_wait",
+	7:  "close",
+	8:  "close_wait",
+	9:  "last_ack",
+	10: "listen",
+	11: "closing",
+	12: "new_syn_recv",
+}
+
+// Netstat 采集套接字和协议栈统计：
+// /proc/net/sockstat{,6} 的套接字数量和内存，/proc/net/{snmp,netstat,snmp6} 中匹配 field_include 的协议计数
+// (如重传、监听队列溢出、UDP 错误)，以及可选的按状态统计的 TCP 连接数。
+// 统计连接数需要遍历 /proc/net/tcp{,6}，在连接数很多的主机上开销较大，默认关闭
+type Netstat struct {
+	procPath  string
+	fields    *regexp.Regexp // 为 nil 时输出全部字段
+	tcpStates bool
+	pageSize  float64
+}
+
+// NewNetstat 创建套接字统计采集器，字段过滤的正则表达式无效时返回错误
+func NewNetstat(procPath string, cfg config.NetstatCollectorConfig) (*Netstat, error) {
+	fields, err := compilePattern("netstat field_include", cfg.FieldInclude)
+	if err != nil {
+		return nil, err
+	}
+	return &Netstat{procPath: procPath, fields: fields, tcpStates: cfg.TCPStates, pageSize: float64(os.Getpagesize())}, nil
+}
+
+// Name 实现 metrics.Collector
+func (c *Netstat) Name() string {
+	return "netstat"
+}
+
+// Collect 实现 metrics.Collector
+func (c *Netstat) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	var families []*metrics.Family
+	for _, name := range []string{"sockstat", "sockstat6"} {
+		f, err := c.sockstat(name)
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, f...)
+	}
+
+	values := make(map[string]float64)
+	var keys []string
+	for _, name := range []string{"snmp", "netstat"} {
+		if err := c.protocolCounters(name, values, &keys); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.snmp6(values, &keys); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if c.fields != nil && !c.fields.MatchString(key) {
+			continue
+		}
+		protocol, field, _ := strings.Cut(key, "_")
+		name := "netstat_" + snakeCase(protocol) + "_" + snakeCase(field)
+		typ := metrics.Counter
+		if netstatGauges[key] {
+			typ = metrics.Gauge
+		} else {
+			name += "_total"
+		}
+		f := metrics.NewFamily(name, protocol+" 协议的 "+field, typ)
+		f.Add(values[key])
+		families = append(families, f)
+	}
+
+	if c.tcpStates {
+		f, err := c.connections()
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, f)
+	}
+	return families, nil
+}
+
+// sockstat 读取 /proc/net/sockstat 或 sockstat6，每行形如
+//
+//	TCP: inuse 4 orphan 0 tw 22 alloc 4 mem 1
+//
+// 输出为 sockstat_<协议>_<字段>，mem 的单位为页，转换为字节；未启用 IPv6 时 sockstat6 不存在，返回 nil
+func (c *Netstat) sockstat(name string) ([]*metrics.Family, error) {
+	path := procFile(c.procPath, "net", name)
+	lines, err := readLines(path)
+	if errors.Is(err, fs.ErrNotExist) && name == "sockstat6" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	var families []*metrics.Family
+	for _, line := range lines {
+		protocol, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		protocol = strings.ToLower(protocol)
+		fields := strings.Fields(rest)
+		for i := 0; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s %s in %s: %w", protocol, fields[i], path, err)
+			}
+			key, help := fields[i], protocol+" 的 "+fields[i]
+			switch key {
+			case "mem":
+				key, v, help = "mem_bytes", v*c.pageSize, protocol+" 占用的内存"
+			case "memory":
+				key, help = "memory_bytes", protocol+" 占用的内存"
+			}
+			f := metrics.NewFamily("sockstat_"+protocol+"_"+key, help, metrics.Gauge)
+			f.Add(v)
+			families = append(families, f)
+		}
+	}
+	return families, nil
+}
+
+// protocolCounters 读取 /proc/net/snmp 或 netstat，文件由成对的行组成，第一行为字段名，第二行为值：
+//
+//	Tcp: RtoAlgorithm RtoMin ...
+//	Tcp: 1 200 ...
+//
+// 结果以 <协议>_<字段> 为键写入 values，新出现的键按顺序追加到 keys
+func (c *Netstat) protocolCounters(name string, values map[string]float64, keys *[]string) error {
+	path := procFile(c.procPath, "net", name)
+	lines, err := readLines(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	for i := 0; i+1 < len(lines); i += 2 {
+		protocol, header, _ := strings.Cut(lines[i], ":")
+		valueProtocol, data, _ := strings.Cut(lines[i+1], ":")
+		names, fields := strings.Fields(header), strings.Fields(data)
+		if protocol != valueProtocol || len(names) != len(fields) {
+			return fmt.Errorf("invalid %s: mismatched lines for %s", path, protocol)
+		}
+		for j, field := range names {
+			v, err := strconv.ParseFloat(fields[j], 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse %s %s in %s: %w", protocol, field, path, err)
+			}
+			setValue(values, keys, protocol+"_"+field, v)
+		}
+	}
+	return nil
+}
+
+// snmp6 读取 /proc/net/snmp6，每行为带协议前缀的字段名和值 (如 Udp6InDatagrams 5)。
+// 未启用 IPv6 时文件不存在，忽略
+func (c *Netstat) snmp6(values map[string]float64, keys *[]string) error {
+	path := procFile(c.procPath, "net", "snmp6")
+	lines, err := readLines(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snmp6: %w", err)
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		for _, protocol := range snmp6Protocols {
+			field, ok := strings.CutPrefix(fields[0], protocol)
+			if !ok {
+				continue
+			}
+			v, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse %s in %s: %w", fields[0], path, err)
+			}
+			setValue(values, keys, protocol+"_"+field, v)
+			break
+		}
+	}
+	return nil
+}
+
+// setValue 写入值并记录首次出现的键，保持输出顺序稳定
+func setValue(values map[string]float64, keys *[]string, key string, v float64) {
+	if _, ok := values[key]; !ok {
+		*keys = append(*keys, key)
+	}
+	values[key] = v
+}
+
+// connections 遍历 /proc/net/tcp 和 tcp6，按状态统计连接数，未启用 IPv6 时 tcp6 不存在
+func (c *Netstat) connections() (*metrics.Family, error) {
+	counts := make([]float64, len(tcpStates))
+	for _, name := range []string{"tcp", "tcp6"} {
+		err := countTCPStates(procFile(c.procPath, "net", name), counts)
+		if errors.Is(err, fs.ErrNotExist) && name == "tcp6" {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+
+	f := metrics.NewFamily("tcp_connections", "各状态的 TCP 连接数 (IPv4 和 IPv6 之和)", metrics.Gauge)
+	for st, state := range tcpStates {
+		if state != "" {
+			f.Add(counts[st], "state", state)
+		}
+	}
+	return f, nil
+}
+
+// countTCPStates 逐行读取 /proc/net/tcp 格式的文件并累加各状态的连接数，连接很多时文件很大，不整体读入内存
+func countTCPStates(path string, counts []float64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // 表头
+	for scanner.Scan() {
+		// sl local_address rem_address st ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		st, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil {
+			return fmt.Errorf("failed to parse state %q: %w", fields[3], err)
+		}
+		if int(st) < len(counts) {
+			counts[st]++
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+const testSnmp = `Ip: Forwarding DefaultTTL InReceives InHdrErrors
+Ip: 1 64 1000 2
+Tcp: RtoAlgorithm RtoMin ActiveOpens PassiveOpens CurrEstab InSegs RetransSegs InErrs
+Tcp: 1 200 30 40 5 9000 12 3
+Udp: InDatagrams NoPorts InErrors RcvbufErrors
+Udp: 700 8 4 1
+`
+
+const testNetstat = `TcpExt: SyncookiesSent ListenOverflows ListenDrops TCPTimeouts
+TcpExt: 0 17 18 6
+IpExt: InOctets OutOctets
+IpExt: 123456 654321
+`
+
+const testTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:07E8 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 662 1
+   1: 0100007F:BC8F 0100007F:07E8 01 00000000:00000000 00:00000000 00000000     0        0 1038 1
+   2: 0100007F:BC90 0100007F:07E8 06 00000000:00000000 00:00000000 00000000     0        0 0 1
+`
+
+const testTCP6 = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 100 1
+   1: 00000000000000000000000001000000:0016 00000000000000000000000001000000:D2A4 01 00000000:00000000 00:00000000 00000000     0        0 101 1
+`
+
+func TestNetstatCollect(t *testing.T) {
+	dir := writeProc(t, "", map[string]string{
+		"net/sockstat":  "sockets: used 16\nTCP: inuse 4 orphan 1 tw 22 alloc 5 mem 3\nUDP: inuse 2 mem 1\nFRAG: inuse 0 memory 0\n",
+		"net/sockstat6": "TCP6: inuse 7\nUDP6: inuse 1\n",
+		"net/snmp":      testSnmp,
+		"net/netstat":   testNetstat,
+		"net/snmp6":     "Ip6InReceives 5\nUdp6InDatagrams 11\nUdp6InErrors 2\n",
+		"net/tcp":       testTCP,
+		"net/tcp6":      testTCP6,
+	})
+	cfg := config.DefaultConfig().Collector.Netstat
+	cfg.TCPStates = true
+	c, err := NewNetstat(dir, cfg)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	c.pageSize = 4096
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"sockstat_sockets_used", nil, 16},
+		{"sockstat_tcp_tw", nil, 22},
+		{"sockstat_tcp_mem_bytes", nil, 3 * 4096},
+		{"sockstat_tcp6_inuse", nil, 7},
+		{"netstat_ip_forwarding", nil, 1},
+		{"netstat_tcp_curr_estab", nil, 5},
+		{"netstat_tcp_retrans_segs_total", nil, 12},
+		{"netstat_tcp_in_errs_total", nil, 3},
+		{"netstat_udp_in_errors_total", nil, 4},
+		{"netstat_udp_rcvbuf_errors_total", nil, 1},
+		{"netstat_tcp_ext_listen_overflows_total", nil, 17},
+		{"netstat_tcp_ext_tcp_timeouts_total", nil, 6},
+		{"netstat_ip_ext_in_octets_total", nil, 123456},
+		{"netstat_udp6_in_datagrams_total", nil, 11},
+		{"tcp_connections", []string{"state", "listen"}, 2},
+		{"tcp_connections", []string{"state", "established"}, 2},
+		{"tcp_connections", []string{"state", "time_wait"}, 1},
+		{"tcp_connections", []string{"state", "close_wait"}, 0},
+	} {
+		if v, ok := findMetric(families, tt.name, tt.labels...); !ok || v != tt.want {
+			t.Errorf("%s%v = %v, %v, 期望 %v", tt.name, tt.labels, v, ok, tt.want)
+		}
+	}
+	// 默认字段规则之外的计数不输出
+	for _, name := range []string{"netstat_ip_in_receives_total", "netstat_tcp_rto_min", "netstat_ip6_in_receives_total"} {
+		if _, ok := findMetric(families, name); ok {
+			t.Errorf("%s 不应输出", name)
+		}
+	}
+}
+
+func TestNetstatCollectMinimal(t *testing.T) {
+	// 未启用 IPv6 的主机没有 sockstat6、snmp6 和 tcp6
+	dir := writeProc(t, "", map[string]string{
+		"net/sockstat": "sockets: used 1\n",
+		"net/snmp":     testSnmp,
+		"net/netstat":  testNetstat,
+	})
+	c, err := NewNetstat(dir, config.NetstatCollectorConfig{Enabled: true})
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("缺少 IPv6 文件时不应失败: %v", err)
+	}
+	if v, ok := findMetric(families, "netstat_tcp_rto_min"); !ok || v != 200 {
+		t.Errorf("不过滤字段时 netstat_tcp_rto_min = %v, %v, 期望 200", v, ok)
+	}
+	if _, ok := findMetric(families, "tcp_connections", "state", "listen"); ok {
+		t.Error("未启用 tcp_states 时不应输出连接数")
+	}
+
+	writeProc(t, dir, map[string]string{"net/snmp": "Tcp: ActiveOpens PassiveOpens\nTcp: 1\n"})
+	if _, err := c.Collect(context.Background()); err == nil {
+		t.Error("字段名和值的数量不一致时应返回错误")
+	}
+}
@@ -0,0 +1,220 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// adjtimex 返回的时钟状态和 status 标志位 (linux/timex.h)
+const (
+	timexTimeError = 5      // TIME_ERROR，时钟未同步
+	timexStaNano   = 0x2000 // STA_NANO，offset 的单位为纳秒而不是微秒
+)
+
+// ntpStatus 从 chronyd 或 ntpd 读取的同步状态
+type ntpStatus struct {
+	daemon         string
+	reference      string  // 同步源的名称或地址
+	stratum        float64 // 本机的层级
+	offset         float64 // 本机时钟与同步源的偏差，单位秒，正值表示本机时钟慢
+	frequency      float64 // 本机时钟频率的误差 (漂移)，单位 ppm
+	rootDelay      float64 // 到一级时钟源的往返延迟，单位秒
+	rootDispersion float64 // 到一级时钟源的累计误差，单位秒
+	synced         bool
+}
+
+// NTP 采集时钟同步状态：通过 adjtimex 读取内核的时钟偏差、频率校正和同步状态，
+// 通过 chronyc 或 ntpq 读取时间同步服务的偏差、漂移和层级
+//
+// 时钟偏差会导致指标的时间戳错误。adjtimex 与使用哪个同步服务无关，systemd-timesyncd 等也会更新；
+// daemon 为 auto 时依次尝试 chronyc 和 ntpq，都不可用时只输出 adjtimex 的指标
+type NTP struct {
+	daemon   string
+	timeout  time.Duration
+	adjtimex func(buf *syscall.Timex) (int, error)                                  // 测试时替换
+	run      func(ctx context.Context, name string, args ...string) ([]byte, error) // 测试时替换
+}
+
+// NewNTP 创建时钟同步采集器
+func NewNTP(cfg config.NTPCollectorConfig) *NTP {
+	return &NTP{daemon: cfg.Daemon, timeout: cfg.Timeout, adjtimex: syscall.Adjtimex, run: runCommand}
+}
+
+// Name 实现 metrics.Collector
+func (c *NTP) Name() string {
+	return "ntp"
+}
+
+// Collect 实现 metrics.Collector
+func (c *NTP) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	families, err := c.collectTimex()
+	if err != nil {
+		return nil, err
+	}
+	if c.daemon == "none" {
+		return families, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	var status *ntpStatus
+	switch c.daemon {
+	case "chrony":
+		status, err = c.chrony(ctx)
+	case "ntpd":
+		status, err = c.ntpd(ctx)
+	default:
+		// auto: 依次尝试，都失败时只输出 adjtimex 的指标
+		for _, query := range []func(context.Context) (*ntpStatus, error){c.chrony, c.ntpd} {
+			if status, err = query(ctx); err == nil {
+				break
+			}
+			slog.Debug("Failed to query time sync daemon", "error", err)
+		}
+		if err != nil {
+			return families, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := metrics.NewFamily("ntp_info", "时间同步服务和当前的同步源，值固定为 1", metrics.Gauge)
+	synced := metrics.NewFamily("ntp_synced", "时间同步服务是否已与同步源同步", metrics.Gauge)
+	stratum := metrics.NewFamily("ntp_stratum", "本机的 NTP 层级，未同步时为 0 (chrony) 或 16 (ntpd)", metrics.Gauge)
+	offset := metrics.NewFamily("ntp_offset_seconds", "本机时钟与同步源的偏差，正值表示本机时钟慢", metrics.Gauge)
+	frequency := metrics.NewFamily("ntp_frequency_ppm", "本机时钟频率的误差 (漂移)，单位百万分之一", metrics.Gauge)
+	rootDelay := metrics.NewFamily("ntp_root_delay_seconds", "到一级时钟源的往返延迟", metrics.Gauge)
+	rootDispersion := metrics.NewFamily("ntp_root_dispersion_seconds", "到一级时钟源的累计误差", metrics.Gauge)
+	info.Add(1, "daemon", status.daemon, "reference", status.reference)
+	synced.Add(boolValue(status.synced))
+	stratum.Add(status.stratum)
+	offset.Add(status.offset)
+	frequency.Add(status.frequency)
+	rootDelay.Add(status.rootDelay)
+	rootDispersion.Add(status.rootDispersion)
+	return append(families, info, synced, stratum, offset, frequency, rootDelay, rootDispersion), nil
+}
+
+// collectTimex 通过 adjtimex 读取内核的时钟状态，modes 为 0 时只读取，不需要权限
+func (c *NTP) collectTimex() ([]*metrics.Family, error) {
+	var tx syscall.Timex
+	state, err := c.adjtimex(&tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call adjtimex: %w", err)
+	}
+
+	synced := metrics.NewFamily("timex_sync_status", "内核时钟是否已同步 (adjtimex 的状态不为 TIME_ERROR)", metrics.Gauge)
+	offset := metrics.NewFamily("timex_offset_seconds", "内核锁相环的时钟偏差", metrics.Gauge)
+	frequency := metrics.NewFamily("timex_frequency_adjustment_ppm", "内核对时钟频率的校正量，单位百万分之一", metrics.Gauge)
+	maxError := metrics.NewFamily("timex_maxerror_seconds", "时钟的最大误差", metrics.Gauge)
+	estError := metrics.NewFamily("timex_estimated_error_seconds", "时钟的估计误差", metrics.Gauge)
+	tai := metrics.NewFamily("timex_tai_offset_seconds", "TAI 与 UTC 的偏差 (闰秒数)，同步服务未设置时为 0", metrics.Gauge)
+
+	unit := 1e-6
+	if tx.Status&timexStaNano != 0 {
+		unit = 1e-9
+	}
+	synced.Add(boolValue(state != timexTimeError))
+	offset.Add(float64(tx.Offset) * unit)
+	frequency.Add(float64(tx.Freq) / 65536) // 16 位小数的定点数
+	maxError.Add(float64(tx.Maxerror) / 1e6)
+	estError.Add(float64(tx.Esterror) / 1e6)
+	tai.Add(float64(tx.Tai))
+	return []*metrics.Family{synced, offset, frequency, maxError, estError, tai}, nil
+}
+
+// chrony 执行 chronyc -c tracking，输出为一行逗号分隔的字段:
+//
+//	A9FEA97B,169.254.169.123,4,1700000000.123456789,-0.000001234,...,Normal
+//
+// 依次为 Ref ID、同步源、层级、参考时间、System time (需要校正的量，正值表示本机时钟慢)、Last offset、RMS offset、
+// Frequency (ppm)、Residual freq、Skew、Root delay、Root dispersion、Update interval 和 Leap status
+func (c *NTP) chrony(ctx context.Context) (*ntpStatus, error) {
+	out, err := c.run(ctx, "chronyc", "-c", "-n", "tracking")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Split(strings.TrimSpace(string(out)), ",")
+	if len(fields) < 14 {
+		return nil, fmt.Errorf("unexpected chronyc tracking output: %q", out)
+	}
+	s := &ntpStatus{daemon: "chrony", reference: fields[1], synced: fields[13] != "Not synchronised"}
+	for _, f := range []struct {
+		dst   *float64
+		field string
+	}{
+		{&s.stratum, fields[2]},
+		{&s.offset, fields[4]},
+		{&s.frequency, fields[7]},
+		{&s.rootDelay, fields[10]},
+		{&s.rootDispersion, fields[11]},
+	} {
+		if *f.dst, err = strconv.ParseFloat(f.field, 64); err != nil {
+			return nil, fmt.Errorf("failed to parse chronyc tracking output: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// ntpd 执行 ntpq -c rv 读取系统变量，输出为逗号分隔的 name=value，可能折行:
+//
+//	associd=0 status=0615 leap_none, sync_ntp, 1 event, clock_sync,
+//	leap=00, stratum=3, precision=-24, rootdelay=1.234, rootdisp=10.567, refid=10.0.0.1,
+//	offset=-0.123456, frequency=-12.345, sys_jitter=0.012345
+//
+// offset、rootdelay 和 rootdisp 的单位为毫秒；leap 为 11 时未同步
+func (c *NTP) ntpd(ctx context.Context) (*ntpStatus, error) {
+	out, err := c.run(ctx, "ntpq", "-n", "-c", "rv")
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	for _, field := range strings.Split(string(out), ",") {
+		if name, value, ok := strings.Cut(strings.TrimSpace(field), "="); ok {
+			vars[name] = strings.Trim(value, `"`)
+		}
+	}
+	s := &ntpStatus{daemon: "ntpd", reference: vars["refid"], synced: vars["leap"] != "11" && vars["leap"] != "3"}
+	for _, f := range []struct {
+		dst   *float64
+		name  string
+		scale float64
+	}{
+		{&s.stratum, "stratum", 1},
+		{&s.offset, "offset", 1e-3},
+		{&s.frequency, "frequency", 1},
+		{&s.rootDelay, "rootdelay", 1e-3},
+		{&s.rootDispersion, "rootdisp", 1e-3},
+	} {
+		v, err := strconv.ParseFloat(vars[f.name], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ntpq variable %s: %w", f.name, err)
+		}
+		*f.dst = v * f.scale
+	}
+	return s, nil
+}
+
+// runCommand 执行命令并返回标准输出，失败时错误中包含标准错误的内容
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("failed to run %s: %w: %s", name, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to run %s: %w", name, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,165 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"syscall"
+	"testing"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+const (
+	testChronyTracking = "A9FEA97B,169.254.169.123,4,1700000000.123456789,0.000012500,-0.000001234,0.000003456,-12.345,0.001,0.050,0.000250000,0.000500000,64.1,Normal\n"
+	testNtpqRV         = `associd=0 status=0615 leap_none, sync_ntp, 1 event, clock_sync,
+version="ntpd 4.2.8p15@1.3728-o Wed Sep 23 11:46:38 UTC 2020 (1)",
+processor="x86_64", system="Linux/5.15.0", leap=00, stratum=3,
+precision=-24, rootdelay=1.500, rootdisp=10.250, refid=10.0.0.1,
+reftime=e9123456.12345678  Wed, Nov 15 2023 10:00:00.000,
+clock=e9123460.12345678  Wed, Nov 15 2023 10:00:04.000, peer=12345, tc=10,
+mintc=3, offset=-0.250000, frequency=-7.125, sys_jitter=0.012345,
+clk_jitter=0.023, clk_wander=0.004
+`
+)
+
+// fakeAdjtimex 返回已同步、offset 单位为纳秒的时钟状态
+func fakeAdjtimex(tx *syscall.Timex) (int, error) {
+	tx.Status = timexStaNano
+	tx.Offset = -1500000
+	tx.Freq = -12 * 65536
+	tx.Maxerror = 250000
+	tx.Esterror = 1000
+	tx.Tai = 37
+	return 0, nil
+}
+
+// fakeNTPCommands 按命令名返回固定输出，未列出的命令返回找不到
+func fakeNTPCommands(outputs map[string]string, calls *[]string) func(context.Context, string, ...string) ([]byte, error) {
+	return func(_ context.Context, name string, _ ...string) ([]byte, error) {
+		*calls = append(*calls, name)
+		out, ok := outputs[name]
+		if !ok {
+			return nil, errors.New(`failed to run ` + name + `: exec: "` + name + `": executable file not found in $PATH`)
+		}
+		return []byte(out), nil
+	}
+}
+
+func TestNTPChrony(t *testing.T) {
+	var calls []string
+	c := NewNTP(config.DefaultConfig().Collector.NTP)
+	c.adjtimex = fakeAdjtimex
+	c.run = fakeNTPCommands(map[string]string{"chronyc": testChronyTracking, "ntpq": testNtpqRV}, &calls)
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if !slices.Equal(calls, []string{"chronyc"}) {
+		t.Errorf("执行的命令 = %v, 期望 chronyc 成功后不再尝试 ntpq", calls)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"timex_sync_status", nil, 1},
+		{"timex_offset_seconds", nil, -0.0015},
+		{"timex_frequency_adjustment_ppm", nil, -12},
+		{"timex_maxerror_seconds", nil, 0.25},
+		{"timex_estimated_error_seconds", nil, 0.001},
+		{"timex_tai_offset_seconds", nil, 37},
+		{"ntp_info", []string{"daemon", "chrony", "reference", "169.254.169.123"}, 1},
+		{"ntp_synced", nil, 1},
+		{"ntp_stratum", nil, 4},
+		{"ntp_offset_seconds", nil, 0.0000125},
+		{"ntp_frequency_ppm", nil, -12.345},
+		{"ntp_root_delay_seconds", nil, 0.00025},
+		{"ntp_root_dispersion_seconds", nil, 0.0005},
+	} {
+		got, ok := findMetric(families, tt.name, tt.labels...)
+		if !ok {
+			t.Errorf("缺少指标 %s%v", tt.name, tt.labels)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s%v = %v, 期望 %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestNTPNtpd(t *testing.T) {
+	var calls []string
+	c := NewNTP(config.DefaultConfig().Collector.NTP)
+	c.adjtimex = fakeAdjtimex
+	c.run = fakeNTPCommands(map[string]string{"ntpq": testNtpqRV}, &calls)
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if !slices.Equal(calls, []string{"chronyc", "ntpq"}) {
+		t.Errorf("执行的命令 = %v, 期望依次尝试 chronyc 和 ntpq", calls)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"ntp_info", []string{"daemon", "ntpd", "reference", "10.0.0.1"}, 1},
+		{"ntp_synced", nil, 1},
+		{"ntp_stratum", nil, 3},
+		{"ntp_offset_seconds", nil, -0.00025},
+		{"ntp_frequency_ppm", nil, -7.125},
+		{"ntp_root_delay_seconds", nil, 0.0015},
+		{"ntp_root_dispersion_seconds", nil, 0.01025},
+	} {
+		got, ok := findMetric(families, tt.name, tt.labels...)
+		if !ok {
+			t.Errorf("缺少指标 %s%v", tt.name, tt.labels)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s%v = %v, 期望 %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestNTPDaemonUnavailable(t *testing.T) {
+	var calls []string
+	unsynced := func(tx *syscall.Timex) (int, error) {
+		tx.Status = 0x40 // STA_UNSYNC
+		tx.Offset = 2000
+		return timexTimeError, nil
+	}
+
+	// auto 时都不可用只输出 adjtimex 的指标
+	c := NewNTP(config.DefaultConfig().Collector.NTP)
+	c.adjtimex = unsynced
+	c.run = fakeNTPCommands(nil, &calls)
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if got, _ := findMetric(families, "timex_sync_status"); got != 0 {
+		t.Errorf("timex_sync_status = %v, 期望 0", got)
+	}
+	if got, _ := findMetric(families, "timex_offset_seconds"); got != 0.002 {
+		t.Errorf("timex_offset_seconds = %v, 期望 0.002 (单位为微秒)", got)
+	}
+	if _, ok := findMetric(families, "ntp_synced"); ok {
+		t.Error("时间同步服务不可用时不应输出 ntp_synced")
+	}
+
+	// 指定服务时不可用返回错误
+	cfg := config.DefaultConfig().Collector.NTP
+	cfg.Daemon = "chrony"
+	c = NewNTP(cfg)
+	c.adjtimex = unsynced
+	c.run = fakeNTPCommands(nil, &calls)
+	if _, err := c.Collect(context.Background()); err == nil {
+		t.Error("指定的时间同步服务不可用时应返回错误")
+	}
+}
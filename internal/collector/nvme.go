@@ -0,0 +1,259 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"syscall"
+	"unsafe"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// NVMe admin 命令的 ioctl 和常量，见 linux/nvme_ioctl.h 和 NVMe 规范
+const (
+	nvmeIoctlAdminCmd      = 0xc0484e41 // _IOWR('N', 0x41, struct nvme_admin_cmd)
+	nvmeAdminGetLogPage    = 0x02
+	nvmeAdminIdentify      = 0x06
+	nvmeLogSMART           = 0x02
+	nvmeIdentifyNamespace  = 0x00
+	nvmeNSIDAll            = 0xffffffff
+	nvmeLogSMARTSize       = 512
+	nvmeIdentifySize       = 4096
+	nvmeAdminTimeoutMillis = 5000
+)
+
+// nvmeNamespacePattern 控制器目录下的命名空间，开启原生多路径时为 nvme<控制器>c<通道>n<命名空间>
+var nvmeNamespacePattern = regexp.MustCompile(`^nvme\d+(?:c\d+)?n(\d+)$`)
+
+// nvmeHealthFields SMART/健康日志中 16 字节计数的偏移，scale 为日志中的单位到输出单位的换算
+var nvmeHealthFields = []struct {
+	offset     int
+	name, help string
+	scale      float64
+}{
+	{32, "nvme_data_read_bytes_total", "主机读取的数据量 (按 512000 字节为单位计)", 512000},
+	{48, "nvme_data_written_bytes_total", "主机写入的数据量 (按 512000 字节为单位计)", 512000},
+	{64, "nvme_host_read_commands_total", "控制器完成的读命令数", 1},
+	{80, "nvme_host_write_commands_total", "控制器完成的写命令数", 1},
+	{96, "nvme_controller_busy_seconds_total", "控制器忙于处理 I/O 命令的时间 (按分钟计)", 60},
+	{112, "nvme_power_cycles_total", "通电次数", 1},
+	{128, "nvme_power_on_seconds_total", "累计通电时间 (按小时计)", 3600},
+	{144, "nvme_unsafe_shutdowns_total", "非正常断电次数", 1},
+	{160, "nvme_media_errors_total", "检测到的不可恢复的数据完整性错误数", 1},
+	{176, "nvme_error_log_entries_total", "错误日志的条目数", 1},
+}
+
+// nvmeAdminCmd 对应 struct nvme_admin_cmd (nvme_passthru_cmd)
+type nvmeAdminCmd struct {
+	opcode      uint8
+	flags       uint8
+	rsvd1       uint16
+	nsid        uint32
+	cdw2        uint32
+	cdw3        uint32
+	metadata    uint64
+	addr        uint64
+	metadataLen uint32
+	dataLen     uint32
+	cdw10       uint32
+	cdw11       uint32
+	cdw12       uint32
+	cdw13       uint32
+	cdw14       uint32
+	cdw15       uint32
+	timeoutMs   uint32
+	result      uint32
+}
+
+// NVMe 从 /sys/class/nvme 采集 NVMe 控制器的信息和状态，并通过 admin 命令读取
+// SMART/健康日志 (严重警告、温度、备用块、磨损、读写量和命令数、介质错误等) 和各命名空间的容量与用量
+//
+// 每个控制器带 device 标签 (如 nvme0)，命名空间另带 nsid 标签。
+// admin 命令需要 CAP_SYS_ADMIN，失败时跳过对应的指标，sysfs 中的信息照常输出
+type NVMe struct {
+	sysPath string
+	devPath string
+	ioctl   bool
+	admin   func(path string, cmd *nvmeAdminCmd, data []byte) error // 测试时替换
+}
+
+// NewNVMe 创建 NVMe 采集器
+func NewNVMe(sysPath string, cfg config.NVMeCollectorConfig) *NVMe {
+	return &NVMe{sysPath: sysPath, devPath: "/dev", ioctl: cfg.Ioctl, admin: nvmeAdmin}
+}
+
+// Name 实现 metrics.Collector
+func (c *NVMe) Name() string {
+	return "nvme"
+}
+
+// Collect 实现 metrics.Collector
+func (c *NVMe) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	info := metrics.NewFamily("nvme_info", "NVMe 控制器信息，值恒为 1", metrics.Gauge)
+	live := metrics.NewFamily("nvme_controller_live", "控制器状态 (state) 是否为 live", metrics.Gauge)
+	warning := metrics.NewFamily("nvme_critical_warning", "严重警告位图，为 0 时正常：1 备用块不足，2 温度超限，4 可靠性下降，8 只读，16 易失性存储备份失效", metrics.Gauge)
+	temperature := metrics.NewFamily("nvme_temperature_celsius", "控制器的综合温度", metrics.Gauge)
+	spare := metrics.NewFamily("nvme_available_spare_ratio", "剩余的备用块比例", metrics.Gauge)
+	spareThreshold := metrics.NewFamily("nvme_available_spare_threshold_ratio", "备用块比例低于该值时触发严重警告", metrics.Gauge)
+	used := metrics.NewFamily("nvme_percentage_used_ratio", "按厂商估计已消耗的寿命比例，可能超过 1", metrics.Gauge)
+	counters := make([]*metrics.Family, len(nvmeHealthFields))
+	for i, f := range nvmeHealthFields {
+		counters[i] = metrics.NewFamily(f.name, f.help, metrics.Counter)
+	}
+	nsSize := metrics.NewFamily("nvme_namespace_size_bytes", "命名空间的大小 (NSZE)", metrics.Gauge)
+	nsCapacity := metrics.NewFamily("nvme_namespace_capacity_bytes", "命名空间可分配的最大容量 (NCAP)", metrics.Gauge)
+	nsUsed := metrics.NewFamily("nvme_namespace_used_bytes", "命名空间已分配的容量 (NUSE)，精简配置时小于 NCAP", metrics.Gauge)
+	families := append([]*metrics.Family{info, live, warning, temperature, spare, spareThreshold, used}, counters...)
+	families = append(families, nsSize, nsCapacity, nsUsed)
+
+	base := filepath.Join(c.sysPath, "class", "nvme")
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return families, nil
+		}
+		return nil, err
+	}
+	for _, e := range entries {
+		device, dir := e.Name(), filepath.Join(base, e.Name())
+		info.Add(1, "device", device,
+			"model", readSysString(filepath.Join(dir, "model")),
+			"serial", readSysString(filepath.Join(dir, "serial")),
+			"firmware", readSysString(filepath.Join(dir, "firmware_rev")),
+			"transport", readSysString(filepath.Join(dir, "transport")))
+		state := readSysString(filepath.Join(dir, "state"))
+		live.Add(boolValue(state == "live"), "device", device)
+		// 控制器正在复位或已断开时 admin 命令会阻塞或失败
+		if !c.ioctl || state != "live" {
+			continue
+		}
+
+		dev := filepath.Join(c.devPath, device)
+		log, err := c.healthLog(dev)
+		if err != nil {
+			slog.Debug("Failed to read NVMe health log", "device", device, "error", err)
+			continue
+		}
+		warning.Add(float64(log[0]), "device", device)
+		// 综合温度单位为开尔文 (整数)，与 nvme-cli 和 smartctl 一样按 273 换算，0 表示未实现
+		if k := binary.LittleEndian.Uint16(log[1:]); k != 0 {
+			temperature.Add(float64(k)-273, "device", device)
+		}
+		spare.Add(float64(log[3])/100, "device", device)
+		spareThreshold.Add(float64(log[4])/100, "device", device)
+		used.Add(float64(log[5])/100, "device", device)
+		for i, f := range nvmeHealthFields {
+			counters[i].Add(nvmeUint128(log[f.offset:])*f.scale, "device", device)
+		}
+
+		for _, nsid := range c.namespaces(dir) {
+			size, capacity, nuse, err := c.namespaceUsage(dev, nsid)
+			if err != nil {
+				slog.Debug("Failed to identify NVMe namespace", "device", device, "nsid", nsid, "error", err)
+				continue
+			}
+			labels := []string{"device", device, "nsid", strconv.FormatUint(uint64(nsid), 10)}
+			nsSize.Add(size, labels...)
+			nsCapacity.Add(capacity, labels...)
+			nsUsed.Add(nuse, labels...)
+		}
+	}
+	return families, nil
+}
+
+// namespaces 返回控制器目录下各命名空间的 ID，优先读取 nsid 文件 (内核 5.x 起提供)
+func (c *NVMe) namespaces(dir string) []uint32 {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var ids []uint32
+	for _, e := range entries {
+		m := nvmeNamespacePattern.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		id := readSysString(filepath.Join(dir, e.Name(), "nsid"))
+		if id == "" {
+			id = m[1]
+		}
+		if v, err := strconv.ParseUint(id, 10, 32); err == nil {
+			ids = append(ids, uint32(v))
+		}
+	}
+	return ids
+}
+
+// healthLog 读取控制器的 SMART/健康日志页 (Log Identifier 02h)
+func (c *NVMe) healthLog(dev string) ([]byte, error) {
+	log := make([]byte, nvmeLogSMARTSize)
+	cmd := &nvmeAdminCmd{
+		opcode: nvmeAdminGetLogPage,
+		nsid:   nvmeNSIDAll,
+		// 低 8 位为日志页 ID，高 16 位为读取的双字数减一
+		cdw10: nvmeLogSMART | (nvmeLogSMARTSize/4-1)<<16,
+	}
+	if err := c.admin(dev, cmd, log); err != nil {
+		return nil, fmt.Errorf("failed to get nvme smart log of %s: %w", dev, err)
+	}
+	return log, nil
+}
+
+// namespaceUsage 通过 Identify Namespace 读取命名空间的大小、容量和已分配容量 (字节)
+func (c *NVMe) namespaceUsage(dev string, nsid uint32) (size, capacity, used float64, err error) {
+	id := make([]byte, nvmeIdentifySize)
+	cmd := &nvmeAdminCmd{opcode: nvmeAdminIdentify, nsid: nsid, cdw10: nvmeIdentifyNamespace}
+	if err := c.admin(dev, cmd, id); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to identify nvme namespace %d of %s: %w", nsid, dev, err)
+	}
+	// FLBAS 的低 4 位为当前使用的 LBA 格式，格式描述符中 16-23 位为块大小的 2 的幂
+	format := id[26] & 0x0f
+	lbads := id[128+int(format)*4+2]
+	if lbads < 9 || lbads > 16 {
+		return 0, 0, 0, fmt.Errorf("invalid lba data size 2^%d of nvme namespace %d of %s", lbads, nsid, dev)
+	}
+	block := float64(uint64(1) << lbads)
+	return float64(binary.LittleEndian.Uint64(id[0:])) * block,
+		float64(binary.LittleEndian.Uint64(id[8:])) * block,
+		float64(binary.LittleEndian.Uint64(id[16:])) * block,
+		nil
+}
+
+// nvmeAdmin 打开控制器的字符设备并执行 admin 命令，data 为命令的数据缓冲区
+func nvmeAdmin(path string, cmd *nvmeAdminCmd, data []byte) error {
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	cmd.addr = uint64(uintptr(unsafe.Pointer(&data[0])))
+	cmd.dataLen = uint32(len(data))
+	cmd.timeoutMs = nvmeAdminTimeoutMillis
+	status, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), nvmeIoctlAdminCmd, uintptr(unsafe.Pointer(cmd)))
+	runtime.KeepAlive(data)
+	if errno != 0 {
+		return errno
+	}
+	// 设备拒绝命令 (如不支持该日志页) 时 ioctl 返回 NVMe 状态码，errno 为 0
+	if status != 0 {
+		return fmt.Errorf("nvme status %#x", status)
+	}
+	return nil
+}
+
+// nvmeUint128 读取小端序的 128 位计数
+func nvmeUint128(b []byte) float64 {
+	return float64(binary.LittleEndian.Uint64(b[0:])) + float64(binary.LittleEndian.Uint64(b[8:]))*math.Exp2(64)
+}
@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+// fakeNVMeAdmin 模拟 nvme0 的 admin 命令，nvme1 返回无权限
+func fakeNVMeAdmin(path string, cmd *nvmeAdminCmd, data []byte) error {
+	if filepath.Base(path) != "nvme0" {
+		return syscall.EACCES
+	}
+	switch cmd.opcode {
+	case nvmeAdminGetLogPage:
+		if cmd.cdw10 != 0x007f0002 || cmd.nsid != nvmeNSIDAll {
+			return syscall.EINVAL
+		}
+		data[0] = 0x04
+		binary.LittleEndian.PutUint16(data[1:], 310)
+		data[3], data[4], data[5] = 100, 10, 3
+		binary.LittleEndian.PutUint64(data[32:], 2000)  // data units read
+		binary.LittleEndian.PutUint64(data[64:], 12345) // host read commands
+		binary.LittleEndian.PutUint64(data[88:], 1)     // host write commands 的高 64 位
+		binary.LittleEndian.PutUint64(data[96:], 90)    // controller busy time
+		binary.LittleEndian.PutUint64(data[128:], 8760) // power on hours
+		binary.LittleEndian.PutUint64(data[160:], 2)    // media errors
+	case nvmeAdminIdentify:
+		if cmd.nsid != 1 {
+			return syscall.EINVAL
+		}
+		binary.LittleEndian.PutUint64(data[0:], 1000)
+		binary.LittleEndian.PutUint64(data[8:], 900)
+		binary.LittleEndian.PutUint64(data[16:], 300)
+		data[26] = 1       // 使用 LBA 格式 1
+		data[128+2] = 9    // 格式 0: 512 字节
+		data[128+4+2] = 12 // 格式 1: 4096 字节
+	}
+	return nil
+}
+
+func TestNVMeCollect(t *testing.T) {
+	sys := writeProc(t, "", map[string]string{
+		"class/nvme/nvme0/model":          "Samsung SSD 980 PRO 1TB                 \n",
+		"class/nvme/nvme0/serial":         "S5GXNF0R123456      \n",
+		"class/nvme/nvme0/firmware_rev":   "5B2QGXA7\n",
+		"class/nvme/nvme0/transport":      "pcie\n",
+		"class/nvme/nvme0/state":          "live\n",
+		"class/nvme/nvme0/nvme0n1/nsid":   "1\n",
+		"class/nvme/nvme1/model":          "INTEL SSDPE2KX040T8\n",
+		"class/nvme/nvme1/transport":      "pcie\n",
+		"class/nvme/nvme1/state":          "live\n",
+		"class/nvme/nvme2/model":          "INTEL SSDPE2KX040T8\n",
+		"class/nvme/nvme2/transport":      "pcie\n",
+		"class/nvme/nvme2/state":          "resetting\n",
+		"class/nvme/nvme2/nvme2c2n1/size": "0\n",
+	})
+
+	c := NewNVMe(sys, config.DefaultConfig().Collector.NVMe)
+	c.admin = fakeNVMeAdmin
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+
+	nvme0 := []string{"device", "nvme0"}
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"nvme_info", []string{"device", "nvme0", "model", "Samsung SSD 980 PRO 1TB", "serial", "S5GXNF0R123456", "firmware", "5B2QGXA7", "transport", "pcie"}, 1},
+		{"nvme_controller_live", nvme0, 1},
+		{"nvme_controller_live", []string{"device", "nvme2"}, 0},
+		{"nvme_critical_warning", nvme0, 4},
+		{"nvme_temperature_celsius", nvme0, 37},
+		{"nvme_available_spare_ratio", nvme0, 1},
+		{"nvme_available_spare_threshold_ratio", nvme0, 0.1},
+		{"nvme_percentage_used_ratio", nvme0, 0.03},
+		{"nvme_data_read_bytes_total", nvme0, 2000 * 512000},
+		{"nvme_host_read_commands_total", nvme0, 12345},
+		{"nvme_host_write_commands_total", nvme0, 1 << 64},
+		{"nvme_controller_busy_seconds_total", nvme0, 90 * 60},
+		{"nvme_power_on_seconds_total", nvme0, 8760 * 3600},
+		{"nvme_media_errors_total", nvme0, 2},
+		{"nvme_unsafe_shutdowns_total", nvme0, 0},
+		{"nvme_namespace_size_bytes", []string{"device", "nvme0", "nsid", "1"}, 1000 * 4096},
+		{"nvme_namespace_capacity_bytes", []string{"device", "nvme0", "nsid", "1"}, 900 * 4096},
+		{"nvme_namespace_used_bytes", []string{"device", "nvme0", "nsid", "1"}, 300 * 4096},
+	} {
+		got, ok := findMetric(families, tt.name, tt.labels...)
+		if !ok {
+			t.Errorf("缺少指标 %s%v", tt.name, tt.labels)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s%v = %v, 期望 %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+
+	// 无权限的控制器和未处于 live 状态的控制器只输出 sysfs 中的信息
+	for _, device := range []string{"nvme1", "nvme2"} {
+		if _, ok := findMetric(families, "nvme_critical_warning", "device", device); ok {
+			t.Errorf("%s 不应输出健康日志", device)
+		}
+		if _, ok := findMetric(families, "nvme_controller_live", "device", device); !ok {
+			t.Errorf("%s 缺少 nvme_controller_live", device)
+		}
+	}
+}
+
+func TestNVMeNamespaces(t *testing.T) {
+	dir := writeProc(t, "", map[string]string{
+		"nvme0n1/size":   "0\n",
+		"nvme0c0n2/size": "0\n",
+		"nvme0n3/nsid":   "7\n",
+		"firmware_rev":   "1\n",
+	})
+	if err := os.Mkdir(filepath.Join(dir, "power"), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	got := (&NVMe{}).namespaces(dir)
+	want := []uint32{2, 1, 7}
+	if len(got) != len(want) {
+		t.Fatalf("命名空间 = %v, 期望 %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("命名空间 = %v, 期望 %v", got, want)
+		}
+	}
+}
+
+func TestNVMeIoctlDisabled(t *testing.T) {
+	sys := writeProc(t, "", map[string]string{
+		"class/nvme/nvme0/model": "Samsung SSD 980 PRO 1TB\n",
+		"class/nvme/nvme0/state": "live\n",
+	})
+	cfg := config.DefaultConfig().Collector.NVMe
+	cfg.Ioctl = false
+	c := NewNVMe(sys, cfg)
+	c.admin = func(string, *nvmeAdminCmd, []byte) error {
+		t.Error("关闭 ioctl 时不应执行 admin 命令")
+		return nil
+	}
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if _, ok := findMetric(families, "nvme_controller_live", "device", "nvme0"); !ok {
+		t.Error("缺少 nvme_controller_live")
+	}
+}
@@ -0,0 +1,200 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// probePhase 探测的一个阶段及其耗时，单位秒
+type probePhase struct {
+	name    string
+	seconds float64
+}
+
+// probeResult 一次探测的结果，各探测方式只填写相关的字段
+type probeResult struct {
+	success    bool
+	err        error        // 失败原因，只用于日志
+	duration   float64      // 总耗时，单位秒
+	phases     []probePhase // 各阶段的耗时
+	certExpiry time.Time    // 证书链中最早的过期时间，未使用 TLS 时为零
+	statusCode int          // HTTP 状态码，未收到响应时为 0
+	sent       int          // 发送的包数 (ICMP) 或尝试建立的连接数 (TCP)
+	rtts       []float64    // 收到回复的各个包的往返时间，单位秒
+	dns        *dnsResult   // DNS 查询的结果，未收到响应时为 nil
+}
+
+// probeTarget 一个探测目标，probe 在超时的 ctx 中执行一次探测
+type probeTarget struct {
+	typ      string        // 探测方式，如 http
+	target   string        // target 标签
+	interval time.Duration // 该目标的探测间隔，为 0 时使用 Probe.interval
+	labels   []string      // 该探测方式附加的标签，如 DNS 探测的 query_type 和 resolver
+	probe    func(ctx context.Context) probeResult
+
+	// 以下字段由 Probe.mu 保护
+	started time.Time     // 最近一次探测开始的时间，为零时尚未探测
+	result  *probeResult  // 最近一次完成的探测结果
+	updated time.Time     // result 完成的时间
+	done    chan struct{} // 进行中的探测完成时关闭，为 nil 时没有进行中的探测
+}
+
+// Probe 按配置的目标进行黑盒探测，输出探测是否成功、耗时及各探测方式的详细结果
+//
+// 各目标在后台独立探测，采集时为到达 interval 的目标启动新的探测并输出最近一次的结果，
+// 因此实际探测间隔不小于采集间隔；ICMP 和 TCP 目标可单独指定 interval。
+// 目标的第一次探测完成前采集会等待 (受采集的超时限制)，超时未完成的目标本次不输出。
+// 每个目标带 type (探测方式，http、icmp、tcp 或 dns) 和 target 两个标签，DNS 目标另带 query_type 和 resolver 标签
+type Probe struct {
+	interval time.Duration
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	targets []*probeTarget
+}
+
+// NewProbe 创建探测采集器，目标的格式无效时返回错误
+func NewProbe(cfg config.ProbeCollectorConfig) (*Probe, error) {
+	c := &Probe{interval: cfg.Interval, timeout: cfg.Timeout}
+	httpTargets, err := newHTTPProbes(cfg.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	pingTargets, err := newPingProbes(cfg.Ping)
+	if err != nil {
+		return nil, err
+	}
+	dnsTargets, err := newDNSProbes(cfg.DNS)
+	if err != nil {
+		return nil, err
+	}
+	c.targets = slices.Concat(httpTargets, pingTargets, dnsTargets)
+	return c, nil
+}
+
+// Name 实现 metrics.Collector
+func (c *Probe) Name() string {
+	return "probe"
+}
+
+// Collect 实现 metrics.Collector
+func (c *Probe) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	now := time.Now()
+	var pending []chan struct{}
+	c.mu.Lock()
+	for _, t := range c.targets {
+		interval := t.interval
+		if interval == 0 {
+			interval = c.interval
+		}
+		if t.done == nil && (t.started.IsZero() || now.Sub(t.started) >= interval) {
+			t.started = now
+			t.done = make(chan struct{})
+			go c.run(t, t.done)
+		}
+		if t.result == nil {
+			pending = append(pending, t.done)
+		}
+	}
+	c.mu.Unlock()
+
+wait:
+	for _, done := range pending {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	success := metrics.NewFamily("probe_success", "最近一次探测是否成功", metrics.Gauge)
+	duration := metrics.NewFamily("probe_duration_seconds", "最近一次探测的总耗时", metrics.Gauge)
+	phases := metrics.NewFamily("probe_phase_duration_seconds", "最近一次探测各阶段的耗时", metrics.Gauge)
+	last := metrics.NewFamily("probe_last_run_timestamp_seconds", "最近一次探测完成的时间", metrics.Gauge)
+	certExpiry := metrics.NewFamily("probe_tls_cert_expiry_days", "证书链中最早过期的证书的剩余天数，已过期时为负数", metrics.Gauge)
+	statusCode := metrics.NewFamily("probe_http_status_code", "最近一次 HTTP 探测最终响应的状态码，未收到响应时为 0", metrics.Gauge)
+	loss := metrics.NewFamily("probe_packet_loss_ratio", "最近一次 ICMP 或 TCP 连接探测的丢包率 (未收到回复或未建立连接的比例)", metrics.Gauge)
+	rtt := metrics.NewFamily("probe_rtt_seconds", "最近一次 ICMP 或 TCP 连接探测各个包往返时间的分位数，quantile 为 0 和 1 时为最小值和最大值", metrics.Gauge)
+	dnsLookup := metrics.NewFamily("probe_dns_lookup_seconds", "最近一次 DNS 探测从发送查询到收到响应的耗时", metrics.Gauge)
+	dnsRcode := metrics.NewFamily("probe_dns_rcode", "最近一次 DNS 探测的响应码，0 为 NOERROR，2 为 SERVFAIL，3 为 NXDOMAIN，5 为 REFUSED", metrics.Gauge)
+	dnsRecords := metrics.NewFamily("probe_dns_records", "最近一次 DNS 探测响应中各区 (answer、authority、additional) 的记录数", metrics.Gauge)
+	dnsMismatch := metrics.NewFamily("probe_dns_answer_mismatch", "最近一次 DNS 探测的应答是否与期望不符，只输出配置了期望应答的目标", metrics.Gauge)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range c.targets {
+		r := t.result
+		if r == nil {
+			continue
+		}
+		labels := slices.Concat([]string{"type", t.typ, "target", t.target}, t.labels)
+		success.Add(boolValue(r.success), labels...)
+		duration.Add(r.duration, labels...)
+		for _, p := range r.phases {
+			phases.Add(p.seconds, slices.Concat(labels, []string{"phase", p.name})...)
+		}
+		last.Add(float64(t.updated.UnixNano())/1e9, labels...)
+		if !r.certExpiry.IsZero() {
+			certExpiry.Add(r.certExpiry.Sub(now).Hours()/24, labels...)
+		}
+		if t.typ == "http" {
+			statusCode.Add(float64(r.statusCode), "target", t.target)
+		}
+		if r.sent > 0 {
+			loss.Add(1-float64(len(r.rtts))/float64(r.sent), labels...)
+		}
+		if len(r.rtts) > 0 {
+			sorted := slices.Sorted(slices.Values(r.rtts))
+			for _, q := range rttQuantiles {
+				rtt.Add(quantile(sorted, q), slices.Concat(labels, []string{"quantile", strconv.FormatFloat(q, 'g', -1, 64)})...)
+			}
+		}
+		if d := r.dns; d != nil {
+			dnsLookup.Add(d.lookup, labels...)
+			dnsRcode.Add(float64(d.rcode), labels...)
+			dnsRecords.Add(float64(d.answers), slices.Concat(labels, []string{"section", "answer"})...)
+			dnsRecords.Add(float64(d.authorities), slices.Concat(labels, []string{"section", "authority"})...)
+			dnsRecords.Add(float64(d.additionals), slices.Concat(labels, []string{"section", "additional"})...)
+			if d.checked {
+				dnsMismatch.Add(boolValue(d.mismatch), labels...)
+			}
+		}
+	}
+	return []*metrics.Family{success, duration, phases, last, certExpiry, statusCode, loss, rtt, dnsLookup, dnsRcode, dnsRecords, dnsMismatch}, nil
+}
+
+// rttQuantiles probe_rtt_seconds 输出的分位数
+var rttQuantiles = []float64{0, 0.5, 0.9, 1}
+
+// quantile 按最近秩法计算已排序样本的分位数
+func quantile(sorted []float64, q float64) float64 {
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
+
+// run 执行一次探测并保存结果
+func (c *Probe) run(t *probeTarget, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	start := time.Now()
+	r := t.probe(ctx)
+	r.duration = time.Since(start).Seconds()
+	if r.err != nil {
+		slog.Debug("Probe failed", "type", t.typ, "target", t.target, "error", r.err)
+	}
+
+	c.mu.Lock()
+	t.result = &r
+	t.updated = time.Now()
+	t.done = nil
+	c.mu.Unlock()
+	close(done)
+}
@@ -0,0 +1,287 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsQueryTypes 支持的查询类型
+var dnsQueryTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"NS":    dnsmessage.TypeNS,
+	"PTR":   dnsmessage.TypePTR,
+	"SOA":   dnsmessage.TypeSOA,
+	"SRV":   dnsmessage.TypeSRV,
+	"TXT":   dnsmessage.TypeTXT,
+}
+
+// dnsUDPSize EDNS0 声明的 UDP 响应的最大长度，避免 IP 分片 (DNS Flag Day 2020)
+const dnsUDPSize = 1232
+
+// dnsResult 一次 DNS 查询的结果
+type dnsResult struct {
+	lookup      float64 // 发送查询到收到响应的耗时，单位秒
+	rcode       int
+	answers     int // 应答区的记录数
+	authorities int // 授权区的记录数
+	additionals int // 附加区的记录数，不含 EDNS0 的 OPT 记录
+	checked     bool
+	mismatch    bool // checked 时应答与期望不符
+}
+
+// dnsProbe 一个 DNS 探测目标，向 resolver 查询 name 的 qtype 记录
+type dnsProbe struct {
+	name      dnsmessage.Name
+	qtype     dnsmessage.Type
+	resolver  string // host:port
+	protocol  string // udp 或 tcp
+	recursion bool
+	expected  []string // 期望的应答，为空时不检查
+}
+
+// newDNSProbes 按配置创建 DNS 探测目标，每个目标向每个 resolver 各查询一次
+//
+// 目标的格式为 name[;type[;answer|answer...]]，type 覆盖 query_type，分号后为期望的应答；
+// resolvers 为空时使用 /etc/resolv.conf 中的 nameserver
+func newDNSProbes(cfg config.DNSProbeConfig) ([]*probeTarget, error) {
+	if len(cfg.Targets) == 0 {
+		return nil, nil
+	}
+	resolvers := slices.Clone(cfg.Resolvers)
+	if len(resolvers) == 0 {
+		var err error
+		if resolvers, err = systemResolvers("/etc/resolv.conf"); err != nil {
+			return nil, err
+		}
+	}
+	for i, resolver := range resolvers {
+		if _, _, err := net.SplitHostPort(resolver); err != nil {
+			resolvers[i] = net.JoinHostPort(strings.Trim(resolver, "[]"), "53")
+		}
+	}
+
+	var targets []*probeTarget
+	seen := make(map[string]bool)
+	for _, target := range cfg.Targets {
+		fields := strings.SplitN(target, ";", 3)
+		typeName := strings.ToUpper(cfg.QueryType)
+		if len(fields) > 1 && fields[1] != "" {
+			typeName = strings.ToUpper(fields[1])
+		}
+		qtype, ok := dnsQueryTypes[typeName]
+		if !ok {
+			return nil, fmt.Errorf("unsupported query type of dns probe target %q", target)
+		}
+		name, err := dnsmessage.NewName(strings.TrimSuffix(fields[0], ".") + ".")
+		if err != nil || strings.Trim(fields[0], ".") == "" {
+			return nil, fmt.Errorf("invalid dns probe target %q", target)
+		}
+		var expected []string
+		if len(fields) > 2 {
+			for answer := range strings.SplitSeq(fields[2], "|") {
+				expected = append(expected, normalizeDNSAnswer(answer))
+			}
+		}
+
+		for _, resolver := range resolvers {
+			key := strings.Join([]string{fields[0], typeName, resolver}, " ")
+			if seen[key] {
+				return nil, fmt.Errorf("duplicate dns probe target %s %s (resolver %s)", fields[0], typeName, resolver)
+			}
+			seen[key] = true
+			p := &dnsProbe{name: name, qtype: qtype, resolver: resolver, protocol: cfg.Protocol, recursion: cfg.Recursion, expected: expected}
+			targets = append(targets, &probeTarget{
+				typ:    "dns",
+				target: fields[0],
+				labels: []string{"query_type", typeName, "resolver", resolver},
+				probe:  p.probe,
+			})
+		}
+	}
+	return targets, nil
+}
+
+// probe 发送查询并解析响应，响应码为 NOERROR 且应答符合期望时成功；
+// 使用 UDP 时响应被截断 (TC) 则改用 TCP 重新查询
+func (p *dnsProbe) probe(ctx context.Context) probeResult {
+	var r probeResult
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: uint16(rand.Uint32()), RecursionDesired: p.recursion},
+		Questions: []dnsmessage.Question{{Name: p.name, Type: p.qtype, Class: dnsmessage.ClassINET}},
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(dnsUDPSize, dnsmessage.RCodeSuccess, false); err != nil {
+		r.err = err
+		return r
+	}
+	query.Additionals = []dnsmessage.Resource{{Header: opt, Body: &dnsmessage.OPTResource{}}}
+
+	start := time.Now()
+	resp, err := p.exchange(ctx, p.protocol, &query)
+	if err == nil && resp.Truncated && p.protocol == "udp" {
+		resp, err = p.exchange(ctx, "tcp", &query)
+	}
+	if err != nil {
+		r.err = err
+		return r
+	}
+
+	d := &dnsResult{
+		lookup:      time.Since(start).Seconds(),
+		rcode:       int(resp.RCode),
+		answers:     len(resp.Answers),
+		authorities: len(resp.Authorities),
+	}
+	for _, rr := range resp.Additionals {
+		if rr.Header.Type != dnsmessage.TypeOPT {
+			d.additionals++
+		}
+	}
+	r.dns = d
+	if len(p.expected) > 0 {
+		d.checked = true
+		d.mismatch = !p.matchAnswers(resp.Answers)
+	}
+	switch {
+	case resp.RCode != dnsmessage.RCodeSuccess:
+		r.err = fmt.Errorf("unexpected rcode %s", resp.RCode)
+	case d.mismatch:
+		r.err = fmt.Errorf("answers do not match %v", p.expected)
+	default:
+		r.success = true
+	}
+	return r
+}
+
+// exchange 通过 UDP 或 TCP 发送查询并返回 ID 和问题与查询相同的响应
+func (p *dnsProbe) exchange(ctx context.Context, network string, query *dnsmessage.Message) (*dnsmessage.Message, error) {
+	b, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack dns query: %w", err)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, p.resolver)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	var resp dnsmessage.Message
+	if network == "tcp" {
+		// TCP 的每个消息前有两个字节的长度
+		if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(b))), b...)); err != nil {
+			return nil, fmt.Errorf("failed to send dns query: %w", err)
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return nil, fmt.Errorf("failed to read dns response: %w", err)
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, fmt.Errorf("failed to read dns response: %w", err)
+		}
+		if err := resp.Unpack(buf); err != nil {
+			return nil, fmt.Errorf("failed to parse dns response: %w", err)
+		}
+		return &resp, nil
+	}
+
+	if _, err := conn.Write(b); err != nil {
+		return nil, fmt.Errorf("failed to send dns query: %w", err)
+	}
+	buf := make([]byte, dnsUDPSize)
+	for {
+		// 忽略 ID 或问题不符的报文 (如之前超时的查询的响应)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dns response: %w", err)
+		}
+		if resp.Unpack(buf[:n]) == nil && resp.Response && resp.ID == query.ID && slices.Equal(resp.Questions, query.Questions) {
+			return &resp, nil
+		}
+	}
+}
+
+// matchAnswers 判断应答区中查询类型的记录是否都在期望的应答中 (不要求包含全部期望的应答，以兼容轮询返回部分地址的服务)，
+// 没有查询类型的记录时不符
+func (p *dnsProbe) matchAnswers(answers []dnsmessage.Resource) bool {
+	found := false
+	for _, rr := range answers {
+		if rr.Header.Type != p.qtype {
+			continue
+		}
+		found = true
+		if !slices.Contains(p.expected, normalizeDNSAnswer(dnsAnswerString(rr.Body))) {
+			return false
+		}
+	}
+	return found
+}
+
+// dnsAnswerString 返回记录的值，与 dig +short 的输出相同
+func dnsAnswerString(body dnsmessage.ResourceBody) string {
+	switch b := body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(b.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return net.IP(b.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		return b.CNAME.String()
+	case *dnsmessage.NSResource:
+		return b.NS.String()
+	case *dnsmessage.PTRResource:
+		return b.PTR.String()
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", b.Pref, b.MX)
+	case *dnsmessage.SRVResource:
+		return fmt.Sprintf("%d %d %d %s", b.Priority, b.Weight, b.Port, b.Target)
+	case *dnsmessage.SOAResource:
+		return fmt.Sprintf("%s %s %d %d %d %d %d", b.NS, b.MBox, b.Serial, b.Refresh, b.Retry, b.Expire, b.MinTTL)
+	case *dnsmessage.TXTResource:
+		return strings.Join(b.TXT, "")
+	}
+	return ""
+}
+
+// normalizeDNSAnswer 统一应答的格式以便比较：忽略大小写、首尾空白和域名末尾的点，IP 地址转为标准格式
+func normalizeDNSAnswer(s string) string {
+	s = strings.TrimSpace(s)
+	if ip := net.ParseIP(s); ip != nil {
+		return ip.String()
+	}
+	return strings.ToLower(strings.TrimSuffix(s, "."))
+}
+
+// systemResolvers 返回 resolv.conf 中的 nameserver
+func systemResolvers(path string) ([]string, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dns resolvers: %w", err)
+	}
+	var resolvers []string
+	for _, line := range lines {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "nameserver" {
+			resolvers = append(resolvers, fields[1])
+		}
+	}
+	if len(resolvers) == 0 {
+		return nil, fmt.Errorf("no nameserver in %s", path)
+	}
+	return resolvers, nil
+}
@@ -0,0 +1,192 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNSServer 在同一端口上监听 UDP 和 TCP 的 DNS 服务器，
+// example.com 有两条 A 记录，big.example.com 通过 UDP 查询时返回截断的响应，其他域名返回 NXDOMAIN
+func fakeDNSServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	pc, err := net.ListenPacket("udp", ln.Addr().String())
+	if err != nil {
+		ln.Close()
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() {
+		ln.Close()
+		pc.Close()
+	})
+
+	answer := func(b []byte, udp bool) []byte {
+		var query dnsmessage.Message
+		if err := query.Unpack(b); err != nil || len(query.Questions) != 1 {
+			return nil
+		}
+		resp := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionDesired: query.RecursionDesired},
+			Questions: query.Questions,
+		}
+		q := query.Questions[0]
+		switch q.Name.String() {
+		case "example.com.", "big.example.com.":
+			if udp && q.Name.String() == "big.example.com." {
+				resp.Truncated = true
+				break
+			}
+			for _, ip := range [][4]byte{{10, 0, 0, 1}, {10, 0, 0, 2}} {
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET},
+					Body:   &dnsmessage.AResource{A: ip},
+				})
+			}
+		default:
+			resp.RCode = dnsmessage.RCodeNameError
+		}
+		out, _ := resp.Pack()
+		return out
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = pc.WriteTo(answer(buf[:n], true), addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var size [2]byte
+			if _, err := io.ReadFull(conn, size[:]); err == nil {
+				buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+				if _, err := io.ReadFull(conn, buf); err == nil {
+					out := answer(buf, false)
+					_, _ = conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(out))), out...))
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestProbeDNS(t *testing.T) {
+	resolver := fakeDNSServer(t)
+	cfg := config.DefaultConfig().Collector.Probe
+	cfg.DNS.Resolvers = []string{resolver}
+	cfg.DNS.Targets = []string{
+		"example.com;A;10.0.0.1|10.0.0.2|10.0.0.3",
+		"example.com;AAAA",
+		"www.example.com",
+		"big.example.com;;10.0.0.1",
+		"big.example.com;TXT;10.0.0.1",
+	}
+	c, err := NewProbe(cfg)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+
+	for _, tt := range []struct {
+		target, qtype string
+		success       float64
+		rcode         float64
+		answers       float64
+		mismatch      float64 // -1 表示不输出
+	}{
+		{"example.com", "A", 1, 0, 2, 0},
+		{"example.com", "AAAA", 1, 0, 2, -1},
+		{"www.example.com", "A", 0, 3, 0, -1},
+		// UDP 响应被截断，改用 TCP 查询；应答中的 10.0.0.2 不在期望的应答中
+		{"big.example.com", "A", 0, 0, 2, 1},
+		{"big.example.com", "TXT", 0, 0, 2, 1},
+	} {
+		labels := []string{"type", "dns", "target", tt.target, "query_type", tt.qtype, "resolver", resolver}
+		if got, _ := findMetric(families, "probe_success", labels...); got != tt.success {
+			t.Errorf("probe_success%v = %v, 期望 %v", labels, got, tt.success)
+		}
+		if got, _ := findMetric(families, "probe_dns_rcode", labels...); got != tt.rcode {
+			t.Errorf("probe_dns_rcode%v = %v, 期望 %v", labels, got, tt.rcode)
+		}
+		if got, _ := findMetric(families, "probe_dns_records", slices.Concat(labels, []string{"section", "answer"})...); got != tt.answers {
+			t.Errorf("probe_dns_records%v = %v, 期望 %v", labels, got, tt.answers)
+		}
+		got, ok := findMetric(families, "probe_dns_answer_mismatch", labels...)
+		if tt.mismatch < 0 && ok {
+			t.Errorf("未配置期望应答时不应输出 probe_dns_answer_mismatch%v", labels)
+		} else if tt.mismatch >= 0 && got != tt.mismatch {
+			t.Errorf("probe_dns_answer_mismatch%v = %v, 期望 %v", labels, got, tt.mismatch)
+		}
+		if _, ok := findMetric(families, "probe_dns_lookup_seconds", labels...); !ok {
+			t.Errorf("缺少 probe_dns_lookup_seconds%v", labels)
+		}
+	}
+}
+
+func TestNewDNSProbes(t *testing.T) {
+	cfg := config.DefaultConfig().Collector.Probe.DNS
+	cfg.Resolvers = []string{"10.0.0.53", "[fd00::53]", "10.0.0.54:5353"}
+	cfg.Targets = []string{"example.com", "example.com;aaaa;::1|::2"}
+	targets, err := newDNSProbes(cfg)
+	if err != nil {
+		t.Fatalf("创建探测目标失败: %v", err)
+	}
+	if len(targets) != 6 {
+		t.Fatalf("目标数 = %d, 期望 6 (每个域名向每个服务器各查询一次)", len(targets))
+	}
+	if got := targets[4].labels; !slices.Equal(got, []string{"query_type", "AAAA", "resolver", "[fd00::53]:53"}) {
+		t.Errorf("labels = %v", got)
+	}
+	if !slices.Equal(cfg.Resolvers, []string{"10.0.0.53", "[fd00::53]", "10.0.0.54:5353"}) {
+		t.Errorf("不应修改配置中的 resolvers: %v", cfg.Resolvers)
+	}
+
+	for _, targets := range [][]string{
+		{"example.com;ANY"},
+		{";A"},
+		{"example.com", "example.com;A"},
+	} {
+		cfg.Targets = targets
+		if _, err := newDNSProbes(cfg); err == nil {
+			t.Errorf("%v 应返回错误", targets)
+		}
+	}
+}
+
+func TestSystemResolvers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	data := "# generated\nsearch example.com\nnameserver 10.0.0.53\nnameserver fd00::53\noptions ndots:2\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := systemResolvers(path)
+	if err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if want := []string{"10.0.0.53", "fd00::53"}; !slices.Equal(got, want) {
+		t.Errorf("resolvers = %v, 期望 %v", got, want)
+	}
+}
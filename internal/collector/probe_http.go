@@ -0,0 +1,231 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+// statusRange HTTP 状态码的范围，包含两端
+type statusRange struct {
+	lo, hi int
+}
+
+// parseStatusRanges 解析逗号分隔的状态码、范围或类别，如 200,204、200-399、2xx
+func parseStatusRanges(spec string) ([]statusRange, error) {
+	var ranges []statusRange
+	for item := range strings.SplitSeq(spec, ",") {
+		item = strings.TrimSpace(item)
+		var r statusRange
+		var err error
+		switch lo, hi, isRange := strings.Cut(item, "-"); {
+		case len(item) == 3 && strings.HasSuffix(item, "xx"):
+			r.lo, err = strconv.Atoi(item[:1])
+			r.lo *= 100
+			r.hi = r.lo + 99
+		case isRange:
+			if r.lo, err = strconv.Atoi(lo); err == nil {
+				r.hi, err = strconv.Atoi(hi)
+			}
+		default:
+			r.lo, err = strconv.Atoi(item)
+			r.hi = r.lo
+		}
+		if err != nil || r.lo < 100 || r.hi > 599 || r.lo > r.hi {
+			return nil, fmt.Errorf("invalid status code %q", item)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// matchStatus 判断状态码是否在任一范围内
+func matchStatus(ranges []statusRange, code int) bool {
+	for _, r := range ranges {
+		if code >= r.lo && code <= r.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// httpProbe 一个 HTTP 探测目标
+type httpProbe struct {
+	client   *http.Client
+	method   string
+	headers  map[string]string
+	url      string
+	expected []statusRange
+}
+
+// newHTTPProbes 按配置创建 HTTP 探测目标，目标为 URL，分号后可指定该目标期望的状态码
+//
+// 所有目标共用一个不复用连接的客户端，每次探测都重新解析域名、建立连接和 TLS 握手，
+// 以便测量各阶段的耗时；不使用 HTTP_PROXY 等环境变量中的代理
+func newHTTPProbes(cfg config.HTTPProbeConfig) ([]*probeTarget, error) {
+	expected, err := parseStatusRanges(cfg.ExpectedStatus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse http expected_status: %w", err)
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			ForceAttemptHTTP2: true,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: cfg.TLSSkipVerify},
+		},
+	}
+	if !cfg.FollowRedirects {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	var targets []*probeTarget
+	seen := make(map[string]bool, len(cfg.Targets))
+	for _, target := range cfg.Targets {
+		rawURL, status, ok := strings.Cut(target, ";")
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid http probe target %q (expected http:// or https:// url)", target)
+		}
+		// URL 作为 target 标签，重复时时间序列冲突
+		if seen[rawURL] {
+			return nil, fmt.Errorf("duplicate http probe target %s", rawURL)
+		}
+		seen[rawURL] = true
+		p := &httpProbe{client: client, method: cfg.Method, headers: cfg.Headers, url: rawURL, expected: expected}
+		if ok {
+			if p.expected, err = parseStatusRanges(status); err != nil {
+				return nil, fmt.Errorf("failed to parse expected status of %s: %w", rawURL, err)
+			}
+		}
+		targets = append(targets, &probeTarget{typ: "http", target: rawURL, probe: p.probe})
+	}
+	return targets, nil
+}
+
+// probe 发送请求并读取完整的响应体，状态码符合期望时成功
+func (p *httpProbe) probe(ctx context.Context) probeResult {
+	var r probeResult
+	trace := &httpTrace{}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace.clientTrace()), p.method, p.url, nil)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	for k, v := range p.headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+		} else {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		r.err = err
+		r.phases = trace.phases(time.Time{})
+		return r
+	}
+	defer resp.Body.Close()
+	r.statusCode = resp.StatusCode
+	if resp.TLS != nil {
+		r.certExpiry = earliestExpiry(resp.TLS.PeerCertificates)
+	}
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		r.err = fmt.Errorf("failed to read response body: %w", err)
+		r.phases = trace.phases(time.Time{})
+		return r
+	}
+	r.phases = trace.phases(time.Now())
+	if !matchStatus(p.expected, resp.StatusCode) {
+		r.err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		return r
+	}
+	r.success = true
+	return r
+}
+
+// earliestExpiry 返回证书链中最早的过期时间
+func earliestExpiry(certs []*x509.Certificate) time.Time {
+	var earliest time.Time
+	for _, cert := range certs {
+		if earliest.IsZero() || cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+	return earliest
+}
+
+// httpTrace 记录 HTTP 请求各阶段的耗时，跟随重定向时累加各次请求的耗时
+//
+// 回调可能在不同的 goroutine 中执行 (如 Happy Eyeballs 并行建立连接)，因此需要加锁
+type httpTrace struct {
+	mu                                                 sync.Mutex
+	dnsStart, connectStart, tlsStart, wrote, firstByte time.Time
+	resolve, connect, tlsHandshake, processing         time.Duration
+}
+
+// clientTrace 返回记录耗时的 httptrace 回调
+func (t *httpTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.since(&t.resolve, &t.dnsStart) },
+		ConnectStart:      func(string, string) { t.mark(&t.connectStart) },
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.since(&t.tlsHandshake, &t.tlsStart) },
+		WroteRequest:      func(httptrace.WroteRequestInfo) { t.mark(&t.wrote) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.since(&t.connect, &t.connectStart)
+			}
+		},
+		GotFirstResponseByte: func() {
+			t.mark(&t.firstByte)
+			t.since(&t.processing, &t.wrote)
+		},
+	}
+}
+
+// mark 记录阶段开始的时间
+func (t *httpTrace) mark(at *time.Time) {
+	t.mu.Lock()
+	*at = time.Now()
+	t.mu.Unlock()
+}
+
+// since 将从 start 到现在的耗时累加到 d
+func (t *httpTrace) since(d *time.Duration, start *time.Time) {
+	t.mu.Lock()
+	if !start.IsZero() {
+		*d += time.Since(*start)
+	}
+	t.mu.Unlock()
+}
+
+// phases 返回各阶段的耗时，end 为读完响应体的时间，为零时不输出 transfer 阶段
+func (t *httpTrace) phases(end time.Time) []probePhase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	phases := []probePhase{
+		{"resolve", t.resolve.Seconds()},
+		{"connect", t.connect.Seconds()},
+		{"tls", t.tlsHandshake.Seconds()},
+		{"processing", t.processing.Seconds()},
+	}
+	if !end.IsZero() && !t.firstByte.IsZero() {
+		phases = append(phases, probePhase{"transfer", end.Sub(t.firstByte).Seconds()})
+	}
+	return phases
+}
@@ -0,0 +1,352 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ICMP 的协议号 (IANA)
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+)
+
+// pingProbe 一个 ICMP 或 TCP 连接探测目标，发送 count 个包 (建立 count 个连接)，统计往返时间和丢包率
+type pingProbe struct {
+	host     string // ICMP 为主机名或 IP，TCP 为 host:port
+	count    int
+	interval time.Duration // 相邻两个包的间隔
+	source   string        // 网卡名或源 IP，为空时按路由表选择
+}
+
+// newPingProbes 按配置创建 ICMP 和 TCP 连接探测目标，分号后可指定该目标的探测间隔；
+// 有目标时 count 和 packet_interval 必须为正数 (来自 flags 或环境变量的取值不经过 config validate)
+func newPingProbes(cfg config.PingProbeConfig) ([]*probeTarget, error) {
+	if len(cfg.ICMPTargets)+len(cfg.TCPTargets) > 0 {
+		if cfg.Count <= 0 {
+			return nil, fmt.Errorf("invalid ping probe count %d: must be positive", cfg.Count)
+		}
+		if cfg.PacketInterval <= 0 {
+			return nil, fmt.Errorf("invalid ping probe packet interval %s: must be positive", cfg.PacketInterval)
+		}
+	}
+	var targets []*probeTarget
+	seen := make(map[string]bool)
+	for _, group := range []struct {
+		typ     string
+		targets []string
+	}{
+		{"icmp", cfg.ICMPTargets},
+		{"tcp", cfg.TCPTargets},
+	} {
+		for _, target := range group.targets {
+			host, rawInterval, ok := strings.Cut(target, ";")
+			var interval time.Duration
+			if ok {
+				var err error
+				if interval, err = time.ParseDuration(rawInterval); err != nil || interval <= 0 {
+					return nil, fmt.Errorf("invalid interval of %s probe target %q", group.typ, target)
+				}
+			}
+			if group.typ == "tcp" {
+				if _, _, err := net.SplitHostPort(host); err != nil {
+					return nil, fmt.Errorf("invalid tcp probe target %q (expected host:port): %w", target, err)
+				}
+			}
+			if host == "" {
+				return nil, fmt.Errorf("invalid %s probe target %q", group.typ, target)
+			}
+			if seen[group.typ+" "+host] {
+				return nil, fmt.Errorf("duplicate %s probe target %s", group.typ, host)
+			}
+			seen[group.typ+" "+host] = true
+
+			p := &pingProbe{host: host, count: cfg.Count, interval: cfg.PacketInterval, source: cfg.SourceInterface}
+			probe := p.probeICMP
+			if group.typ == "tcp" {
+				probe = p.probeTCP
+			}
+			targets = append(targets, &probeTarget{typ: group.typ, target: host, interval: interval, probe: probe})
+		}
+	}
+	return targets, nil
+}
+
+// probeICMP 发送 ICMP echo 请求并等待回复，收到任一回复即为成功，超时未收到的回复计为丢包
+func (p *pingProbe) probeICMP(ctx context.Context) probeResult {
+	var r probeResult
+	start := time.Now()
+	ip, err := resolveProbeHost(ctx, p.host)
+	r.phases = []probePhase{{"resolve", time.Since(start).Seconds()}}
+	if err != nil {
+		r.err = err
+		return r
+	}
+	conn, raw, err := listenICMP(ip.To4() == nil, p.source)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetReadDeadline(deadline)
+	}
+
+	proto, typ, replyType := protocolICMP, icmp.Type(ipv4.ICMPTypeEcho), icmp.Type(ipv4.ICMPTypeEchoReply)
+	if ip.To4() == nil {
+		proto, typ, replyType = protocolIPv6ICMP, ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if !raw {
+		dst = &net.UDPAddr{IP: ip}
+	}
+	// 原始套接字收到本机所有的 ICMP 报文，按 ID 区分各次探测；数据报套接字的 ID 由内核分配，只收到本套接字的回复
+	id := rand.IntN(0xffff) + 1
+
+	type reply struct {
+		seq int
+		at  time.Time
+	}
+	replies := make(chan reply, p.count)
+	go func() {
+		defer close(replies)
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			at := time.Now()
+			msg, err := icmp.ParseMessage(proto, buf[:n])
+			if err != nil || msg.Type != replyType {
+				continue
+			}
+			echo, ok := msg.Body.(*icmp.Echo)
+			if !ok || (raw && echo.ID != id) || !addrIP(from).Equal(ip) {
+				continue
+			}
+			select {
+			case replies <- reply{seq: echo.Seq, at: at}:
+			default:
+			}
+		}
+	}()
+
+	sent := make([]time.Time, 0, p.count)
+	received := make(map[int]bool, p.count)
+	send := func() error {
+		msg := icmp.Message{Type: typ, Body: &icmp.Echo{ID: id, Seq: len(sent), Data: []byte("vm-metrics")}}
+		b, err := msg.Marshal(nil)
+		if err != nil {
+			return err
+		}
+		sent = append(sent, time.Now())
+		if _, err := conn.WriteTo(b, dst); err != nil {
+			return fmt.Errorf("failed to send icmp echo: %w", err)
+		}
+		return nil
+	}
+	if err := send(); err != nil {
+		r.err = err
+		return r
+	}
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+loop:
+	for len(received) < p.count {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+			if len(sent) < p.count {
+				if err := send(); err != nil {
+					r.err = err
+					break loop
+				}
+			}
+		case rep, ok := <-replies:
+			if !ok {
+				break loop
+			}
+			if rep.seq < len(sent) && !received[rep.seq] {
+				received[rep.seq] = true
+				r.rtts = append(r.rtts, rep.at.Sub(sent[rep.seq]).Seconds())
+			}
+		}
+	}
+	r.sent = len(sent)
+	r.success = len(r.rtts) > 0
+	if !r.success && r.err == nil {
+		r.err = fmt.Errorf("no icmp echo reply from %s", ip)
+	}
+	return r
+}
+
+// probeTCP 依次建立 TCP 连接并立即关闭，往返时间为建立连接的耗时，建立失败计为丢包
+func (p *pingProbe) probeTCP(ctx context.Context) probeResult {
+	var r probeResult
+	host, port, _ := net.SplitHostPort(p.host)
+	start := time.Now()
+	ip, err := resolveProbeHost(ctx, host)
+	r.phases = []probePhase{{"resolve", time.Since(start).Seconds()}}
+	if err != nil {
+		r.err = err
+		return r
+	}
+	dialer := &net.Dialer{}
+	if src := net.ParseIP(p.source); src != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: src}
+	} else if p.source != "" {
+		dialer.Control = bindToDevice(p.source)
+	}
+	addr := net.JoinHostPort(ip.String(), port)
+	var lastErr error
+	for i := range p.count {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(p.interval):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		r.sent++
+		begin := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		r.rtts = append(r.rtts, time.Since(begin).Seconds())
+		conn.Close()
+	}
+	r.success = len(r.rtts) > 0
+	if !r.success {
+		r.err = lastErr
+	}
+	return r
+}
+
+// resolveProbeHost 解析主机名，有多个地址时优先使用 IPv4 地址
+func resolveProbeHost(ctx context.Context, host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			return addr.IP, nil
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address for %s", host)
+	}
+	return addrs[0].IP, nil
+}
+
+// listenICMP 打开 ICMP 套接字，source 为源 IP 时绑定该地址，为网卡名时绑定该网卡 (SO_BINDTODEVICE)
+//
+// 优先使用原始套接字 (需要 CAP_NET_RAW)，无权限时使用非特权的 ICMP 数据报套接字
+// (需要 sysctl net.ipv4.ping_group_range 包含当前进程的组)，raw 表示使用的是原始套接字
+func listenICMP(ipv6 bool, source string) (conn net.PacketConn, raw bool, err error) {
+	network, family, proto := "ip4:icmp", syscall.AF_INET, protocolICMP
+	if ipv6 {
+		network, family, proto = "ip6:ipv6-icmp", syscall.AF_INET6, protocolIPv6ICMP
+	}
+	src := net.ParseIP(source)
+	device := ""
+	if src == nil {
+		device = source
+	}
+
+	lc := net.ListenConfig{Control: bindToDevice(device)}
+	address := ""
+	if src != nil {
+		address = src.String()
+	}
+	conn, err = lc.ListenPacket(context.Background(), network, address)
+	if err == nil {
+		return conn, true, nil
+	}
+	if !errors.Is(err, os.ErrPermission) {
+		return nil, false, fmt.Errorf("failed to open icmp socket: %w", err)
+	}
+
+	fd, err := syscall.Socket(family, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open icmp socket (raw socket requires CAP_NET_RAW, datagram socket requires net.ipv4.ping_group_range): %w", err)
+	}
+	var sa syscall.Sockaddr = &syscall.SockaddrInet4{}
+	if ipv6 {
+		sa6 := &syscall.SockaddrInet6{}
+		copy(sa6.Addr[:], src.To16())
+		sa = sa6
+	} else if src != nil {
+		sa4 := &syscall.SockaddrInet4{}
+		copy(sa4.Addr[:], src.To4())
+		sa = sa4
+	}
+	if device != "" {
+		if err := syscall.BindToDevice(fd, device); err != nil {
+			syscall.Close(fd)
+			return nil, false, fmt.Errorf("failed to bind icmp socket to %s: %w", device, err)
+		}
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, false, fmt.Errorf("failed to bind icmp socket: %w", err)
+	}
+	f := os.NewFile(uintptr(fd), "icmp")
+	defer f.Close()
+	conn, err = net.FilePacketConn(f)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open icmp socket: %w", err)
+	}
+	return conn, false, nil
+}
+
+// bindToDevice 返回将套接字绑定到网卡的 Control 函数，device 为空时不绑定
+func bindToDevice(device string) func(network, address string, c syscall.RawConn) error {
+	if device == "" {
+		return nil
+	}
+	return func(_, _ string, c syscall.RawConn) error {
+		var err error
+		if cerr := c.Control(func(fd uintptr) {
+			err = syscall.BindToDevice(int(fd), device)
+		}); cerr != nil {
+			return cerr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to bind to %s: %w", device, err)
+		}
+		return nil
+	}
+}
+
+// addrIP 返回地址中的 IP
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
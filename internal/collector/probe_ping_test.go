@@ -0,0 +1,159 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+func TestNewPingProbes(t *testing.T) {
+	cfg := config.DefaultConfig().Collector.Probe.Ping
+	cfg.ICMPTargets = []string{"10.0.0.1", "example.com;1m"}
+	cfg.TCPTargets = []string{"10.0.0.1:22;15s"}
+	targets, err := newPingProbes(cfg)
+	if err != nil {
+		t.Fatalf("创建探测目标失败: %v", err)
+	}
+	want := []struct {
+		typ, target string
+		interval    time.Duration
+	}{
+		{"icmp", "10.0.0.1", 0},
+		{"icmp", "example.com", time.Minute},
+		{"tcp", "10.0.0.1:22", 15 * time.Second},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("目标数 = %d, 期望 %d", len(targets), len(want))
+	}
+	for i, w := range want {
+		if got := targets[i]; got.typ != w.typ || got.target != w.target || got.interval != w.interval {
+			t.Errorf("目标 %d = {%s %s %v}, 期望 %v", i, got.typ, got.target, got.interval, w)
+		}
+	}
+
+	for _, modify := range []func(*config.PingProbeConfig){
+		func(c *config.PingProbeConfig) { c.ICMPTargets = []string{"10.0.0.1;soon"} },
+		func(c *config.PingProbeConfig) { c.ICMPTargets = []string{";1m"} },
+		func(c *config.PingProbeConfig) { c.ICMPTargets = []string{"10.0.0.1", "10.0.0.1;1m"} },
+		func(c *config.PingProbeConfig) { c.TCPTargets = []string{"10.0.0.1"} },
+		// 来自 flags 或环境变量的取值，ticker 的间隔不为正数时会 panic
+		func(c *config.PingProbeConfig) { c.ICMPTargets, c.Count = []string{"10.0.0.1"}, 0 },
+		func(c *config.PingProbeConfig) { c.TCPTargets, c.PacketInterval = []string{"10.0.0.1:22"}, 0 },
+	} {
+		bad := config.DefaultConfig().Collector.Probe.Ping
+		modify(&bad)
+		if _, err := newPingProbes(bad); err == nil {
+			t.Errorf("%+v 应返回错误", bad)
+		}
+	}
+	// 没有目标时不检查
+	if _, err := newPingProbes(config.PingProbeConfig{}); err != nil {
+		t.Errorf("没有目标时不应返回错误: %v", err)
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	// 关闭的端口，连接被拒绝
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	closed.Close()
+
+	cfg := config.DefaultConfig().Collector.Probe
+	cfg.Ping.Count = 3
+	cfg.Ping.PacketInterval = time.Millisecond
+	cfg.Ping.TCPTargets = []string{ln.Addr().String(), closed.Addr().String()}
+	c, err := NewProbe(cfg)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+
+	open := []string{"type", "tcp", "target", ln.Addr().String()}
+	if got, _ := findMetric(families, "probe_success", open...); got != 1 {
+		t.Errorf("probe_success = %v, 期望 1", got)
+	}
+	if got, _ := findMetric(families, "probe_packet_loss_ratio", open...); got != 0 {
+		t.Errorf("probe_packet_loss_ratio = %v, 期望 0", got)
+	}
+	for _, q := range []string{"0", "0.5", "0.9", "1"} {
+		if got, ok := findMetric(families, "probe_rtt_seconds", append(open, "quantile", q)...); !ok || got <= 0 {
+			t.Errorf("probe_rtt_seconds{quantile=%s} = %v, 期望大于 0", q, got)
+		}
+	}
+
+	refused := []string{"type", "tcp", "target", closed.Addr().String()}
+	if got, _ := findMetric(families, "probe_success", refused...); got != 0 {
+		t.Errorf("连接被拒绝时 probe_success = %v, 期望 0", got)
+	}
+	if got, _ := findMetric(families, "probe_packet_loss_ratio", refused...); got != 1 {
+		t.Errorf("连接被拒绝时 probe_packet_loss_ratio = %v, 期望 1", got)
+	}
+	if _, ok := findMetric(families, "probe_rtt_seconds", append(refused, "quantile", "0.5")...); ok {
+		t.Error("没有建立连接时不应输出 probe_rtt_seconds")
+	}
+}
+
+func TestProbeICMP(t *testing.T) {
+	conn, _, err := listenICMP(false, "")
+	if err != nil {
+		t.Skipf("无法打开 ICMP 套接字: %v", err)
+	}
+	conn.Close()
+
+	cfg := config.DefaultConfig().Collector.Probe
+	cfg.Ping.Count = 3
+	cfg.Ping.PacketInterval = 10 * time.Millisecond
+	cfg.Ping.ICMPTargets = []string{"127.0.0.1"}
+	c, err := NewProbe(cfg)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	labels := []string{"type", "icmp", "target", "127.0.0.1"}
+	if got, _ := findMetric(families, "probe_success", labels...); got != 1 {
+		t.Errorf("probe_success = %v, 期望 1", got)
+	}
+	if got, _ := findMetric(families, "probe_packet_loss_ratio", labels...); got != 0 {
+		t.Errorf("probe_packet_loss_ratio = %v, 期望 0", got)
+	}
+	if _, ok := findMetric(families, "probe_rtt_seconds", append(labels, "quantile", "1")...); !ok {
+		t.Error("缺少 probe_rtt_seconds")
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for q, want := range map[float64]float64{0: 1, 0.5: 5, 0.9: 9, 1: 10} {
+		if got := quantile(sorted, q); got != want {
+			t.Errorf("quantile(%v) = %v, 期望 %v", q, got, want)
+		}
+	}
+	if got := quantile([]float64{3}, 0.5); got != 3 {
+		t.Errorf("单个样本的 quantile(0.5) = %v, 期望 3", got)
+	}
+}
@@ -0,0 +1,191 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+func TestParseStatusRanges(t *testing.T) {
+	tests := []struct {
+		spec  string
+		match []int
+		miss  []int
+	}{
+		{"200", []int{200}, []int{201, 199}},
+		{"2xx", []int{200, 299}, []int{300, 199}},
+		{"200-399, 404", []int{200, 302, 399, 404}, []int{400, 500}},
+	}
+	for _, tt := range tests {
+		ranges, err := parseStatusRanges(tt.spec)
+		if err != nil {
+			t.Errorf("解析 %q 失败: %v", tt.spec, err)
+			continue
+		}
+		for _, code := range tt.match {
+			if !matchStatus(ranges, code) {
+				t.Errorf("%q 应匹配 %d", tt.spec, code)
+			}
+		}
+		for _, code := range tt.miss {
+			if matchStatus(ranges, code) {
+				t.Errorf("%q 不应匹配 %d", tt.spec, code)
+			}
+		}
+	}
+	for _, spec := range []string{"", "ok", "600", "399-200", "200-", "xxx"} {
+		if _, err := parseStatusRanges(spec); err == nil {
+			t.Errorf("解析 %q 应返回错误", spec)
+		}
+	}
+}
+
+func TestProbeHTTP(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/teapot", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	cfg := config.DefaultConfig().Collector.Probe
+	cfg.HTTP.TLSSkipVerify = true
+	cfg.HTTP.Headers = map[string]string{"Authorization": "Bearer token"}
+	cfg.HTTP.Targets = []string{srv.URL + "/ok", srv.URL + "/redirect", srv.URL + "/missing", srv.URL + "/teapot;400-499"}
+	c, err := NewProbe(cfg)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+
+	for _, tt := range []struct {
+		target  string
+		success float64
+		status  float64
+	}{
+		{srv.URL + "/ok", 1, 200},
+		{srv.URL + "/redirect", 1, 200},
+		{srv.URL + "/missing", 0, 404},
+		{srv.URL + "/teapot", 1, 418},
+	} {
+		labels := []string{"type", "http", "target", tt.target}
+		if got, _ := findMetric(families, "probe_success", labels...); got != tt.success {
+			t.Errorf("probe_success%v = %v, 期望 %v", labels, got, tt.success)
+		}
+		if got, _ := findMetric(families, "probe_http_status_code", "target", tt.target); got != tt.status {
+			t.Errorf("probe_http_status_code{target=%s} = %v, 期望 %v", tt.target, got, tt.status)
+		}
+	}
+
+	ok := []string{"type", "http", "target", srv.URL + "/ok"}
+	for _, phase := range []string{"connect", "tls", "processing", "transfer"} {
+		if _, found := findMetric(families, "probe_phase_duration_seconds", slices.Concat(ok, []string{"phase", phase})...); !found {
+			t.Errorf("缺少 %s 阶段的耗时", phase)
+		}
+	}
+	if got, _ := findMetric(families, "probe_duration_seconds", ok...); got <= 0 {
+		t.Errorf("probe_duration_seconds = %v, 期望大于 0", got)
+	}
+	// httptest 的证书在 2084 年过期
+	if got, _ := findMetric(families, "probe_tls_cert_expiry_days", ok...); got < 365 {
+		t.Errorf("probe_tls_cert_expiry_days = %v, 期望大于 365", got)
+	}
+}
+
+func TestProbeHTTPNoFollowRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.RedirectHandler("/elsewhere", http.StatusMovedPermanently))
+	defer srv.Close()
+
+	cfg := config.DefaultConfig().Collector.Probe
+	cfg.HTTP.FollowRedirects = false
+	cfg.HTTP.Targets = []string{srv.URL + ";301"}
+	c, err := NewProbe(cfg)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if got, _ := findMetric(families, "probe_success", "type", "http", "target", srv.URL); got != 1 {
+		t.Errorf("不跟随重定向时 301 应符合期望, probe_success = %v", got)
+	}
+	if _, ok := findMetric(families, "probe_tls_cert_expiry_days", "type", "http", "target", srv.URL); ok {
+		t.Error("HTTP 目标不应输出证书的剩余天数")
+	}
+}
+
+func TestProbeInterval(t *testing.T) {
+	var calls atomic.Int32
+	c := &Probe{interval: time.Hour, timeout: time.Second}
+	c.targets = []*probeTarget{{typ: "test", target: "a", probe: func(context.Context) probeResult {
+		calls.Add(1)
+		return probeResult{success: true}
+	}}}
+
+	for range 3 {
+		if _, err := c.Collect(context.Background()); err != nil {
+			t.Fatalf("采集失败: %v", err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("探测 %d 次, 期望 1 (未到 interval 时使用上次的结果)", n)
+	}
+
+	// 超过 interval 后重新探测，采集不等待
+	c.mu.Lock()
+	c.targets[0].started = c.targets[0].started.Add(-time.Hour)
+	c.mu.Unlock()
+	if _, err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	c.mu.Lock()
+	done := c.targets[0].done
+	c.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("探测 %d 次, 期望 2", n)
+	}
+}
+
+func TestProbeFirstRunTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	c := &Probe{interval: time.Hour, timeout: time.Minute}
+	c.targets = []*probeTarget{{typ: "test", target: "slow", probe: func(context.Context) probeResult {
+		<-block
+		return probeResult{success: true}
+	}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	families, err := c.Collect(ctx)
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if _, ok := findMetric(families, "probe_success", "type", "test", "target", "slow"); ok {
+		t.Error("第一次探测未完成的目标不应输出")
+	}
+}
@@ -0,0 +1,287 @@
+package collector
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// pfKthread /proc/<pid>/stat 中 flags 字段的内核线程标志，见 include/linux/sched.h
+const pfKthread = 0x00200000
+
+// /proc/<pid>/stat 中进程名之后各字段的下标 (man 5 proc 中的字段号减 3)
+const (
+	statFlags      = 6
+	statUtime      = 11
+	statStime      = 12
+	statNumThreads = 17
+	statStartTime  = 19
+	statRSS        = 21
+	statFields     = 22
+)
+
+// process 一次扫描中的进程
+type process struct {
+	pid        int
+	name       string
+	cmdline    string
+	startTime  uint64 // 进程启动时间 (tick)，与 pid 一起识别进程，避免 pid 复用
+	cpuSeconds float64
+	cpuRatio   float64 // 与上次采集之间的平均 CPU 使用率，首次出现时为 0
+	hasRatio   bool
+	rss        float64
+	threads    float64
+}
+
+// processSample 进程上次采集的 CPU 时间
+type processSample struct {
+	startTime  uint64
+	cpuSeconds float64
+}
+
+// Process 扫描 /proc 下的进程，输出匹配进程的 CPU、常驻内存、打开的文件描述符和线程数
+//
+// 进程按名称、命令行或 cgroup 匹配，均未配置时匹配所有用户态进程 (不含内核线程)。
+// 开启 top_n 时只输出 CPU 使用率和常驻内存各自排名前 N 的进程，
+// 输出的进程数最多为 max_processes，命令行标签截断到 cmdline_max_length，以限制时间序列数。
+// CPU 使用率为与上一次采集之间的平均值 (1 表示占满一个核心)，首次采集只记录基线
+type Process struct {
+	procPath   string
+	names      []string
+	cmdline    *regexp.Regexp // 为 nil 时不按命令行匹配
+	cgroup     *regexp.Regexp // 为 nil 时不按 cgroup 匹配
+	topN       int
+	max        int
+	cmdlineMax int
+	pageSize   float64
+	now        func() time.Time
+
+	mu       sync.Mutex
+	previous map[int]processSample
+	lastTime time.Time
+}
+
+// NewProcess 创建进程采集器，匹配用的正则表达式无效时返回错误
+func NewProcess(procPath string, cfg config.ProcessCollectorConfig) (*Process, error) {
+	c := &Process{
+		procPath:   procPath,
+		names:      cfg.Names,
+		topN:       cfg.TopN,
+		max:        cfg.MaxProcesses,
+		cmdlineMax: cfg.CmdlineMaxLength,
+		pageSize:   float64(os.Getpagesize()),
+		now:        time.Now,
+		previous:   make(map[int]processSample),
+	}
+	var err error
+	if c.cmdline, err = compilePattern("process cmdline_include", cfg.CmdlineInclude); err != nil {
+		return nil, err
+	}
+	if c.cgroup, err = compilePattern("process cgroup_include", cfg.CgroupInclude); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name 实现 metrics.Collector
+func (c *Process) Name() string {
+	return "process"
+}
+
+// Collect 实现 metrics.Collector
+func (c *Process) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	entries, err := os.ReadDir(c.procPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", c.procPath, err)
+	}
+
+	var processes []*process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// 扫描期间退出的进程读取失败，直接跳过
+		if p, ok := c.read(pid); ok {
+			processes = append(processes, p)
+		}
+	}
+	matched := len(processes)
+
+	c.mu.Lock()
+	now := c.now()
+	elapsed := now.Sub(c.lastTime).Seconds()
+	current := make(map[int]processSample, len(processes))
+	for _, p := range processes {
+		if prev, ok := c.previous[p.pid]; ok && prev.startTime == p.startTime && elapsed > 0 && p.cpuSeconds >= prev.cpuSeconds {
+			p.cpuRatio, p.hasRatio = (p.cpuSeconds-prev.cpuSeconds)/elapsed, true
+		}
+		current[p.pid] = processSample{p.startTime, p.cpuSeconds}
+	}
+	c.previous, c.lastTime = current, now
+	c.mu.Unlock()
+
+	processes = c.selectTop(processes)
+
+	cpu := metrics.NewFamily("process_cpu_seconds_total", "进程占用的 CPU 时间 (用户态和内核态之和)", metrics.Counter)
+	ratio := metrics.NewFamily("process_cpu_ratio", "进程的 CPU 使用率，1 表示占满一个核心", metrics.Gauge)
+	rss := metrics.NewFamily("process_resident_memory_bytes", "进程的常驻内存 (RSS)", metrics.Gauge)
+	fds := metrics.NewFamily("process_open_fds", "进程打开的文件描述符数，无权限读取时不输出", metrics.Gauge)
+	threads := metrics.NewFamily("process_threads", "进程的线程数", metrics.Gauge)
+	for _, p := range processes {
+		labels := []string{"pid", strconv.Itoa(p.pid), "name", p.name, "cmdline", p.cmdline}
+		cpu.Add(p.cpuSeconds, labels...)
+		if p.hasRatio {
+			ratio.Add(p.cpuRatio, labels...)
+		}
+		rss.Add(p.rss, labels...)
+		threads.Add(p.threads, labels...)
+		if n, err := os.ReadDir(procFile(c.procPath, strconv.Itoa(p.pid), "fd")); err == nil {
+			fds.Add(float64(len(n)), labels...)
+		}
+	}
+
+	matchedFamily := metrics.NewFamily("process_matched", "匹配的进程数，包括因 top_n 和 max_processes 未输出的进程", metrics.Gauge)
+	matchedFamily.Add(float64(matched))
+	return []*metrics.Family{cpu, ratio, rss, fds, threads, matchedFamily}, nil
+}
+
+// read 读取进程信息，进程不匹配、是内核线程或已退出时返回 false
+func (c *Process) read(pid int) (*process, bool) {
+	dir := strconv.Itoa(pid)
+	data, err := os.ReadFile(procFile(c.procPath, dir, "stat"))
+	if err != nil {
+		return nil, false
+	}
+	// pid (comm) state ...，进程名可能包含空格和括号，以最后一个右括号为界
+	stat := string(data)
+	open, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return nil, false
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < statFields {
+		return nil, false
+	}
+	values := make(map[int]uint64)
+	for _, i := range []int{statFlags, statUtime, statStime, statNumThreads, statStartTime, statRSS} {
+		v, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		values[i] = v
+	}
+	if values[statFlags]&pfKthread != 0 {
+		return nil, false
+	}
+
+	p := &process{
+		pid:        pid,
+		name:       stat[open+1 : end],
+		startTime:  values[statStartTime],
+		cpuSeconds: float64(values[statUtime]+values[statStime]) / userHZ,
+		rss:        float64(values[statRSS]) * c.pageSize,
+		threads:    float64(values[statNumThreads]),
+	}
+	cmdline, err := os.ReadFile(procFile(c.procPath, dir, "cmdline"))
+	if err != nil {
+		return nil, false
+	}
+	// 参数以 NUL 分隔，参数中的换行等空白也合并为一个空格，使标签保持单行
+	p.cmdline = strings.Join(strings.Fields(strings.ReplaceAll(string(cmdline), "\x00", " ")), " ")
+	if p.cmdline == "" {
+		p.cmdline = "[" + p.name + "]" // 僵尸进程没有命令行，与 ps 一致显示为 [进程名]
+	}
+	if !c.match(p, dir) {
+		return nil, false
+	}
+	p.cmdline = truncateRunes(p.cmdline, c.cmdlineMax)
+	return p, true
+}
+
+// match 判断进程是否匹配任一条件，未配置任何条件时匹配所有进程
+func (c *Process) match(p *process, dir string) bool {
+	if len(c.names) == 0 && c.cmdline == nil && c.cgroup == nil {
+		return true
+	}
+	if slices.Contains(c.names, p.name) {
+		return true
+	}
+	if c.cmdline != nil && c.cmdline.MatchString(p.cmdline) {
+		return true
+	}
+	if c.cgroup != nil {
+		lines, err := readLines(procFile(c.procPath, dir, "cgroup"))
+		if err != nil {
+			return false
+		}
+		return c.cgroup.MatchString(cgroupPath(lines))
+	}
+	return false
+}
+
+// selectTop 按 top_n 和 max_processes 选出要输出的进程，结果按 pid 排序
+func (c *Process) selectTop(processes []*process) []*process {
+	byCPU := slices.SortedStableFunc(slices.Values(processes), func(a, b *process) int {
+		return cmp.Compare(b.cpuRatio, a.cpuRatio)
+	})
+	selected := byCPU
+	if c.topN > 0 && len(processes) > c.topN {
+		byRSS := slices.SortedStableFunc(slices.Values(processes), func(a, b *process) int {
+			return cmp.Compare(b.rss, a.rss)
+		})
+		selected = slices.Clone(byCPU[:c.topN])
+		for _, p := range byRSS[:c.topN] {
+			if !slices.Contains(selected, p) {
+				selected = append(selected, p)
+			}
+		}
+	}
+	if c.max > 0 && len(selected) > c.max {
+		selected = selected[:c.max]
+	}
+	slices.SortFunc(selected, func(a, b *process) int { return cmp.Compare(a.pid, b.pid) })
+	return selected
+}
+
+// cgroupPath 从 /proc/<pid>/cgroup 中取出进程所在的 cgroup 路径，
+// 优先取 cgroup v2 的统一层级 (0::/path)，v1 时取第一个层级
+func cgroupPath(lines []string) string {
+	var first string
+	for _, line := range lines {
+		// hierarchy-ID:controllers:path
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			return parts[2]
+		}
+		if first == "" {
+			first = parts[2]
+		}
+	}
+	return first
+}
+
+// truncateRunes 将字符串截断到最多 n 个字符，n 不为正时不截断
+func truncateRunes(s string, n int) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,177 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// testProcStat 构造 /proc/<pid>/stat，utime/stime 单位为 tick，rss 单位为页
+func testProcStat(pid int, name string, flags, utime, stime, threads, rss uint64) string {
+	return fmt.Sprintf("%d (%s) S 1 %d %d 0 -1 %d 0 0 0 0 %d %d 0 0 20 0 %d 0 12345 100000 %d 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n",
+		pid, name, pid, pid, flags, utime, stime, threads, rss)
+}
+
+// writeTestProcess 在 proc 目录下写入一个进程的 stat、cmdline、cgroup 和 fds 个文件描述符
+func writeTestProcess(t *testing.T, dir string, pid int, name, cmdline, cgroup string, utime, rss uint64, fds int) {
+	t.Helper()
+	files := map[string]string{
+		fmt.Sprintf("%d/stat", pid):    testProcStat(pid, name, 0x400100, utime, 0, 4, rss),
+		fmt.Sprintf("%d/cmdline", pid): strings.ReplaceAll(cmdline, " ", "\x00") + "\x00",
+		fmt.Sprintf("%d/cgroup", pid):  "0::" + cgroup + "\n",
+	}
+	for i := range fds {
+		files[fmt.Sprintf("%d/fd/%d", pid, i)] = ""
+	}
+	writeProc(t, dir, files)
+}
+
+func TestProcessCollect(t *testing.T) {
+	dir := t.TempDir()
+	writeTestProcess(t, dir, 1, "systemd", "/sbin/init splash", "/init.scope", 100, 1000, 3)
+	writeTestProcess(t, dir, 200, "nginx", "nginx: worker process", "/system.slice/nginx.service", 50, 300, 5)
+	writeTestProcess(t, dir, 300, "my app (v2)", "/usr/bin/app --config /etc/app.yaml", "/user.slice", 10, 5000, 2)
+	// 内核线程没有命令行，按 PF_KTHREAD 标志排除
+	writeProc(t, dir, map[string]string{
+		"2/stat":    testProcStat(2, "kthreadd", 0x208040, 0, 0, 1, 0),
+		"2/cmdline": "",
+		"self":      "",
+	})
+
+	now := time.Unix(1700000000, 0)
+	cfg := config.DefaultConfig().Collector.Process
+	cfg.Enabled = true
+	c, err := NewProcess(dir, cfg)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	c.pageSize = 4096
+	c.now = func() time.Time { return now }
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	app := []string{"pid", "300", "name", "my app (v2)", "cmdline", "/usr/bin/app --config /etc/app.yaml"}
+	for name, want := range map[string]float64{
+		"process_cpu_seconds_total":     0.1,
+		"process_resident_memory_bytes": 5000 * 4096,
+		"process_open_fds":              2,
+		"process_threads":               4,
+	} {
+		if v, ok := findMetric(families, name, app...); !ok || v != want {
+			t.Errorf("%s = %v, %v, 期望 %v", name, v, ok, want)
+		}
+	}
+	if v, ok := findMetric(families, "process_matched"); !ok || v != 3 {
+		t.Errorf("匹配的进程数 = %v, %v, 期望 3 (不含内核线程)", v, ok)
+	}
+	if _, ok := findMetric(families, "process_cpu_ratio", app...); ok {
+		t.Error("首次采集不应输出 CPU 使用率")
+	}
+
+	// 10 秒内 nginx 使用 5 秒 CPU
+	writeTestProcess(t, dir, 200, "nginx", "nginx: worker process", "/system.slice/nginx.service", 550, 300, 5)
+	now = now.Add(10 * time.Second)
+	if families, err = c.Collect(context.Background()); err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	nginx := []string{"pid", "200", "name", "nginx", "cmdline", "nginx: worker process"}
+	if v, ok := findMetric(families, "process_cpu_ratio", nginx...); !ok || v != 0.5 {
+		t.Errorf("nginx CPU 使用率 = %v, %v, 期望 0.5", v, ok)
+	}
+}
+
+func TestProcessMatch(t *testing.T) {
+	dir := t.TempDir()
+	writeTestProcess(t, dir, 1, "systemd", "/sbin/init", "/init.scope", 0, 10, 0)
+	writeTestProcess(t, dir, 200, "nginx", "nginx: master process", "/system.slice/nginx.service", 0, 10, 0)
+	writeTestProcess(t, dir, 300, "python3", "python3 /opt/worker.py", "/user.slice", 0, 10, 0)
+	writeTestProcess(t, dir, 400, "postgres", "postgres -D /var/lib/postgresql", "/system.slice/postgresql.service", 0, 10, 0)
+
+	tests := []struct {
+		name string
+		cfg  config.ProcessCollectorConfig
+		want []string
+	}{
+		{"名称", config.ProcessCollectorConfig{Names: []string{"nginx", "systemd"}}, []string{"1", "200"}},
+		{"命令行", config.ProcessCollectorConfig{CmdlineInclude: `worker\.py`}, []string{"300"}},
+		{"cgroup", config.ProcessCollectorConfig{CgroupInclude: `^/system\.slice/`}, []string{"200", "400"}},
+		{"任一条件", config.ProcessCollectorConfig{Names: []string{"systemd"}, CmdlineInclude: "^postgres"}, []string{"1", "400"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewProcess(dir, tt.cfg)
+			if err != nil {
+				t.Fatalf("创建采集器失败: %v", err)
+			}
+			families, err := c.Collect(context.Background())
+			if err != nil {
+				t.Fatalf("采集失败: %v", err)
+			}
+			if got := processPIDs(families); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("匹配的进程 = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewProcess(dir, config.ProcessCollectorConfig{CgroupInclude: "("}); err == nil {
+		t.Error("无效的正则表达式应返回错误")
+	}
+}
+
+func TestProcessTopN(t *testing.T) {
+	dir := t.TempDir()
+	// pid 为 10-15 的进程，CPU 随 pid 递增，内存随 pid 递减
+	for i := range 6 {
+		pid := 10 + i
+		writeTestProcess(t, dir, pid, fmt.Sprintf("p%d", pid), fmt.Sprintf("/bin/p%d %s", pid, strings.Repeat("x", 200)), "/", 0, uint64(100-i), 0)
+	}
+	now := time.Unix(1700000000, 0)
+	c, err := NewProcess(dir, config.ProcessCollectorConfig{TopN: 2, MaxProcesses: 3, CmdlineMaxLength: 8})
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	c.now = func() time.Time { return now }
+	if _, err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	for i := range 6 {
+		pid := 10 + i
+		writeTestProcess(t, dir, pid, fmt.Sprintf("p%d", pid), fmt.Sprintf("/bin/p%d %s", pid, strings.Repeat("x", 200)), "/", uint64(i*10), uint64(100-i), 0)
+	}
+	now = now.Add(time.Second)
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	// CPU 前 2 为 15、14，内存前 2 为 10、11，受 max_processes 限制只保留 3 个
+	if got := processPIDs(families); strings.Join(got, ",") != "10,14,15" {
+		t.Errorf("输出的进程 = %v, 期望 [10 14 15]", got)
+	}
+	if v, ok := findMetric(families, "process_matched"); !ok || v != 6 {
+		t.Errorf("匹配的进程数 = %v, %v, 期望 6", v, ok)
+	}
+	if _, ok := findMetric(families, "process_threads", "pid", "15", "name", "p15", "cmdline", "/bin/p15"); !ok {
+		t.Error("cmdline 标签应截断到 8 个字符")
+	}
+}
+
+// processPIDs 返回 process_threads 中输出的 pid
+func processPIDs(families []*metrics.Family) []string {
+	var pids []string
+	for _, f := range families {
+		if !strings.HasSuffix(f.Name, "_process_threads") {
+			continue
+		}
+		for _, m := range f.Metrics {
+			pids = append(pids, m.Labels[0].Value)
+		}
+	}
+	return pids
+}
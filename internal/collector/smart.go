@@ -0,0 +1,322 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// smartctl 退出码的低两位表示命令行错误或无法打开设备，其余位表示磁盘的健康状态，输出仍然有效
+const smartctlFatalStatus = 0x3
+
+// smartWearAttributes 表示 SSD 剩余寿命的 ATA 属性，归一化值从 100 递减，
+// 各厂商使用的 ID 不同：177 Wear_Leveling_Count (三星)，231 SSD_Life_Left，233 Media_Wearout_Indicator (英特尔)
+var smartWearAttributes = []int{177, 231, 233}
+
+// smartDevice smartctl --scan-open 返回的设备
+type smartDevice struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// smartctlOutput smartctl --json --all 中用到的字段
+type smartctlOutput struct {
+	Smartctl struct {
+		ExitStatus int `json:"exit_status"`
+		Messages   []struct {
+			String string `json:"string"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+	Device struct {
+		Protocol string `json:"protocol"`
+	} `json:"device"`
+	ModelName       string `json:"model_name"`
+	SerialNumber    string `json:"serial_number"`
+	FirmwareVersion string `json:"firmware_version"`
+	SmartStatus     *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature *struct {
+		Current float64 `json:"current"`
+	} `json:"temperature"`
+	PowerOnTime *struct {
+		Hours float64 `json:"hours"`
+	} `json:"power_on_time"`
+	PowerCycleCount    *float64 `json:"power_cycle_count"`
+	ATASmartAttributes struct {
+		Table []struct {
+			ID     int     `json:"id"`
+			Name   string  `json:"name"`
+			Value  float64 `json:"value"`
+			Worst  float64 `json:"worst"`
+			Thresh float64 `json:"thresh"`
+			Raw    struct {
+				Value float64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	NVMeHealth *struct {
+		CriticalWarning  float64 `json:"critical_warning"`
+		AvailableSpare   float64 `json:"available_spare"`
+		PercentageUsed   float64 `json:"percentage_used"`
+		DataUnitsRead    float64 `json:"data_units_read"`
+		DataUnitsWritten float64 `json:"data_units_written"`
+		UnsafeShutdowns  float64 `json:"unsafe_shutdowns"`
+		MediaErrors      float64 `json:"media_errors"`
+	} `json:"nvme_smart_health_information_log"`
+	SCSIGrownDefectList *float64 `json:"scsi_grown_defect_list"`
+}
+
+// smartResult 一块磁盘最近一次读取的结果，err 不为 nil 时只输出 smart_device_scrape_success 为 0
+type smartResult struct {
+	device string
+	output *smartctlOutput
+	err    error
+}
+
+// SMART 通过 smartctl 采集磁盘的 SMART 健康状态、温度、通电时间、重映射扇区、SSD 磨损和 ATA 属性
+//
+// 读取 SMART 数据需要向每块磁盘发命令，机械盘上可能耗时数秒，因此在后台按 interval 刷新，
+// 采集时只输出最近一次的结果，不阻塞其他采集器；启动后的第一次采集等待第一轮刷新完成 (受采集的超时限制)。
+// 每块磁盘带 device 标签 (去掉 /dev/ 前缀的设备名，RAID 卡后的磁盘附加设备类型，如 bus/0/megaraid,0)
+type SMART struct {
+	smartctl string
+	devices  []smartDevice // 为空时通过 smartctl --scan-open 发现
+	interval time.Duration
+	timeout  time.Duration
+	run      func(ctx context.Context, args ...string) ([]byte, error) // 测试时替换
+
+	mu      sync.Mutex
+	results []smartResult
+	err     error         // 最近一次发现磁盘失败的错误
+	updated time.Time     // 最近一次刷新完成的时间，为零时尚未完成过刷新
+	done    chan struct{} // 进行中的刷新完成时关闭，为 nil 时没有进行中的刷新
+}
+
+// NewSMART 创建 SMART 采集器，devices 中分号后为 smartctl -d 的设备类型
+func NewSMART(cfg config.SMARTCollectorConfig) *SMART {
+	c := &SMART{smartctl: cfg.Smartctl, interval: cfg.Interval, timeout: cfg.Timeout}
+	if c.smartctl == "" {
+		c.smartctl = "smartctl"
+	}
+	for _, d := range cfg.Devices {
+		name, typ, _ := strings.Cut(d, ";")
+		c.devices = append(c.devices, smartDevice{Name: name, Type: typ})
+	}
+	c.run = c.exec
+	return c
+}
+
+// Name 实现 metrics.Collector
+func (c *SMART) Name() string {
+	return "smart"
+}
+
+// Collect 实现 metrics.Collector，结果距上次刷新超过 interval 时在后台开始新一轮刷新
+func (c *SMART) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	c.mu.Lock()
+	if c.done == nil && time.Since(c.updated) >= c.interval {
+		c.done = make(chan struct{})
+		go c.refresh(c.done)
+	}
+	done, first := c.done, c.updated.IsZero()
+	c.mu.Unlock()
+
+	if first {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("first smartctl run not finished: %w", ctx.Err())
+		}
+	}
+
+	c.mu.Lock()
+	results, updated, err := c.results, c.updated, c.err
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return smartFamilies(results, updated), nil
+}
+
+// refresh 依次读取各磁盘的 SMART 数据，完成后替换缓存的结果并关闭 done。
+// 与采集的 ctx 无关，每块磁盘单独受 timeout 限制，读取失败的磁盘每轮输出一次警告
+func (c *SMART) refresh(done chan struct{}) {
+	var results []smartResult
+	devices, err := c.scan()
+	for _, d := range devices {
+		r := c.read(d)
+		if r.err != nil {
+			slog.Warn("Failed to read SMART data", "device", r.device, "error", r.err)
+		}
+		results = append(results, r)
+	}
+
+	c.mu.Lock()
+	c.results, c.err, c.updated, c.done = results, err, time.Now(), nil
+	c.mu.Unlock()
+	close(done)
+}
+
+// scan 返回配置的磁盘，未配置时通过 smartctl --scan-open 发现
+func (c *SMART) scan() ([]smartDevice, error) {
+	if len(c.devices) > 0 {
+		return c.devices, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	out, err := c.run(ctx, "--json", "--scan-open")
+	if err != nil {
+		return nil, err
+	}
+	var scan struct {
+		Devices []smartDevice `json:"devices"`
+	}
+	if err := json.Unmarshal(out, &scan); err != nil {
+		return nil, fmt.Errorf("failed to decode smartctl scan output: %w", err)
+	}
+	return scan.Devices, nil
+}
+
+// read 读取一块磁盘的 SMART 数据
+func (c *SMART) read(d smartDevice) smartResult {
+	r := smartResult{device: strings.TrimPrefix(d.Name, "/dev/")}
+	args := []string{"--json", "--all"}
+	if d.Type != "" {
+		args = append(args, "--device", d.Type)
+		// 同一个 RAID 卡设备后有多块磁盘，用类型区分
+		if strings.Contains(d.Type, ",") {
+			r.device += "/" + d.Type
+		}
+	}
+	args = append(args, d.Name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	out, err := c.run(ctx, args...)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	var o smartctlOutput
+	if err := json.Unmarshal(out, &o); err != nil {
+		r.err = fmt.Errorf("failed to decode smartctl output for %s: %w", d.Name, err)
+		return r
+	}
+	if o.Smartctl.ExitStatus&smartctlFatalStatus != 0 {
+		msg := ""
+		if len(o.Smartctl.Messages) > 0 {
+			msg = o.Smartctl.Messages[0].String
+		}
+		r.err = fmt.Errorf("smartctl failed for %s (exit status %d): %s", d.Name, o.Smartctl.ExitStatus, msg)
+		return r
+	}
+	r.output = &o
+	return r
+}
+
+// exec 执行 smartctl，非零退出码只要有输出就交给调用方按 exit_status 判断
+func (c *SMART) exec(ctx context.Context, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, c.smartctl, args...).Output()
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && len(out) > 0) {
+		return nil, fmt.Errorf("failed to run %s: %w", c.smartctl, err)
+	}
+	return out, nil
+}
+
+// smartFamilies 将缓存的结果转换为指标
+func smartFamilies(results []smartResult, updated time.Time) []*metrics.Family {
+	refresh := metrics.NewFamily("smart_last_refresh_timestamp_seconds", "最近一次读取 SMART 数据完成的时间", metrics.Gauge)
+	success := metrics.NewFamily("smart_device_scrape_success", "最近一次读取磁盘的 SMART 数据是否成功", metrics.Gauge)
+	info := metrics.NewFamily("smart_device_info", "磁盘信息，值恒为 1", metrics.Gauge)
+	healthy := metrics.NewFamily("smart_device_healthy", "磁盘的 SMART 总体健康评估是否通过", metrics.Gauge)
+	temperature := metrics.NewFamily("smart_temperature_celsius", "磁盘的当前温度", metrics.Gauge)
+	powerOn := metrics.NewFamily("smart_power_on_seconds_total", "磁盘的累计通电时间 (按小时计)", metrics.Counter)
+	powerCycles := metrics.NewFamily("smart_power_cycles_total", "磁盘的通电次数", metrics.Counter)
+	reallocated := metrics.NewFamily("smart_reallocated_sectors", "已重映射的扇区数 (ATA 属性 5 或 SCSI 的 grown defect list)", metrics.Gauge)
+	wear := metrics.NewFamily("smart_wear_used_ratio", "SSD 已消耗的寿命比例，可能超过 1", metrics.Gauge)
+	attrValue := metrics.NewFamily("smart_attribute_value", "ATA SMART 属性的归一化值", metrics.Gauge)
+	attrWorst := metrics.NewFamily("smart_attribute_worst", "ATA SMART 属性归一化值的历史最差值", metrics.Gauge)
+	attrThreshold := metrics.NewFamily("smart_attribute_threshold", "ATA SMART 属性的失效阈值，归一化值低于阈值表示即将失效", metrics.Gauge)
+	attrRaw := metrics.NewFamily("smart_attribute_raw_value", "ATA SMART 属性的原始值，含义由厂商定义", metrics.Gauge)
+	nvmeWarning := metrics.NewFamily("smart_nvme_critical_warning", "NVMe 的严重警告位图，为 0 时正常", metrics.Gauge)
+	nvmeSpare := metrics.NewFamily("smart_nvme_available_spare_ratio", "NVMe 剩余的备用块比例", metrics.Gauge)
+	nvmeRead := metrics.NewFamily("smart_nvme_read_bytes_total", "NVMe 累计读取的字节数", metrics.Counter)
+	nvmeWritten := metrics.NewFamily("smart_nvme_written_bytes_total", "NVMe 累计写入的字节数", metrics.Counter)
+	nvmeUnsafe := metrics.NewFamily("smart_nvme_unsafe_shutdowns_total", "NVMe 的非正常断电次数", metrics.Counter)
+	nvmeMediaErrors := metrics.NewFamily("smart_nvme_media_errors_total", "NVMe 检测到的不可恢复的数据完整性错误数", metrics.Counter)
+	families := []*metrics.Family{
+		refresh, success, info, healthy, temperature, powerOn, powerCycles, reallocated, wear,
+		attrValue, attrWorst, attrThreshold, attrRaw,
+		nvmeWarning, nvmeSpare, nvmeRead, nvmeWritten, nvmeUnsafe, nvmeMediaErrors,
+	}
+	if updated.IsZero() {
+		return families
+	}
+	refresh.Add(float64(updated.UnixMilli()) / 1000)
+
+	for _, r := range results {
+		if r.err != nil {
+			success.Add(0, "device", r.device)
+			continue
+		}
+		success.Add(1, "device", r.device)
+		o, device := r.output, []string{"device", r.device}
+		info.Add(1, "device", r.device, "protocol", o.Device.Protocol, "model", o.ModelName, "serial", o.SerialNumber, "firmware", o.FirmwareVersion)
+		if o.SmartStatus != nil {
+			healthy.Add(boolValue(o.SmartStatus.Passed), device...)
+		}
+		if o.Temperature != nil {
+			temperature.Add(o.Temperature.Current, device...)
+		}
+		if o.PowerOnTime != nil {
+			powerOn.Add(o.PowerOnTime.Hours*3600, device...)
+		}
+		if o.PowerCycleCount != nil {
+			powerCycles.Add(*o.PowerCycleCount, device...)
+		}
+		if o.SCSIGrownDefectList != nil {
+			reallocated.Add(*o.SCSIGrownDefectList, device...)
+		}
+
+		wearAdded := false
+		for _, a := range o.ATASmartAttributes.Table {
+			labels := []string{"device", r.device, "id", strconv.Itoa(a.ID), "name", a.Name}
+			attrValue.Add(a.Value, labels...)
+			attrWorst.Add(a.Worst, labels...)
+			attrThreshold.Add(a.Thresh, labels...)
+			attrRaw.Add(a.Raw.Value, labels...)
+			if a.ID == 5 {
+				reallocated.Add(a.Raw.Value, device...)
+			}
+			if !wearAdded && slices.Contains(smartWearAttributes, a.ID) {
+				wear.Add((100-a.Value)/100, device...)
+				wearAdded = true
+			}
+		}
+
+		if h := o.NVMeHealth; h != nil {
+			wear.Add(h.PercentageUsed/100, device...)
+			nvmeWarning.Add(h.CriticalWarning, device...)
+			nvmeSpare.Add(h.AvailableSpare/100, device...)
+			// 数据单位为 1000 个 512 字节的块
+			nvmeRead.Add(h.DataUnitsRead*512000, device...)
+			nvmeWritten.Add(h.DataUnitsWritten*512000, device...)
+			nvmeUnsafe.Add(h.UnsafeShutdowns, device...)
+			nvmeMediaErrors.Add(h.MediaErrors, device...)
+		}
+	}
+	return families
+}
@@ -0,0 +1,207 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+const (
+	testSmartScan = `{"devices": [
+  {"name": "/dev/sda", "info_name": "/dev/sda [SAT]", "type": "sat", "protocol": "ATA"},
+  {"name": "/dev/nvme0", "info_name": "/dev/nvme0", "type": "nvme", "protocol": "NVMe"},
+  {"name": "/dev/bus/0", "info_name": "/dev/bus/0 [megaraid_disk_01]", "type": "megaraid,1", "protocol": "SCSI"}
+]}`
+	testSmartATA = `{
+  "smartctl": {"exit_status": 64},
+  "device": {"name": "/dev/sda", "type": "sat", "protocol": "ATA"},
+  "model_name": "Samsung SSD 870 EVO 1TB", "serial_number": "S6PTNX0T123456", "firmware_version": "SVT02B6Q",
+  "smart_status": {"passed": true},
+  "ata_smart_attributes": {"table": [
+    {"id": 5, "name": "Reallocated_Sector_Ct", "value": 100, "worst": 100, "thresh": 10, "raw": {"value": 3, "string": "3"}},
+    {"id": 9, "name": "Power_On_Hours", "value": 95, "worst": 95, "thresh": 0, "raw": {"value": 21000, "string": "21000"}},
+    {"id": 177, "name": "Wear_Leveling_Count", "value": 92, "worst": 92, "thresh": 0, "raw": {"value": 61, "string": "61"}}
+  ]},
+  "power_on_time": {"hours": 21000},
+  "power_cycle_count": 310,
+  "temperature": {"current": 34}
+}`
+	testSmartNVMe = `{
+  "smartctl": {"exit_status": 0},
+  "device": {"name": "/dev/nvme0", "type": "nvme", "protocol": "NVMe"},
+  "model_name": "INTEL SSDPE2KX040T8", "serial_number": "PHLJ000000004P0DGN", "firmware_version": "VDV10131",
+  "smart_status": {"passed": false},
+  "nvme_smart_health_information_log": {
+    "critical_warning": 4, "temperature": 41, "available_spare": 98, "available_spare_threshold": 10,
+    "percentage_used": 105, "data_units_read": 2000, "data_units_written": 1000,
+    "unsafe_shutdowns": 12, "media_errors": 0
+  },
+  "power_on_time": {"hours": 30000},
+  "temperature": {"current": 41}
+}`
+	testSmartOpenFailed = `{
+  "smartctl": {"exit_status": 2, "messages": [{"string": "Smartctl open device: /dev/bus/0 [megaraid_disk_01] failed: INQUIRY failed", "severity": "error"}]}
+}`
+)
+
+// fakeSmartctl 按参数返回固定输出，并记录调用
+type fakeSmartctl struct {
+	mu    sync.Mutex
+	calls [][]string
+	block chan struct{} // 不为 nil 时读取磁盘前等待
+}
+
+func (f *fakeSmartctl) run(ctx context.Context, args ...string) ([]byte, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, args)
+	f.mu.Unlock()
+	if slices.Contains(args, "--scan-open") {
+		return []byte(testSmartScan), nil
+	}
+	if f.block != nil {
+		<-f.block
+	}
+	switch args[len(args)-1] {
+	case "/dev/sda":
+		return []byte(testSmartATA), nil
+	case "/dev/nvme0":
+		return []byte(testSmartNVMe), nil
+	default:
+		return []byte(testSmartOpenFailed), nil
+	}
+}
+
+func (f *fakeSmartctl) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+func TestSMARTCollect(t *testing.T) {
+	c := NewSMART(config.DefaultConfig().Collector.SMART)
+	fake := &fakeSmartctl{}
+	c.run = fake.run
+
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if !slices.Equal(fake.calls[2], []string{"--json", "--all", "--device", "nvme", "/dev/nvme0"}) {
+		t.Errorf("smartctl 参数 = %v", fake.calls[2])
+	}
+
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"smart_device_scrape_success", []string{"device", "sda"}, 1},
+		{"smart_device_scrape_success", []string{"device", "bus/0/megaraid,1"}, 0},
+		{"smart_device_info", []string{"device", "sda", "protocol", "ATA", "model", "Samsung SSD 870 EVO 1TB", "serial", "S6PTNX0T123456", "firmware", "SVT02B6Q"}, 1},
+		{"smart_device_healthy", []string{"device", "sda"}, 1},
+		{"smart_device_healthy", []string{"device", "nvme0"}, 0},
+		{"smart_temperature_celsius", []string{"device", "sda"}, 34},
+		{"smart_power_on_seconds_total", []string{"device", "sda"}, 21000 * 3600},
+		{"smart_power_cycles_total", []string{"device", "sda"}, 310},
+		{"smart_reallocated_sectors", []string{"device", "sda"}, 3},
+		{"smart_wear_used_ratio", []string{"device", "sda"}, 0.08},
+		{"smart_attribute_value", []string{"device", "sda", "id", "177", "name", "Wear_Leveling_Count"}, 92},
+		{"smart_attribute_threshold", []string{"device", "sda", "id", "5", "name", "Reallocated_Sector_Ct"}, 10},
+		{"smart_attribute_raw_value", []string{"device", "sda", "id", "9", "name", "Power_On_Hours"}, 21000},
+		{"smart_wear_used_ratio", []string{"device", "nvme0"}, 1.05},
+		{"smart_nvme_critical_warning", []string{"device", "nvme0"}, 4},
+		{"smart_nvme_available_spare_ratio", []string{"device", "nvme0"}, 0.98},
+		{"smart_nvme_read_bytes_total", []string{"device", "nvme0"}, 2000 * 512000},
+		{"smart_nvme_unsafe_shutdowns_total", []string{"device", "nvme0"}, 12},
+	} {
+		got, ok := findMetric(families, tt.name, tt.labels...)
+		if !ok {
+			t.Errorf("缺少指标 %s%v", tt.name, tt.labels)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s%v = %v, 期望 %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+	if _, ok := findMetric(families, "smart_device_info", "device", "bus/0/megaraid,1"); ok {
+		t.Error("读取失败的磁盘不应输出 smart_device_info")
+	}
+}
+
+func TestSMARTBackgroundRefresh(t *testing.T) {
+	cfg := config.DefaultConfig().Collector.SMART
+	cfg.Devices = []string{"/dev/sda"}
+	c := NewSMART(cfg)
+	fake := &fakeSmartctl{}
+	c.run = fake.run
+
+	if _, err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	// 未到 interval 时使用缓存的结果
+	if _, err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if n := fake.count(); n != 1 {
+		t.Fatalf("smartctl 调用 %d 次, 期望 1 (配置了磁盘时不扫描)", n)
+	}
+
+	// 超过 interval 后在后台刷新，采集不等待 smartctl
+	fake.block = make(chan struct{})
+	c.mu.Lock()
+	c.updated = c.updated.Add(-cfg.Interval)
+	c.mu.Unlock()
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		families, err := c.Collect(context.Background())
+		if err != nil {
+			t.Errorf("采集失败: %v", err)
+		}
+		if got, _ := findMetric(families, "smart_temperature_celsius", "device", "sda"); got != 34 {
+			t.Errorf("刷新期间应输出上次的结果, smart_temperature_celsius = %v", got)
+		}
+	}()
+	select {
+	case <-collected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("采集等待了后台刷新")
+	}
+
+	c.mu.Lock()
+	done := c.done
+	c.mu.Unlock()
+	close(fake.block)
+	<-done
+	if n := fake.count(); n != 2 {
+		t.Errorf("smartctl 调用 %d 次, 期望 2", n)
+	}
+}
+
+func TestSMARTFirstRefreshTimeout(t *testing.T) {
+	c := NewSMART(config.DefaultConfig().Collector.SMART)
+	fake := &fakeSmartctl{block: make(chan struct{})}
+	c.run = fake.run
+	defer close(fake.block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Collect(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("第一次刷新未完成时应返回超时错误, 实际 %v", err)
+	}
+}
+
+func TestSMARTScanFailed(t *testing.T) {
+	c := NewSMART(config.DefaultConfig().Collector.SMART)
+	c.run = func(context.Context, ...string) ([]byte, error) {
+		return nil, errors.New(`failed to run smartctl: exec: "smartctl": executable file not found in $PATH`)
+	}
+	if _, err := c.Collect(context.Background()); err == nil {
+		t.Error("找不到 smartctl 时应返回错误")
+	}
+}
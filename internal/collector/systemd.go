@@ -0,0 +1,210 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// systemd 的 D-Bus 服务名、对象路径和接口
+const (
+	systemdDest      = "org.freedesktop.systemd1"
+	systemdPath      = "/org/freedesktop/systemd1"
+	systemdManager   = "org.freedesktop.systemd1.Manager"
+	systemdUnitIface = "org.freedesktop.systemd1.Unit"
+	systemdService   = "org.freedesktop.systemd1.Service"
+	systemdSocket    = "org.freedesktop.systemd1.Socket"
+)
+
+// systemdActiveStates systemd_unit_state 输出的状态 (ActiveState)
+var systemdActiveStates = []string{"active", "activating", "deactivating", "inactive", "failed", "reloading"}
+
+// systemdUnit ListUnits 返回的单元，字段顺序与 D-Bus 签名 (ssssssouso) 一致
+type systemdUnit struct {
+	Name        string
+	Description string
+	LoadState   string
+	ActiveState string
+	SubState    string
+	Followed    string
+	Path        dbus.ObjectPath
+	JobID       uint32
+	JobType     string
+	JobPath     dbus.ObjectPath
+}
+
+// systemdBus systemd 的 D-Bus 接口
+type systemdBus interface {
+	// listUnits 列出已加载的单元
+	listUnits(ctx context.Context) ([]systemdUnit, error)
+	// properties 读取单元在一个接口上的所有属性
+	properties(ctx context.Context, path dbus.ObjectPath, iface string) (map[string]dbus.Variant, error)
+	close()
+}
+
+// Systemd 通过 D-Bus 采集 systemd 单元的状态、服务的重启次数和启动耗时、套接字的连接数
+//
+// 每个单元带 unit (如 nginx.service) 和 type (如 service) 两个标签，只采集通过 unit_include 和 unit_exclude 过滤的单元，
+// systemd_units 按状态统计过滤后的单元数。每次采集建立一次 D-Bus 连接，systemd 重启后不需要重连
+type Systemd struct {
+	include *regexp.Regexp // 为 nil 时不限制
+	exclude *regexp.Regexp // 为 nil 时不排除
+	timeout time.Duration
+	connect func(ctx context.Context) (systemdBus, error) // 测试时替换
+}
+
+// NewSystemd 创建 systemd 单元采集器，单元过滤的正则表达式无效时返回错误
+func NewSystemd(cfg config.SystemdCollectorConfig) (*Systemd, error) {
+	c := &Systemd{timeout: cfg.Timeout}
+	c.connect = func(ctx context.Context) (systemdBus, error) {
+		return dialSystemd(ctx, cfg.Address)
+	}
+	var err error
+	if c.include, err = compilePattern("systemd unit_include", cfg.UnitInclude); err != nil {
+		return nil, err
+	}
+	if c.exclude, err = compilePattern("systemd unit_exclude", cfg.UnitExclude); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name 实现 metrics.Collector
+func (c *Systemd) Name() string {
+	return "systemd"
+}
+
+// Collect 实现 metrics.Collector
+func (c *Systemd) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	bus, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer bus.close()
+	units, err := bus.listUnits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state := metrics.NewFamily("systemd_unit_state", "单元的状态 (ActiveState)，当前状态为 1，其余为 0", metrics.Gauge)
+	counts := metrics.NewFamily("systemd_units", "各状态的单元数", metrics.Gauge)
+	restarts := metrics.NewFamily("systemd_service_restarts_total", "服务被 Restart= 自动重启的次数 (NRestarts，systemd 235 起提供)", metrics.Counter)
+	startDuration := metrics.NewFamily("systemd_service_start_duration_seconds", "服务最近一次从开始启动到进入 active 的耗时", metrics.Gauge)
+	accepted := metrics.NewFamily("systemd_socket_accepted_connections_total", "套接字接受的连接数 (Accept=yes 时)", metrics.Counter)
+	current := metrics.NewFamily("systemd_socket_current_connections", "套接字当前的连接数 (Accept=yes 时)", metrics.Gauge)
+	refused := metrics.NewFamily("systemd_socket_refused_connections_total", "套接字拒绝的连接数 (systemd 239 起提供)", metrics.Counter)
+
+	stateCounts := make(map[string]int, len(systemdActiveStates))
+	for _, u := range units {
+		if u.LoadState != "loaded" || !matchFilter(c.include, c.exclude, u.Name) {
+			continue
+		}
+		typ := strings.TrimPrefix(path.Ext(u.Name), ".")
+		labels := []string{"unit", u.Name, "type", typ}
+		for _, s := range systemdActiveStates {
+			state.Add(boolValue(u.ActiveState == s), slices.Concat(labels, []string{"state", s})...)
+		}
+		stateCounts[u.ActiveState]++
+
+		switch typ {
+		case "service":
+			props, err := bus.properties(ctx, u.Path, systemdService)
+			if err != nil {
+				slog.Debug("Failed to get systemd unit properties", "unit", u.Name, "error", err)
+				break
+			}
+			if v, ok := props["NRestarts"].Value().(uint32); ok {
+				restarts.Add(float64(v), labels...)
+			}
+			if u.ActiveState != "active" && u.ActiveState != "reloading" {
+				break
+			}
+			// 时间戳为单调时钟的微秒数，oneshot 等服务没有启动过程时两者相等
+			unit, err := bus.properties(ctx, u.Path, systemdUnitIface)
+			if err != nil {
+				slog.Debug("Failed to get systemd unit properties", "unit", u.Name, "error", err)
+				break
+			}
+			exit, ok1 := unit["InactiveExitTimestampMonotonic"].Value().(uint64)
+			enter, ok2 := unit["ActiveEnterTimestampMonotonic"].Value().(uint64)
+			if ok1 && ok2 && exit > 0 && enter >= exit {
+				startDuration.Add(float64(enter-exit)/1e6, labels...)
+			}
+		case "socket":
+			props, err := bus.properties(ctx, u.Path, systemdSocket)
+			if err != nil {
+				slog.Debug("Failed to get systemd unit properties", "unit", u.Name, "error", err)
+				break
+			}
+			if v, ok := props["NAccepted"].Value().(uint32); ok {
+				accepted.Add(float64(v), labels...)
+			}
+			if v, ok := props["NConnections"].Value().(uint32); ok {
+				current.Add(float64(v), labels...)
+			}
+			if v, ok := props["NRefused"].Value().(uint32); ok {
+				refused.Add(float64(v), labels...)
+			}
+		}
+	}
+	for _, s := range systemdActiveStates {
+		counts.Add(float64(stateCounts[s]), "state", s)
+	}
+	return []*metrics.Family{state, counts, restarts, startDuration, accepted, current, refused}, nil
+}
+
+// dbusSystemd 通过 godbus 连接的 systemd
+type dbusSystemd struct {
+	conn *dbus.Conn
+}
+
+// dialSystemd 连接 D-Bus，address 为空时连接系统总线
+func dialSystemd(ctx context.Context, address string) (*dbusSystemd, error) {
+	var conn *dbus.Conn
+	var err error
+	if address == "" {
+		conn, err = dbus.ConnectSystemBus(dbus.WithContext(ctx))
+	} else {
+		conn, err = dbus.Connect(address, dbus.WithContext(ctx))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to dbus: %w", err)
+	}
+	return &dbusSystemd{conn: conn}, nil
+}
+
+// listUnits 实现 systemdBus
+func (s *dbusSystemd) listUnits(ctx context.Context) ([]systemdUnit, error) {
+	var units []systemdUnit
+	err := s.conn.Object(systemdDest, systemdPath).CallWithContext(ctx, systemdManager+".ListUnits", 0).Store(&units)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list systemd units: %w", err)
+	}
+	return units, nil
+}
+
+// properties 实现 systemdBus
+func (s *dbusSystemd) properties(ctx context.Context, path dbus.ObjectPath, iface string) (map[string]dbus.Variant, error) {
+	var props map[string]dbus.Variant
+	err := s.conn.Object(systemdDest, path).CallWithContext(ctx, "org.freedesktop.DBus.Properties.GetAll", 0, iface).Store(&props)
+	if err != nil {
+		return nil, err
+	}
+	return props, nil
+}
+
+// close 实现 systemdBus
+func (s *dbusSystemd) close() {
+	s.conn.Close()
+}
@@ -0,0 +1,166 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+// fakeSystemd 返回固定单元和属性的 systemdBus
+type fakeSystemd struct {
+	units  []systemdUnit
+	props  map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	calls  int
+	closed bool
+}
+
+func (f *fakeSystemd) listUnits(context.Context) ([]systemdUnit, error) {
+	return f.units, nil
+}
+
+func (f *fakeSystemd) properties(_ context.Context, path dbus.ObjectPath, iface string) (map[string]dbus.Variant, error) {
+	f.calls++
+	props, ok := f.props[path][iface]
+	if !ok {
+		return nil, errors.New("org.freedesktop.DBus.Error.UnknownObject")
+	}
+	return props, nil
+}
+
+func (f *fakeSystemd) close() {
+	f.closed = true
+}
+
+func TestSystemdCollect(t *testing.T) {
+	bus := &fakeSystemd{
+		units: []systemdUnit{
+			{Name: "nginx.service", LoadState: "loaded", ActiveState: "active", Path: "/org/freedesktop/systemd1/unit/nginx_2eservice"},
+			{Name: "backup.service", LoadState: "loaded", ActiveState: "failed", Path: "/org/freedesktop/systemd1/unit/backup_2eservice"},
+			{Name: "sshd.socket", LoadState: "loaded", ActiveState: "active", Path: "/org/freedesktop/systemd1/unit/sshd_2esocket"},
+			{Name: "missing.service", LoadState: "not-found", ActiveState: "inactive", Path: "/org/freedesktop/systemd1/unit/missing_2eservice"},
+			{Name: "dev-sda1.device", LoadState: "loaded", ActiveState: "active", Path: "/org/freedesktop/systemd1/unit/dev_2dsda1_2edevice"},
+			{Name: "session-1.scope", LoadState: "loaded", ActiveState: "active", Path: "/org/freedesktop/systemd1/unit/session_2d1_2escope"},
+		},
+		props: map[dbus.ObjectPath]map[string]map[string]dbus.Variant{
+			"/org/freedesktop/systemd1/unit/nginx_2eservice": {
+				systemdService: {"NRestarts": dbus.MakeVariant(uint32(3))},
+				systemdUnitIface: {
+					"InactiveExitTimestampMonotonic": dbus.MakeVariant(uint64(5_000_000)),
+					"ActiveEnterTimestampMonotonic":  dbus.MakeVariant(uint64(6_250_000)),
+				},
+			},
+			"/org/freedesktop/systemd1/unit/backup_2eservice": {
+				systemdService: {"NRestarts": dbus.MakeVariant(uint32(0))},
+			},
+			"/org/freedesktop/systemd1/unit/sshd_2esocket": {
+				systemdSocket: {
+					"NAccepted":    dbus.MakeVariant(uint32(120)),
+					"NConnections": dbus.MakeVariant(uint32(2)),
+					"NRefused":     dbus.MakeVariant(uint32(1)),
+				},
+			},
+		},
+	}
+
+	c, err := NewSystemd(config.DefaultConfig().Collector.Systemd)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	c.connect = func(context.Context) (systemdBus, error) { return bus, nil }
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if !bus.closed {
+		t.Error("采集后应关闭 D-Bus 连接")
+	}
+
+	nginx := []string{"unit", "nginx.service", "type", "service"}
+	backup := []string{"unit", "backup.service", "type", "service"}
+	sshd := []string{"unit", "sshd.socket", "type", "socket"}
+	for _, tt := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"systemd_unit_state", append(nginx, "state", "active"), 1},
+		{"systemd_unit_state", append(nginx, "state", "failed"), 0},
+		{"systemd_unit_state", append(backup, "state", "failed"), 1},
+		{"systemd_units", []string{"state", "active"}, 2},
+		{"systemd_units", []string{"state", "failed"}, 1},
+		{"systemd_units", []string{"state", "inactive"}, 0},
+		{"systemd_service_restarts_total", nginx, 3},
+		{"systemd_service_restarts_total", backup, 0},
+		{"systemd_service_start_duration_seconds", nginx, 1.25},
+		{"systemd_socket_accepted_connections_total", sshd, 120},
+		{"systemd_socket_current_connections", sshd, 2},
+		{"systemd_socket_refused_connections_total", sshd, 1},
+	} {
+		got, ok := findMetric(families, tt.name, tt.labels...)
+		if !ok {
+			t.Errorf("缺少指标 %s%v", tt.name, tt.labels)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s%v = %v, 期望 %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+
+	// 未加载和被排除的单元不输出，未运行的服务不输出启动耗时
+	for _, tt := range []struct {
+		name   string
+		labels []string
+	}{
+		{"systemd_unit_state", []string{"unit", "missing.service", "type", "service", "state", "inactive"}},
+		{"systemd_unit_state", []string{"unit", "dev-sda1.device", "type", "device", "state", "active"}},
+		{"systemd_unit_state", []string{"unit", "session-1.scope", "type", "scope", "state", "active"}},
+		{"systemd_service_start_duration_seconds", backup},
+	} {
+		if _, ok := findMetric(families, tt.name, tt.labels...); ok {
+			t.Errorf("不应输出 %s%v", tt.name, tt.labels)
+		}
+	}
+}
+
+func TestSystemdUnitInclude(t *testing.T) {
+	bus := &fakeSystemd{units: []systemdUnit{
+		{Name: "nginx.service", LoadState: "loaded", ActiveState: "active"},
+		{Name: "cron.service", LoadState: "loaded", ActiveState: "active"},
+	}}
+	cfg := config.DefaultConfig().Collector.Systemd
+	cfg.UnitInclude = `^nginx\.service$`
+	c, err := NewSystemd(cfg)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	c.connect = func(context.Context) (systemdBus, error) { return bus, nil }
+	families, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if _, ok := findMetric(families, "systemd_unit_state", "unit", "cron.service", "type", "service", "state", "active"); ok {
+		t.Error("不匹配 unit_include 的单元不应输出")
+	}
+	if got, _ := findMetric(families, "systemd_units", "state", "active"); got != 1 {
+		t.Errorf("systemd_units{state=active} = %v, 期望 1", got)
+	}
+	if bus.calls != 1 {
+		t.Errorf("读取属性 %d 次, 期望 1 (只读取匹配的单元)", bus.calls)
+	}
+}
+
+func TestSystemdConnectFailed(t *testing.T) {
+	c, err := NewSystemd(config.DefaultConfig().Collector.Systemd)
+	if err != nil {
+		t.Fatalf("创建采集器失败: %v", err)
+	}
+	c.connect = func(ctx context.Context) (systemdBus, error) {
+		return dialSystemd(ctx, "unix:path="+t.TempDir()+"/missing.sock")
+	}
+	if _, err := c.Collect(context.Background()); err == nil {
+		t.Error("无法连接 D-Bus 时应返回错误")
+	}
+}
@@ -4,6 +4,7 @@ package command
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/lwmacct/251203-vm-metrics/internal/config"
@@ -30,9 +31,30 @@ func BeforeLoadConfig(ctx context.Context, cmd *cli.Command) (context.Context, e
 		cmd.Metadata = make(map[string]any)
 	}
 	cmd.Metadata[MetaKeyConfig] = cfg
+
+	// 记录使用过的服务器地址，供 endpoint 类 flag 补全；失败不影响命令执行
+	// 只在带 --server-url 的命令中记录，未修改的默认地址不记录
+	if hasFlag(cmd, "server-url") && cfg.Server.URL != Defaults.Server.URL {
+		_ = RecordEndpoint(appRawName(cmd), cfg.Server.URL)
+	}
 	return ctx, nil
 }
 
+// hasFlag 判断命令是否定义了名为 name 的 flag
+func hasFlag(cmd *cli.Command, name string) bool {
+	return slices.ContainsFunc(cmd.Flags, func(f cli.Flag) bool {
+		return slices.Contains(f.Names(), name)
+	})
+}
+
+// appRawName 返回应用名称，构建时未注入则使用根命令名称
+func appRawName(cmd *cli.Command) string {
+	if name := version.GetAppRawName(); name != "" && name != "Unknown" {
+		return name
+	}
+	return cmd.Root().Name
+}
+
 // GetConfig 从 cmd.Metadata 获取已加载的配置
 func GetConfig(cmd *cli.Command) *config.Config {
 	if cmd.Metadata == nil {
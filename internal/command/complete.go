@@ -0,0 +1,173 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/version"
+	"github.com/lwmacct/251203-vm-metrics/internal/vmapi"
+	"github.com/urfave/cli/v3"
+)
+
+// DynamicCompleter 动态补全器，在补全时运行并返回候选值
+// args 为补全脚本传入的上下文（如 fields 补全时的指标名）
+type DynamicCompleter func(ctx context.Context, cmd *cli.Command, args []string) ([]string, error)
+
+// 内置动态补全类型
+const (
+	dynamicFields      = "fields"       // 指标的标签名称，用于 --fields 投影
+	dynamicLabels      = "labels"       // 指标的标签名称，用于 --group-by 分组
+	dynamicAPIVersion  = "api-version"  // 支持的 API 版本，用于 --api-version
+	dynamicNamespace   = "namespace"    // namespace 标签的值，用于 --namespace
+	dynamicLabelValues = "label-values" // 指定标签的值，用于 --selector
+	dynamicInstances   = "instances"    // instance 标签的值，用于 --instance、--from/--to 等实例 flag
+)
+
+// apiVersions 本工具支持的 API 版本（Prometheus 兼容的 HTTP API）
+var apiVersions = []string{"v1"}
+
+// dynamicCompleters 已注册的动态补全器，key 为 __complete 的类型参数
+var dynamicCompleters = map[string]DynamicCompleter{
+	dynamicFields:      completeLabelNames,
+	dynamicLabels:      completeLabelNames,
+	dynamicAPIVersion:  completeAPIVersions,
+	dynamicNamespace:   completeNamespaces,
+	dynamicLabelValues: completeLabelValues,
+	dynamicInstances:   completeInstances,
+}
+
+// completeWordsEnv 补全脚本传入已输入单词的环境变量，单词以换行分隔
+// 从当前（最内层）子命令名称开始，到光标所在单词之前，包括位置参数和 flags
+const completeWordsEnv = "COMPLETE_WORDS"
+
+// completeWordsKey CompletionWords 在 context 中的 key
+type completeWordsKey struct{}
+
+// CompletionWords 返回补全时命令行上已输入的单词（子命令、位置参数和 flags）
+// 供需要完整上下文的 DynamicCompleter 使用，如按已选择的 <type> 位置参数补全字段；
+// 补全脚本未传入时返回 nil
+func CompletionWords(ctx context.Context) []string {
+	words, _ := ctx.Value(completeWordsKey{}).([]string)
+	return words
+}
+
+// RegisterDynamicCompleter 注册动态补全器，同名类型会被覆盖
+func RegisterDynamicCompleter(kind string, fn DynamicCompleter) {
+	dynamicCompleters[kind] = fn
+}
+
+// NewCompleteCommand 创建隐藏的 __complete 子命令
+// 补全脚本在补全时调用 `<root> __complete <type> [args...]`，每行输出一个候选值；
+// 类型 words 按运行中的命令树补全整个命令行，供 --runtime 生成的脚本使用
+func NewCompleteCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "__complete",
+		Usage:     "输出动态补全候选值 (供补全脚本调用)",
+		ArgsUsage: "<type> [args...]",
+		Hidden:    true,
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			kind := cmd.Args().First()
+			fn, ok := dynamicCompleters[kind]
+			if !ok {
+				return fmt.Errorf("unknown completion type: %s", kind)
+			}
+			if line := os.Getenv(completeWordsEnv); line != "" {
+				ctx = context.WithValue(ctx, completeWordsKey{}, strings.Split(line, "\n"))
+			}
+			values, err := fn(ctx, cmd, cmd.Args().Tail())
+			if err != nil {
+				return err
+			}
+			for _, v := range values {
+				fmt.Fprintln(cmd.Root().Writer, v)
+			}
+			return nil
+		},
+	}
+}
+
+// completeLabelNames 返回指标的标签名称
+// args[0] 为 --metric 的值，未指定时返回所有标签名称
+func completeLabelNames(ctx context.Context, cmd *cli.Command, args []string) ([]string, error) {
+	client, err := completionClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 0 || args[0] == "" {
+		result, err := client.Labels(ctx, time.Time{}, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		return result.Labels, nil
+	}
+
+	result, err := client.Series(ctx, []string{args[0]}, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var fields []string
+	for _, series := range result.Series {
+		for name := range series {
+			if name == "__name__" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// completeNamespaces 返回 namespace 标签的所有值
+func completeNamespaces(ctx context.Context, cmd *cli.Command, args []string) ([]string, error) {
+	return completeLabelValues(ctx, cmd, []string{"namespace"})
+}
+
+// completeInstances 返回 instance 标签的所有值
+func completeInstances(ctx context.Context, cmd *cli.Command, args []string) ([]string, error) {
+	return completeLabelValues(ctx, cmd, []string{"instance"})
+}
+
+// completeLabelValues 返回标签的所有值
+// args[0] 为标签名称，未指定时没有候选
+func completeLabelValues(ctx context.Context, cmd *cli.Command, args []string) ([]string, error) {
+	if len(args) == 0 || args[0] == "" {
+		return nil, nil
+	}
+	client, err := completionClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+	result, err := client.LabelValues(ctx, args[0], time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return result.Values, nil
+}
+
+// completionClient 创建动态补全使用的客户端
+// __complete 不经过 BeforeLoadConfig，未加载配置时按 --config 加载
+func completionClient(cmd *cli.Command) (vmapi.Client, error) {
+	cfg := GetConfig(cmd.Root())
+	if cfg == nil {
+		var err error
+		if cfg, err = config.Load(cmd, cmd.String("config"), version.GetAppRawName()); err != nil {
+			return nil, err
+		}
+	}
+	return NewClient(cfg)
+}
+
+// completeAPIVersions 返回支持的 API 版本
+// 需要从服务端发现版本时可通过 RegisterDynamicCompleter 替换
+func completeAPIVersions(ctx context.Context, cmd *cli.Command, args []string) ([]string, error) {
+	return apiVersions, nil
+}
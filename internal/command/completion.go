@@ -2,22 +2,29 @@ package command
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/urfave/cli/v3"
 )
 
 // NewCompletionCommand 创建 completion 子命令
-// 自动从传入的 rootCmd 生成 zsh 补全脚本
+// 自动从传入的 rootCmd 生成 zsh、bash、fish 或 PowerShell 补全脚本
 func NewCompletionCommand(rootCmd *cli.Command) *cli.Command {
 	return &cli.Command{
 		Name:   "completion",
-		Usage:  "生成 zsh 补全脚本",
+		Usage:  "生成 zsh、bash、fish 或 PowerShell 补全脚本",
 		Hidden: true, // 不在帮助中显示，也不出现在补全列表
-		Description: fmt.Sprintf(`生成 zsh 补全脚本。
+		Description: fmt.Sprintf(`生成 zsh、bash、fish 或 PowerShell 补全脚本。
 
 启用补全:
 
@@ -31,78 +38,654 @@ func NewCompletionCommand(rootCmd *cli.Command) *cli.Command {
 
   # 重新加载 zsh
   exec zsh
-`, rootCmd.Name, rootCmd.Name),
+
+也可以使用 install 子命令直接安装，或追加到共享的补全文件:
+
+  %s completion install
+  %s completion install --append ~/.zsh/completions.zsh
+
+bash 用户使用 --shell bash 生成，并在 ~/.bashrc 中加载:
+
+  echo 'source <(%s completion --shell bash)' >> ~/.bashrc
+
+fish 用户写入 fish 的补全目录:
+
+  %s completion --shell fish > ~/.config/fish/completions/%s.fish
+
+PowerShell 用户在 $PROFILE 中加载:
+
+  %s completion --shell powershell | Out-String | Invoke-Expression
+
+打包时可一次生成所有 shell 的补全脚本到目录:
+
+  %s completion --shell all --output ./completions
+`, rootCmd.Name, rootCmd.Name, rootCmd.Name, rootCmd.Name, rootCmd.Name, rootCmd.Name, rootCmd.Name, rootCmd.Name, rootCmd.Name),
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "annotated",
+				Usage: "在脚本中输出解释性注释 (用于学习和调试)",
+			},
+			&cli.BoolFlag{
+				Name:  "hide-deprecated",
+				Usage: "不补全已弃用的 flag",
+			},
+			&cli.BoolFlag{
+				Name:  "checksum",
+				Usage: "在脚本末尾追加 sha256 校验和注释 (可用 verify 子命令校验)",
+			},
+			&cli.BoolFlag{
+				Name:  "names-only",
+				Usage: "只补全命令和 flag 名称，不补全 flag 值",
+			},
+			&cli.BoolFlag{
+				Name:  "include-feature-gated",
+				Usage: "包含受特性开关控制的命令 (仅在对应环境变量非空时补全)",
+			},
+			&cli.BoolFlag{
+				Name:  "strict",
+				Usage: "同名 flag 定义不一致时输出警告",
+			},
+			&cli.StringFlag{
+				Name:  "shell",
+				Usage: "目标 shell: " + strings.Join(append(shellNames(), shellAll), ", "),
+				Value: "zsh",
+			},
+			&cli.StringFlag{
+				Name:  "locale",
+				Usage: "补全脚本内部文本 (help 说明、分组标签) 的语言: " + strings.Join(locales(), ", "),
+				Value: LocaleZh,
+			},
+			&cli.StringFlag{
+				Name:  "output",
+				Usage: "以约定文件名写入的目录 (--shell all 时必需)",
+			},
+			&cli.BoolFlag{
+				Name:  "runtime",
+				Usage: "生成运行时补全脚本，补全时调用 __complete 获取候选 (命令树变化后无需重新生成，仅 zsh、bash)",
+			},
+			&cli.BoolFlag{
+				Name:  "cache",
+				Usage: "使用缓存的脚本 (命令树变化时自动重新生成)，适合在 shell 启动时调用",
+			},
+		},
+		Commands: []*cli.Command{
+			newCompletionInstallCommand(rootCmd),
+			newCompletionSpecCommand(rootCmd),
+			newCompletionFromSpecCommand(),
+			newCompletionVerifyCommand(),
+			newCompletionAuditCommand(rootCmd),
+			newCompletionExplainCommand(rootCmd),
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			opts := GenerateOptions{
+				Annotated:           cmd.Bool("annotated"),
+				HideDeprecated:      cmd.Bool("hide-deprecated"),
+				Checksum:            cmd.Bool("checksum"),
+				Strict:              cmd.Bool("strict"),
+				NamesOnly:           cmd.Bool("names-only"),
+				IncludeFeatureGated: cmd.Bool("include-feature-gated"),
+				Locale:              cmd.String("locale"),
+				Runtime:             cmd.Bool("runtime"),
+			}
+			spec := BuildCompletionSpec(rootCmd, opts)
+			shell := cmd.String("shell")
+
+			if dir := cmd.String("output"); dir != "" {
+				paths, err := writeCompletionFiles(dir, shell, spec, opts)
+				if err != nil {
+					return err
+				}
+				for _, p := range paths {
+					fmt.Printf("已生成补全脚本: %s\n", p)
+				}
+				return nil
+			}
+
+			if shell == shellAll {
+				return fmt.Errorf("--shell all requires --output <dir>")
+			}
+			g, err := findShellGenerator(shell)
+			if err != nil {
+				return err
+			}
+			if cmd.Bool("cache") {
+				key, err := completionCacheKey(spec, shell, opts)
+				if err != nil {
+					return err
+				}
+				dir, err := completionCacheDir(spec.App)
+				if err != nil {
+					return err
+				}
+				return writeCachedCompletion(os.Stdout, dir, shell, key, func(w io.Writer) error {
+					return g.generate(w, spec, opts)
+				})
+			}
+			return g.generate(os.Stdout, spec, opts)
+		},
+	}
+}
+
+// newCompletionInstallCommand 创建 completion install 子命令
+// 默认写入 ~/.zsh/completions/_<name>，--append 时合并到共享补全文件
+func newCompletionInstallCommand(rootCmd *cli.Command) *cli.Command {
+	return &cli.Command{
+		Name:  "install",
+		Usage: "安装 zsh 补全脚本",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "append",
+				Usage: "追加到共享补全文件路径 (已存在的区块会被替换)",
+			},
+			&cli.StringFlag{
+				Name:  "binary",
+				Usage: "安装后的可执行文件路径，以其文件名作为补全的程序名 (用于重命名或符号链接的安装)",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			spec := BuildCompletionSpec(rootCmd, GenerateOptions{})
+			if binary := cmd.String("binary"); binary != "" {
+				spec.Root.Name = programName(binary)
+			}
+			var sb strings.Builder
+			if err := GenerateZshFromSpec(&sb, spec, GenerateOptions{}); err != nil {
+				return err
+			}
+
+			if path := cmd.String("append"); path != "" {
+				if err := appendCompletionFile(path, spec.Root.Name, sb.String()); err != nil {
+					return err
+				}
+				fmt.Printf("已更新 %s 中的 %s 补全区块\n", path, spec.Root.Name)
+				return nil
+			}
+
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("failed to get home dir: %w", err)
+			}
+			path := completionInstallPath(home, spec.Root.Name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("failed to create completion dir: %w", err)
+			}
+			if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+				return fmt.Errorf("failed to write completion file: %w", err)
+			}
+			fmt.Printf("已安装补全脚本: %s\n", path)
+			return nil
+		},
+	}
+}
+
+// programName 返回可执行文件路径对应的程序名（文件名）
+func programName(binary string) string {
+	return filepath.Base(binary)
+}
+
+// completionInstallPath 返回程序的 zsh 补全脚本安装路径
+func completionInstallPath(home, name string) string {
+	return filepath.Join(home, ".zsh", "completions", "_"+name)
+}
+
+// newCompletionSpecCommand 创建 completion spec 子命令
+// 以 JSON 导出补全规格，供 from-spec 或外部工具使用
+func newCompletionSpecCommand(rootCmd *cli.Command) *cli.Command {
+	return &cli.Command{
+		Name:  "spec",
+		Usage: "以 JSON 格式导出补全规格",
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(BuildCompletionSpec(rootCmd, GenerateOptions{}))
+		},
+	}
+}
+
+// newCompletionFromSpecCommand 创建 completion from-spec 子命令
+// 从导出的 JSON 补全规格生成脚本，无需运行中的命令树
+func newCompletionFromSpecCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "from-spec",
+		Usage:     "从 JSON 补全规格生成补全脚本",
+		ArgsUsage: "<spec.json>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "shell",
+				Usage: "目标 shell: " + strings.Join(shellNames(), ", "),
+				Value: "zsh",
+			},
+		},
 		Action: func(ctx context.Context, cmd *cli.Command) error {
-			return GenerateZsh(os.Stdout, rootCmd)
+			path := cmd.Args().First()
+			if path == "" {
+				return fmt.Errorf("spec file is required")
+			}
+			spec, err := ReadCompletionSpec(path)
+			if err != nil {
+				return err
+			}
+
+			g, err := findShellGenerator(cmd.String("shell"))
+			if err != nil {
+				return err
+			}
+			return g.generate(os.Stdout, spec, GenerateOptions{})
 		},
 	}
 }
 
+// completionBlockMarkers 返回共享补全文件中本工具区块的起止标记
+func completionBlockMarkers(name string) (begin, end string) {
+	return fmt.Sprintf("# >>> %s zsh completion >>>", name),
+		fmt.Sprintf("# <<< %s zsh completion <<<", name)
+}
+
+// appendCompletionFile 将补全脚本合并到共享补全文件
+// 文件不存在时创建；其他工具的内容保持不变
+func appendCompletionFile(path, name, script string) error {
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	merged := mergeCompletionBlock(string(content), name, script)
+	if err := os.WriteFile(path, []byte(merged), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// mergeCompletionBlock 在 content 中插入或替换本工具的补全区块
+// 区块由起止标记包围，重复执行结果一致（幂等）
+func mergeCompletionBlock(content, name, script string) string {
+	begin, end := completionBlockMarkers(name)
+	block := begin + "\n" + strings.TrimRight(script, "\n") + "\n" + end + "\n"
+
+	// 已存在区块：原地替换
+	if start := strings.Index(content, begin); start != -1 {
+		if stop := strings.Index(content[start:], end); stop != -1 {
+			stop += start + len(end)
+			// 吞掉结束标记后的换行，避免重复替换时累积空行
+			if stop < len(content) && content[stop] == '\n' {
+				stop++
+			}
+			return content[:start] + block + content[stop:]
+		}
+	}
+
+	// 不存在：追加到末尾，与前面的内容空一行
+	if content != "" {
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += "\n"
+	}
+	return content + block
+}
+
+// GenerateOptions 补全脚本生成选项
+type GenerateOptions struct {
+	// Annotated 在脚本中穿插解释性注释（各函数用途、flag 的补全推断依据），
+	// 用于学习或调试 zsh 补全，默认关闭以保持输出精简
+	Annotated bool
+
+	// EnumProviders 按 flag 名称提供权威候选值（如代码中定义的常量集合），
+	// 生成时调用，优先于从 Usage 解析的枚举和其他推断
+	EnumProviders map[string]func() []string
+
+	// HideDeprecated 隐藏 Usage 以 [DEPRECATED] 开头的 flag；
+	// 默认保留并标注 (deprecated)，排在其他 flag 之后
+	HideDeprecated bool
+
+	// Checksum 在脚本末尾追加 sha256 校验和注释（不含该行本身），
+	// 用于检测安装后的脚本是否被篡改
+	Checksum bool
+
+	// Indent 每级缩进使用的字符串，为空时使用 4 个空格；
+	// 用于匹配共享补全仓库的 shell 风格规范（如 tab 缩进）
+	Indent string
+
+	// Strict 严格模式：同一命令中名称相同但定义不同的 flag 输出警告，
+	// 用于发现重复定义的 bug；无论是否开启都只保留第一个定义
+	Strict bool
+
+	// Warnings 严格模式下警告的输出位置，为空时使用 os.Stderr
+	Warnings io.Writer
+
+	// NamesOnly 只补全命令和 flag 名称：接受值的 flag 一律为 :value:，
+	// 不做任何值补全（枚举、文件、动态候选等），位置参数也不补全；
+	// 与关闭某项推断不同，输出结果可预测且不依赖 Usage 文本
+	NamesOnly bool
+
+	// IncludeFeatureGated 包含以 MetaKeyFeatureGate 标记的命令（即使当前隐藏），
+	// 生成的脚本只在对应环境变量非空时补全这些命令，用于预览功能先行提供补全
+	IncludeFeatureGated bool
+
+	// Locale 生成器内部文本（help 说明、分组标签等）使用的语言: zh (默认), en；
+	// 命令和 flag 的 Usage 不受影响，保持声明时的原文
+	Locale string
+
+	// Messages 按 Msg* key 覆盖 Locale 选择的内置文本
+	Messages Messages
+
+	// NoCompdefCall 省略末尾的 compdef 注册行，只输出函数定义，
+	// 用于集中管理补全注册的场景
+	NoCompdefCall bool
+
+	// HelperFile 辅助函数（动态补全、配置文件等）所在的共享文件路径；
+	// 设置后脚本不再内联辅助函数，而是 source 该文件，由 GenerateZshSplit 同时生成两者。
+	// 路径原样写入脚本，可使用 ~ 或 $VAR
+	HelperFile string
+
+	// CompleteTimeout 补全时调用 __complete 的超时，为 0 时使用 2s；
+	// 超时或失败时回退到静态候选（被动态补全覆盖的枚举或文件补全），保持补全响应
+	CompleteTimeout time.Duration
+
+	// Runtime 生成运行时补全脚本：脚本不包含命令树，补全时调用 __complete words，
+	// 候选始终与安装的可执行文件一致；目前支持 zsh 和 bash
+	Runtime bool
+}
+
 // GenerateZsh 从 cli.Command 自动生成 zsh 补全脚本
 func GenerateZsh(w io.Writer, cmd *cli.Command) error {
-	funcName := toZshFuncName(cmd.Name)
+	return GenerateZshWithOptions(w, cmd, GenerateOptions{})
+}
+
+// GenerateZshWithOptions 按指定选项生成 zsh 补全脚本
+func GenerateZshWithOptions(w io.Writer, cmd *cli.Command, opts GenerateOptions) error {
+	return GenerateZshFromSpec(w, BuildCompletionSpec(cmd, opts), opts)
+}
+
+// GenerateZshFromSpec 从补全规格生成 zsh 补全脚本，不依赖运行中的命令树
+// 完整生成后一次性写入 w，出错时 w 不会收到不完整的脚本
+func GenerateZshFromSpec(w io.Writer, spec *CompletionSpec, opts GenerateOptions) error {
+	var sb strings.Builder
+	if err := generateZsh(&sb, spec, opts); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// GenerateZshStream 从 cli.Command 流式生成 zsh 补全脚本
+// 不预先构建补全规格，遍历命令树时逐个构建命令的规格，每生成一个函数就写入 w，
+// 同一时刻只保留当前路径上的命令，内存占用不随命令数量增长，适用于命令数量巨大的工具；
+// 输出与 GenerateZshWithOptions 完全一致，但出错时 w 可能已收到部分脚本
+func GenerateZshStream(w io.Writer, cmd *cli.Command, opts GenerateOptions) error {
+	// 生成器只用到根命令名称和应用名称
+	spec := &CompletionSpec{App: appRawName(cmd), Root: CommandSpec{Name: cmd.Name}}
+	if _, err := resolveMessages(opts.Locale, opts.Messages); err != nil {
+		return err
+	}
+	if opts.Runtime {
+		return generateZshRuntime(w, spec, opts)
+	}
+	return newZshGenerator(spec, opts).generate(w, cliNode(cmd, true, opts))
+}
+
+// generateZsh 从补全规格生成 zsh 补全脚本，生成的内容逐个函数写入 w
+func generateZsh(w io.Writer, spec *CompletionSpec, opts GenerateOptions) error {
+	if _, err := resolveMessages(opts.Locale, opts.Messages); err != nil {
+		return err
+	}
+	if opts.Runtime {
+		return generateZshRuntime(w, spec, opts)
+	}
+	return newZshGenerator(spec, opts).generate(w, specNode(&spec.Root))
+}
+
+// zshNode 生成脚本时遍历的命令
+// spec 中的子命令只需名称、别名、说明等条目信息，child 按需返回第 i 个子命令
+type zshNode struct {
+	spec  *CommandSpec
+	child func(i int) zshNode
+}
+
+// specNode 返回补全规格中的命令，子命令已完整构建
+func specNode(cs *CommandSpec) zshNode {
+	return zshNode{spec: cs, child: func(i int) zshNode { return specNode(&cs.Commands[i]) }}
+}
+
+// cliNode 返回 cli.Command 的命令，子命令在遍历到时才构建规格
+func cliNode(cmd *cli.Command, isRoot bool, opts GenerateOptions) zshNode {
+	subs := completionSubcommands(cmd, isRoot, opts)
+	cs := buildCommandNode(cmd, subs, isRoot, opts)
+	return zshNode{spec: &cs, child: func(i int) zshNode {
+		sub := cliNode(subs[i], false, opts)
+		sub.spec.Gate, sub.spec.Hidden = cs.Commands[i].Gate, cs.Commands[i].Hidden
+		return sub
+	}}
+}
+
+// GenerateZshSplit 生成主脚本和共享的辅助函数文件内容
+// 主脚本 source opts.HelperFile 而不内联辅助函数；同一命令树拆分生成的多个脚本
+// 可共用一份辅助函数，减少 __complete 等通用逻辑的重复
+func GenerateZshSplit(spec *CompletionSpec, opts GenerateOptions) (script, helpers string, err error) {
+	if opts.HelperFile == "" {
+		return "", "", fmt.Errorf("helper file path is required")
+	}
+	if _, err := resolveMessages(opts.Locale, opts.Messages); err != nil {
+		return "", "", err
+	}
+	g := newZshGenerator(spec, opts)
+	var main strings.Builder
+	if err := g.generate(&main, specNode(&spec.Root)); err != nil {
+		return "", "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s zsh completion helpers (auto-generated)\n\n", spec.Root.Name))
+	g.generateHelpers(&sb)
+	return main.String(), reindent(sb.String(), opts.Indent), nil
+}
+
+// generate 将补全脚本写入 w
+func (g *zshGenerator) generate(w io.Writer, node zshNode) error {
+	opts := g.opts
+	root := node.spec
+
+	// 校验和随写入同步计算，无需保留整个脚本
+	hash := sha256.New()
+	g.out = w
+	if opts.Checksum {
+		g.out = io.MultiWriter(w, hash)
+	}
 
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("#compdef %s\n\n", cmd.Name))
-	sb.WriteString(fmt.Sprintf("# %s zsh completion script (auto-generated)\n\n", cmd.Name))
+	sb.WriteString(fmt.Sprintf("#compdef %s\n\n", root.Name))
+	sb.WriteString(fmt.Sprintf("# %s zsh completion script (auto-generated)\n\n", root.Name))
+	if opts.Annotated {
+		sb.WriteString("# 注释模式: 每个函数和 flag 前附有说明，便于理解和调试补全行为。\n")
+		sb.WriteString("# 每个命令对应一个补全函数，由 _arguments 解析 flags，\n")
+		sb.WriteString("# 遇到子命令时通过 case 分发到子命令的补全函数。\n\n")
+	}
 
 	// 生成主函数
-	generateZshFunction(&sb, cmd, funcName, true)
+	g.generateFunction(&sb, root, g.prefix, true)
+	g.flush(&sb)
 
 	// 生成子命令函数
-	generateSubcommandFunctions(&sb, cmd, funcName)
+	g.generateSubcommandFunctions(&sb, node, g.prefix)
 
-	sb.WriteString(fmt.Sprintf("compdef %s %s\n", funcName, cmd.Name))
+	// 生成 flag 值补全用到的辅助函数，或引用共享的辅助函数文件
+	if opts.HelperFile == "" {
+		g.generateHelpers(&sb)
+	} else if len(g.helpers) > 0 {
+		g.comment(&sb, "", "辅助函数由共享文件提供")
+		sb.WriteString(fmt.Sprintf("source %s\n\n", opts.HelperFile))
+	}
 
-	_, err := io.WriteString(w, sb.String())
-	return err
+	if !opts.NoCompdefCall {
+		g.comment(&sb, "", "将 %s 注册为 %s 命令的补全函数", g.prefix, root.Name)
+		sb.WriteString(fmt.Sprintf("compdef %s %s\n", g.prefix, root.Name))
+	}
+	g.flush(&sb)
+
+	if g.err == nil && opts.Checksum {
+		_, g.err = io.WriteString(w, checksumLine(hash.Sum(nil)))
+	}
+	return g.err
+}
+
+// defaultIndent 生成脚本时每级缩进的宽度
+const defaultIndent = "    "
+
+// reindent 将行首每 4 个空格替换为 indent
+// 生成过程统一使用 defaultIndent 书写，最后一次性转换，保证各函数缩进一致
+func reindent(script, indent string) string {
+	if indent == "" || indent == defaultIndent {
+		return script
+	}
+	lines := strings.SplitAfter(script, "\n")
+	for i, line := range lines {
+		rest := line
+		level := 0
+		for strings.HasPrefix(rest, defaultIndent) {
+			rest = rest[len(defaultIndent):]
+			level++
+		}
+		if level > 0 {
+			lines[i] = strings.Repeat(indent, level) + rest
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// zshGenerator 保存一次 zsh 补全脚本生成过程中的状态
+type zshGenerator struct {
+	opts     GenerateOptions
+	prefix   string   // 根命令的函数名，辅助函数以此为前缀避免与其他工具冲突
+	binary   string   // 根命令名称，动态补全时调用其 __complete 子命令
+	appName  string   // 应用名称，用于推导配置文件路径
+	helpers  []string // 已使用的辅助函数，按首次使用顺序记录
+	messages Messages // 生成器内部文本，由 Locale 和 Messages 决定
+
+	out io.Writer // 脚本输出位置
+	err error     // 第一个写入错误，之后的写入被跳过
 }
 
-// generateZshFunction 生成单个命令的 zsh 补全函数
-func generateZshFunction(sb *strings.Builder, cmd *cli.Command, funcName string, isRoot bool) {
+// flush 将 sb 中已生成的内容调整缩进后写入输出，并清空 sb
+func (g *zshGenerator) flush(sb *strings.Builder) {
+	if g.err == nil {
+		_, g.err = io.WriteString(g.out, reindent(sb.String(), g.opts.Indent))
+	}
+	sb.Reset()
+}
+
+// newZshGenerator 为补全规格创建生成器
+// 不支持的 Locale 回退到默认语言，由 generateZsh 等调用方提前报错
+func newZshGenerator(spec *CompletionSpec, opts GenerateOptions) *zshGenerator {
+	messages, err := resolveMessages(opts.Locale, opts.Messages)
+	if err != nil {
+		messages, _ = resolveMessages("", opts.Messages)
+	}
+	return &zshGenerator{
+		opts:     opts,
+		prefix:   toZshFuncName(spec.Root.Name),
+		binary:   spec.Root.Name,
+		appName:  spec.App,
+		messages: messages,
+	}
+}
+
+// comment 在注释模式下输出一行注释
+func (g *zshGenerator) comment(sb *strings.Builder, indent, format string, args ...any) {
+	if !g.opts.Annotated {
+		return
+	}
+	fmt.Fprintf(sb, "%s# %s\n", indent, fmt.Sprintf(format, args...))
+}
+
+// zshFlag 单个 flag 的补全规格
+type zshFlag struct {
+	name string // 主名称，用于注释
+	spec string // _arguments 规格字符串
+	rule string // 值补全命中的推断规则
+}
+
+// generateFunction 生成单个命令的 zsh 补全函数
+func (g *zshGenerator) generateFunction(sb *strings.Builder, cmd *CommandSpec, funcName string, isRoot bool) {
+	g.comment(sb, "", "%s: %s 命令的补全函数", funcName, cmd.Name)
 	fmt.Fprintf(sb, "%s() {\n", funcName)
+
+	// 没有 flags、子命令和位置参数的纯动作命令，只提示没有可补全的参数
+	flags := g.collectFlags(cmd, isRoot)
+	if len(flags) == 0 && len(cmd.Commands) == 0 && cmd.Arg == nil && cmd.ArgsUsage == "" {
+		fmt.Fprintf(sb, "    _message '%s'\n", g.msg(MsgNoArguments))
+		sb.WriteString("}\n\n")
+		return
+	}
+
 	sb.WriteString("    local curcontext=\"$curcontext\" state line\n")
 	sb.WriteString("    typeset -A opt_args\n\n")
 
-	// 收集 flags
-	flags := collectFlags(cmd, isRoot)
 	if len(flags) > 0 {
 		sb.WriteString("    local -a flags\n")
 		sb.WriteString("    flags=(\n")
 		for _, f := range flags {
-			fmt.Fprintf(sb, "        %s\n", f)
+			g.comment(sb, "        ", "%s: %s", f.name, ruleNotes[f.rule])
+			fmt.Fprintf(sb, "        %s\n", f.spec)
 		}
 		sb.WriteString("    )\n\n")
 	}
 
-	// 收集可见的子命令（只有需要展开的才处理）
-	subcommands := getVisibleCommands(cmd)
-	hasSubcommands := len(subcommands) > 0 && shouldExpandSubcommands(cmd)
+	hasSubcommands := len(cmd.Commands) > 0
+
+	// 必填位置参数：第一个参数位置只补全该参数，输入后才补全 flags
+	if cmd.Arg != nil {
+		g.comment(sb, "    ", "%s 必须先于 flags 输入，第一个参数位置只补全它", cmd.Arg.Name)
+		sb.WriteString("    if (( CURRENT == 2 )); then\n")
+		fmt.Fprintf(sb, "        _arguments %s\n", g.argToZsh(cmd.Arg))
+		sb.WriteString("        return\n")
+		sb.WriteString("    fi\n\n")
+	}
 
 	// 生成 _arguments 调用
+	switch {
+	case hasSubcommands:
+		g.comment(sb, "    ", "第一个位置参数补全子命令，其余参数进入 args 状态交给子命令函数处理")
+	case g.opts.NamesOnly:
+		g.comment(sb, "    ", "仅补全名称模式，位置参数不补全")
+	default:
+		g.comment(sb, "    ", "没有子命令，位置参数按文件补全")
+	}
 	sb.WriteString("    _arguments -C \\\n")
 	if len(flags) > 0 {
 		sb.WriteString("        $flags \\\n")
 	}
+	if cmd.Arg != nil {
+		fmt.Fprintf(sb, "        %s \\\n", g.argToZsh(cmd.Arg))
+	}
 	if hasSubcommands {
-		fmt.Fprintf(sb, "        '1: :%s_commands' \\\n", funcName)
+		fmt.Fprintf(sb, "        '1: :%s' \\\n", toZshCommandsFuncName(funcName))
 		sb.WriteString("        '*::arg:->args'\n")
+	} else if g.opts.NamesOnly {
+		sb.WriteString("        '*:arg: '\n")
 	} else {
 		sb.WriteString("        '*:file:_files'\n")
 	}
 
 	// 生成子命令状态处理
 	if hasSubcommands {
-		sb.WriteString("\n    case $state in\n")
+		sb.WriteString("\n")
+		g.comment(sb, "    ", "根据已输入的子命令名（含别名）分发到对应的补全函数")
+		sb.WriteString("    case $state in\n")
 		sb.WriteString("        args)\n")
 		sb.WriteString("            case $line[1] in\n")
-		for _, sub := range subcommands {
-			subFuncName := funcName + "_" + toZshFuncName(sub.Name)
-			// 包含别名
-			names := []string{sub.Name}
-			names = append(names, sub.Aliases...)
-			fmt.Fprintf(sb, "                %s)\n", strings.Join(names, "|"))
-			fmt.Fprintf(sb, "                    %s\n", subFuncName)
+		subFuncNames := subcommandFuncNames(funcName, cmd.Commands)
+		for i, sub := range cmd.Commands {
+			subFuncName := subFuncNames[i]
+			// 包含别名，特殊字符转义后作为 case 模式
+			var patterns []string
+			for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+				patterns = append(patterns, zshCasePattern(name))
+			}
+			fmt.Fprintf(sb, "                %s)\n", strings.Join(patterns, "|"))
+			if sub.Gate != "" {
+				fmt.Fprintf(sb, "                    [[ -n $%s ]] && %s\n", sub.Gate, subFuncName)
+			} else {
+				fmt.Fprintf(sb, "                    %s\n", subFuncName)
+			}
 			sb.WriteString("                    ;;\n")
 		}
 		sb.WriteString("            esac\n")
@@ -114,298 +697,357 @@ func generateZshFunction(sb *strings.Builder, cmd *cli.Command, funcName string,
 }
 
 // generateSubcommandFunctions 递归生成所有子命令的函数
-func generateSubcommandFunctions(sb *strings.Builder, cmd *cli.Command, parentFuncName string) {
-	subcommands := getVisibleCommands(cmd)
-	if len(subcommands) == 0 {
+func (g *zshGenerator) generateSubcommandFunctions(sb *strings.Builder, node zshNode, parentFuncName string) {
+	cmd := node.spec
+	if len(cmd.Commands) == 0 {
 		return
 	}
 
 	// 生成 _commands 函数
-	fmt.Fprintf(sb, "%s_commands() {\n", parentFuncName)
+	g.comment(sb, "", "%s: 列出 %s 的子命令及说明，供 _describe 展示", toZshCommandsFuncName(parentFuncName), cmd.Name)
+	fmt.Fprintf(sb, "%s() {\n", toZshCommandsFuncName(parentFuncName))
 	sb.WriteString("    local -a commands\n")
 	sb.WriteString("    commands=(\n")
-	for _, sub := range subcommands {
+	var gated []string
+	for _, sub := range cmd.Commands {
+		name := strings.ReplaceAll(strings.ReplaceAll(sub.Name, ":", "\\:"), "'", "'\\''")
 		usage := strings.ReplaceAll(sub.Usage, "'", "'\\''")
-		fmt.Fprintf(sb, "        '%s:%s'\n", sub.Name, usage)
+		if sub.Hidden {
+			continue
+		}
+		if sub.Gate != "" {
+			gated = append(gated, fmt.Sprintf("    [[ -n $%s ]] && commands+=('%s:%s')\n", sub.Gate, name, usage))
+			continue
+		}
+		fmt.Fprintf(sb, "        '%s:%s'\n", name, usage)
 	}
 	sb.WriteString("    )\n")
-	sb.WriteString("    _describe -t commands 'commands' commands\n")
+	// 受特性开关控制的命令只在环境变量非空时列出
+	for _, line := range gated {
+		sb.WriteString(line)
+	}
+	fmt.Fprintf(sb, "    _describe -t commands '%s' commands\n", g.msg(MsgCommands))
 	sb.WriteString("}\n\n")
+	g.flush(sb)
 
 	// 递归生成每个子命令的函数
-	for _, sub := range subcommands {
-		subFuncName := parentFuncName + "_" + toZshFuncName(sub.Name)
-		generateZshFunction(sb, sub, subFuncName, false)
-		// 只有需要展开的命令才递归
-		if shouldExpandSubcommands(sub) {
-			generateSubcommandFunctions(sb, sub, subFuncName)
-		}
+	subFuncNames := subcommandFuncNames(parentFuncName, cmd.Commands)
+	for i := range cmd.Commands {
+		sub := node.child(i)
+		subFuncName := subFuncNames[i]
+		g.generateFunction(sb, sub.spec, subFuncName, false)
+		g.flush(sb)
+		g.generateSubcommandFunctions(sb, sub, subFuncName)
 	}
 }
 
 // collectFlags 收集命令的 flags，转换为 zsh 格式
-func collectFlags(cmd *cli.Command, includeGlobal bool) []string {
-	var flags []string
-	seen := make(map[string]bool)
+func (g *zshGenerator) collectFlags(cmd *CommandSpec, includeGlobal bool) []zshFlag {
+	var flags []zshFlag
 
-	// 收集当前命令的 flags
+	// 收集当前命令的 flags，按名称去重：任一名称已出现过则只保留第一个，
+	// 避免生成互相冲突的规格；定义不同时在严格模式下给出警告
+	seen := make(map[string]string) // flag 名称 -> 首次出现的规格
 	for _, f := range cmd.Flags {
-		zshFlag := flagToZsh(f)
-		if zshFlag != "" && !seen[zshFlag] {
-			flags = append(flags, zshFlag)
-			seen[zshFlag] = true
+		spec := g.flagToZsh(f)
+		if first, dup := firstSeen(seen, f.Names); dup {
+			if first != spec {
+				g.warn("command %q: flag %s defined more than once with different definitions, keeping the first", cmd.Name, flagDisplayName(f.Names[0]))
+			}
+			continue
 		}
+		for _, name := range f.Names {
+			seen[name] = spec
+		}
+		flags = append(flags, zshFlag{name: flagDisplayName(f.Names[0]), spec: spec, rule: f.Rule})
 	}
 
 	// 如果是子命令，也收集父命令的 flags（通过 root 传递）
 	if includeGlobal {
 		// help flag
-		flags = append(flags, "'(- *)'{-h,--help}'[显示帮助信息]'")
+		flags = append(flags, zshFlag{name: "--help", spec: "'(- *)'{-h,--help}'[" + strings.NewReplacer("[", "(", "]", ")").Replace(g.msg(MsgHelp)) + "]'", rule: ruleHelp})
 	}
 
 	return flags
 }
 
-// flagToZsh 将 cli.Flag 转换为 zsh 补全格式
-func flagToZsh(f cli.Flag) string {
-	names := f.Names()
-	if len(names) == 0 {
-		return ""
+// firstSeen 返回 names 中第一个已出现名称对应的规格
+func firstSeen(seen map[string]string, names []string) (string, bool) {
+	for _, name := range names {
+		if spec, ok := seen[name]; ok {
+			return spec, true
+		}
 	}
+	return "", false
+}
 
-	// 获取 flag 的描述和其他属性
-	usage := ""
-	takesValue := false
-	valueType := ""
-
-	switch flag := f.(type) {
-	case *cli.StringFlag:
-		usage = flag.Usage
-		takesValue = true
-		valueType = getValueCompletion(flag.Name, flag.Usage)
-	case *cli.BoolFlag:
-		usage = flag.Usage
-		takesValue = false
-	case *cli.IntFlag:
-		usage = flag.Usage
-		takesValue = true
-		valueType = ":number:"
-	case *cli.DurationFlag:
-		usage = flag.Usage
-		takesValue = true
-		valueType = ":duration:"
-	case *cli.StringSliceFlag:
-		usage = flag.Usage
-		takesValue = true
-		valueType = ":value:"
-	default:
-		// 其他类型，尝试获取基本信息
-		if nf, ok := f.(interface{ GetUsage() string }); ok {
-			usage = nf.GetUsage()
-		}
+// warn 严格模式下输出警告
+func (g *zshGenerator) warn(format string, args ...any) {
+	if !g.opts.Strict {
+		return
+	}
+	w := g.opts.Warnings
+	if w == nil {
+		w = os.Stderr
 	}
+	fmt.Fprintf(w, "warning: %s\n", fmt.Sprintf(format, args...))
+}
 
+// flagToZsh 将 flag 补全规格转换为 zsh 补全格式
+func (g *zshGenerator) flagToZsh(f FlagSpec) string {
+	usage := f.Usage
+	if f.Deprecated {
+		usage = strings.TrimSpace(usage + " (" + g.messages[MsgDeprecated] + ")")
+	}
 	usage = strings.ReplaceAll(usage, "'", "'\\''")
 	usage = strings.ReplaceAll(usage, "[", "(")
 	usage = strings.ReplaceAll(usage, "]", ")")
 
-	// 构建 zsh flag 字符串
-	if len(names) == 1 {
-		name := names[0]
-		if len(name) == 1 {
-			// 短选项
-			if takesValue {
-				return fmt.Sprintf("'-%s[%s]%s'", name, usage, valueType)
-			}
-			return fmt.Sprintf("'-%s[%s]'", name, usage)
+	return zshFlagSpec{
+		names:      f.Names,
+		usage:      usage,
+		takesValue: f.Value != ValueNone,
+		valueType:  g.valueAction(f),
+		repeatable: f.Repeatable,
+		excludes:   f.Excludes,
+	}.String()
+}
+
+// valueAction 返回 flag 值对应的 zsh 补全动作
+func (g *zshGenerator) valueAction(f FlagSpec) string {
+	if g.opts.NamesOnly && f.Value != ValueNone {
+		return ":value:"
+	}
+	switch f.Value {
+	case ValueNone:
+		return ""
+	case ValueEnum:
+		if len(f.DisabledValues) > 0 {
+			return ":value:{" + enumWithDisabledValues(f) + "}"
 		}
-		// 长选项
-		if takesValue {
-			return fmt.Sprintf("'--%s[%s]%s'", name, usage, valueType)
+		return fmt.Sprintf(":value:(%s)", strings.Join(f.Values, " "))
+	case ValueFile:
+		return ":" + fileMessage(f.Names) + ":_files"
+	case ValueDir:
+		return ":directory:_files -/"
+	case ValueRegexp:
+		return ":regexp:" + g.helper(zshHelperRegexp)
+	case ValueSort:
+		call := g.helper(zshHelperSort)
+		if f.ContextOf != "" {
+			call += " -c " + f.ContextOf
 		}
-		return fmt.Sprintf("'--%s[%s]'", name, usage)
-	}
-
-	// 有别名的情况（如 -c, --config）
-	var short, long string
-	for _, n := range names {
-		if len(n) == 1 {
-			short = "-" + n
-		} else {
-			long = "--" + n
+		return ":sort:{" + call + "}"
+	case ValueUnit:
+		return ":unit:" + g.helper(zshHelperUnits)
+	case ValueURL:
+		return ":url:"
+	case ValueSecret:
+		return ":secret:"
+	case ValueHost:
+		return ":host:_hosts"
+	case ValueTemplate:
+		return ":template:"
+	case ValueSelector:
+		return ":selector:" + g.helper(zshHelperSelector)
+	case ValueNumber:
+		return ":number:"
+	case ValueDuration:
+		if len(f.Values) > 0 {
+			return fmt.Sprintf(":duration:(%s)", strings.Join(f.Values, " "))
 		}
-	}
-
-	if short != "" && long != "" {
-		if takesValue {
-			return fmt.Sprintf("'(%s %s)'{%s,%s}'[%s]%s'", short, long, short, long, usage, valueType)
+		return ":duration:"
+	case ValueContext:
+		return ":context:" + g.helper(zshHelperContexts)
+	case ValueConfig:
+		return ":" + fileMessage(f.Names) + ":" + g.helper(zshHelperConfigFiles)
+	case ValueEndpoint:
+		return ":endpoint:" + g.helper(zshHelperEndpoints)
+	case ValueOutput:
+		return fmt.Sprintf(`:output:{_alternative "files:%s:_files" "sinks:%s:(%s)"}`, g.msg(MsgFiles), g.msg(MsgDestinations), strings.Join(f.Values, " "))
+	case ValueDynamic:
+		call := g.helper(zshHelperDynamic) + " " + f.Dynamic
+		if f.ContextOf != "" {
+			call += " -c " + f.ContextOf
+		}
+		if f.Separator != "" {
+			call += " -s " + f.Separator
+		}
+		if len(f.Values) > 0 {
+			call += " -a " + zshEscapeGlob(strings.Join(f.Values, ","))
 		}
-		return fmt.Sprintf("'(%s %s)'{%s,%s}'[%s]'", short, long, short, long, usage)
+		if len(f.Fallback) > 0 {
+			call += " -f " + zshEscapeGlob(strings.Join(f.Fallback, ","))
+		} else if f.FallbackFiles {
+			call += " -F"
+		}
+		return fmt.Sprintf(":%s:{%s}", f.Dynamic, call)
+	default:
+		return ":value:"
 	}
+}
 
-	// fallback
-	name := names[0]
-	prefix := "--"
-	if len(name) == 1 {
-		prefix = "-"
-	}
-	if takesValue {
-		return fmt.Sprintf("'%s%s[%s]%s'", prefix, name, usage, valueType)
+// enumWithDisabledValues 生成按命令行上已有的 flag 过滤枚举值的动作
+// 如 --watch 出现时去掉 json: values=(${values:#(json)})
+func enumWithDisabledValues(f FlagSpec) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "local -a values=(%s); ", strings.Join(f.Values, " "))
+	for _, d := range f.DisabledValues {
+		var present []string
+		for _, name := range d.When {
+			present = append(present, "$+opt_args["+flagDisplayName(name)+"]")
+		}
+		fmt.Fprintf(&sb, "(( %s )) && values=(${values:#(%s)}); ", strings.Join(present, " || "), strings.Join(d.Values, "|"))
 	}
-	return fmt.Sprintf("'%s%s[%s]'", prefix, name, usage)
+	sb.WriteString("_wanted values expl value compadd -a values")
+	return sb.String()
 }
 
-// getValueCompletion 根据 flag 名称和描述推断补全类型
-// 设计原则：从 Usage 描述推断，不硬编码业务值
-func getValueCompletion(name, usage string) string {
-	nameLower := strings.ToLower(name)
-	usageLower := strings.ToLower(usage)
+// zshEscapeGlob 转义通配符等特殊字符，使 * 这类值在动作执行时按字面传递
+func zshEscapeGlob(s string) string {
+	return strings.NewReplacer("*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`, "#", `\#`, "~", `\~`).Replace(s)
+}
 
-	// 1. 优先从 Usage 解析枚举值（如 "类型: a, b, c" 或 "format: json, csv"）
-	if values := parseEnumFromUsage(usage); len(values) > 0 {
-		return fmt.Sprintf(":value:(%s)", strings.Join(values, " "))
+// argToZsh 将位置参数转换为 _arguments 的第一个位置参数规格
+func (g *zshGenerator) argToZsh(arg *ArgSpec) string {
+	action := " "
+	switch {
+	case g.opts.NamesOnly:
+	case len(arg.Values) > 0:
+		action = "(" + strings.Join(arg.Values, " ") + ")"
+	case arg.Glob != "":
+		action = "_files -g \"" + arg.Glob + "\""
 	}
+	name := strings.ReplaceAll(arg.Name, ":", "\\:")
+	return fmt.Sprintf("'1:%s:%s'", strings.ReplaceAll(name, "'", "'\\''"), action)
+}
 
-	// 2. URL 类型（从 name 推断）
-	if strings.Contains(nameLower, "url") {
-		return ":url:"
+// fileMessage 从 flag 长名称推导文件补全的说明，使菜单显示期望的文件类型
+// 如 --config -> "config file"，--tls-cert -> "tls cert file"，--output-file -> "output file"
+func fileMessage(names []string) string {
+	name := names[0]
+	for _, n := range names {
+		if len(n) > 1 {
+			name = n
+			break
+		}
 	}
-
-	// 3. 文件路径类型（从 name 或 usage 推断）
-	if isFilePath(nameLower, usageLower) {
-		return ":file:_files"
+	words := strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' || r == '.' || r == ':' })
+	if len(words) == 0 {
+		return "file"
+	}
+	if last := words[len(words)-1]; last != "file" && last != "path" {
+		words = append(words, "file")
 	}
+	return strings.Join(words, " ")
+}
 
-	// 4. 数字类型
-	if strings.Contains(usageLower, "number") ||
-		strings.Contains(usageLower, "数量") ||
-		strings.Contains(usageLower, "个数") {
-		return ":number:"
+// flagDisplayName 返回带前缀的 flag 名称（短选项 -x，长选项 --xxx）
+func flagDisplayName(name string) string {
+	if len(name) == 1 {
+		return "-" + name
 	}
+	return "--" + name
+}
 
-	return ":value:"
+// zshFlagSpec 构建 _arguments flag 规格所需的信息
+type zshFlagSpec struct {
+	names      []string // flag 名称（不含 - 前缀）
+	usage      string   // 已转义的描述
+	takesValue bool     // 是否接受值
+	valueType  string   // 值补全动作，如 ":file:_files"
+	repeatable bool     // 可在命令行中重复出现（如计数 flag -vvv）
+	excludes   []string // 互斥的其他 flag 名称（不含 - 前缀）
 }
 
-// parseEnumFromUsage 从 Usage 描述中解析枚举值
-// 支持格式：
-//   - "类型: a, b, c"
-//   - "format: json, csv, xml"
-//   - "模式 (a/b/c)"
-//   - "type (a|b|c)"
-func parseEnumFromUsage(usage string) []string {
-	// 模式1: "xxx: a, b, c" 或 "xxx：a, b, c"（中英文冒号）
-	if idx := strings.IndexAny(usage, ":："); idx != -1 {
-		rest := strings.TrimSpace(usage[idx+1:])
-		// 去掉括号内容（如果有的话，可能是补充说明）
-		if parenIdx := strings.IndexAny(rest, "(（"); parenIdx != -1 {
-			rest = strings.TrimSpace(rest[:parenIdx])
-		}
-		// 按逗号分割
-		if strings.Contains(rest, ",") || strings.Contains(rest, "，") {
-			parts := strings.FieldsFunc(rest, func(r rune) bool {
-				return r == ',' || r == '，' || r == ' '
-			})
-			var values []string
-			for _, p := range parts {
-				p = strings.TrimSpace(p)
-				// 只保留简单的值（无空格、非空）
-				if p != "" && !strings.Contains(p, " ") && len(p) < 20 {
-					values = append(values, p)
-				}
-			}
-			if len(values) >= 2 {
-				return values
-			}
+// String 构建 _arguments 使用的 flag 规格字符串
+// 有多个名称时互相排斥（如 '(-c --config)'{-c,--config}），可重复的 flag 以 * 标记
+func (s zshFlagSpec) String() string {
+	// 短选项在前，与 -c/--config 的书写习惯一致
+	var options, longs []string
+	for _, n := range s.names {
+		if len(n) == 1 {
+			options = append(options, flagDisplayName(n))
+		} else {
+			longs = append(longs, flagDisplayName(n))
 		}
 	}
+	options = append(options, longs...)
 
-	// 模式2: "(a/b/c)" 或 "(a|b|c)"
-	if start := strings.IndexAny(usage, "(（"); start != -1 {
-		if end := strings.IndexAny(usage[start:], ")）"); end != -1 {
-			inner := usage[start+1 : start+end]
-			// 检查是否是枚举格式
-			if strings.ContainsAny(inner, "/|") && !strings.Contains(inner, " ") {
-				parts := strings.FieldsFunc(inner, func(r rune) bool {
-					return r == '/' || r == '|'
-				})
-				if len(parts) >= 2 {
-					var values []string
-					for _, p := range parts {
-						p = strings.TrimSpace(p)
-						if p != "" && len(p) < 20 {
-							values = append(values, p)
-						}
-					}
-					return values
-				}
-			}
-		}
+	value := ""
+	if s.takesValue {
+		value = s.valueType
 	}
 
-	return nil
-}
-
-// isFilePath 判断是否是文件路径类型
-// 从 flag 名称和 usage 描述推断
-func isFilePath(nameLower, usageLower string) bool {
-	// 从 name 推断
-	fileNamePatterns := []string{
-		"file", "path", "config", "input", "output",
-		"cert", "key", "ca", // 证书相关
-	}
-	for _, pattern := range fileNamePatterns {
-		if strings.Contains(nameLower, pattern) {
-			// 排除 "prefix"、"format" 等误判
-			if strings.Contains(nameLower, "prefix") ||
-				strings.Contains(nameLower, "format") {
-				return false
-			}
-			return true
-		}
+	var group []string
+	if len(options) > 1 && !s.repeatable {
+		group = append(group, options...)
+	}
+	for _, n := range s.excludes {
+		group = append(group, flagDisplayName(n))
 	}
 
-	// 从 usage 推断（中英文）
-	fileUsagePatterns := []string{
-		"file", "path", "文件", "路径", "证书",
+	prefix := ""
+	if len(group) > 0 {
+		prefix = "(" + strings.Join(group, " ") + ")"
 	}
-	for _, pattern := range fileUsagePatterns {
-		if strings.Contains(usageLower, pattern) {
-			return true
-		}
+	if s.repeatable {
+		prefix += "*"
 	}
 
-	return false
+	if len(options) == 1 {
+		return fmt.Sprintf("'%s%s[%s]%s'", prefix, options[0], s.usage, value)
+	}
+	return fmt.Sprintf("'%s'{%s}'[%s]%s'", prefix, strings.Join(options, ","), s.usage, value)
 }
 
-// getVisibleCommands 获取可见的子命令（排除 hidden 和特殊命令）
-func getVisibleCommands(cmd *cli.Command) []*cli.Command {
-	var visible []*cli.Command
-	for _, sub := range cmd.Commands {
-		// 跳过隐藏命令
-		if sub.Hidden {
-			continue
+// toZshFuncName 将命令名转换为合法的 zsh 函数名
+func toZshFuncName(name string) string {
+	// 替换 - 等函数名中不能使用的字符为 _，添加前缀 _
+	return "_" + strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r > 127 {
+			return r
 		}
-		// 跳过 help、completion 等不需要在补全中显示的命令
-		if sub.Name == "help" || sub.Name == "completion" {
-			continue
+		return '_'
+	}, name)
+}
+
+// zshCasePattern 转义 case 模式中的特殊字符（如 | ) * [），使名称按字面匹配
+func zshCasePattern(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if !(r == '-' || r == '_' || r == '.' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r > 127) {
+			sb.WriteByte('\\')
 		}
-		visible = append(visible, sub)
+		sb.WriteRune(r)
 	}
-	return visible
+	return sb.String()
 }
 
-// shouldExpandSubcommands 判断是否需要展开子命令的补全
-// version 等终端命令不需要展开其子命令
-func shouldExpandSubcommands(cmd *cli.Command) bool {
-	// version 命令的子命令（short、json）不需要在补全中展开
-	if cmd.Name == "version" {
-		return false
+// subcommandFuncNames 返回各子命令的补全函数名（<parent>__<name>）
+// 兄弟命令转换后重名时（如 a-b 与 a_b），追加由父函数名和命令名派生的短哈希，
+// 不使用位置序号，保证函数名与声明顺序无关
+func subcommandFuncNames(parentFuncName string, subs []CommandSpec) []string {
+	names := make([]string, len(subs))
+	count := make(map[string]int)
+	for i, sub := range subs {
+		names[i] = parentFuncName + "_" + toZshFuncName(sub.Name)
+		count[names[i]]++
 	}
-	return true
+	for i, sub := range subs {
+		if count[names[i]] > 1 {
+			h := fnv.New32a()
+			h.Write([]byte(parentFuncName + "\x00" + sub.Name))
+			names[i] += fmt.Sprintf("_%08x", h.Sum32())
+		}
+	}
+	return names
 }
 
-// toZshFuncName 将命令名转换为合法的 zsh 函数名
-func toZshFuncName(name string) string {
-	// 替换 - 为 _，添加前缀 _
-	return "_" + strings.ReplaceAll(name, "-", "_")
+// toZshCommandsFuncName 返回列出子命令的 zsh 函数名
+// 额外的前缀 _ 使其不会与子命令函数重名：
+// 子命令函数为 <parent>__<name>，若直接追加 _commands，
+// 名为 x_commands 的兄弟命令会与 x 的子命令列表函数冲突
+func toZshCommandsFuncName(funcName string) string {
+	return "_" + funcName + "_commands"
 }
@@ -0,0 +1,132 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/urfave/cli/v3"
+)
+
+// 审计发现的问题类型
+const (
+	auditDuplicateFlag    = "duplicate-flag"    // 同一命令中 flag 名称重复
+	auditDuplicateCommand = "duplicate-command" // 同一层级中命令名称或别名重复
+	auditEmptyUsage       = "empty-usage"       // flag 没有描述，补全时无说明
+	auditAmbiguous        = "ambiguous"         // 补全推断存在歧义
+)
+
+// auditProblem 审计发现的单个问题
+type auditProblem struct {
+	Command string // 命令路径，如 vm-metrics query
+	Kind    string // 问题类型
+	Detail  string // 问题说明
+}
+
+// String 格式化为一行报告
+func (p auditProblem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Command, p.Kind, p.Detail)
+}
+
+// newCompletionAuditCommand 创建 completion audit 子命令
+// 检查命令树中影响补全的问题，存在问题时返回错误（非零退出），可用作 pre-commit 检查
+func newCompletionAuditCommand(rootCmd *cli.Command) *cli.Command {
+	return &cli.Command{
+		Name:   "audit",
+		Usage:  "检查命令树中影响补全的问题 (重复名称、缺少描述、推断歧义)",
+		Hidden: true,
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			problems := auditCommandTree(rootCmd)
+			return reportAuditProblems(cmd.Root().Writer, problems)
+		},
+	}
+}
+
+// reportAuditProblems 每行输出一个问题，存在问题时返回错误
+func reportAuditProblems(w io.Writer, problems []auditProblem) error {
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d completion problems", len(problems))
+	}
+	return nil
+}
+
+// auditCommandTree 递归检查命令树，包括隐藏命令
+func auditCommandTree(root *cli.Command) []auditProblem {
+	var problems []auditProblem
+	var walk func(cmd *cli.Command, path string)
+	walk = func(cmd *cli.Command, path string) {
+		problems = append(problems, auditFlags(cmd, path)...)
+		problems = append(problems, auditSubcommandNames(cmd, path)...)
+		for _, sub := range cmd.Commands {
+			walk(sub, path+" "+sub.Name)
+		}
+	}
+	walk(root, root.Name)
+	return problems
+}
+
+// auditFlags 检查单个命令的 flags
+// 重复名称与严格模式的检查相同，但不区分定义是否一致：重复定义即使相同也是冗余
+func auditFlags(cmd *cli.Command, path string) []auditProblem {
+	var problems []auditProblem
+	seen := make(map[string]bool)
+	declared := declaredCompletions(cmd)
+	for _, f := range cmd.Flags {
+		names := f.Names()
+		if len(names) == 0 {
+			continue
+		}
+		for _, name := range names {
+			if seen[name] {
+				problems = append(problems, auditProblem{path, auditDuplicateFlag, flagDisplayName(name) + " defined more than once"})
+			}
+			seen[name] = true
+		}
+
+		fs, ok := buildFlagSpec(f, declared, GenerateOptions{})
+		if !ok {
+			continue
+		}
+		if fs.Usage == "" {
+			problems = append(problems, auditProblem{path, auditEmptyUsage, flagDisplayName(names[0]) + " has no usage"})
+		}
+		if detail := inferenceAmbiguity(fs); detail != "" {
+			problems = append(problems, auditProblem{path, auditAmbiguous, flagDisplayName(names[0]) + " " + detail})
+		}
+	}
+	return problems
+}
+
+// inferenceAmbiguity 返回 flag 补全推断的歧义说明，无歧义时返回空
+// 未识别的 flag 类型只能补全名称；Usage 列出枚举而名称指向其他类型时，
+// 枚举覆盖了名称推断（如 --output-file 的描述写成 "格式: json, csv"）
+func inferenceAmbiguity(fs FlagSpec) string {
+	switch fs.Rule {
+	case ruleUnknown:
+		return "has an unrecognized flag type, only its name is completed"
+	case ruleEnumUsage:
+		if kind, _, rule := inferValue(fs.Names[0], ""); kind != ValueAny && kind != ValueEnum {
+			return fmt.Sprintf("lists values in usage but its name suggests %s completion", rule)
+		}
+	}
+	return ""
+}
+
+// auditSubcommandNames 检查同一层级的子命令名称和别名是否重复
+func auditSubcommandNames(cmd *cli.Command, path string) []auditProblem {
+	var problems []auditProblem
+	owner := make(map[string]string)
+	for _, sub := range cmd.Commands {
+		for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+			if prev, ok := owner[name]; ok {
+				problems = append(problems, auditProblem{path, auditDuplicateCommand, fmt.Sprintf("%q used by both %s and %s", name, prev, sub.Name)})
+				continue
+			}
+			owner[name] = sub.Name
+		}
+	}
+	return problems
+}
@@ -0,0 +1,276 @@
+package command
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// GenerateBash 从 cli.Command 自动生成 bash 补全脚本
+func GenerateBash(w io.Writer, cmd *cli.Command) error {
+	opts := GenerateOptions{}
+	return GenerateBashFromSpec(w, BuildCompletionSpec(cmd, opts), opts)
+}
+
+// GenerateBashFromSpec 从补全规格生成 bash 补全脚本
+// 与 zsh 共用同一份规格：子命令、flag 名称与值补全类型一致，
+// bash 没有描述展示，只补全候选本身；zsh 专有的选项（Locale、HelperFile 等）被忽略
+func GenerateBashFromSpec(w io.Writer, spec *CompletionSpec, opts GenerateOptions) error {
+	if opts.Runtime {
+		return generateBashRuntime(w, spec, opts)
+	}
+	g := &bashGenerator{
+		opts:   opts,
+		prefix: toZshFuncName(spec.Root.Name),
+		binary: spec.Root.Name,
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s bash completion script (auto-generated)\n\n", spec.Root.Name))
+
+	// 收集所有命令的补全函数名，子命令定位与分发共用
+	nodes := collectCommandNodes(&spec.Root, g.prefix)
+
+	fmt.Fprintf(&sb, "%s() {\n", g.prefix)
+	sb.WriteString("    local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}\n")
+	fmt.Fprintf(&sb, "    local cmd=%s i\n", g.prefix)
+	sb.WriteString("    COMPREPLY=()\n\n")
+
+	// 沿已输入的子命令向下定位，跳过接受值的 flag 及其值
+	sb.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	sb.WriteString("        case \"$cmd:${COMP_WORDS[i]}\" in\n")
+	for _, n := range nodes {
+		if skips := n.valueFlagPatterns(); len(skips) > 0 {
+			fmt.Fprintf(&sb, "            %s) ((i++)) ;;\n", strings.Join(skips, "|"))
+		}
+		names := subcommandFuncNames(n.funcName, n.cmd.Commands)
+		for j, sub := range n.cmd.Commands {
+			var patterns []string
+			for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+				patterns = append(patterns, bashQuote(n.funcName+":"+name))
+			}
+			fmt.Fprintf(&sb, "            %s) cmd=%s ;;\n", strings.Join(patterns, "|"), names[j])
+		}
+	}
+	sb.WriteString("        esac\n")
+	sb.WriteString("    done\n\n")
+
+	sb.WriteString("    case $cmd in\n")
+	for _, n := range nodes {
+		g.generateCommand(&sb, n)
+	}
+	sb.WriteString("    esac\n")
+	sb.WriteString("}\n\n")
+
+	if g.dynamic {
+		g.generateCompleteHelper(&sb)
+	}
+
+	fmt.Fprintf(&sb, "complete -F %s %s\n", g.prefix, spec.Root.Name)
+
+	script := reindent(sb.String(), opts.Indent)
+	if opts.Checksum {
+		sum := sha256.Sum256([]byte(script))
+		script += checksumLine(sum[:])
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+// bashGenerator bash 补全脚本生成器
+type bashGenerator struct {
+	opts    GenerateOptions
+	prefix  string // 主补全函数名，如 _vm_metrics
+	binary  string // 调用 __complete 的程序名
+	dynamic bool   // 是否用到动态补全辅助函数
+}
+
+// commandNode 命令树中的单个命令及其标识
+// 标识与 zsh 生成器的子命令函数名一致，bash 和 fish 用它定位当前子命令
+type commandNode struct {
+	cmd      *CommandSpec
+	funcName string
+}
+
+// collectCommandNodes 按先序遍历收集命令，根命令在第一个
+func collectCommandNodes(cmd *CommandSpec, funcName string) []commandNode {
+	nodes := []commandNode{{cmd: cmd, funcName: funcName}}
+	names := subcommandFuncNames(funcName, cmd.Commands)
+	for i := range cmd.Commands {
+		nodes = append(nodes, collectCommandNodes(&cmd.Commands[i], names[i])...)
+	}
+	return nodes
+}
+
+// valueFlagPatterns 返回接受值的 flag 在定位循环中的 case 模式（<func>:--flag）
+func (n commandNode) valueFlagPatterns() []string {
+	var patterns []string
+	for _, f := range n.cmd.Flags {
+		if f.Value == ValueNone {
+			continue
+		}
+		for _, name := range f.Names {
+			patterns = append(patterns, bashQuote(n.funcName+":"+flagDisplayName(name)))
+		}
+	}
+	return patterns
+}
+
+// generateCommand 生成单个命令的 case 分支：先按前一个词补全 flag 值，再补全 flag 和子命令
+func (g *bashGenerator) generateCommand(sb *strings.Builder, n commandNode) {
+	fmt.Fprintf(sb, "        %s)\n", bashQuote(n.funcName))
+
+	var values []string
+	for _, f := range n.cmd.Flags {
+		if f.Value == ValueNone {
+			continue
+		}
+		var patterns []string
+		for _, name := range f.Names {
+			patterns = append(patterns, bashQuote(flagDisplayName(name)))
+		}
+		action := "return"
+		if a := g.valueAction(f); a != "" {
+			action = a + "; return"
+		}
+		values = append(values, fmt.Sprintf("                %s) %s ;;\n", strings.Join(patterns, "|"), action))
+	}
+	if len(values) > 0 {
+		sb.WriteString("            case $prev in\n")
+		for _, v := range values {
+			sb.WriteString(v)
+		}
+		sb.WriteString("            esac\n")
+	}
+
+	// 重复定义的 flag 名称只列出一次
+	var words []string
+	seen := make(map[string]bool)
+	for _, f := range n.cmd.Flags {
+		for _, name := range f.Names {
+			if !seen[name] {
+				words = append(words, flagDisplayName(name))
+			}
+			seen[name] = true
+		}
+	}
+	var gated []string
+	for _, sub := range n.cmd.Commands {
+		switch {
+		case sub.Hidden:
+		case sub.Gate != "":
+			gated = append(gated, fmt.Sprintf("            [[ -n $%s ]] && words+=%s\n", sub.Gate, bashQuote(" "+bashEscapeWord(sub.Name))))
+		default:
+			words = append(words, bashEscapeWord(sub.Name))
+		}
+	}
+	fmt.Fprintf(sb, "            local words=%s\n", bashQuote(strings.Join(words, " ")))
+	for _, line := range gated {
+		sb.WriteString(line)
+	}
+	sb.WriteString("            mapfile -t COMPREPLY < <(compgen -W \"$words\" -- \"$cur\")\n")
+	sb.WriteString("            ;;\n")
+}
+
+// valueAction 返回补全 flag 值的命令
+// 没有候选的类型（任意值、数字、密钥、模板等）返回空，调用处直接 return，避免回退到文件补全
+func (g *bashGenerator) valueAction(f FlagSpec) string {
+	if g.opts.NamesOnly {
+		return ""
+	}
+	switch f.Value {
+	case ValueEnum, ValueDuration:
+		if len(f.Values) == 0 {
+			return ""
+		}
+		return bashWords(f.Values)
+	case ValueFile, ValueConfig:
+		return bashFiles("-f")
+	case ValueDir:
+		return bashFiles("-d")
+	case ValueHost:
+		return "mapfile -t COMPREPLY < <(compgen -A hostname -- \"$cur\")"
+	case ValueOutput:
+		// 特殊目标在前，文件追加在后
+		return bashWords(f.Values) + "; compopt -o filenames 2>/dev/null; mapfile -t -O ${#COMPREPLY[@]} COMPREPLY < <(compgen -f -- \"$cur\")"
+	case ValueDynamic:
+		g.dynamic = true
+		call := fmt.Sprintf("%s_complete %s", g.prefix, f.Dynamic)
+		if f.ContextOf != "" {
+			call += " " + flagDisplayName(f.ContextOf)
+		}
+		action := fmt.Sprintf("mapfile -t COMPREPLY < <(compgen -W \"$(%s)\" -- \"$cur\")", call)
+		if len(f.Fallback) > 0 {
+			action += "; (( ${#COMPREPLY[@]} )) || " + bashWords(f.Fallback)
+		} else if f.FallbackFiles {
+			action += "; (( ${#COMPREPLY[@]} )) || { " + bashFiles("-f") + "; }"
+		}
+		if len(f.Values) > 0 {
+			action += fmt.Sprintf("; mapfile -t -O ${#COMPREPLY[@]} COMPREPLY < <(compgen -W %s -- \"$cur\")", bashQuote(bashEscapeWords(f.Values)))
+		}
+		return action
+	default:
+		return ""
+	}
+}
+
+// generateCompleteHelper 生成带超时调用 __complete 的辅助函数
+// 第二个参数为上下文 flag（如 --context），其在命令行上的值作为参数传给 __complete
+func (g *bashGenerator) generateCompleteHelper(sb *strings.Builder) {
+	timeout := g.opts.CompleteTimeout
+	if timeout <= 0 {
+		timeout = defaultCompleteTimeout
+	}
+	fmt.Fprintf(sb, "%s_complete() {\n", g.prefix)
+	sb.WriteString("    local kind=$1 ctx=$2 i\n")
+	sb.WriteString("    local -a args run=(env)\n")
+	sb.WriteString("    if [[ -n $ctx ]]; then\n")
+	sb.WriteString("        for ((i = 1; i < COMP_CWORD - 1; i++)); do\n")
+	sb.WriteString("            [[ ${COMP_WORDS[i]} == \"$ctx\" ]] && args=(\"${COMP_WORDS[i+1]}\")\n")
+	sb.WriteString("        done\n")
+	sb.WriteString("    fi\n")
+	fmt.Fprintf(sb, "    type -P timeout >/dev/null && run=(timeout %s env)\n", strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64))
+	fmt.Fprintf(sb, "    \"${run[@]}\" %s=\"$(printf '%%s\\n' \"${COMP_WORDS[@]:0:COMP_CWORD}\")\" %s __complete \"$kind\" \"${args[@]}\" 2>/dev/null\n", completeWordsEnv, g.binary)
+	sb.WriteString("}\n\n")
+}
+
+// bashWords 返回从固定候选中补全的命令
+func bashWords(values []string) string {
+	return fmt.Sprintf("mapfile -t COMPREPLY < <(compgen -W %s -- \"$cur\")", bashQuote(bashEscapeWords(values)))
+}
+
+// bashFiles 返回补全文件（-f）或目录（-d）的命令，并让 readline 按文件名处理候选（追加 / 等）
+func bashFiles(option string) string {
+	return fmt.Sprintf("compopt -o filenames 2>/dev/null; mapfile -t COMPREPLY < <(compgen %s -- \"$cur\")", option)
+}
+
+// bashQuote 将字符串放入 bash 单引号
+func bashQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// bashEscapeWords 转义后以空格连接，作为 compgen -W 的单词列表
+func bashEscapeWords(values []string) string {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = bashEscapeWord(v)
+	}
+	return strings.Join(escaped, " ")
+}
+
+// bashEscapeWord 转义 compgen -W 展开时有特殊含义的字符（如 * 和空格），使值按字面补全
+// fish 的 complete -a 同样按反斜杠转义，两者共用
+func bashEscapeWord(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if !(r == '-' || r == '_' || r == '.' || r == '/' || r == ':' || r == ',' || r == '=' || r == '@' || r == '+' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r > 127) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
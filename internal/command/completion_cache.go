@@ -0,0 +1,80 @@
+package command
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// completionCacheKey 返回补全脚本缓存的 key
+// 由补全规格和影响输出的生成选项序列化后取摘要：命令树的任何结构变化都会使缓存失效，
+// 而相同构建产生相同的 key（不依赖版本号，开发构建同样安全）
+func completionCacheKey(spec *CompletionSpec, shell string, opts GenerateOptions) (string, error) {
+	data, err := json.Marshal(struct {
+		Spec            *CompletionSpec `json:"spec"`
+		Shell           string          `json:"shell"`
+		Annotated       bool            `json:"annotated"`
+		HideDeprecated  bool            `json:"hide_deprecated"`
+		Checksum        bool            `json:"checksum"`
+		Indent          string          `json:"indent"`
+		NamesOnly       bool            `json:"names_only"`
+		Locale          string          `json:"locale"`
+		Messages        Messages        `json:"messages"`
+		NoCompdefCall   bool            `json:"no_compdef_call"`
+		HelperFile      string          `json:"helper_file"`
+		CompleteTimeout time.Duration   `json:"complete_timeout"`
+		Runtime         bool            `json:"runtime"`
+	}{spec, shell, opts.Annotated, opts.HideDeprecated, opts.Checksum, opts.Indent, opts.NamesOnly, opts.Locale, opts.Messages, opts.NoCompdefCall, opts.HelperFile, opts.CompleteTimeout, opts.Runtime})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal completion spec: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// completionCacheDir 返回补全脚本缓存目录（$XDG_CACHE_HOME/<app>/completion）
+func completionCacheDir(appName string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get cache dir: %w", err)
+	}
+	return filepath.Join(dir, appName, "completion"), nil
+}
+
+// writeCachedCompletion 将缓存的补全脚本写入 w，缓存不存在时生成并写入缓存
+// 缓存文件名为 <shell>-<key>，写入时清理同一 shell 其他 key 的旧缓存
+func writeCachedCompletion(w io.Writer, dir, shell, key string, generate func(io.Writer) error) error {
+	path := filepath.Join(dir, shell+"-"+key)
+	if content, err := os.ReadFile(path); err == nil {
+		_, err = w.Write(content)
+		return err
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read completion cache: %w", err)
+	}
+
+	var sb strings.Builder
+	if err := generate(&sb); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	if old, err := filepath.Glob(filepath.Join(dir, shell+"-*")); err == nil {
+		for _, p := range old {
+			_ = os.Remove(p)
+		}
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write completion cache: %w", err)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,62 @@
+package command
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// checksumPrefix 校验和注释行的前缀，行内容为 sha256 十六进制摘要
+const checksumPrefix = "# sha256: "
+
+// checksumLine 返回追加在脚本末尾的校验和注释行，sum 为该行之前全部内容的摘要
+func checksumLine(sum []byte) string {
+	return checksumPrefix + hex.EncodeToString(sum) + "\n"
+}
+
+// VerifyCompletionChecksum 校验补全脚本末尾的校验和注释
+// 脚本被修改或缺少校验和时返回错误
+func VerifyCompletionChecksum(script string) error {
+	body := strings.TrimSuffix(script, "\n")
+	idx := strings.LastIndex(body, "\n")
+	content, last := body[:idx+1], body[idx+1:]
+	want, ok := strings.CutPrefix(last, checksumPrefix)
+	if !ok {
+		return fmt.Errorf("checksum line not found")
+	}
+	sum := sha256.Sum256([]byte(content))
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", want, got)
+	}
+	return nil
+}
+
+// newCompletionVerifyCommand 创建 completion verify 子命令
+// 校验以 --checksum 生成的补全脚本是否被修改
+func newCompletionVerifyCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "verify",
+		Usage:     "校验补全脚本的校验和",
+		ArgsUsage: "<file>",
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			path := cmd.Args().First()
+			if path == "" {
+				return fmt.Errorf("completion file is required")
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			if err := VerifyCompletionChecksum(string(content)); err != nil {
+				return fmt.Errorf("failed to verify %s: %w", path, err)
+			}
+			fmt.Printf("校验通过: %s\n", path)
+			return nil
+		},
+	}
+}
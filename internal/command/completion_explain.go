@@ -0,0 +1,89 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// flagDecision 单个 flag 的值补全决策
+type flagDecision struct {
+	Command string    `json:"command"`           // 命令路径，如 vm-metrics query
+	Flag    string    `json:"flag"`              // flag 名称，如 --output-format
+	Kind    ValueKind `json:"kind"`              // 值补全类型，空表示不接受值
+	Values  []string  `json:"values,omitempty"`  // 候选值
+	Dynamic string    `json:"dynamic,omitempty"` // 动态补全的 __complete 类型
+	Rule    string    `json:"rule"`              // 命中的推断规则
+}
+
+// newCompletionExplainCommand 创建 completion explain 子命令
+// 列出整个命令树中每个 flag 的值补全决策，用于排查补全不符合预期的原因
+func newCompletionExplainCommand(rootCmd *cli.Command) *cli.Command {
+	return &cli.Command{
+		Name:  "explain",
+		Usage: "列出每个 flag 的值补全类型、候选值和推断规则",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "输出格式: text, json",
+				Value: "text",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			decisions := explainCompletion(BuildCompletionSpec(rootCmd, GenerateOptions{}))
+			return writeDecisions(cmd.Root().Writer, decisions, cmd.String("format"))
+		},
+	}
+}
+
+// explainCompletion 按命令树的顺序收集每个 flag 的补全决策
+func explainCompletion(spec *CompletionSpec) []flagDecision {
+	var decisions []flagDecision
+	var walk func(cs *CommandSpec, path string)
+	walk = func(cs *CommandSpec, path string) {
+		for _, f := range cs.Flags {
+			decisions = append(decisions, flagDecision{
+				Command: path,
+				Flag:    flagDisplayName(f.Names[0]),
+				Kind:    f.Value,
+				Values:  f.Values,
+				Dynamic: f.Dynamic,
+				Rule:    f.Rule,
+			})
+		}
+		for i := range cs.Commands {
+			walk(&cs.Commands[i], path+" "+cs.Commands[i].Name)
+		}
+	}
+	walk(&spec.Root, spec.Root.Name)
+	return decisions
+}
+
+// writeDecisions 按格式输出补全决策
+func writeDecisions(w io.Writer, decisions []flagDecision, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(decisions)
+	case "text":
+		for _, d := range decisions {
+			kind := string(d.Kind)
+			if kind == "" {
+				kind = "-"
+			}
+			line := fmt.Sprintf("%s %s: %s (%s)", d.Command, d.Flag, kind, d.Rule)
+			if len(d.Values) > 0 {
+				line += " " + strings.Join(d.Values, " ")
+			}
+			fmt.Fprintln(w, line)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
@@ -0,0 +1,254 @@
+package command
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// GenerateFish 从 cli.Command 自动生成 fish 补全脚本
+func GenerateFish(w io.Writer, cmd *cli.Command) error {
+	opts := GenerateOptions{}
+	return GenerateFishFromSpec(w, BuildCompletionSpec(cmd, opts), opts)
+}
+
+// GenerateFishFromSpec 从补全规格生成 fish 补全脚本
+// 每个子命令和 flag 对应一条 complete -c 语句，以 -d 附带描述，
+// 枚举值（含从 Usage 解析的候选）以 -a 列出；zsh 专有的选项（Locale、HelperFile 等）被忽略
+func GenerateFishFromSpec(w io.Writer, spec *CompletionSpec, opts GenerateOptions) error {
+	if opts.Runtime {
+		return fmt.Errorf("runtime completion is not supported for fish")
+	}
+	g := &fishGenerator{
+		opts:   opts,
+		prefix: "_" + toZshFuncName(spec.Root.Name),
+		binary: spec.Root.Name,
+	}
+
+	nodes := collectCommandNodes(&spec.Root, toZshFuncName(spec.Root.Name))
+
+	var body strings.Builder
+	for _, n := range nodes {
+		g.generateCommand(&body, n)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s fish completion script (auto-generated)\n\n", spec.Root.Name))
+	g.generateLocateFunction(&sb, nodes)
+	if g.dynamic {
+		g.generateCompleteHelper(&sb)
+	}
+	// 默认不补全文件，只有文件类 flag 通过 -F 开启
+	fmt.Fprintf(&sb, "complete -c %s -f\n", g.binary)
+	sb.WriteString(body.String())
+
+	script := reindent(sb.String(), opts.Indent)
+	if opts.Checksum {
+		sum := sha256.Sum256([]byte(script))
+		script += checksumLine(sum[:])
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+// fishGenerator fish 补全脚本生成器
+type fishGenerator struct {
+	opts    GenerateOptions
+	prefix  string // 辅助函数名前缀，如 __vm_metrics
+	binary  string // 补全的程序名，也用于调用 __complete
+	dynamic bool   // 是否用到动态补全辅助函数
+}
+
+// generateLocateFunction 生成定位当前子命令的函数
+// <prefix>_cmd 输出已输入的命令路径对应的标识（与 bash 的 case 分支一致），
+// <prefix>_using 判断当前是否位于指定命令，供 complete -n 使用
+func (g *fishGenerator) generateLocateFunction(sb *strings.Builder, nodes []commandNode) {
+	fmt.Fprintf(sb, "function %s_cmd\n", g.prefix)
+	fmt.Fprintf(sb, "    set -l cmd %s\n", nodes[0].funcName)
+	sb.WriteString("    set -l skip 0\n")
+	sb.WriteString("    for tok in (commandline -opc)[2..-1]\n")
+	sb.WriteString("        if test $skip = 1\n")
+	sb.WriteString("            set skip 0\n")
+	sb.WriteString("            continue\n")
+	sb.WriteString("        end\n")
+	sb.WriteString("        switch \"$cmd:$tok\"\n")
+	for _, n := range nodes {
+		var skips []string
+		for _, f := range n.cmd.Flags {
+			if f.Value == ValueNone {
+				continue
+			}
+			for _, name := range f.Names {
+				skips = append(skips, fishQuote(n.funcName+":"+flagDisplayName(name)))
+			}
+		}
+		if len(skips) > 0 {
+			fmt.Fprintf(sb, "            case %s\n", strings.Join(skips, " "))
+			sb.WriteString("                set skip 1\n")
+		}
+		names := subcommandFuncNames(n.funcName, n.cmd.Commands)
+		for j, sub := range n.cmd.Commands {
+			var patterns []string
+			for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+				patterns = append(patterns, fishQuote(n.funcName+":"+name))
+			}
+			fmt.Fprintf(sb, "            case %s\n", strings.Join(patterns, " "))
+			fmt.Fprintf(sb, "                set cmd %s\n", names[j])
+		}
+	}
+	sb.WriteString("        end\n")
+	sb.WriteString("    end\n")
+	sb.WriteString("    echo $cmd\n")
+	sb.WriteString("end\n\n")
+
+	fmt.Fprintf(sb, "function %s_using\n", g.prefix)
+	fmt.Fprintf(sb, "    test (%s_cmd) = $argv[1]\n", g.prefix)
+	sb.WriteString("end\n\n")
+}
+
+// generateCommand 生成单个命令的子命令和 flag 补全语句
+func (g *fishGenerator) generateCommand(sb *strings.Builder, n commandNode) {
+	cond := fmt.Sprintf("%s_using %s", g.prefix, n.funcName)
+	for _, sub := range n.cmd.Commands {
+		if sub.Hidden {
+			continue
+		}
+		subCond := cond
+		if sub.Gate != "" {
+			// 受特性开关控制的命令只在环境变量非空时列出
+			subCond += fmt.Sprintf("; and test -n \"$%s\"", sub.Gate)
+		}
+		fmt.Fprintf(sb, "complete -c %s -n %s -a %s", g.binary, fishQuote(subCond), fishQuote(sub.Name))
+		if sub.Usage != "" {
+			fmt.Fprintf(sb, " -d %s", fishQuote(sub.Usage))
+		}
+		sb.WriteString("\n")
+	}
+
+	seen := make(map[string]bool)
+	for _, f := range n.cmd.Flags {
+		if firstDuplicate(seen, f.Names) {
+			continue
+		}
+		fmt.Fprintf(sb, "complete -c %s -n %s", g.binary, fishQuote(cond))
+		for _, name := range f.Names {
+			if len(name) == 1 {
+				fmt.Fprintf(sb, " -s %s", name)
+			} else {
+				fmt.Fprintf(sb, " -l %s", name)
+			}
+		}
+		if action := g.valueAction(f); action != "" {
+			sb.WriteString(" " + action)
+		}
+		if f.Usage != "" {
+			fmt.Fprintf(sb, " -d %s", fishQuote(f.Usage))
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// firstDuplicate 判断 names 中是否有名称已出现过，并记录 names
+// 重复定义的 flag 只保留第一个，与 zsh 生成器一致
+func firstDuplicate(seen map[string]bool, names []string) bool {
+	for _, name := range names {
+		if seen[name] {
+			return true
+		}
+	}
+	for _, name := range names {
+		seen[name] = true
+	}
+	return false
+}
+
+// valueAction 返回补全 flag 值的 complete 选项
+// -r 表示需要值，-x 表示需要值且不补全文件，-F 在需要值时补全文件
+func (g *fishGenerator) valueAction(f FlagSpec) string {
+	if f.Value == ValueNone {
+		return ""
+	}
+	if g.opts.NamesOnly {
+		return "-x"
+	}
+	switch f.Value {
+	case ValueEnum, ValueDuration:
+		if len(f.Values) == 0 {
+			return "-x"
+		}
+		return "-x -a " + fishQuote(bashEscapeWords(f.Values))
+	case ValueFile, ValueConfig:
+		return "-r -F"
+	case ValueDir:
+		return "-x -a '(__fish_complete_directories)'"
+	case ValueHost:
+		return "-x -a '(__fish_print_hostnames)'"
+	case ValueOutput:
+		return "-r -F -a " + fishQuote(bashEscapeWords(f.Values))
+	case ValueDynamic:
+		g.dynamic = true
+		call := g.prefix + "_complete"
+		if f.ContextOf != "" {
+			call += " -c " + f.ContextOf
+		}
+		if f.Separator != "" {
+			call += " -s " + bashEscapeWord(f.Separator)
+		}
+		call += " " + f.Dynamic
+		if len(f.Fallback) > 0 {
+			call += " " + bashEscapeWords(f.Fallback)
+		}
+		candidates := "(" + call + ")"
+		if len(f.Values) > 0 {
+			candidates = bashEscapeWords(f.Values) + " " + candidates
+		}
+		action := "-x"
+		if f.FallbackFiles {
+			// fish 无法只在动态候选为空时补全文件，改为同时提供文件
+			action = "-r -F"
+		}
+		return action + " -a " + fishQuote(candidates)
+	default:
+		return "-x"
+	}
+}
+
+// generateCompleteHelper 生成带超时调用 __complete 的辅助函数
+// 用法: <helper> [-c <flag>] [-s <sep>] <type> [<fallback>...]
+// -c 将命令行上已输入的 --<flag> 值作为上下文传给 __complete；
+// -s 指定多值分隔符，候选前补上当前词中已输入的部分；
+// __complete 超时或失败（无输出）时输出 fallback 静态候选
+func (g *fishGenerator) generateCompleteHelper(sb *strings.Builder) {
+	timeout := g.opts.CompleteTimeout
+	if timeout <= 0 {
+		timeout = defaultCompleteTimeout
+	}
+	fmt.Fprintf(sb, "function %s_complete\n", g.prefix)
+	sb.WriteString("    argparse 'c=' 's=' -- $argv; or return\n")
+	sb.WriteString("    set -l tokens (commandline -opc)\n")
+	sb.WriteString("    set -l args\n")
+	sb.WriteString("    if set -q _flag_c\n")
+	sb.WriteString("        set -l i (contains -i -- --$_flag_c $tokens)\n")
+	sb.WriteString("        and set -q tokens[(math $i + 1)]\n")
+	sb.WriteString("        and set args $tokens[(math $i + 1)]\n")
+	sb.WriteString("    end\n")
+	sb.WriteString("    set -l run env\n")
+	fmt.Fprintf(sb, "    command -q timeout; and set run timeout %s env\n", strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64))
+	fmt.Fprintf(sb, "    set -l candidates ($run %s=(string join \\n -- $tokens) %s __complete $argv[1] $args 2>/dev/null)\n", completeWordsEnv, g.binary)
+	sb.WriteString("    set -q candidates[1]; or set candidates $argv[2..-1]\n")
+	sb.WriteString("    set -l prefix ''\n")
+	sb.WriteString("    if set -q _flag_s\n")
+	sb.WriteString("        set prefix (string replace -r -- '[^'$_flag_s']*$' '' (commandline -ct))\n")
+	sb.WriteString("    end\n")
+	sb.WriteString("    printf '%s\\n' $prefix$candidates\n")
+	sb.WriteString("end\n\n")
+}
+
+// fishQuote 将字符串放入 fish 单引号（单引号内只需转义 \ 和 '）
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
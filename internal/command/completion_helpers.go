@@ -0,0 +1,297 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+// zsh 辅助函数名称（生成时会加上根命令前缀）
+const (
+	zshHelperContexts    = "contexts"     // 从配置文件读取命名上下文
+	zshHelperDynamic     = "dynamic"      // 调用 __complete 获取动态候选值
+	zshHelperConfigFiles = "config_files" // 配置文件：XDG 约定路径 + 文件补全
+	zshHelperEndpoints   = "endpoints"    // 最近使用的服务端点 + scheme 提示
+	zshHelperRegexp      = "regexp"       // 正则表达式的锚点和模板
+	zshHelperSort        = "sort"         // 排序表达式 field:asc|desc
+	zshHelperUnits       = "units"        // systemctl list-unit-files 列出的 unit
+	zshHelperSelector    = "selector"     // 标签选择器 key<op>"value"
+	zshHelperComplete    = "complete"     // 带超时和上下文调用 __complete，供其他辅助函数使用
+)
+
+// helper 记录需要输出的辅助函数，返回其完整函数名
+func (g *zshGenerator) helper(name string) string {
+	found := false
+	for _, h := range g.helpers {
+		if h == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		g.helpers = append(g.helpers, name)
+	}
+	return g.helperFuncName(name)
+}
+
+// helperFuncName 返回辅助函数的完整函数名
+// 形如 __vm_metrics_contexts，不会与命令函数 (<prefix>__<name>) 冲突
+func (g *zshGenerator) helperFuncName(name string) string {
+	return "_" + g.prefix + "_" + name
+}
+
+// generateHelpers 输出所有被使用过的辅助函数
+func (g *zshGenerator) generateHelpers(sb *strings.Builder) {
+	// 辅助函数之间可能互相引用（如 dynamic 使用 complete），生成过程中 g.helpers 会增长
+	for i := 0; i < len(g.helpers); i++ {
+		switch name := g.helpers[i]; name {
+		case zshHelperContexts:
+			g.generateContextsHelper(sb)
+		case zshHelperDynamic:
+			g.generateDynamicHelper(sb)
+		case zshHelperConfigFiles:
+			g.generateConfigFilesHelper(sb)
+		case zshHelperEndpoints:
+			g.generateEndpointsHelper(sb)
+		case zshHelperRegexp:
+			g.generateRegexpHelper(sb)
+		case zshHelperSort:
+			g.generateSortHelper(sb)
+		case zshHelperUnits:
+			g.generateUnitsHelper(sb)
+		case zshHelperSelector:
+			g.generateSelectorHelper(sb)
+		case zshHelperComplete:
+			g.generateCompleteHelper(sb)
+		}
+	}
+}
+
+// generateContextsHelper 生成读取命名上下文的辅助函数
+// 补全时读取 --config 指定的文件，未指定则按默认路径搜索第一个可读文件，
+// 支持 contexts 下的 map 形式 (prod: ...) 与列表形式 (- name: prod)；
+// 文件不存在或无 contexts 时不提供候选
+func (g *zshGenerator) generateContextsHelper(sb *strings.Builder) {
+	g.comment(sb, "", "%s: 读取配置文件 contexts 下的上下文名称", g.helperFuncName(zshHelperContexts))
+	fmt.Fprintf(sb, "%s() {\n", g.helperFuncName(zshHelperContexts))
+	sb.WriteString("    local -a files contexts\n")
+	sb.WriteString("    local f line in_block=0\n")
+	sb.WriteString("    if [[ -n ${opt_args[--config]:-${opt_args[-c]}} ]]; then\n")
+	sb.WriteString("        files=(${~opt_args[--config]:-${opt_args[-c]}})\n")
+	sb.WriteString("    else\n")
+	fmt.Fprintf(sb, "        files=(%s)\n", strings.Join(zshConfigPaths(g.appName), " "))
+	sb.WriteString("    fi\n")
+	sb.WriteString("    for f in $files; do\n")
+	sb.WriteString("        [[ -r $f ]] || continue\n")
+	sb.WriteString("        while IFS= read -r line; do\n")
+	sb.WriteString("            if [[ $line == contexts:* ]]; then\n")
+	sb.WriteString("                in_block=1\n")
+	sb.WriteString("                continue\n")
+	sb.WriteString("            fi\n")
+	sb.WriteString("            (( in_block )) || continue\n")
+	sb.WriteString("            [[ $line == [^[:space:]#]* ]] && break\n")
+	sb.WriteString("            if [[ $line =~ '^[[:space:]]*-[[:space:]]*name:[[:space:]]*([^[:space:]#]+)' ]]; then\n")
+	sb.WriteString("                contexts+=(${match[1]//[\\\"\\']/})\n")
+	sb.WriteString("            elif [[ $line =~ '^  ([^[:space:]#:-][^:]*):' ]]; then\n")
+	sb.WriteString("                contexts+=(${match[1]})\n")
+	sb.WriteString("            fi\n")
+	sb.WriteString("        done < $f\n")
+	sb.WriteString("        break\n")
+	sb.WriteString("    done\n")
+	fmt.Fprintf(sb, "    _describe -t contexts '%s' contexts\n", g.msg(MsgContexts))
+	sb.WriteString("}\n\n")
+}
+
+// defaultCompleteTimeout 调用 __complete 的默认超时
+const defaultCompleteTimeout = 2 * time.Second
+
+// generateCompleteHelper 生成调用 __complete 的底层函数
+// 用法: <helper> <type> [args...]，每行输出一个候选值
+// 光标之前的已输入单词（子命令、位置参数等）通过 COMPLETE_WORDS 环境变量传给 __complete；
+// 有 timeout 命令时限制运行时间，后端缓慢时返回空结果，由调用方回退到静态候选
+func (g *zshGenerator) generateCompleteHelper(sb *strings.Builder) {
+	timeout := g.opts.CompleteTimeout
+	if timeout <= 0 {
+		timeout = defaultCompleteTimeout
+	}
+	g.comment(sb, "", "%s: 带超时调用 %s __complete，并传入已输入的单词", g.helperFuncName(zshHelperComplete), g.binary)
+	fmt.Fprintf(sb, "%s() {\n", g.helperFuncName(zshHelperComplete))
+	sb.WriteString("    local kind=$1; shift\n")
+	fmt.Fprintf(sb, "    local line=\"%s=${(pj:\\n:)words[1,CURRENT-1]}\"\n", completeWordsEnv)
+	sb.WriteString("    local -a run\n")
+	sb.WriteString("    run=(env)\n")
+	fmt.Fprintf(sb, "    (( $+commands[timeout] )) && run=(timeout %s env)\n", strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64))
+	fmt.Fprintf(sb, "    _call_program $kind $run ${(q)line} %s __complete $kind ${(q)@} 2>/dev/null\n", g.binary)
+	sb.WriteString("}\n\n")
+}
+
+// generateDynamicHelper 生成获取动态候选值的辅助函数
+// 用法: <helper> <type> [-c <flag>] [-s <sep>] [-a <values>] [-f <values> | -F]
+// -c 将命令行上已输入的 --<flag> 值作为上下文传给 __complete；
+// -s 指定多值分隔符，由 _values 排除当前词中已选择的值；
+// -a 逗号分隔的固定候选（如 namespace 的 * 和 all），排在动态候选之前，__complete 失败时仍提供；
+// -f/-F __complete 超时或失败时回退到逗号分隔的静态候选或文件补全
+func (g *zshGenerator) generateDynamicHelper(sb *strings.Builder) {
+	g.comment(sb, "", "%s: 调用 %s __complete 获取动态候选值", g.helperFuncName(zshHelperDynamic), g.binary)
+	fmt.Fprintf(sb, "%s() {\n", g.helperFuncName(zshHelperDynamic))
+	sb.WriteString("    local kind=$1; shift\n")
+	sb.WriteString("    local -A opts\n")
+	sb.WriteString("    local -a args candidates\n")
+	sb.WriteString("    zparseopts -D -A opts c: s: a: f: F\n")
+	sb.WriteString("    if [[ -n ${opts[-c]} && -n ${opt_args[--${opts[-c]}]} ]]; then\n")
+	sb.WriteString("        args=(${opt_args[--${opts[-c]}]})\n")
+	sb.WriteString("    fi\n")
+	fmt.Fprintf(sb, "    candidates=(${(f)\"$(%s $kind $args)\"})\n", g.helper(zshHelperComplete))
+	sb.WriteString("    if (( ! $#candidates )); then\n")
+	sb.WriteString("        if (( ${+opts[-F]} )); then\n")
+	sb.WriteString("            _files\n")
+	sb.WriteString("            return\n")
+	sb.WriteString("        fi\n")
+	sb.WriteString("        candidates=(${(s:,:)opts[-f]})\n")
+	sb.WriteString("    fi\n")
+	sb.WriteString("    [[ -n ${opts[-a]} ]] && candidates=(${(s:,:)opts[-a]} $candidates)\n")
+	sb.WriteString("    (( $#candidates )) || return 1\n")
+	sb.WriteString("    if [[ -n ${opts[-s]} ]]; then\n")
+	sb.WriteString("        _values -s ${opts[-s]} $kind ${candidates//:/\\:}\n")
+	sb.WriteString("    else\n")
+	sb.WriteString("        _describe -t $kind $kind candidates\n")
+	sb.WriteString("    fi\n")
+	sb.WriteString("}\n\n")
+}
+
+// generateConfigFilesHelper 生成配置文件补全函数
+// 先提示 $XDG_CONFIG_HOME/<app>/config.yaml 与 ~/.config/<app>/config.yaml，
+// 再回退到普通文件补全；未设置 XDG_CONFIG_HOME 时两者相同，只提示一次
+func (g *zshGenerator) generateConfigFilesHelper(sb *strings.Builder) {
+	g.comment(sb, "", "%s: 提示 XDG 约定的配置文件路径，并回退到文件补全", g.helperFuncName(zshHelperConfigFiles))
+	fmt.Fprintf(sb, "%s() {\n", g.helperFuncName(zshHelperConfigFiles))
+	sb.WriteString("    local -a hints\n")
+	fmt.Fprintf(sb, "    hints=(${XDG_CONFIG_HOME:-$HOME/.config}/%s/config.yaml)\n", g.appName)
+	sb.WriteString("    if [[ -n $XDG_CONFIG_HOME && $XDG_CONFIG_HOME != $HOME/.config ]]; then\n")
+	fmt.Fprintf(sb, "        hints+=($HOME/.config/%s/config.yaml)\n", g.appName)
+	sb.WriteString("    fi\n")
+	sb.WriteString("    _alternative \\\n")
+	fmt.Fprintf(sb, "        'hints:%s:compadd -a hints' \\\n", g.msg(MsgDefaultConfig))
+	fmt.Fprintf(sb, "        'files:%s:_files'\n", g.msg(MsgFiles))
+	sb.WriteString("}\n\n")
+}
+
+// generateEndpointsHelper 生成服务端点补全函数
+// 读取 RecordEndpoint 维护的历史文件（每行一个，最近使用的在前），
+// 保持文件中的顺序提示，并附带 scheme 提示；历史文件不存在时只提示 scheme
+func (g *zshGenerator) generateEndpointsHelper(sb *strings.Builder) {
+	g.comment(sb, "", "%s: 提示最近使用的服务端点（历史文件由每次运行更新）", g.helperFuncName(zshHelperEndpoints))
+	fmt.Fprintf(sb, "%s() {\n", g.helperFuncName(zshHelperEndpoints))
+	sb.WriteString("    local -a recent schemes\n")
+	fmt.Fprintf(sb, "    local f=${XDG_STATE_HOME:-$HOME/.local/state}/%s/endpoints\n", g.appName)
+	sb.WriteString("    [[ -r $f ]] && recent=(${(f)\"$(<$f)\"})\n")
+	sb.WriteString("    schemes=(http:// https://)\n")
+	sb.WriteString("    _alternative \\\n")
+	fmt.Fprintf(sb, "        'recent:%s:compadd -V recent -a recent' \\\n", g.msg(MsgRecentEndpoints))
+	fmt.Fprintf(sb, "        'schemes:%s:compadd -S \"\" -a schemes'\n", g.msg(MsgSchemes))
+	sb.WriteString("}\n\n")
+}
+
+// generateRegexpHelper 生成正则表达式补全函数
+// 提示 ^ $ 锚点和 .* 模板作为起点（常被遗忘的锚定），不加引号和后缀，可继续自由输入
+func (g *zshGenerator) generateRegexpHelper(sb *strings.Builder) {
+	g.comment(sb, "", "%s: 提示正则表达式的锚点和模板", g.helperFuncName(zshHelperRegexp))
+	fmt.Fprintf(sb, "%s() {\n", g.helperFuncName(zshHelperRegexp))
+	sb.WriteString("    local -a anchors templates\n")
+	sb.WriteString("    anchors=('^' '$')\n")
+	sb.WriteString("    templates=('.*' '^.*$')\n")
+	sb.WriteString("    _alternative \\\n")
+	fmt.Fprintf(sb, "        'anchors:%s:compadd -Q -S \"\" -a anchors' \\\n", g.msg(MsgAnchors))
+	fmt.Fprintf(sb, "        'templates:%s:compadd -Q -S \"\" -a templates'\n", g.msg(MsgTemplates))
+	sb.WriteString("}\n\n")
+}
+
+// generateSortHelper 生成排序表达式补全函数
+// 用法: <helper> [-c <flag>]
+// 光标在 : 之后时补全排序方向；否则以 --<flag> 的值为上下文调用 __complete fields 补全字段，
+// 补全后自动追加 :；获取不到字段时直接补全 asc/desc
+func (g *zshGenerator) generateSortHelper(sb *strings.Builder) {
+	g.comment(sb, "", "%s: 补全 field:asc|desc 形式的排序表达式", g.helperFuncName(zshHelperSort))
+	fmt.Fprintf(sb, "%s() {\n", g.helperFuncName(zshHelperSort))
+	sb.WriteString("    local -A opts\n")
+	sb.WriteString("    local -a expl args fields directions\n")
+	sb.WriteString("    directions=(asc desc)\n")
+	sb.WriteString("    zparseopts -D -A opts c:\n")
+	sb.WriteString("    if compset -P '*:'; then\n")
+	fmt.Fprintf(sb, "        _wanted directions expl '%s' compadd -a directions\n", g.msg(MsgSortDirections))
+	sb.WriteString("        return\n")
+	sb.WriteString("    fi\n")
+	sb.WriteString("    if [[ -n ${opts[-c]} && -n ${opt_args[--${opts[-c]}]} ]]; then\n")
+	sb.WriteString("        args=(${opt_args[--${opts[-c]}]})\n")
+	sb.WriteString("    fi\n")
+	fmt.Fprintf(sb, "    fields=(${(f)\"$(%s %s $args)\"})\n", g.helper(zshHelperComplete), dynamicFields)
+	sb.WriteString("    if (( $#fields )); then\n")
+	fmt.Fprintf(sb, "        _wanted fields expl '%s' compadd -S : -a fields\n", g.msg(MsgSortFields))
+	sb.WriteString("    else\n")
+	fmt.Fprintf(sb, "        _wanted directions expl '%s' compadd -a directions\n", g.msg(MsgSortDirections))
+	sb.WriteString("    fi\n")
+	sb.WriteString("}\n\n")
+}
+
+// unitListTimeout 列出 systemd unit 的超时秒数，避免 systemd 无响应时卡住补全
+const unitListTimeout = 2
+
+// generateUnitsHelper 生成 systemd unit 补全函数
+// 运行 systemctl list-unit-files --no-legend 并取每行第一列；有 timeout 命令时限制运行时间，
+// 没有 systemctl（非 systemd 系统、容器）或命令失败时不提供候选
+func (g *zshGenerator) generateUnitsHelper(sb *strings.Builder) {
+	g.comment(sb, "", "%s: 补全 systemctl list-unit-files 列出的 unit", g.helperFuncName(zshHelperUnits))
+	fmt.Fprintf(sb, "%s() {\n", g.helperFuncName(zshHelperUnits))
+	sb.WriteString("    local -a expl cmd units\n")
+	sb.WriteString("    (( $+commands[systemctl] )) || return 1\n")
+	sb.WriteString("    cmd=(systemctl list-unit-files --no-legend)\n")
+	fmt.Fprintf(sb, "    (( $+commands[timeout] )) && cmd=(timeout %d $cmd)\n", unitListTimeout)
+	sb.WriteString("    units=(${${(f)\"$(_call_program units $cmd 2>/dev/null)\"}%% *})\n")
+	sb.WriteString("    (( $#units )) || return 1\n")
+	fmt.Fprintf(sb, "    _wanted units expl '%s' compadd -a units\n", g.msg(MsgUnits))
+	sb.WriteString("}\n\n")
+}
+
+// generateSelectorHelper 生成标签选择器补全函数
+// 多个匹配器以逗号分隔，只补全最后一个：已输入操作符时补全该标签的值（加双引号），
+// 输入了完整的标签名时补全操作符，否则补全标签名；获取不到标签时在输入后直接提示操作符
+func (g *zshGenerator) generateSelectorHelper(sb *strings.Builder) {
+	g.comment(sb, "", "%s: 补全 key<op>\"value\" 形式的标签选择器", g.helperFuncName(zshHelperSelector))
+	fmt.Fprintf(sb, "%s() {\n", g.helperFuncName(zshHelperSelector))
+	sb.WriteString("    local -a expl keys values operators\n")
+	sb.WriteString("    local key\n")
+	sb.WriteString("    operators=('=' '!=' '=~' '!~')\n")
+	sb.WriteString("    compset -P '*,'\n")
+	sb.WriteString("    if [[ $PREFIX == *[=~]* ]]; then\n")
+	sb.WriteString("        key=${PREFIX%%[=!]*}\n")
+	sb.WriteString("        compset -P '*[=~]'\n")
+	fmt.Fprintf(sb, "        values=(${(f)\"$(%s %s $key)\"})\n", g.helper(zshHelperComplete), dynamicLabelValues)
+	fmt.Fprintf(sb, "        _wanted values expl '%s' compadd -Q -P '\"' -S '\"' -a values\n", g.msg(MsgLabelValues))
+	sb.WriteString("        return\n")
+	sb.WriteString("    fi\n")
+	fmt.Fprintf(sb, "    keys=(${(f)\"$(%s %s)\"})\n", g.helper(zshHelperComplete), dynamicLabels)
+	sb.WriteString("    if (( ${keys[(Ie)$PREFIX]} )) || [[ -n $PREFIX && $#keys -eq 0 ]]; then\n")
+	sb.WriteString("        compset -P '*'\n")
+	fmt.Fprintf(sb, "        _wanted operators expl '%s' compadd -Q -S '' -a operators\n", g.msg(MsgOperators))
+	sb.WriteString("    else\n")
+	fmt.Fprintf(sb, "        _wanted labels expl '%s' compadd -S '' -a keys\n", g.msg(MsgLabels))
+	sb.WriteString("    fi\n")
+	sb.WriteString("}\n\n")
+}
+
+// zshConfigPaths 返回默认配置文件路径，主目录替换为 ~ 以便在其他机器上使用
+func zshConfigPaths(appName string) []string {
+	home, _ := os.UserHomeDir()
+	var paths []string
+	for _, p := range config.DefaultConfigPaths(appName) {
+		if home != "" && strings.HasPrefix(p, home+"/") {
+			p = "~" + strings.TrimPrefix(p, home)
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
@@ -0,0 +1,113 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Messages 生成器内部使用的文本（help 说明、分组标签等），key 为 Msg* 常量
+// 只影响生成器自身输出的文本，命令和 flag 的 Usage 保持声明时的原文
+type Messages map[string]string
+
+// 生成器内部文本的 key
+const (
+	MsgHelp            = "help"             // --help 的说明
+	MsgCommands        = "commands"         // 子命令分组标签
+	MsgNoArguments     = "no-arguments"     // 无参数命令的提示
+	MsgDeprecated      = "deprecated"       // 已弃用 flag 的标注
+	MsgContexts        = "contexts"         // 命名上下文分组标签
+	MsgDefaultConfig   = "default-config"   // 默认配置文件分组标签
+	MsgFiles           = "files"            // 文件分组标签
+	MsgRecentEndpoints = "recent-endpoints" // 最近使用的端点分组标签
+	MsgSchemes         = "schemes"          // scheme 提示分组标签
+	MsgDestinations    = "destinations"     // 输出目标分组标签
+	MsgAnchors         = "anchors"          // 正则锚点分组标签
+	MsgTemplates       = "templates"        // 正则模板分组标签
+	MsgSortFields      = "sort-fields"      // 排序字段分组标签
+	MsgSortDirections  = "sort-directions"  // 排序方向分组标签
+	MsgUnits           = "units"            // systemd unit 分组标签
+	MsgLabels          = "labels"           // 标签名分组标签
+	MsgOperators       = "operators"        // 选择器操作符分组标签
+	MsgLabelValues     = "label-values"     // 标签值分组标签
+)
+
+// 内置语言
+const (
+	LocaleZh = "zh" // 默认，与未设置 Locale 时的输出一致
+	LocaleEn = "en"
+)
+
+// localeMessages 内置的各语言文本
+var localeMessages = map[string]Messages{
+	LocaleZh: {
+		MsgHelp:            "显示帮助信息",
+		MsgCommands:        "commands",
+		MsgNoArguments:     "no arguments",
+		MsgDeprecated:      "deprecated",
+		MsgContexts:        "context",
+		MsgDefaultConfig:   "default config",
+		MsgFiles:           "file",
+		MsgRecentEndpoints: "recent endpoint",
+		MsgSchemes:         "scheme",
+		MsgDestinations:    "destination",
+		MsgAnchors:         "anchor",
+		MsgTemplates:       "template",
+		MsgSortFields:      "sort field",
+		MsgSortDirections:  "sort direction",
+		MsgUnits:           "systemd unit",
+		MsgLabels:          "label",
+		MsgOperators:       "operator",
+		MsgLabelValues:     "label value",
+	},
+	LocaleEn: {
+		MsgHelp:            "show help",
+		MsgCommands:        "commands",
+		MsgNoArguments:     "no arguments",
+		MsgDeprecated:      "deprecated",
+		MsgContexts:        "context",
+		MsgDefaultConfig:   "default config",
+		MsgFiles:           "file",
+		MsgRecentEndpoints: "recent endpoint",
+		MsgSchemes:         "scheme",
+		MsgDestinations:    "destination",
+		MsgAnchors:         "anchor",
+		MsgTemplates:       "template",
+		MsgSortFields:      "sort field",
+		MsgSortDirections:  "sort direction",
+		MsgUnits:           "systemd unit",
+		MsgLabels:          "label",
+		MsgOperators:       "operator",
+		MsgLabelValues:     "label value",
+	},
+}
+
+// locales 返回内置语言列表
+func locales() []string {
+	return []string{LocaleZh, LocaleEn}
+}
+
+// resolveMessages 按 Locale 选择内置文本，再用 overrides 覆盖
+// Locale 为空时使用 zh；未知 Locale 返回错误
+func resolveMessages(locale string, overrides Messages) (Messages, error) {
+	if locale == "" {
+		locale = LocaleZh
+	}
+	base, ok := localeMessages[locale]
+	if !ok {
+		return nil, fmt.Errorf("unsupported locale: %s (supported: %s)", locale, strings.Join(locales(), ", "))
+	}
+	msgs := make(Messages, len(base)+len(overrides))
+	for k, v := range base {
+		msgs[k] = v
+	}
+	for k, v := range overrides {
+		msgs[k] = v
+	}
+	return msgs, nil
+}
+
+// msg 返回 key 对应的文本，已转义为可放入 zsh 单引号的形式
+// 文本会作为 _alternative 的描述，覆盖时不应包含 :
+func (g *zshGenerator) msg(key string) string {
+	return strings.ReplaceAll(g.messages[key], "'", "'\\''")
+}
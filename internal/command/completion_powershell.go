@@ -0,0 +1,211 @@
+package command
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// GeneratePowerShell 从 cli.Command 自动生成 PowerShell 补全脚本
+func GeneratePowerShell(w io.Writer, cmd *cli.Command) error {
+	opts := GenerateOptions{}
+	return GeneratePowerShellFromSpec(w, BuildCompletionSpec(cmd, opts), opts)
+}
+
+// GeneratePowerShellFromSpec 从补全规格生成 PowerShell 补全脚本
+// 通过 Register-ArgumentCompleter -Native 注册，同时匹配 <name> 与 Windows 下的 <name>.exe；
+// 子命令和 flag 的描述作为 ToolTip 显示。补全器返回空结果时 PowerShell 回退到路径补全，
+// 因此文件类 flag 直接返回空，没有候选的值（数字、密钥等）也会得到路径补全
+func GeneratePowerShellFromSpec(w io.Writer, spec *CompletionSpec, opts GenerateOptions) error {
+	if opts.Runtime {
+		return fmt.Errorf("runtime completion is not supported for powershell")
+	}
+	g := &powershellGenerator{opts: opts, binary: spec.Root.Name}
+	nodes := collectCommandNodes(&spec.Root, toZshFuncName(spec.Root.Name))
+
+	var values, commands strings.Builder
+	for _, n := range nodes {
+		g.generateValues(&values, n)
+		g.generateCommand(&commands, n)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s PowerShell completion script (auto-generated)\n\n", spec.Root.Name))
+	fmt.Fprintf(&sb, "Register-ArgumentCompleter -Native -CommandName %s, %s -ScriptBlock {\n", psQuote(g.binary), psQuote(g.binary+".exe"))
+	sb.WriteString("    param($wordToComplete, $commandAst, $cursorPosition)\n\n")
+
+	// 光标之前已输入的单词，不含正在输入的单词
+	sb.WriteString("    $words = @($commandAst.CommandElements | Where-Object { $_.Extent.EndOffset -le $cursorPosition } | ForEach-Object { $_.ToString() })\n")
+	sb.WriteString("    if ($wordToComplete -ne '' -and $words.Count -gt 1) {\n")
+	sb.WriteString("        $words = @($words[0..($words.Count - 2)])\n")
+	sb.WriteString("    }\n\n")
+
+	// 沿已输入的子命令向下定位，跳过接受值的 flag 及其值
+	fmt.Fprintf(&sb, "    $cmd = %s\n", psQuote(nodes[0].funcName))
+	sb.WriteString("    for ($i = 1; $i -lt $words.Count; $i++) {\n")
+	sb.WriteString("        switch -Exact -CaseSensitive ($cmd + ':' + $words[$i]) {\n")
+	for _, n := range nodes {
+		for _, f := range n.cmd.Flags {
+			if f.Value == ValueNone {
+				continue
+			}
+			for _, name := range f.Names {
+				fmt.Fprintf(&sb, "            %s { $i++ }\n", psQuote(n.funcName+":"+flagDisplayName(name)))
+			}
+		}
+		names := subcommandFuncNames(n.funcName, n.cmd.Commands)
+		for j, sub := range n.cmd.Commands {
+			for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+				fmt.Fprintf(&sb, "            %s { $cmd = %s }\n", psQuote(n.funcName+":"+name), psQuote(names[j]))
+			}
+		}
+	}
+	sb.WriteString("        }\n")
+	sb.WriteString("    }\n")
+	sb.WriteString("    $prev = $words[-1]\n\n")
+
+	sb.WriteString("    $results = [System.Collections.Generic.List[System.Management.Automation.CompletionResult]]::new()\n")
+	sb.WriteString("    $add = {\n")
+	sb.WriteString("        param($text, $tip, $type)\n")
+	sb.WriteString("        if ($text.StartsWith($wordToComplete, [System.StringComparison]::Ordinal)) {\n")
+	sb.WriteString("            $results.Add([System.Management.Automation.CompletionResult]::new($text, $text, $type, $(if ($tip) { $tip } else { $text })))\n")
+	sb.WriteString("        }\n")
+	sb.WriteString("    }\n")
+	if g.dynamic {
+		g.generateCompleteHelper(&sb)
+	}
+	sb.WriteString("\n")
+
+	// 前一个词是接受值的 flag 时补全其值
+	sb.WriteString("    switch -Exact -CaseSensitive ($cmd + ':' + $prev) {\n")
+	sb.WriteString(values.String())
+	sb.WriteString("    }\n\n")
+
+	sb.WriteString("    switch -Exact -CaseSensitive ($cmd) {\n")
+	sb.WriteString(commands.String())
+	sb.WriteString("    }\n")
+	sb.WriteString("    $results\n")
+	sb.WriteString("}\n")
+
+	script := reindent(sb.String(), opts.Indent)
+	if opts.Checksum {
+		sum := sha256.Sum256([]byte(script))
+		script += checksumLine(sum[:])
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+// powershellGenerator PowerShell 补全脚本生成器
+type powershellGenerator struct {
+	opts    GenerateOptions
+	binary  string // 补全的程序名，也用于调用 __complete
+	dynamic bool   // 是否用到动态补全辅助脚本块
+}
+
+// generateValues 生成单个命令中接受值的 flag 的 switch 分支
+func (g *powershellGenerator) generateValues(sb *strings.Builder, n commandNode) {
+	seen := make(map[string]bool)
+	for _, f := range n.cmd.Flags {
+		if f.Value == ValueNone || firstDuplicate(seen, f.Names) {
+			continue
+		}
+		action := g.valueAction(f)
+		for _, name := range f.Names {
+			fmt.Fprintf(sb, "        %s { %s }\n", psQuote(n.funcName+":"+flagDisplayName(name)), action)
+		}
+	}
+}
+
+// generateCommand 生成单个命令列出 flag 和子命令的 switch 分支
+func (g *powershellGenerator) generateCommand(sb *strings.Builder, n commandNode) {
+	fmt.Fprintf(sb, "        %s {\n", psQuote(n.funcName))
+	seen := make(map[string]bool)
+	for _, f := range n.cmd.Flags {
+		if firstDuplicate(seen, f.Names) {
+			continue
+		}
+		for _, name := range f.Names {
+			fmt.Fprintf(sb, "            & $add %s %s ParameterName\n", psQuote(flagDisplayName(name)), psQuote(f.Usage))
+		}
+	}
+	for _, sub := range n.cmd.Commands {
+		if sub.Hidden {
+			continue
+		}
+		add := fmt.Sprintf("& $add %s %s ParameterValue", psQuote(sub.Name), psQuote(sub.Usage))
+		if sub.Gate != "" {
+			// 受特性开关控制的命令只在环境变量非空时列出
+			fmt.Fprintf(sb, "            if ($env:%s) { %s }\n", sub.Gate, add)
+			continue
+		}
+		fmt.Fprintf(sb, "            %s\n", add)
+	}
+	sb.WriteString("        }\n")
+}
+
+// valueAction 返回补全 flag 值的语句，均以 return 结束，不再列出 flag 和子命令
+func (g *powershellGenerator) valueAction(f FlagSpec) string {
+	if g.opts.NamesOnly {
+		return "return"
+	}
+	switch f.Value {
+	case ValueEnum, ValueDuration, ValueOutput:
+		if len(f.Values) == 0 {
+			return "return"
+		}
+		return psValues(f.Values) + " | ForEach-Object { & $add $_ $_ ParameterValue }; return $results"
+	case ValueDynamic:
+		g.dynamic = true
+		args := []string{psQuote(f.Dynamic), psQuote(f.ContextOf), psQuote(f.Separator), "@(" + psValues(f.Fallback) + ")"}
+		action := "& $complete " + strings.Join(args, " ")
+		if len(f.Values) > 0 {
+			action = psValues(f.Values) + " | ForEach-Object { & $add $_ $_ ParameterValue }; " + action
+		}
+		if f.FallbackFiles {
+			// 没有候选时返回空，由 PowerShell 回退到路径补全
+			return action + "; if ($results.Count -gt 0) { return $results }; return"
+		}
+		return action + "; return $results"
+	default:
+		// 文件、目录由 PowerShell 的路径补全处理；其他类型没有候选
+		return "return"
+	}
+}
+
+// generateCompleteHelper 生成调用 __complete 的辅助脚本块
+// 参数: 类型、上下文 flag（其在命令行上的值传给 __complete）、多值分隔符、失败时的静态候选
+func (g *powershellGenerator) generateCompleteHelper(sb *strings.Builder) {
+	sb.WriteString("    $complete = {\n")
+	sb.WriteString("        param($kind, $context, $separator, $fallback)\n")
+	sb.WriteString("        $arguments = @('__complete', $kind)\n")
+	sb.WriteString("        if ($context) {\n")
+	sb.WriteString("            $index = [array]::LastIndexOf($words, '--' + $context)\n")
+	sb.WriteString("            if ($index -ge 0 -and $index + 1 -lt $words.Count) { $arguments += $words[$index + 1] }\n")
+	sb.WriteString("        }\n")
+	fmt.Fprintf(sb, "        $env:%s = $words -join \"`n\"\n", completeWordsEnv)
+	fmt.Fprintf(sb, "        $candidates = @(& %s @arguments 2>$null)\n", psQuote(g.binary))
+	fmt.Fprintf(sb, "        Remove-Item Env:%s -ErrorAction SilentlyContinue\n", completeWordsEnv)
+	sb.WriteString("        if ($candidates.Count -eq 0) { $candidates = $fallback }\n")
+	sb.WriteString("        $prefix = ''\n")
+	sb.WriteString("        if ($separator) { $prefix = $wordToComplete -replace ('[^' + [regex]::Escape($separator) + ']*$'), '' }\n")
+	sb.WriteString("        $candidates | ForEach-Object { & $add ($prefix + $_) $_ ParameterValue }\n")
+	sb.WriteString("    }\n")
+}
+
+// psValues 返回以逗号分隔的 PowerShell 字符串列表
+func psValues(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = psQuote(v)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// psQuote 将字符串放入 PowerShell 单引号，其中的单引号重复一次转义
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,224 @@
+package command
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// dynamicWords 运行时补全整个命令行的 __complete 类型
+// 补全脚本以 `<root> __complete words -- <word>...` 调用，最后一个单词为光标所在的单词（可为空），
+// 候选按当前安装的命令树实时生成，命令树变化（如动态注册的子命令）后无需重新生成脚本
+const dynamicWords = "words"
+
+// 运行时补全输出中指示 shell 自行补全的行，放在候选之后
+const (
+	runtimeDirectiveFiles = ":files" // 补全文件
+	runtimeDirectiveDirs  = ":dirs"  // 补全目录
+)
+
+func init() {
+	// completeWords 依赖 BuildCompletionSpec，在 init 中注册以避免初始化循环
+	RegisterDynamicCompleter(dynamicWords, completeWords)
+}
+
+// completeWords 按命令行上已输入的单词返回候选，每个候选为 "<值>\t<描述>"
+func completeWords(ctx context.Context, cmd *cli.Command, args []string) ([]string, error) {
+	spec := BuildCompletionSpec(cmd.Root(), GenerateOptions{})
+	return runtimeCandidates(ctx, cmd, spec, args), nil
+}
+
+// runtimeCandidates 沿已输入的子命令定位当前命令，再补全 flag 值、flag 或子命令
+// 定位规则与生成的静态脚本一致：跳过接受值的 flag 及其值，按名称或别名进入子命令
+func runtimeCandidates(ctx context.Context, cmd *cli.Command, spec *CompletionSpec, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur, done := words[len(words)-1], words[:len(words)-1]
+
+	current := &spec.Root
+	var prev *FlagSpec
+	for i := 0; i < len(done); i++ {
+		prev = nil
+		if f := findFlagSpec(current, done[i]); f != nil {
+			if f.Value != ValueNone {
+				if i == len(done)-1 {
+					prev = f
+				}
+				i++
+			}
+			continue
+		}
+		for j := range current.Commands {
+			if sub := &current.Commands[j]; sub.Name == done[i] || containsString(sub.Aliases, done[i]) {
+				current = sub
+				break
+			}
+		}
+	}
+
+	if prev != nil {
+		return runtimeValues(ctx, cmd, prev, done, cur)
+	}
+
+	var candidates []string
+	add := func(value, usage string) {
+		if strings.HasPrefix(value, cur) {
+			candidates = append(candidates, value+"\t"+usage)
+		}
+	}
+	for _, sub := range current.Commands {
+		if sub.Hidden || sub.Gate != "" && os.Getenv(sub.Gate) == "" {
+			continue
+		}
+		add(sub.Name, sub.Usage)
+	}
+	for _, f := range current.Flags {
+		for _, name := range f.Names {
+			add(flagDisplayName(name), f.Usage)
+		}
+	}
+	return candidates
+}
+
+// runtimeValues 返回 flag 值的候选；文件和目录输出指示行，由 shell 补全
+func runtimeValues(ctx context.Context, cmd *cli.Command, f *FlagSpec, done []string, cur string) []string {
+	var values []string
+	var directive string
+	switch f.Value {
+	case ValueEnum, ValueDuration:
+		values = f.Values
+	case ValueFile, ValueConfig:
+		directive = runtimeDirectiveFiles
+	case ValueOutput:
+		values, directive = f.Values, runtimeDirectiveFiles
+	case ValueDir:
+		directive = runtimeDirectiveDirs
+	case ValueDynamic:
+		values = append(values, f.Values...)
+		var args []string
+		if f.ContextOf != "" {
+			if v := flagValue(done, flagDisplayName(f.ContextOf)); v != "" {
+				args = []string{v}
+			}
+		}
+		dynamic, err := dynamicCompleters[f.Dynamic](ctx, cmd, args)
+		switch {
+		case err == nil && len(dynamic) > 0:
+			values = append(values, dynamic...)
+		case f.FallbackFiles:
+			directive = runtimeDirectiveFiles
+		default:
+			values = append(values, f.Fallback...)
+		}
+	}
+
+	var candidates []string
+	for _, v := range values {
+		if strings.HasPrefix(v, cur) {
+			candidates = append(candidates, v+"\t")
+		}
+	}
+	if directive != "" {
+		candidates = append(candidates, directive)
+	}
+	return candidates
+}
+
+// findFlagSpec 按命令行上的 flag（如 --config、-c）查找当前命令的 flag
+func findFlagSpec(cmd *CommandSpec, word string) *FlagSpec {
+	if !strings.HasPrefix(word, "-") {
+		return nil
+	}
+	name := strings.TrimLeft(word, "-")
+	if i := flagIndex(cmd.Flags, name); i >= 0 {
+		return &cmd.Flags[i]
+	}
+	return nil
+}
+
+// flagValue 返回 words 中最后一次出现的 flag 的值
+func flagValue(words []string, flag string) string {
+	for i := len(words) - 2; i >= 0; i-- {
+		if words[i] == flag {
+			return words[i+1]
+		}
+	}
+	return ""
+}
+
+// containsString 判断 values 中是否包含 s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// generateZshRuntime 生成运行时补全的 zsh 脚本
+// 脚本本身不包含命令树，每次补全都调用 __complete words
+func generateZshRuntime(w io.Writer, spec *CompletionSpec, opts GenerateOptions) error {
+	prefix := toZshFuncName(spec.Root.Name)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "#compdef %s\n\n", spec.Root.Name)
+	fmt.Fprintf(&sb, "# %s zsh completion script (auto-generated, runtime)\n\n", spec.Root.Name)
+	fmt.Fprintf(&sb, "%s() {\n", prefix)
+	sb.WriteString("    local -a lines candidates\n")
+	sb.WriteString("    local line directive\n")
+	fmt.Fprintf(&sb, "    lines=(${(f)\"$(%s __complete %s -- \"${(@)words[2,CURRENT]}\" 2>/dev/null)\"})\n", spec.Root.Name, dynamicWords)
+	sb.WriteString("    for line in $lines; do\n")
+	sb.WriteString("        case $line in\n")
+	fmt.Fprintf(&sb, "            %s) directive=files ;;\n", runtimeDirectiveFiles)
+	fmt.Fprintf(&sb, "            %s) directive=dirs ;;\n", runtimeDirectiveDirs)
+	sb.WriteString("            *) candidates+=(\"${${line%%$'\\t'*}//:/\\\\:}:${line#*$'\\t'}\") ;;\n")
+	sb.WriteString("        esac\n")
+	sb.WriteString("    done\n")
+	sb.WriteString("    (( $#candidates )) && _describe -t values value candidates\n")
+	sb.WriteString("    case $directive in\n")
+	sb.WriteString("        files) _files ;;\n")
+	sb.WriteString("        dirs) _files -/ ;;\n")
+	sb.WriteString("    esac\n")
+	sb.WriteString("}\n")
+	if !opts.NoCompdefCall {
+		fmt.Fprintf(&sb, "\ncompdef %s %s\n", prefix, spec.Root.Name)
+	}
+	return writeRuntimeScript(w, sb.String(), opts)
+}
+
+// generateBashRuntime 生成运行时补全的 bash 脚本
+func generateBashRuntime(w io.Writer, spec *CompletionSpec, opts GenerateOptions) error {
+	prefix := toZshFuncName(spec.Root.Name)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s bash completion script (auto-generated, runtime)\n\n", spec.Root.Name)
+	fmt.Fprintf(&sb, "%s() {\n", prefix)
+	sb.WriteString("    local cur=${COMP_WORDS[COMP_CWORD]} line\n")
+	sb.WriteString("    COMPREPLY=()\n")
+	sb.WriteString("    while IFS= read -r line; do\n")
+	sb.WriteString("        case $line in\n")
+	fmt.Fprintf(&sb, "            %s) compopt -o filenames 2>/dev/null; mapfile -t -O ${#COMPREPLY[@]} COMPREPLY < <(compgen -f -- \"$cur\") ;;\n", runtimeDirectiveFiles)
+	fmt.Fprintf(&sb, "            %s) compopt -o filenames 2>/dev/null; mapfile -t -O ${#COMPREPLY[@]} COMPREPLY < <(compgen -d -- \"$cur\") ;;\n", runtimeDirectiveDirs)
+	sb.WriteString("            *) COMPREPLY+=(\"${line%%$'\\t'*}\") ;;\n")
+	sb.WriteString("        esac\n")
+	fmt.Fprintf(&sb, "    done < <(%s __complete %s -- \"${COMP_WORDS[@]:1:COMP_CWORD}\" 2>/dev/null)\n", spec.Root.Name, dynamicWords)
+	sb.WriteString("}\n\n")
+	fmt.Fprintf(&sb, "complete -F %s %s\n", prefix, spec.Root.Name)
+	return writeRuntimeScript(w, sb.String(), opts)
+}
+
+// writeRuntimeScript 按缩进和校验和选项写入运行时补全脚本
+func writeRuntimeScript(w io.Writer, script string, opts GenerateOptions) error {
+	script = reindent(script, opts.Indent)
+	if opts.Checksum {
+		sum := sha256.Sum256([]byte(script))
+		script += checksumLine(sum[:])
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
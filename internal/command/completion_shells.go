@@ -0,0 +1,81 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// shellAll --shell 的特殊值，生成所有已注册 shell 的补全脚本
+const shellAll = "all"
+
+// shellGenerator 单个 shell 的补全脚本生成器
+type shellGenerator struct {
+	name     string                                                              // shell 名称，即 --shell 的取值
+	fileName func(cmdName string) string                                         // 该 shell 约定的补全文件名
+	generate func(w io.Writer, spec *CompletionSpec, opts GenerateOptions) error // 从补全规格生成脚本
+}
+
+// shellGenerators 已注册的补全脚本生成器，按输出顺序排列
+var shellGenerators = []shellGenerator{
+	{name: "zsh", fileName: func(cmdName string) string { return "_" + cmdName }, generate: GenerateZshFromSpec},
+	{name: "bash", fileName: func(cmdName string) string { return cmdName }, generate: GenerateBashFromSpec},
+	{name: "fish", fileName: func(cmdName string) string { return cmdName + ".fish" }, generate: GenerateFishFromSpec},
+	{name: "powershell", fileName: func(cmdName string) string { return cmdName + ".ps1" }, generate: GeneratePowerShellFromSpec},
+}
+
+// findShellGenerator 按名称查找生成器
+func findShellGenerator(name string) (shellGenerator, error) {
+	for _, g := range shellGenerators {
+		if g.name == name {
+			return g, nil
+		}
+	}
+	return shellGenerator{}, fmt.Errorf("unsupported shell: %s (supported: %s)", name, strings.Join(shellNames(), ", "))
+}
+
+// shellNames 返回所有已注册 shell 的名称
+func shellNames() []string {
+	names := make([]string, 0, len(shellGenerators))
+	for _, g := range shellGenerators {
+		names = append(names, g.name)
+	}
+	return names
+}
+
+// writeCompletionFiles 将补全脚本以约定文件名写入目录，shell 为 all 时写入所有已注册 shell
+// 返回写入的文件路径；dir 必须是已存在的目录
+func writeCompletionFiles(dir, shell string, spec *CompletionSpec, opts GenerateOptions) ([]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat output dir %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("output %s is not a directory", dir)
+	}
+
+	gens := shellGenerators
+	if shell != shellAll {
+		g, err := findShellGenerator(shell)
+		if err != nil {
+			return nil, err
+		}
+		gens = []shellGenerator{g}
+	}
+
+	var paths []string
+	for _, g := range gens {
+		var sb strings.Builder
+		if err := g.generate(&sb, spec, opts); err != nil {
+			return nil, fmt.Errorf("failed to generate %s completion: %w", g.name, err)
+		}
+		path := filepath.Join(dir, g.fileName(spec.Root.Name))
+		if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,986 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// CompletionSpec 从命令树提取的补全规格，与具体 shell 无关
+// 可序列化为 JSON，脱离运行中的命令树重新生成补全脚本
+type CompletionSpec struct {
+	App  string      `json:"app"`  // 应用名称，用于推导配置文件路径
+	Root CommandSpec `json:"root"` // 根命令
+}
+
+// CommandSpec 单个命令的补全规格
+type CommandSpec struct {
+	Name      string        `json:"name"`
+	Aliases   []string      `json:"aliases,omitempty"`
+	Usage     string        `json:"usage,omitempty"`
+	ArgsUsage string        `json:"args_usage,omitempty"` // 位置参数说明，为空表示不接受位置参数
+	Flags     []FlagSpec    `json:"flags,omitempty"`
+	Commands  []CommandSpec `json:"commands,omitempty"` // 需要展开补全的可见子命令
+	Arg       *ArgSpec      `json:"arg,omitempty"`      // 必须先于 flags 输入的位置参数
+	Gate      string        `json:"gate,omitempty"`     // 特性开关环境变量，补全时仅在其非空时提供该命令
+	Hidden    bool          `json:"hidden,omitempty"`   // 不在子命令列表中出现，但输入后仍补全其参数
+}
+
+// MetaKeyFeatureGate 在 cli.Command.Metadata 中声明命令受特性开关控制
+// 值为环境变量名（如 VM_METRICS_PREVIEW），该命令只在变量非空时可用；
+// 生成补全时仅在 GenerateOptions.IncludeFeatureGated 开启时包含（即使当前隐藏）
+const MetaKeyFeatureGate = "completion.feature-gate"
+
+// MetaKeyRequiredArg 在 cli.Command.Metadata 中声明必须先于 flags 输入的位置参数
+// 值为 ArgSpec，如 describe <name> [flags]；仅对没有子命令的命令生效
+const MetaKeyRequiredArg = "completion.required-arg"
+
+// MetaKeyExclusions 在 cli.Command.Metadata 中声明条件排斥
+// 值为 map[string][]string，key 出现在命令行后不再补全其列表中的 flag，
+// 如 {"dry-run": {"confirm"}} 表示已输入 --dry-run 时不再提示 --confirm；名称不含 - 前缀
+const MetaKeyExclusions = "completion.exclusions"
+
+// MetaKeyValueExclusions 在 cli.Command.Metadata 中声明 flag 出现时禁用另一个 flag 的部分枚举值
+// 值为 []ValueExclusion，如 --watch 出现时 --output 不再补全 json；比 MetaKeyExclusions 更细粒度
+const MetaKeyValueExclusions = "completion.value-exclusions"
+
+// MetaKeyFlagCompletions 在 cli.Command.Metadata 中声明该命令 flags 的值补全
+// 值为 map[string]FlagCompletion，key 为 flag 的任一名称或别名（不含 - 前缀），只作用于该命令自身的 flags；
+// 声明优先于按名称和 Usage 的推断，不接受值的 flag 忽略声明
+const MetaKeyFlagCompletions = "completion.flag-completions"
+
+// MetaKeySameAs 在 cli.Command.Metadata 中声明 flag 的补全与另一个 flag 相同
+// 值为 map[string]string，如 {"exclude": "include"} 表示 --exclude 复用 --include 的候选来源（静态或动态）
+const MetaKeySameAs = "completion.same-as"
+
+// ValueExclusion 声明 When 出现在命令行时，Flag 不再补全 Values 中的值（名称不含 - 前缀）
+type ValueExclusion struct {
+	When   string
+	Flag   string
+	Values []string
+}
+
+// ArgSpec 位置参数的补全规格
+type ArgSpec struct {
+	Name   string   `json:"name"`             // 参数名称，用于补全提示
+	Values []string `json:"values,omitempty"` // 固定候选值
+	Glob   string   `json:"glob,omitempty"`   // 文件匹配模式（如 *.yaml），Values 为空时使用
+}
+
+// FlagSpec 单个 flag 的补全规格
+type FlagSpec struct {
+	Names          []string         `json:"names"`                     // flag 名称（不含 - 前缀）
+	Usage          string           `json:"usage,omitempty"`           // 描述
+	Value          ValueKind        `json:"value,omitempty"`           // 值补全类型，空表示不接受值
+	Values         []string         `json:"values,omitempty"`          // ValueEnum 的候选值；ValueOutput、ValueDynamic 附加的固定候选；ValueDuration 的常用取值
+	Repeatable     bool             `json:"repeatable,omitempty"`      // 可在命令行中重复出现
+	Deprecated     bool             `json:"deprecated,omitempty"`      // 已弃用（Usage 以 [DEPRECATED] 开头）
+	Dynamic        string           `json:"dynamic,omitempty"`         // ValueDynamic 的 __complete 类型
+	ContextOf      string           `json:"context_of,omitempty"`      // 作为动态补全上下文传入的 flag 名称
+	Separator      string           `json:"separator,omitempty"`       // 多值分隔符，已选择的值不再补全
+	Excludes       []string         `json:"excludes,omitempty"`        // 互斥的其他 flag 名称（如 --x 与 --no-x，或 MetaKeyExclusions 声明的排斥）
+	Rule           string           `json:"rule,omitempty"`            // 命中的推断规则
+	DisabledValues []DisabledValues `json:"disabled_values,omitempty"` // 其他 flag 出现时不再补全的枚举值
+	Fallback       []string         `json:"fallback,omitempty"`        // ValueDynamic 超时或失败时回退的静态候选
+	FallbackFiles  bool             `json:"fallback_files,omitempty"`  // ValueDynamic 超时或失败时回退到文件补全
+}
+
+// DisabledValues 在 When 中任一 flag 出现时不再补全的枚举值
+type DisabledValues struct {
+	When   []string `json:"when"`   // 触发的 flag 名称（含别名，不含 - 前缀）
+	Values []string `json:"values"` // 不再补全的值
+}
+
+// ValueKind flag 值的补全类型
+type ValueKind string
+
+// flag 值的补全类型
+const (
+	ValueNone     ValueKind = ""         // 不接受值
+	ValueAny      ValueKind = "value"    // 任意值，无候选
+	ValueEnum     ValueKind = "enum"     // 固定候选值
+	ValueFile     ValueKind = "file"     // 文件路径
+	ValueURL      ValueKind = "url"      // URL
+	ValueNumber   ValueKind = "number"   // 数字
+	ValueDuration ValueKind = "duration" // 时间间隔
+	ValueContext  ValueKind = "context"  // 配置文件中的命名上下文
+	ValueDynamic  ValueKind = "dynamic"  // 补全时调用 __complete 获取候选值
+	ValueConfig   ValueKind = "config"   // 配置文件，优先提示 XDG 约定路径
+	ValueEndpoint ValueKind = "endpoint" // 服务端点，提示最近使用的地址
+	ValueOutput   ValueKind = "output"   // 输出目标：文件路径或 Values 中的特殊目标（- 表示 stdout、syslog）
+	ValueDir      ValueKind = "dir"      // 目录
+	ValueRegexp   ValueKind = "regexp"   // Go 正则表达式，提示锚点和模板
+	ValueSort     ValueKind = "sort"     // 排序表达式 field:asc|desc，字段来自 __complete fields
+	ValueUnit     ValueKind = "unit"     // systemd unit，补全时运行 systemctl list-unit-files
+	ValueSecret   ValueKind = "secret"   // 密钥、密码等敏感值，不提供任何候选
+	ValueTemplate ValueKind = "template" // Go 模板字符串，自由输入，不提供候选
+	ValueSelector ValueKind = "selector" // 标签选择器 key<op>"value"，补全标签、操作符和标签值
+	ValueHost     ValueKind = "host"     // 主机名，使用 shell 内置的主机名补全
+)
+
+// FlagCompletion 声明式的 flag 值补全，通过 MetaKeyFlagCompletions 声明
+type FlagCompletion struct {
+	Type    ValueKind // 值补全类型，如 ValueFile、ValueEnum、ValueHost
+	Values  []string  // ValueEnum 的候选值；ValueDynamic 附加的固定候选
+	Dynamic string    // ValueDynamic 的 __complete 类型
+}
+
+// declaredCompletions 返回命令通过 MetaKeyFlagCompletions 声明的补全，未声明时为 nil
+func declaredCompletions(cmd *cli.Command) map[string]FlagCompletion {
+	declared, _ := cmd.Metadata[MetaKeyFlagCompletions].(map[string]FlagCompletion)
+	return declared
+}
+
+// deprecatedMarker 弃用 flag 在 Usage 开头使用的标记
+// urfave/cli 没有弃用字段，约定以此前缀标记
+const deprecatedMarker = "[DEPRECATED]"
+
+// BuildCompletionSpec 从命令树构建补全规格
+func BuildCompletionSpec(cmd *cli.Command, opts GenerateOptions) *CompletionSpec {
+	return &CompletionSpec{
+		App:  appRawName(cmd),
+		Root: buildCommandSpec(cmd, true, opts),
+	}
+}
+
+// ReadCompletionSpec 从 JSON 文件读取补全规格
+func ReadCompletionSpec(path string) (*CompletionSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec file %s: %w", path, err)
+	}
+	var spec CompletionSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec file %s: %w", path, err)
+	}
+	if spec.Root.Name == "" {
+		return nil, fmt.Errorf("invalid spec file %s: missing root command name", path)
+	}
+	return &spec, nil
+}
+
+// buildCommandSpec 递归构建命令的补全规格
+func buildCommandSpec(cmd *cli.Command, isRoot bool, opts GenerateOptions) CommandSpec {
+	subs := completionSubcommands(cmd, isRoot, opts)
+	cs := buildCommandNode(cmd, subs, isRoot, opts)
+	for i, sub := range subs {
+		cs.Commands[i] = buildSubcommandSpec(cs.Commands[i], sub, opts)
+	}
+	return cs
+}
+
+// buildSubcommandSpec 将 buildCommandNode 生成的子命令条目展开为完整规格，保留父命令决定的 Gate 和 Hidden
+func buildSubcommandSpec(entry CommandSpec, sub *cli.Command, opts GenerateOptions) CommandSpec {
+	sc := buildCommandSpec(sub, false, opts)
+	sc.Gate, sc.Hidden = entry.Gate, entry.Hidden
+	return sc
+}
+
+// completionSubcommands 返回需要补全的子命令
+// 只有需要展开的命令才收集子命令；根命令的 completion 命令不在子命令列表中出现，
+// 但输入后补全其 flags（如 --output 目录），追加在最后并标记为隐藏
+func completionSubcommands(cmd *cli.Command, isRoot bool, opts GenerateOptions) []*cli.Command {
+	var subs []*cli.Command
+	if shouldExpandSubcommands(cmd) {
+		subs = getVisibleCommands(cmd, opts)
+	}
+	if isRoot {
+		for _, sub := range cmd.Commands {
+			if sub.Name == "completion" {
+				subs = append(subs, sub)
+			}
+		}
+	}
+	return subs
+}
+
+// buildCommandNode 构建单个命令的补全规格，不递归
+// 子命令只填写名称、别名、说明等条目信息，生成函数分发和子命令列表已足够；
+// 流式生成时逐个展开，同一时刻只保留当前路径上的命令
+func buildCommandNode(cmd *cli.Command, subs []*cli.Command, isRoot bool, opts GenerateOptions) CommandSpec {
+	cs := CommandSpec{
+		Name:      cmd.Name,
+		Aliases:   cmd.Aliases,
+		Usage:     cmd.Usage,
+		ArgsUsage: cmd.ArgsUsage,
+	}
+
+	// 收集 flags，完全相同的定义只保留一个；弃用的 flag 排在最后或隐藏
+	seen := make(map[string]bool)
+	var deprecated []FlagSpec
+	declared := declaredCompletions(cmd)
+	for _, f := range cmd.Flags {
+		fs, ok := buildFlagSpec(f, declared, opts)
+		if !ok {
+			continue
+		}
+		key := fmt.Sprintf("%v", fs)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if fs.Deprecated {
+			if !opts.HideDeprecated {
+				deprecated = append(deprecated, fs)
+			}
+			continue
+		}
+		cs.Flags = append(cs.Flags, fs)
+	}
+	cs.Flags = append(cs.Flags, deprecated...)
+	linkNegatedFlags(cs.Flags)
+	if exclusions, ok := cmd.Metadata[MetaKeyExclusions].(map[string][]string); ok {
+		applyExclusions(cs.Flags, exclusions)
+	}
+	if sameAs, ok := cmd.Metadata[MetaKeySameAs].(map[string]string); ok {
+		applySameAs(cs.Flags, sameAs)
+	}
+	if exclusions, ok := cmd.Metadata[MetaKeyValueExclusions].([]ValueExclusion); ok {
+		applyValueExclusions(cs.Flags, exclusions)
+	}
+
+	visible := 0
+	for _, sub := range subs {
+		sc := CommandSpec{Name: sub.Name, Aliases: sub.Aliases, Usage: sub.Usage}
+		if isRoot && sub.Name == "completion" {
+			sc.Hidden = true
+		} else {
+			sc.Gate, _ = sub.Metadata[MetaKeyFeatureGate].(string)
+			visible++
+		}
+		cs.Commands = append(cs.Commands, sc)
+	}
+
+	// 必填位置参数与子命令分发冲突，只用于叶子命令（隐藏的 completion 命令不计入）
+	if arg, ok := cmd.Metadata[MetaKeyRequiredArg].(ArgSpec); ok && visible == 0 {
+		cs.Arg = &arg
+	}
+	return cs
+}
+
+// linkNegatedFlags 为 --x 与 --no-x 配对的 flag 互相添加排斥（如 --color 与 --no-color）
+// 两者同时出现没有意义，补全时输入其中一个后不再提示另一个
+func linkNegatedFlags(flags []FlagSpec) {
+	index := make(map[string]int)
+	for i, f := range flags {
+		for _, name := range f.Names {
+			if _, ok := index[name]; !ok {
+				index[name] = i
+			}
+		}
+	}
+	for i := range flags {
+		for _, name := range flags[i].Names {
+			positive, ok := strings.CutPrefix(name, "no-")
+			if !ok {
+				continue
+			}
+			j, ok := index[positive]
+			if !ok || j == i {
+				continue
+			}
+			flags[i].Excludes = appendMissing(flags[i].Excludes, flags[j].Names...)
+			flags[j].Excludes = appendMissing(flags[j].Excludes, flags[i].Names...)
+		}
+	}
+}
+
+// applyExclusions 将声明的条件排斥添加到对应 flag 的 Excludes
+// 只作用于单向：key 排斥列表中的 flag，反之不受影响；未定义的 flag 名称被忽略
+func applyExclusions(flags []FlagSpec, exclusions map[string][]string) {
+	index := make(map[string]int)
+	for i, f := range flags {
+		for _, name := range f.Names {
+			if _, ok := index[name]; !ok {
+				index[name] = i
+			}
+		}
+	}
+	for name, excluded := range exclusions {
+		i, ok := index[name]
+		if !ok {
+			continue
+		}
+		for _, e := range excluded {
+			if j, ok := index[e]; ok && j != i {
+				flags[i].Excludes = appendMissing(flags[i].Excludes, flags[j].Names...)
+			}
+		}
+	}
+}
+
+// applySameAs 将声明的 flag 的值补全替换为来源 flag 的值补全
+// 只复制候选来源（类型、候选值、动态补全参数及回退），名称、描述和排斥关系保持不变；
+// 来源本身也声明了 same-as 时沿链找到最终来源（如 a→b→c 时 a 与 b 都复用 c），与声明的遍历顺序无关；
+// 任一方不存在、不接受值或链中有环时忽略
+func applySameAs(flags []FlagSpec, sameAs map[string]string) {
+	for _, name := range slices.Sorted(maps.Keys(sameAs)) {
+		i, j := flagIndex(flags, name), sameAsSource(flags, sameAs, name)
+		if i < 0 || j < 0 || i == j || flags[i].Value == ValueNone || flags[j].Value == ValueNone {
+			continue
+		}
+		src := flags[j]
+		flags[i].Value, flags[i].Values, flags[i].Rule = src.Value, src.Values, src.Rule
+		flags[i].Dynamic, flags[i].ContextOf, flags[i].Separator = src.Dynamic, src.ContextOf, src.Separator
+		flags[i].Fallback, flags[i].FallbackFiles = src.Fallback, src.FallbackFiles
+	}
+}
+
+// sameAsSource 沿 same-as 链返回 name 最终来源 flag 的下标，来源不存在或链中有环时返回 -1
+// 最终来源自身没有声明 same-as，其补全不会被 applySameAs 修改
+func sameAsSource(flags []FlagSpec, sameAs map[string]string, name string) int {
+	seen := map[int]bool{flagIndex(flags, name): true}
+	j := flagIndex(flags, sameAs[name])
+	for j >= 0 {
+		next := ""
+		for _, n := range flags[j].Names {
+			if s, ok := sameAs[n]; ok {
+				next = s
+				break
+			}
+		}
+		if next == "" {
+			return j
+		}
+		if seen[j] {
+			return -1
+		}
+		seen[j] = true
+		j = flagIndex(flags, next)
+	}
+	return -1
+}
+
+// applyValueExclusions 将声明的值排斥添加到受影响 flag 的 DisabledValues
+// 只作用于枚举类型的 flag；未定义的 flag 名称被忽略
+func applyValueExclusions(flags []FlagSpec, exclusions []ValueExclusion) {
+	for _, e := range exclusions {
+		when, target := flagIndex(flags, e.When), flagIndex(flags, e.Flag)
+		if when < 0 || target < 0 || when == target || flags[target].Value != ValueEnum {
+			continue
+		}
+		flags[target].DisabledValues = append(flags[target].DisabledValues, DisabledValues{
+			When:   flags[when].Names,
+			Values: e.Values,
+		})
+	}
+}
+
+// flagIndex 返回名称（含别名）为 name 的 flag 的下标，不存在时返回 -1
+func flagIndex(flags []FlagSpec, name string) int {
+	for i, f := range flags {
+		if slices.Contains(f.Names, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// appendMissing 追加 s 中尚未包含的元素
+func appendMissing(s []string, items ...string) []string {
+	for _, item := range items {
+		if !slices.Contains(s, item) {
+			s = append(s, item)
+		}
+	}
+	return s
+}
+
+// buildFlagSpec 将 cli.Flag 转换为补全规格，declared 为所属命令声明的补全；无名称的 flag 返回 false
+func buildFlagSpec(f cli.Flag, declared map[string]FlagCompletion, opts GenerateOptions) (FlagSpec, bool) {
+	names := f.Names()
+	if len(names) == 0 {
+		return FlagSpec{}, false
+	}
+
+	fs := FlagSpec{Names: names}
+	if nf, ok := f.(interface{ GetUsage() string }); ok {
+		fs.Usage = nf.GetUsage()
+	}
+
+	// 弃用标记从描述中剥离，不参与推断
+	if rest, ok := strings.CutPrefix(strings.TrimSpace(fs.Usage), deprecatedMarker); ok {
+		fs.Usage = strings.TrimSpace(rest)
+		fs.Deprecated = true
+	}
+
+	switch flag := f.(type) {
+	case *cli.StringFlag:
+		fs.Value, fs.Values, fs.Rule = inferValue(flag.Name, fs.Usage)
+	case *cli.BoolFlag:
+		fs.Rule = ruleBool
+		if isCountFlag(flag) {
+			fs.Repeatable = true
+			fs.Rule = ruleCount
+		}
+	case *cli.IntFlag:
+		fs.Value, fs.Rule = ValueNumber, ruleNumeric
+		if values := inferConcurrencyValues(strings.ToLower(flag.Name), strings.ToLower(fs.Usage), false); len(values) > 0 {
+			fs.Value, fs.Values, fs.Rule = ValueEnum, values, rulePreset
+		}
+	case *cli.FloatFlag:
+		fs.Value, fs.Rule = ValueNumber, ruleNumeric
+	case *cli.DurationFlag:
+		fs.Value, fs.Rule = ValueDuration, ruleDuration
+	case *cli.StringSliceFlag, *cli.StringMapFlag:
+		fs.Value, fs.Rule = ValueAny, ruleValue
+	default:
+		fs.Rule = ruleUnknown
+	}
+
+	// 声明式补全优先于之后的所有推断
+	if fs.Value != ValueNone {
+		for _, name := range names {
+			if c, ok := declared[name]; ok {
+				fs.Value, fs.Values, fs.Dynamic, fs.Rule = c.Type, c.Values, c.Dynamic, ruleDeclared
+				return fs, true
+			}
+		}
+	}
+
+	// 动态补全覆盖之前的静态推断，作为 __complete 超时或失败时的回退
+	static := fs
+
+	// 查询分辨率提示常用时间间隔，Usage 显式列出枚举时仍以枚举为准
+	if (fs.Value == ValueDuration || fs.Value == ValueAny) && isStepFlag(strings.ToLower(names[0]), strings.ToLower(fs.Usage)) {
+		fs.Value, fs.Values, fs.Rule = ValueDuration, stepPresets, ruleStep
+	}
+
+	// 投影字段、分组标签按 --metric 所选指标的标签动态补全，逗号分隔多选
+	if fs.Value != ValueNone {
+		if kind, rule := inferLabelList(strings.ToLower(names[0])); kind != "" {
+			fs.Value, fs.Values, fs.Rule = ValueDynamic, nil, rule
+			fs.Dynamic, fs.ContextOf, fs.Separator = kind, "metric", ","
+		}
+	}
+
+	// 排序表达式 field:asc|desc，Usage 显式列出枚举时仍以枚举为准
+	if fs.Value != ValueNone && fs.Rule != ruleEnumUsage && isSortFlag(strings.ToLower(names[0]), strings.ToLower(fs.Usage)) {
+		fs.Value, fs.Values, fs.Rule = ValueSort, nil, ruleSort
+		fs.ContextOf = "metric"
+	}
+
+	// API 版本补全时调用 __complete api-version；EnumProviders 提供静态列表时优先使用
+	if fs.Value != ValueNone && strings.Contains(strings.ToLower(names[0]), "api-version") {
+		fs.Value, fs.Values, fs.Rule = ValueDynamic, nil, ruleAPIVersion
+		fs.Dynamic = dynamicAPIVersion
+	}
+
+	// namespace 补全时调用 __complete namespace；描述表明支持通配时附加 * 或 all
+	if fs.Value != ValueNone && strings.Contains(strings.ToLower(names[0]), "namespace") {
+		fs.Value, fs.Values, fs.Rule = ValueDynamic, namespaceSentinels(strings.ToLower(fs.Usage)), ruleNamespace
+		fs.Dynamic = dynamicNamespace
+	}
+
+	// 实例名称补全时调用 __complete instances，只用于没有其他推断的 flag（如 --from/--to 描述为实例时共用）
+	if fs.Value == ValueAny && isInstanceFlag(strings.ToLower(names[0]), strings.ToLower(fs.Usage)) {
+		fs.Value, fs.Rule = ValueDynamic, ruleInstance
+		fs.Dynamic = dynamicInstances
+	}
+
+	if fs.Value == ValueDynamic {
+		switch static.Value {
+		case ValueEnum:
+			fs.Fallback = static.Values
+		case ValueFile:
+			fs.FallbackFiles = true
+		}
+	}
+
+	// 代码提供的候选值优先于推断
+	if fs.Value != ValueNone {
+		if provider, ok := opts.EnumProviders[names[0]]; ok && provider != nil {
+			if values := provider(); len(values) > 0 {
+				fs.Value, fs.Values, fs.Rule = ValueEnum, values, ruleProvider
+			}
+		}
+	}
+
+	return fs, true
+}
+
+// 值补全推断规则，用于注释模式和调试输出
+const (
+	ruleBool       = "bool"            // 布尔 flag，不接受值
+	ruleCount      = "count"           // 计数 flag，可重复出现
+	ruleProvider   = "custom-provider" // GenerateOptions.EnumProviders 提供的候选值
+	ruleDeclared   = "declared"        // MetaKeyFlagCompletions 声明的补全
+	ruleEnumUsage  = "enum-usage"      // 从 Usage 解析的枚举
+	rulePreset     = "preset"          // 按名称/描述关键字匹配的常见取值
+	ruleContext    = "context"         // 从配置文件读取的命名上下文
+	ruleFields     = "fields"          // 指标标签投影，补全时动态获取
+	ruleGroupBy    = "group-by"        // 分组标签，补全时动态获取
+	ruleAPIVersion = "api-version"     // API 版本，补全时动态获取
+	ruleNamespace  = "namespace"       // namespace，补全时动态获取
+	ruleInstance   = "instance"        // 实例名称，补全时动态获取
+	ruleEndpoint   = "endpoint"        // 服务端点，读取历史记录
+	ruleURL        = "url"             // URL
+	ruleFile       = "file"            // 文件路径
+	ruleConfig     = "config"          // 配置文件路径
+	ruleOutput     = "output"          // 输出目标：文件或 stdout/syslog
+	ruleDir        = "dir"             // 目录
+	ruleRegexp     = "regexp"          // Go 正则表达式
+	ruleSort       = "sort"            // 排序表达式
+	ruleUnit       = "unit"            // systemd unit
+	ruleSecret     = "secret"          // 敏感值
+	ruleTemplate   = "template"        // Go 模板
+	ruleSelector   = "selector"        // 标签选择器
+	ruleNumeric    = "numeric"         // 数字
+	ruleDuration   = "duration"        // 时间间隔
+	ruleStep       = "step"            // 查询分辨率
+	ruleValue      = "value"           // 任意值，无补全
+	ruleUnknown    = "unknown"         // 未识别的 flag 类型
+	ruleHelp       = "help"            // 帮助 flag
+)
+
+// ruleNotes 各推断规则在注释模式下的说明
+var ruleNotes = map[string]string{
+	ruleBool:       "布尔开关，不接受值",
+	ruleCount:      "计数开关，可重复出现以递增 (如 -vvv)，不接受值",
+	ruleProvider:   "候选值由代码中注册的 EnumProviders 提供",
+	ruleDeclared:   "补全类型由命令的 MetaKeyFlagCompletions 声明，不做推断",
+	ruleEnumUsage:  "Usage 中列出了可选值，补全这些枚举",
+	rulePreset:     "名称或描述匹配常见取值集合，补全预设候选值",
+	ruleContext:    "命名上下文，补全时从配置文件读取",
+	ruleFields:     "投影字段，补全时以 --metric 的值调用 __complete fields 获取标签，逗号分隔多选",
+	ruleGroupBy:    "分组标签，补全时以 --metric 的值调用 __complete labels 获取标签，逗号分隔多选",
+	ruleAPIVersion: "API 版本，未提供静态列表时调用 __complete api-version 获取",
+	ruleNamespace:  "namespace，调用 __complete namespace 获取，描述表明支持通配时在前面提示 * 或 all",
+	ruleInstance:   "名称或描述包含 instance/node/实例，调用 __complete instances 获取，服务不可用时不提供候选",
+	ruleEndpoint:   "服务端点，提示最近使用的地址及 http:// https://",
+	ruleURL:        "名称包含 url，按 URL 补全",
+	ruleFile:       "名称或描述表明是文件路径，使用 _files 补全",
+	ruleConfig:     "配置文件，先提示 XDG 约定路径，再按文件补全",
+	ruleOutput:     "输出目标，描述中提到 stdout/syslog，在文件之外补全 - 和 syslog",
+	ruleDir:        "描述表明是目录，只补全目录",
+	ruleRegexp:     "描述表明是 Go 正则 (RE2)，提示 ^ $ 锚点和 .* 模板，仍可自由输入",
+	ruleSort:       "排序表达式，先补全 --metric 所选指标的字段，输入 : 后补全 asc/desc",
+	ruleUnit:       "名称或描述包含 unit/service，补全 systemctl list-unit-files 列出的 unit (超时或无 systemd 时不提供候选)",
+	ruleSecret:     "名称或描述表明是 token/密码等敏感值，不补全文件或枚举，避免泄露文件名或给出提示",
+	ruleTemplate:   "名称或描述表明是 Go 模板，自由输入，不补全文件或枚举",
+	ruleSelector:   "标签选择器，依次补全标签名、操作符 (= != =~ !~) 和标签值，标签来自 __complete",
+	ruleNumeric:    "数字，无候选值",
+	ruleDuration:   "时间间隔，无候选值",
+	ruleStep:       "查询分辨率 (step/resolution)，提示常用时间间隔，仍可输入其他值",
+	ruleValue:      "任意值，无候选值",
+	ruleUnknown:    "未识别的 flag 类型，仅补全名称",
+	ruleHelp:       "显示帮助后不再补全其他参数",
+}
+
+// isCountFlag 判断是否是计数 flag（可重复出现，如 -v -v 递增详细程度）
+// urfave/cli 中计数 flag 为设置了 Config.Count 的 BoolFlag；
+// IntFlag 必须带值，无法作为重复出现的计数器
+func isCountFlag(f cli.Flag) bool {
+	bf, ok := f.(*cli.BoolFlag)
+	return ok && bf.Config.Count != nil
+}
+
+// inferValue 根据 flag 名称和描述推断补全类型，返回类型、候选值和命中的规则
+// 设计原则：从 Usage 描述推断，不硬编码业务值
+func inferValue(name, usage string) (ValueKind, []string, string) {
+	nameLower := strings.ToLower(name)
+	usageLower := strings.ToLower(usage)
+
+	// 敏感值不提供任何候选，先于枚举、文件等所有推断
+	if isSecretFlag(nameLower, usageLower) {
+		return ValueSecret, nil, ruleSecret
+	}
+
+	// Go 模板自由输入，描述中的示例（如 "{{.name}}: {{.value}}"）不作为枚举
+	if isTemplateFlag(nameLower, usageLower) {
+		return ValueTemplate, nil, ruleTemplate
+	}
+
+	// 1. 优先从 Usage 解析枚举值（如 "类型: a, b, c" 或 "format: json, csv"）
+	if values := parseEnumFromUsage(usage); len(values) > 0 {
+		return ValueEnum, values, ruleEnumUsage
+	}
+
+	// 2. 常见取值集合（如压缩算法），Usage 未显式列出枚举时兜底
+	if values := inferPresetValues(nameLower, usageLower); len(values) > 0 {
+		return ValueEnum, values, rulePreset
+	}
+
+	// 并发数预设（字符串类型可额外接受 auto）
+	if values := inferConcurrencyValues(nameLower, usageLower, true); len(values) > 0 {
+		return ValueEnum, values, rulePreset
+	}
+
+	// 正则表达式，提示锚点和模板
+	if strings.Contains(usageLower, "regexp") || strings.Contains(usageLower, "re2") {
+		return ValueRegexp, nil, ruleRegexp
+	}
+
+	// 标签选择器，先于上下文等按名称的推断
+	if isSelectorFlag(nameLower, usageLower) {
+		return ValueSelector, nil, ruleSelector
+	}
+
+	// 3. 命名上下文，补全时从配置文件读取
+	if isContextFlag(nameLower) {
+		return ValueContext, nil, ruleContext
+	}
+
+	// 4. 服务端点，先于 URL 判断（如 --endpoint-url）
+	if strings.Contains(nameLower, "endpoint") || strings.Contains(nameLower, "addr") {
+		return ValueEndpoint, nil, ruleEndpoint
+	}
+
+	// 5. URL 类型（从 name 推断）
+	if strings.Contains(nameLower, "url") {
+		return ValueURL, nil, ruleURL
+	}
+
+	// systemd unit（"units" 复数已由单位制预设匹配）
+	if isUnitFlag(nameLower, usageLower) {
+		return ValueUnit, nil, ruleUnit
+	}
+
+	// 6. 配置文件，在文件补全之外提示 XDG 约定路径
+	if nameLower == "config" {
+		return ValueConfig, nil, ruleConfig
+	}
+
+	// 7. 文件路径类型（从 name 或 usage 推断），描述中提到 stdout/syslog 时附加这些目标
+	if isFilePath(nameLower, usageLower) {
+		if sinks := outputSinks(usageLower); len(sinks) > 0 {
+			return ValueOutput, sinks, ruleOutput
+		}
+		if strings.Contains(usageLower, "目录") || strings.Contains(usageLower, "directory") {
+			return ValueDir, nil, ruleDir
+		}
+		return ValueFile, nil, ruleFile
+	}
+
+	// 8. 数字类型
+	if strings.Contains(usageLower, "number") ||
+		strings.Contains(usageLower, "数量") ||
+		strings.Contains(usageLower, "个数") {
+		return ValueNumber, nil, ruleNumeric
+	}
+
+	return ValueAny, nil, ruleValue
+}
+
+// valuePreset 按 flag 名称或描述关键字推断的候选值
+type valuePreset struct {
+	keywords []string // 作为子串匹配 name 或 usage 的关键字（小写）
+	words    []string // 作为完整单词匹配的关键字，用于 si 这类易误匹配的短词
+	context  []string // 非空时还须同时包含其中之一，用于 level 这类多义词
+	exclude  []string // 名称包含其中之一时不匹配，用于避免与其他推断冲突
+	values   []string // 候选值
+}
+
+// valuePresets 常见 flag 的候选值表，按顺序匹配，先匹配者优先
+var valuePresets = []valuePreset{
+	// 压缩算法
+	{keywords: []string{"compress", "压缩"}, values: []string{"none", "gzip", "zstd", "lz4"}},
+	// 字节单位制（"unit" 单数留给 systemd unit 等其他含义）
+	{keywords: []string{"units", "unit-system", "单位制"}, words: []string{"si", "iec"}, values: []string{"si", "iec"}},
+	// 告警严重级别（level 仅在告警语境下匹配，避免与日志级别混淆）
+	{keywords: []string{"severity", "严重"}, values: []string{"info", "warning", "critical"}},
+	{keywords: []string{"level", "级别"}, context: []string{"alert", "告警"}, values: []string{"info", "warning", "critical"}},
+	// Prometheus 指标类型
+	{keywords: []string{"metric-type", "指标类型"}, values: []string{"counter", "gauge", "histogram", "summary", "untyped"}},
+	// 限速表达式（rate 按完整单词匹配，避免 generate、separate 等误匹配）
+	{keywords: []string{"ratelimit", "限速", "速率"}, words: []string{"rate"}, values: []string{"10/s", "100/s", "1000/m"}},
+	// 协议 scheme（--server-url 的描述可能提到协议，交给 URL 推断）
+	{keywords: []string{"protocol", "协议"}, exclude: []string{"url"}, values: []string{"http", "https", "grpc"}},
+	// 聚合函数（agg 按完整单词匹配，如 --agg）
+	{keywords: []string{"aggregation", "聚合"}, words: []string{"agg"}, values: []string{"sum", "avg", "min", "max", "p50", "p90", "p99", "rate"}},
+}
+
+// inferPresetValues 从 valuePresets 中查找与 flag 匹配的候选值
+func inferPresetValues(nameLower, usageLower string) []string {
+	words := splitWords(nameLower + " " + usageLower)
+	for _, preset := range valuePresets {
+		if len(preset.context) > 0 && !containsAny(nameLower, preset.context) && !containsAny(usageLower, preset.context) {
+			continue
+		}
+		if containsAny(nameLower, preset.exclude) {
+			continue
+		}
+		for _, kw := range preset.keywords {
+			if strings.Contains(nameLower, kw) || strings.Contains(usageLower, kw) {
+				return preset.values
+			}
+		}
+		for _, w := range preset.words {
+			if words[w] {
+				return preset.values
+			}
+		}
+	}
+	return nil
+}
+
+// concurrencyValues 并发数 flag 的预设候选值，取常见 CPU 核数
+var concurrencyValues = []string{"1", "2", "4", "8", "16"}
+
+// inferConcurrencyValues 为并发数 flag（--concurrency、--workers 等）返回预设候选值
+// allowAuto 为 true 且描述中提到 auto 时追加 auto（仅字符串类型的 flag 能接受）
+func inferConcurrencyValues(nameLower, usageLower string, allowAuto bool) []string {
+	keywords := []string{"concurrency", "worker", "并发"}
+	if !containsAny(nameLower, keywords) && !containsAny(usageLower, keywords) {
+		return nil
+	}
+	values := append([]string(nil), concurrencyValues...)
+	if allowAuto && strings.Contains(usageLower, "auto") {
+		values = append(values, "auto")
+	}
+	return values
+}
+
+// containsAny 判断 s 是否包含任一子串
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretKeywords 表明 flag 值是敏感信息的关键字
+var secretKeywords = []string{"token", "password", "passwd", "secret", "密钥", "密码"}
+
+// isSecretFlag 判断是否是携带敏感值的 flag（如 --token、--password）
+// 指向密钥文件的 flag（如 --tls-key 客户端密钥路径、--password-file）仍按文件补全
+func isSecretFlag(nameLower, usageLower string) bool {
+	if containsAny(nameLower, []string{"file", "path"}) || containsAny(usageLower, []string{"file", "path", "文件", "路径"}) {
+		return false
+	}
+	return containsAny(nameLower, secretKeywords) || containsAny(usageLower, secretKeywords)
+}
+
+// isTemplateFlag 判断是否是 Go 模板 flag（如 --template、--go-template）
+// 指向模板文件的 flag（如 --template-file）仍按文件补全
+func isTemplateFlag(nameLower, usageLower string) bool {
+	if containsAny(nameLower, []string{"file", "path"}) || containsAny(usageLower, []string{"file", "path", "文件", "路径"}) {
+		return false
+	}
+	return containsAny(nameLower+" "+usageLower, []string{"template", "模板"})
+}
+
+// isSelectorFlag 判断是否是标签选择器 flag（如 --selector、--labels）
+func isSelectorFlag(nameLower, usageLower string) bool {
+	return strings.Contains(nameLower, "selector") || nameLower == "labels" ||
+		strings.Contains(usageLower, "selector") || strings.Contains(usageLower, "选择器")
+}
+
+// isInstanceFlag 判断是否是实例名称 flag（如 --instance、--node，或描述为实例的 --from/--to）
+func isInstanceFlag(nameLower, usageLower string) bool {
+	return containsAny(nameLower+" "+usageLower, []string{"instance", "node", "实例"})
+}
+
+// isUnitFlag 判断是否是 systemd unit 类 flag（如 --unit、--service）
+// 按完整单词匹配，避免 units、community 等误匹配
+func isUnitFlag(nameLower, usageLower string) bool {
+	words := splitWords(nameLower + " " + usageLower)
+	return words["unit"] || words["service"]
+}
+
+// splitWords 按非字母数字字符切分，返回单词集合
+func splitWords(s string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}) {
+		words[w] = true
+	}
+	return words
+}
+
+// parseEnumFromUsage 从 Usage 描述中解析枚举值
+// 支持格式：
+//   - "类型: a, b, c"
+//   - "format: json, csv, xml"
+//   - "模式 (a/b/c)"
+//   - "type (a|b|c)"
+func parseEnumFromUsage(usage string) []string {
+	// 模式1: "xxx: a, b, c" 或 "xxx：a, b, c"（中英文冒号）
+	if idx := strings.IndexAny(usage, ":："); idx != -1 {
+		rest := strings.TrimSpace(usage[idx+1:])
+		// 去掉括号内容（如果有的话，可能是补充说明）
+		if parenIdx := strings.IndexAny(rest, "(（"); parenIdx != -1 {
+			rest = strings.TrimSpace(rest[:parenIdx])
+		}
+		// 按逗号分割
+		if strings.Contains(rest, ",") || strings.Contains(rest, "，") {
+			parts := strings.FieldsFunc(rest, func(r rune) bool {
+				return r == ',' || r == '，' || r == ' '
+			})
+			var values []string
+			for _, p := range parts {
+				p = strings.TrimSpace(p)
+				// 只保留简单的值（无空格、非空）
+				if p != "" && !strings.Contains(p, " ") && len(p) < 20 {
+					values = append(values, p)
+				}
+			}
+			if len(values) >= 2 {
+				return values
+			}
+		}
+	}
+
+	// 模式2: "(a/b/c)" 或 "(a|b|c)"
+	if start := strings.IndexAny(usage, "(（"); start != -1 {
+		if end := strings.IndexAny(usage[start:], ")）"); end != -1 {
+			inner := usage[start+1 : start+end]
+			// 检查是否是枚举格式
+			if strings.ContainsAny(inner, "/|") && !strings.Contains(inner, " ") {
+				parts := strings.FieldsFunc(inner, func(r rune) bool {
+					return r == '/' || r == '|'
+				})
+				if len(parts) >= 2 {
+					var values []string
+					for _, p := range parts {
+						p = strings.TrimSpace(p)
+						if p != "" && len(p) < 20 {
+							values = append(values, p)
+						}
+					}
+					return values
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// isContextFlag 判断是否是命名上下文 flag（类似 kubectl --context）
+func isContextFlag(nameLower string) bool {
+	return nameLower == "context" || strings.HasSuffix(nameLower, "-context")
+}
+
+// inferLabelList 判断是否是以逗号分隔的标签列表 flag，返回 __complete 类型和命中的规则
+//   - 字段投影：--fields、--output-fields
+//   - 分组标签：--group-by、--groupby
+func inferLabelList(nameLower string) (string, string) {
+	switch {
+	case nameLower == "fields" || strings.HasSuffix(nameLower, "-fields"):
+		return dynamicFields, ruleFields
+	case nameLower == "group-by" || nameLower == "groupby" || strings.HasSuffix(nameLower, "-group-by"):
+		return dynamicLabels, ruleGroupBy
+	}
+	return "", ""
+}
+
+// stepPresets 查询分辨率的常用取值
+var stepPresets = []string{"15s", "30s", "1m", "5m"}
+
+// isStepFlag 判断是否是查询分辨率 flag（如 --step、--resolution）
+func isStepFlag(nameLower, usageLower string) bool {
+	return containsAny(nameLower, []string{"step", "resolution"}) ||
+		containsAny(usageLower, []string{"step", "resolution", "步长", "分辨率"})
+}
+
+// isSortFlag 判断是否是排序 flag（如 --sort、--sort-by）
+func isSortFlag(nameLower, usageLower string) bool {
+	return strings.Contains(nameLower, "sort") || strings.Contains(usageLower, "排序")
+}
+
+// namespaceSentinels 从描述中识别表示全部 namespace 的通配值
+// 提到 * 时补全 *，提到 all/所有/全部 时补全 all
+func namespaceSentinels(usageLower string) []string {
+	var sentinels []string
+	if strings.Contains(usageLower, "*") {
+		sentinels = append(sentinels, "*")
+	}
+	if splitWords(usageLower)["all"] || strings.Contains(usageLower, "所有") || strings.Contains(usageLower, "全部") {
+		sentinels = append(sentinels, "all")
+	}
+	return sentinels
+}
+
+// outputSinks 从描述中识别文件之外的输出目标
+// 提到 stdout 时补全 -（约定表示标准输出），提到 syslog 时补全 syslog
+func outputSinks(usageLower string) []string {
+	var sinks []string
+	if strings.Contains(usageLower, "stdout") || strings.Contains(usageLower, "标准输出") {
+		sinks = append(sinks, "-")
+	}
+	if strings.Contains(usageLower, "syslog") {
+		sinks = append(sinks, "syslog")
+	}
+	return sinks
+}
+
+// isFilePath 判断是否是文件路径类型
+// 从 flag 名称和 usage 描述推断
+func isFilePath(nameLower, usageLower string) bool {
+	// 排除 "prefix"、"format" 等误判
+	// 先于所有模式检查，避免 --output-format 被 "output" 或描述中的 "文件" 识别为文件
+	if strings.Contains(nameLower, "prefix") ||
+		strings.Contains(nameLower, "format") {
+		return false
+	}
+
+	// 从 name 推断
+	fileNamePatterns := []string{
+		"file", "path", "config", "input", "output",
+		"cert", "key", // 证书相关
+	}
+	for _, pattern := range fileNamePatterns {
+		if strings.Contains(nameLower, pattern) {
+			return true
+		}
+	}
+	// ca 按完整单词匹配（如 --tls-ca），避免 locale、cache 等误匹配
+	if splitWords(nameLower)["ca"] {
+		return true
+	}
+
+	// 从 usage 推断（中英文）
+	fileUsagePatterns := []string{
+		"file", "path", "文件", "路径", "证书",
+	}
+	for _, pattern := range fileUsagePatterns {
+		if strings.Contains(usageLower, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getVisibleCommands 获取可见的子命令（排除 hidden 和特殊命令）
+// 受特性开关控制的命令仅在 IncludeFeatureGated 时包含，不论是否隐藏
+func getVisibleCommands(cmd *cli.Command, opts GenerateOptions) []*cli.Command {
+	var visible []*cli.Command
+	for _, sub := range cmd.Commands {
+		if gate, _ := sub.Metadata[MetaKeyFeatureGate].(string); gate != "" {
+			if opts.IncludeFeatureGated {
+				visible = append(visible, sub)
+			}
+			continue
+		}
+		// 跳过隐藏命令
+		if sub.Hidden {
+			continue
+		}
+		// 跳过 help、completion 等不需要在补全中显示的命令
+		if sub.Name == "help" || sub.Name == "completion" {
+			continue
+		}
+		visible = append(visible, sub)
+	}
+	return visible
+}
+
+// shouldExpandSubcommands 判断是否需要展开子命令的补全
+// version 等终端命令不需要展开其子命令
+func shouldExpandSubcommands(cmd *cli.Command) bool {
+	// version 命令的子命令（short、json）不需要在补全中展开
+	if cmd.Name == "version" {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package configcmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/version"
+	"github.com/urfave/cli/v3"
+)
+
+// configPath 返回要处理的配置文件：位置参数优先，其次 --config，最后搜索默认路径
+func configPath(cmd *cli.Command) (string, error) {
+	if cmd.Args().Len() > 0 {
+		return cmd.Args().First(), nil
+	}
+	if path := cmd.String("config"); path != "" {
+		return path, nil
+	}
+	if path := config.FindConfigFile(version.GetAppRawName()); path != "" {
+		return path, nil
+	}
+	return "", fmt.Errorf("no config file found, specify one with --config or as an argument")
+}
+
+// actionValidate 校验配置文件并逐行输出问题
+func actionValidate(ctx context.Context, cmd *cli.Command) error {
+	path, err := configPath(cmd)
+	if err != nil {
+		return err
+	}
+
+	problems, err := config.Validate(path)
+	if err != nil {
+		return err
+	}
+	w := cmd.Root().Writer
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("config %s has %d problem(s)", path, len(problems))
+	}
+	fmt.Fprintf(w, "%s: OK\n", path)
+	return nil
+}
+
+// actionPrint 加载配置并输出，敏感配置项已隐藏
+func actionPrint(ctx context.Context, cmd *cli.Command) error {
+	cfg, err := config.Load(cmd, cmd.String("config"), version.GetAppRawName())
+	if err != nil {
+		return err
+	}
+	return config.Encode(cmd.Root().Writer, config.Redact(*cfg), cmd.String("format"))
+}
@@ -0,0 +1,46 @@
+// Package configcmd 提供 config 命令
+// 注意：包名使用 configcmd 避免与 internal/config 包冲突
+package configcmd
+
+import (
+	"github.com/urfave/cli/v3"
+)
+
+// Command 配置管理命令
+var Command = &cli.Command{
+	Name:  "config",
+	Usage: "配置文件管理",
+	Commands: []*cli.Command{
+		validateCommand,
+		printCommand,
+	},
+}
+
+// validateCommand 校验配置文件
+var validateCommand = &cli.Command{
+	Name:      "validate",
+	Usage:     "校验配置文件",
+	ArgsUsage: "[file]",
+	Description: `检查配置文件的语法、未知配置项和取值（时长、地址、枚举、重复的采集目标、端口冲突及配置项之间的依赖），
+每个问题输出一行 <文件>:<行>: <配置项>: <说明>，存在问题时以非零状态退出，可直接用于 CI。
+
+配置文件按以下顺序确定：位置参数、--config、默认搜索路径。`,
+	Action: actionValidate,
+}
+
+// printCommand 输出生效的配置
+var printCommand = &cli.Command{
+	Name:  "print",
+	Usage: "输出合并后生效的配置 (默认值、配置文件、环境变量、flags)",
+	Description: `按与其他命令相同的优先级合并配置并输出，用于排查某项配置为何未生效。
+密码、Token 等敏感配置项以 ****** 代替。`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "format",
+			Aliases: []string{"f"},
+			Usage:   "输出格式: yaml, json",
+			Value:   "yaml",
+		},
+	},
+	Action: actionPrint,
+}
@@ -0,0 +1,67 @@
+package command
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/version"
+	"github.com/urfave/cli/v3"
+)
+
+// BindEnvVars 为命令树中的所有 flag 绑定环境变量，在 app.Run 之前调用
+// 变量名为配置环境变量前缀加上 flag 名称，如 --server-url → VM_METRICS_SERVER_URL，
+// 与配置文件的环境变量共用前缀；命令行参数优先于环境变量。
+// 只属于某个子命令的 flag 在变量名中加上命令路径，如 export --output → VM_METRICS_EXPORT_OUTPUT，
+// 避免不同命令的同名 flag 共用一个变量。
+// 切片（逗号分隔）、时长等值由 cli 按 flag 类型解析，变量名显示在 --help 中。
+// 已指定 Sources 的 flag 保持不变
+func BindEnvVars(root *cli.Command) {
+	shared := config.ConfigFlagNames()
+	shared["config"] = true
+	bindEnvVars(root, config.EnvPrefix(version.GetAppRawName()), "", shared)
+}
+
+// bindEnvVars 递归绑定命令及其子命令的 flag，path 为命令路径对应的变量名片段（根命令为空）
+// shared 中的 flag（配置项及 --config）在各命令中含义相同，变量名不含命令路径；
+// 隐藏的命令（completion、__complete）不绑定
+func bindEnvVars(cmd *cli.Command, prefix, path string, shared map[string]bool) {
+	for _, f := range cmd.Flags {
+		if names := f.Names(); len(names) > 0 && shared[names[0]] {
+			bindFlagEnv(f, prefix)
+		} else {
+			bindFlagEnv(f, prefix+path)
+		}
+	}
+	for _, sub := range cmd.Commands {
+		if !sub.Hidden {
+			bindEnvVars(sub, prefix, path+flagEnvVar("", sub.Name)+"_", shared)
+		}
+	}
+}
+
+// bindFlagEnv 为单个 flag 设置环境变量来源
+// cli 的各类 flag 均为 FlagBase 的实例，通过反射统一设置 Sources 字段
+func bindFlagEnv(f cli.Flag, prefix string) {
+	names := f.Names()
+	if len(names) == 0 {
+		return
+	}
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+	sources := v.Elem().FieldByName("Sources")
+	if !sources.IsValid() || !sources.CanSet() || sources.Type() != reflect.TypeOf(cli.ValueSourceChain{}) {
+		return
+	}
+	if len(sources.Interface().(cli.ValueSourceChain).Chain) > 0 {
+		return
+	}
+	sources.Set(reflect.ValueOf(cli.EnvVars(flagEnvVar(prefix, names[0]))))
+}
+
+// flagEnvVar 返回 flag 对应的环境变量名，如 (VM_METRICS_, server-url) → VM_METRICS_SERVER_URL
+func flagEnvVar(prefix, flagName string) string {
+	return prefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+func TestBindEnvVars(t *testing.T) {
+	var got struct {
+		url     string
+		labels  []string
+		timeout time.Duration
+		custom  string
+	}
+	newCmd := func() *cli.Command {
+		return &cli.Command{
+			Name: "app",
+			Commands: []*cli.Command{{
+				Name: "run",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "server-url"},
+					&cli.StringSliceFlag{Name: "label"},
+					&cli.DurationFlag{Name: "timeout"},
+					&cli.StringFlag{Name: "custom", Sources: cli.EnvVars("CUSTOM_VAR")},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					got.url = cmd.String("server-url")
+					got.labels = cmd.StringSlice("label")
+					got.timeout = cmd.Duration("timeout")
+					got.custom = cmd.String("custom")
+					return nil
+				},
+			}},
+		}
+	}
+
+	// server-url 对应配置项，变量名不含命令路径；其他 flag 只属于 run 命令
+	shared := map[string]bool{"server-url": true}
+	t.Setenv("TEST_SERVER_URL", "http://env:8428")
+	t.Setenv("TEST_RUN_LABEL", "a,b")
+	t.Setenv("TEST_RUN_TIMEOUT", "5s")
+	t.Setenv("TEST_LABEL", "ignored")
+	t.Setenv("TEST_RUN_CUSTOM", "ignored")
+	t.Setenv("CUSTOM_VAR", "custom")
+
+	cmd := newCmd()
+	bindEnvVars(cmd, "TEST_", "", shared)
+	if err := cmd.Run(context.Background(), []string{"app", "run"}); err != nil {
+		t.Fatalf("运行命令失败: %v", err)
+	}
+	if got.url != "http://env:8428" || got.timeout != 5*time.Second || !reflect.DeepEqual(got.labels, []string{"a", "b"}) {
+		t.Errorf("环境变量未生效: %+v", got)
+	}
+	if got.custom != "custom" {
+		t.Errorf("已指定 Sources 的 flag 不应被覆盖: %q", got.custom)
+	}
+
+	// 命令行参数优先于环境变量
+	cmd = newCmd()
+	bindEnvVars(cmd, "TEST_", "", shared)
+	if err := cmd.Run(context.Background(), []string{"app", "run", "--server-url", "http://cli:8428"}); err != nil {
+		t.Fatalf("运行命令失败: %v", err)
+	}
+	if got.url != "http://cli:8428" {
+		t.Errorf("命令行参数应优先: %q", got.url)
+	}
+}
+
+func TestBindEnvVarsSkipsHidden(t *testing.T) {
+	output := &cli.StringFlag{Name: "output"}
+	root := &cli.Command{
+		Name:     "app",
+		Commands: []*cli.Command{{Name: "completion", Hidden: true, Flags: []cli.Flag{output}}},
+	}
+	bindEnvVars(root, "TEST_", "", nil)
+	if len(output.Sources.Chain) != 0 {
+		t.Errorf("隐藏命令的 flag 不应绑定环境变量: %v", output.Sources.EnvKeys())
+	}
+}
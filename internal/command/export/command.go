@@ -24,7 +24,7 @@ var Command = &cli.Command{
 }
 
 func init() {
-	Command.Commands = append(Command.Commands, command.NewCompletionCommand(Command))
+	Command.Commands = append(Command.Commands, command.NewCompletionCommand(Command), command.NewCompleteCommand())
 }
 
 // exportFlags 返回导出命令的 flags (基础 + 导出特定)
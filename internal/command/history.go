@@ -0,0 +1,72 @@
+package command
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxEndpointHistory 端点历史最多保留的条数
+const maxEndpointHistory = 20
+
+// endpointHistoryPath 返回端点历史文件路径
+// 位于 $XDG_STATE_HOME/<app>/endpoints，未设置时使用 ~/.local/state/<app>/endpoints
+func endpointHistoryPath(appName string) (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home dir: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, appName, "endpoints"), nil
+}
+
+// RecordEndpoint 将本次使用的端点写入历史文件首行，供补全时按最近使用顺序提示
+// 已存在的相同端点移到首行，超出 maxEndpointHistory 的旧记录被丢弃；
+// 地址中的用户名和密码不写入，历史文件仅当前用户可读写
+func RecordEndpoint(appName, endpoint string) error {
+	endpoint = stripUserinfo(endpoint)
+	if endpoint == "" {
+		return nil
+	}
+	path, err := endpointHistoryPath(appName)
+	if err != nil {
+		return err
+	}
+
+	history := []string{endpoint}
+	if content, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && line != endpoint && len(history) < maxEndpointHistory {
+				history = append(history, line)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create history dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(history, "\n")+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write endpoint history: %w", err)
+	}
+	// WriteFile 不修改已存在文件的权限，旧版本创建的文件可能为 0644
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("failed to chmod endpoint history: %w", err)
+	}
+	return nil
+}
+
+// stripUserinfo 去掉地址中的用户名和密码，无法解析的地址原样返回
+func stripUserinfo(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.User == nil {
+		return endpoint
+	}
+	u.User = nil
+	return u.String()
+}
@@ -26,7 +26,7 @@ var Command = &cli.Command{
 }
 
 func init() {
-	Command.Commands = append(Command.Commands, command.NewCompletionCommand(Command))
+	Command.Commands = append(Command.Commands, command.NewCompletionCommand(Command), command.NewCompleteCommand())
 }
 
 // importFlags 返回导入命令的 flags (基础 + 导入特定)
@@ -28,7 +28,7 @@ var Command = &cli.Command{
 
 func init() {
 	// 延迟添加 completion 命令，避免循环引用
-	Command.Commands = append(Command.Commands, command.NewCompletionCommand(Command))
+	Command.Commands = append(Command.Commands, command.NewCompletionCommand(Command), command.NewCompleteCommand())
 }
 
 // queryFlags 返回查询命令的 flags (基础 + 查询特定)
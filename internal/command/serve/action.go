@@ -0,0 +1,246 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/command"
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/exporter"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+	"github.com/lwmacct/251203-vm-metrics/internal/version"
+	"github.com/urfave/cli/v3"
+)
+
+// actionServe 注册采集器并启动 HTTP 服务，配置文件变化或收到 SIGHUP 时重载采集器和导出器
+func actionServe(ctx context.Context, cmd *cli.Command) error {
+	cfg := command.GetConfig(cmd)
+	// 环境变量和 flags 的取值不经过 config validate，启动前检查合并后的配置
+	if problems := config.ValidateConfig(cfg); len(problems) > 0 {
+		return invalidConfig(problems)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 推送导出器与 HTTP 服务并行运行，ctx 取消后推送最后一次并关闭
+	s, err := newServer(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	path := cmd.String("config")
+	if path == "" {
+		path = config.FindConfigFile(version.GetAppRawName())
+	}
+	reloader := config.NewReloader(path, func() (*config.Config, error) { return loadConfig(cmd, path) }, s.reload)
+	reloaderDone := make(chan struct{})
+	go func() {
+		defer close(reloaderDone)
+		if err := reloader.Run(ctx); err != nil {
+			slog.Warn("Config reload disabled", "error", err)
+		}
+	}()
+
+	err = run(ctx, cfg.Serve, newHandler(s.gather))
+	// HTTP 服务出错时 ctx 未取消，先停止 Reloader 并等待进行中的重载完成再关闭
+	stop()
+	<-reloaderDone
+	s.shutdown()
+	return err
+}
+
+// scheduledExporter 导出器及其推送间隔
+type scheduledExporter struct {
+	exp      exporter.Exporter
+	interval time.Duration
+}
+
+// startExporters 按配置创建推送导出器并在后台运行，全部创建成功后才启动
+func startExporters(ctx context.Context, cfg *config.Config, reg *metrics.Registry) (*exporter.Group, error) {
+	exporters, err := newExporters(cfg)
+	if err != nil {
+		return nil, err
+	}
+	g := exporter.NewGroup(ctx, cfg.Serve.ShutdownTimeout)
+	for i, e := range exporters {
+		if err := g.Go(reg, e.exp, e.interval); err != nil {
+			// 已启动的导出器由 Stop 关闭，未启动的在此关闭
+			for _, rest := range exporters[i:] {
+				if c, ok := rest.exp.(io.Closer); ok {
+					_ = c.Close()
+				}
+			}
+			_ = g.Stop(cfg.Serve.ShutdownTimeout)
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// newExporters 创建配置中启用的推送导出器，任一创建失败时关闭已创建的导出器
+func newExporters(cfg *config.Config) (exporters []scheduledExporter, err error) {
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, e := range exporters {
+			if c, ok := e.exp.(io.Closer); ok {
+				_ = c.Close()
+			}
+		}
+	}()
+	add := func(exp exporter.Exporter, interval time.Duration) {
+		exporters = append(exporters, scheduledExporter{exp: exp, interval: interval})
+	}
+
+	if cfg.RemoteWrite.URL != "" {
+		rw, err := exporter.NewRemoteWrite(cfg.RemoteWrite)
+		if err != nil {
+			return exporters, err
+		}
+		add(rw, cfg.RemoteWrite.FlushInterval)
+	}
+	if cfg.OTLP.Endpoint != "" {
+		otlp, err := exporter.NewOTLP(cfg.OTLP)
+		if err != nil {
+			return exporters, err
+		}
+		add(otlp, cfg.OTLP.Interval)
+	}
+	if cfg.InfluxDB.URL != "" || cfg.InfluxDB.Output != "" {
+		influx, err := exporter.NewInfluxDB(cfg.InfluxDB)
+		if err != nil {
+			return exporters, err
+		}
+		add(influx, cfg.InfluxDB.Interval)
+	}
+	if cfg.Graphite.Addr != "" {
+		graphite, err := exporter.NewGraphite(cfg.Graphite)
+		if err != nil {
+			return exporters, err
+		}
+		add(graphite, cfg.Graphite.Interval)
+	}
+	if cfg.StatsD.Addr != "" {
+		add(exporter.NewStatsD(cfg.StatsD), cfg.StatsD.Interval)
+	}
+	if len(cfg.Kafka.Brokers) > 0 {
+		kafka, err := exporter.NewKafka(cfg.Kafka)
+		if err != nil {
+			return exporters, err
+		}
+		add(kafka, cfg.Kafka.Interval)
+	}
+	if cfg.MQTT.Broker != "" {
+		mqtt, err := exporter.NewMQTT(cfg.MQTT)
+		if err != nil {
+			return exporters, err
+		}
+		add(mqtt, cfg.MQTT.Interval)
+	}
+	if cfg.NATS.URL != "" {
+		nats, err := exporter.NewNATS(cfg.NATS)
+		if err != nil {
+			return exporters, err
+		}
+		add(nats, cfg.NATS.Interval)
+	}
+	return exporters, nil
+}
+
+// newHandler 创建 HTTP 路由，gather 返回当前生效的所有指标
+func newHandler(gather func(context.Context) []*metrics.Family) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler(gather))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintln(w, `<html><body><a href="/metrics">/metrics</a></body></html>`)
+	})
+	return mux
+}
+
+// metricsHandler 每次请求时采集并输出所有指标，按 Accept 请求头选择 OpenMetrics 或 Prometheus 文本格式
+func metricsHandler(gather func(context.Context) []*metrics.Family) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		families := gather(r.Context())
+		format := metrics.Negotiate(r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Add("Vary", "Accept")
+		if err := format.Write(w, families); err != nil {
+			slog.Warn("Failed to write metrics", "error", err)
+		}
+	})
+}
+
+// run 启动 HTTP 服务，阻塞直到 ctx 取消后优雅退出，或服务出错
+func run(ctx context.Context, cfg config.ServeConfig, handler http.Handler) error {
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", cfg.Addr, err)
+	}
+	srv := &http.Server{
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Serve(ln) }()
+	slog.Info("Serving metrics", "addr", ln.Addr().String())
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down", "timeout", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve: %w", err)
+	}
+	return nil
+}
+
+// buildInfoCollector 输出版本信息和进程启动时间
+type buildInfoCollector struct {
+	start time.Time
+}
+
+// newBuildInfoCollector 创建版本信息采集器
+func newBuildInfoCollector() *buildInfoCollector {
+	return &buildInfoCollector{start: time.Now()}
+}
+
+// Name 实现 metrics.Collector
+func (c *buildInfoCollector) Name() string {
+	return "build_info"
+}
+
+// Collect 实现 metrics.Collector
+func (c *buildInfoCollector) Collect(ctx context.Context) ([]*metrics.Family, error) {
+	info := metrics.NewFamily("build_info", "版本信息，值恒为 1", metrics.Gauge)
+	info.Add(1, "version", version.AppVersion, "commit", version.GitCommit, "goversion", runtime.Version())
+	start := metrics.NewFamily("start_time_seconds", "进程启动时间 (Unix 秒)", metrics.Gauge)
+	start.Add(float64(c.start.UnixNano()) / 1e9)
+	return []*metrics.Family{info, start}, nil
+}
@@ -0,0 +1,268 @@
+package serve
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/command"
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+	"github.com/urfave/cli/v3"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	reg := metrics.NewRegistry()
+	if err := reg.Register(newBuildInfoCollector()); err != nil {
+		t.Fatalf("注册失败: %v", err)
+	}
+	handler := newHandler(reg.Gather)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != metrics.TextContentType {
+		t.Fatalf("响应 = %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "# TYPE vm_metrics_build_info gauge\n") {
+		t.Errorf("缺少 build_info 指标:\n%s", rec.Body.String())
+	}
+
+	// 抓取方声明支持 OpenMetrics 时按 OpenMetrics 输出
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Type") != metrics.OpenMetricsContentType || !strings.HasSuffix(rec.Body.String(), "# EOF\n") {
+		t.Errorf("应输出 OpenMetrics 格式: %s\n%s", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("未知路径应返回 404: %d", rec.Code)
+	}
+}
+
+func TestExportersFlushOnShutdown(t *testing.T) {
+	var pushes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pushes.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	reg := metrics.NewRegistry()
+	if err := reg.Register(newBuildInfoCollector()); err != nil {
+		t.Fatalf("注册失败: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.RemoteWrite.URL = srv.URL
+	cfg.RemoteWrite.FlushInterval = time.Hour // 运行期间不会到达推送周期
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exporters, err := startExporters(ctx, &cfg, reg)
+	if err != nil {
+		t.Fatalf("启动导出器失败: %v", err)
+	}
+	cancel()
+	if err := exporters.Stop(5 * time.Second); err != nil {
+		t.Fatalf("停止导出器失败: %v", err)
+	}
+	if got := pushes.Load(); got != 1 {
+		t.Errorf("退出时应推送最后一次, 推送次数 = %d", got)
+	}
+}
+
+// newTestApp 返回包含 serve 命令副本的根命令，flag 也复制一份，
+// 避免多次运行 (如 -count=2) 时沿用上一次解析的 flag 取值和是否设置的状态
+func newTestApp() *cli.Command {
+	serve := *Command
+	serve.Flags = make([]cli.Flag, len(Command.Flags))
+	for i, f := range Command.Flags {
+		v := reflect.New(reflect.TypeOf(f).Elem())
+		v.Elem().Set(reflect.ValueOf(f).Elem())
+		serve.Flags[i] = v.Interface().(cli.Flag)
+	}
+	return &cli.Command{Name: "vm-metrics", Flags: command.BaseFlags(), Commands: []*cli.Command{&serve}}
+}
+
+// startServe 以 path 为配置文件在后台运行 serve 命令，返回 /metrics 地址，测试结束时退出
+func startServe(t *testing.T, path string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	app := newTestApp()
+	go func() { done <- app.Run(ctx, []string{"vm-metrics", "serve", "--config", path, "--serve-addr", addr}) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("serve 退出失败: %v", err)
+		}
+	})
+
+	url := "http://" + addr + "/metrics"
+	waitFor(t, "serve 启动", func() bool {
+		resp, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	})
+	return url
+}
+
+// scrape 抓取一次指标
+func scrape(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("抓取失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("读取响应失败: %v", err)
+	}
+	return string(body)
+}
+
+// waitFor 每 50ms 检查一次 cond，5 秒内不满足时失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// hangupUntil 反复发送 SIGHUP 直到 cond 满足 (Reloader 可能尚未开始监听信号)
+func hangupUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	waitFor(t, what, func() bool {
+		if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+			t.Fatalf("发送 SIGHUP 失败: %v", err)
+		}
+		return cond()
+	})
+}
+
+// writeLinkedConfig 将配置写入 dir 下的文件，返回指向它的另一个目录中的符号链接，
+// 修改配置时不会触发对链接所在目录的监听，只能通过 SIGHUP 重载
+func writeLinkedConfig(t *testing.T, data string) (file, link string) {
+	t.Helper()
+	file = filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link = filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.Symlink(file, link); err != nil {
+		t.Fatal(err)
+	}
+	return file, link
+}
+
+func TestServeReloadOnSIGHUP(t *testing.T) {
+	// 接收 SIGHUP，避免 Reloader 开始监听前收到的信号终止测试进程
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var pushes atomic.Int32
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/write" {
+			pushes.Add(1)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(sink.Close) // 在 serve 退出 (最后一次推送) 之后关闭
+
+	file, link := writeLinkedConfig(t, fmt.Sprintf("remote_write:\n  url: %s/write\n  flush_interval: 1h\n", sink.URL))
+	url := startServe(t, link)
+	if strings.Contains(scrape(t, url), "vm_metrics_probe_success") {
+		t.Fatal("未启用探测采集器时不应输出 probe_success")
+	}
+
+	// 启用探测采集器并缩短推送间隔
+	cfg := fmt.Sprintf("remote_write:\n  url: %s/write\n  flush_interval: 100ms\ncollector:\n  probe:\n    enabled: true\n    http:\n      targets: [%s/health]\n", sink.URL, sink.URL)
+	if err := os.WriteFile(file, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hangupUntil(t, "重载后输出 probe_success", func() bool {
+		return strings.Contains(scrape(t, url), "vm_metrics_probe_success")
+	})
+
+	// 旧导出器在替换时推送最后一次，之后按新的间隔推送
+	pushes.Store(0)
+	time.Sleep(time.Second)
+	if got := pushes.Load(); got < 3 {
+		t.Errorf("重载后 1 秒内推送次数 = %d, 期望按 100ms 间隔推送", got)
+	}
+}
+
+func TestServeReloadRotatedSecret(t *testing.T) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var auth atomic.Value
+	auth.Store("")
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(sink.Close)
+
+	token := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(token, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// 配置文件本身不变，只轮换引用的密钥文件
+	_, link := writeLinkedConfig(t, fmt.Sprintf("remote_write:\n  url: %s\n  flush_interval: 50ms\n  token: file://%s\n", sink.URL, token))
+	startServe(t, link)
+	waitFor(t, "使用旧 token 推送", func() bool { return auth.Load() == "Bearer old" })
+
+	if err := os.WriteFile(token, []byte("new\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := auth.Load(); got != "Bearer old" {
+		t.Fatalf("重载前不应重新读取密钥文件: %v", got)
+	}
+	hangupUntil(t, "重载后使用新 token 推送", func() bool { return auth.Load() == "Bearer new" })
+}
+
+func TestServeRejectsInvalidFlags(t *testing.T) {
+	// 配置文件本身没有问题
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("remote_write:\n  max_retries: 3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp()
+	err := app.Run(context.Background(), []string{"vm-metrics", "serve", "--config", path,
+		"--serve-addr", "127.0.0.1:0", "--remote-write-url", "http://127.0.0.1:8428/api/v1/write", "--remote-write-max-retries", "20"})
+	if err == nil || !strings.Contains(err.Error(), "remote_write.max_retries: max retries 20 out of range [0, 10]") {
+		t.Errorf("flags 中的无效取值应在启动前报告: %v", err)
+	}
+}
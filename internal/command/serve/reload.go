@@ -0,0 +1,193 @@
+package serve
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/collector"
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/exporter"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+	"github.com/lwmacct/251203-vm-metrics/internal/version"
+	"github.com/urfave/cli/v3"
+)
+
+// server 持有当前生效的采集器和导出器，重载配置时整体替换
+//
+// serve 段 (监听地址、超时) 不随重载变化，修改后需重启进程
+type server struct {
+	ctx       context.Context
+	buildInfo *buildInfoCollector // 跨重载共享，进程启动时间不变
+	serve     config.ServeConfig
+	current   atomic.Pointer[instance]
+
+	mu      sync.Mutex // 串行化 reload 与 shutdown
+	stopped bool
+}
+
+// instance 按一份配置创建的注册表、采集器和导出器
+type instance struct {
+	reg        *metrics.Registry
+	collectors []metrics.Collector
+	exporters  *exporter.Group
+
+	mu     sync.RWMutex // 抓取持有读锁，关闭时等待进行中的抓取完成
+	closed bool
+}
+
+// newServer 按初始配置创建采集器和导出器并开始推送
+func newServer(ctx context.Context, cfg *config.Config) (*server, error) {
+	s := &server{ctx: ctx, buildInfo: newBuildInfoCollector(), serve: cfg.Serve}
+	inst, err := s.newInstance(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.current.Store(inst)
+	return s, nil
+}
+
+// newInstance 注册配置中启用的采集器并启动推送导出器，失败时关闭已创建的采集器
+func (s *server) newInstance(cfg *config.Config) (*instance, error) {
+	collectors, err := collector.Enabled(cfg.Collector)
+	if err != nil {
+		return nil, err
+	}
+	inst := &instance{reg: metrics.NewRegistry(), collectors: collectors}
+	if err := inst.register(s.buildInfo); err != nil {
+		inst.closeCollectors()
+		return nil, err
+	}
+	inst.exporters, err = startExporters(s.ctx, cfg, inst.reg)
+	if err != nil {
+		inst.closeCollectors()
+		return nil, err
+	}
+	return inst, nil
+}
+
+// gather 从当前生效的注册表采集，采集前被替换并关闭时改用新的注册表 (只重试一次)；
+// shutdown 后不再替换，返回空结果
+func (s *server) gather(ctx context.Context) []*metrics.Family {
+	inst := s.current.Load()
+	families, ok := inst.gather(ctx)
+	if !ok {
+		if next := s.current.Load(); next != inst {
+			families, _ = next.gather(ctx)
+		}
+	}
+	return families
+}
+
+// reload 按新配置重建采集器和导出器并替换当前的，
+// 替换后旧导出器推送最后一次，进行中的抓取完成后关闭旧采集器；重建失败时保留当前的
+func (s *server) reload(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	if cfg.Serve != s.serve {
+		slog.Warn("Serve config changed, restart to apply", "addr", cfg.Serve.Addr)
+	}
+	inst, err := s.newInstance(cfg)
+	if err != nil {
+		slog.Warn("Failed to apply reloaded config, keeping current config", "error", err)
+		return
+	}
+	old := s.current.Swap(inst)
+	old.close(s.serve.ShutdownTimeout)
+	slog.Info("Collectors and exporters reloaded", "collectors", len(inst.collectors))
+}
+
+// shutdown 停止导出器 (推送最后一次) 并关闭采集器，之后的 reload 不再生效
+func (s *server) shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	s.current.Load().close(s.serve.ShutdownTimeout)
+}
+
+// register 注册 buildInfo 和所有采集器
+func (i *instance) register(buildInfo *buildInfoCollector) error {
+	if err := i.reg.Register(buildInfo); err != nil {
+		return err
+	}
+	for _, c := range i.collectors {
+		if err := i.reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gather 采集所有指标，已关闭时 ok 为 false
+func (i *instance) gather(ctx context.Context) (families []*metrics.Family, ok bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if i.closed {
+		return nil, false
+	}
+	return i.reg.Gather(ctx), true
+}
+
+// close 停止导出器，等待进行中的抓取完成后关闭采集器
+func (i *instance) close(timeout time.Duration) {
+	if err := i.exporters.Stop(timeout); err != nil {
+		slog.Warn("Failed to stop exporters", "error", err)
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.closed = true
+	i.closeCollectors()
+}
+
+// closeCollectors 关闭实现了 io.Closer 的采集器 (如数据库连接)
+func (i *instance) closeCollectors() {
+	for _, c := range i.collectors {
+		if closer, ok := c.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				slog.Warn("Failed to close collector", "collector", c.Name(), "error", err)
+			}
+		}
+	}
+}
+
+// loadConfig 重载时先按 config validate 的规则检查配置文件，再按启动时的方式加载
+// (默认值 → 配置文件 → 环境变量 → 命令行参数) 并检查合并后的配置；path 为空时没有配置文件，跳过文件检查
+func loadConfig(cmd *cli.Command, path string) (*config.Config, error) {
+	if path != "" {
+		problems, err := config.Validate(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(problems) > 0 {
+			return nil, invalidConfig(problems)
+		}
+	}
+	cfg, err := config.Load(cmd, path, version.GetAppRawName())
+	if err != nil {
+		return nil, err
+	}
+	if problems := config.ValidateConfig(cfg); len(problems) > 0 {
+		return nil, invalidConfig(problems)
+	}
+	return cfg, nil
+}
+
+// invalidConfig 将校验发现的问题合并为一个错误，每个问题一行
+func invalidConfig(problems []config.Problem) error {
+	msgs := make([]string, len(problems))
+	for i, p := range problems {
+		msgs[i] = p.String()
+	}
+	return fmt.Errorf("invalid config, %d problem(s):\n%s", len(problems), strings.Join(msgs, "\n"))
+}
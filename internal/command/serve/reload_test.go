@@ -0,0 +1,39 @@
+package serve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+)
+
+func TestServerGatherAfterShutdown(t *testing.T) {
+	cfg := config.DefaultConfig()
+	s, err := newServer(context.Background(), &cfg)
+	if err != nil {
+		t.Fatalf("创建失败: %v", err)
+	}
+	if len(s.gather(context.Background())) == 0 {
+		t.Fatal("关闭前应采集到指标")
+	}
+	s.shutdown()
+
+	// 关闭后不再替换注册表，抓取应立即返回而不是不断重试
+	done := make(chan int, 1)
+	go func() { done <- len(s.gather(context.Background())) }()
+	select {
+	case n := <-done:
+		if n != 0 {
+			t.Errorf("关闭后采集到 %d 个指标族, 期望 0", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("关闭后的抓取未返回")
+	}
+
+	// 关闭后的 reload 不生效
+	s.reload(&cfg)
+	if len(s.gather(context.Background())) != 0 {
+		t.Error("关闭后不应重载")
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/knadh/koanf/parsers/toml/v2"
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/env/v2"
 	"github.com/knadh/koanf/providers/file"
@@ -18,8 +20,8 @@ import (
 	"github.com/urfave/cli/v3"
 )
 
-// 默认配置文件搜索路径
-func defaultConfigPaths(appRawName string) []string {
+// DefaultConfigPaths 返回默认配置文件搜索路径（按优先级排序）
+func DefaultConfigPaths(appRawName string) []string {
 	paths := []string{
 		"config.yaml",
 		"config/config.yaml",
@@ -35,6 +37,34 @@ func defaultConfigPaths(appRawName string) []string {
 	return paths
 }
 
+// configCandidates 返回按优先级排序的候选配置文件，每个默认路径先 .yaml 后同名 .toml
+func configCandidates(appRawName string) []string {
+	var paths []string
+	for _, path := range DefaultConfigPaths(appRawName) {
+		paths = append(paths, path, strings.TrimSuffix(path, ".yaml")+".toml")
+	}
+	return paths
+}
+
+// FindConfigFile 返回默认路径中第一个存在的配置文件，没有时返回空字符串
+func FindConfigFile(appRawName string) string {
+	for _, path := range configCandidates(appRawName) {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// EnvPrefix 返回环境变量前缀（含结尾下划线），如 vm-metrics → VM_METRICS_
+// 配置项和 CLI flags 的环境变量共用此前缀
+func EnvPrefix(appRawName string) string {
+	if appRawName == "" || appRawName == "Unknown" {
+		appRawName = "app"
+	}
+	return strings.ReplaceAll(strings.ToUpper(appRawName), "-", "_") + "_"
+}
+
 // Load 加载配置，按优先级合并：
 // 1. 默认值 (最低优先级)
 // 2. 配置文件 (通过 configPath 指定，或搜索默认路径)
@@ -45,7 +75,7 @@ func Load(cmd *cli.Command, configPath, AppRawName string) (*Config, error) {
 		AppRawName = "app"
 	}
 
-	EnvPrefix := strings.ReplaceAll(strings.ToUpper(AppRawName), "-", "_")
+	envPrefix := EnvPrefix(AppRawName)
 
 	k := koanf.New(".")
 
@@ -59,15 +89,15 @@ func Load(cmd *cli.Command, configPath, AppRawName string) (*Config, error) {
 
 	if configPath != "" {
 		// 用户指定了配置文件路径
-		if err := k.Load(file.Provider(configPath), yaml.Parser()); err != nil {
+		if err := loadConfigFile(k, configPath); err != nil {
 			return nil, fmt.Errorf("failed to load config file %s: %w", configPath, err)
 		}
 		slog.Debug("Loaded config from specified file", "path", configPath)
 		configLoaded = true
 	} else {
 		// 搜索默认配置文件路径
-		for _, path := range defaultConfigPaths(AppRawName) {
-			if err := k.Load(file.Provider(path), yaml.Parser()); err == nil {
+		for _, path := range configCandidates(AppRawName) {
+			if err := loadConfigFile(k, path); err == nil {
 				configLoaded = true
 				break
 			}
@@ -79,12 +109,17 @@ func Load(cmd *cli.Command, configPath, AppRawName string) (*Config, error) {
 	}
 
 	// 3️⃣ 加载环境变量
+	// 只接受对应配置项的变量，如 VM_METRICS_SERVER_PATH_PREFIX → server.path_prefix；
+	// 同前缀的其他变量（如只对应 CLI flag 的变量）忽略；列表类型的配置项按逗号分隔
+	envKeys := envKeyMap()
 	if err := k.Load(env.Provider(".", env.Opt{
-		Prefix: EnvPrefix,
+		Prefix: envPrefix,
 		TransformFunc: func(key, value string) (string, any) {
-			key = strings.ToLower(strings.TrimPrefix(key, EnvPrefix))
-			key = strings.ReplaceAll(key, "_", ".")
-			return key, value
+			ek := envKeys[strings.ToLower(strings.TrimPrefix(key, envPrefix))]
+			if ek.list {
+				return ek.key, strings.Split(value, ",")
+			}
+			return ek.key, value
 		},
 	}), nil); err != nil {
 		return nil, fmt.Errorf("failed to load environment variables: %w", err)
@@ -101,9 +136,121 @@ func Load(cmd *cli.Command, configPath, AppRawName string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	// 解析敏感配置项中的 env://、file://、exec:// 引用
+	if err := resolveSecrets(&cfg); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
+// envKey 环境变量对应的配置项
+type envKey struct {
+	key  string // 配置项，如 server.path_prefix
+	list bool   // 是否为列表类型
+}
+
+// envKeyMap 返回环境变量名（去掉前缀、小写）到配置项的映射，如 server_path_prefix → server.path_prefix
+func envKeyMap() map[string]envKey {
+	known := koanf.New(".")
+	_ = known.Load(structs.Provider(DefaultConfig(), "koanf"), nil)
+
+	keys := make(map[string]envKey)
+	for _, key := range known.Keys() {
+		v := known.Get(key)
+		list := v != nil && reflect.ValueOf(v).Kind() == reflect.Slice
+		keys[strings.ReplaceAll(key, ".", "_")] = envKey{key: key, list: list}
+	}
+	return keys
+}
+
+// configParser 按扩展名选择配置文件解析器，.toml 按 TOML 解析，其余按 YAML 解析
+func configParser(path string) koanf.Parser {
+	if isTOML(path) {
+		return toml.Parser()
+	}
+	return yaml.Parser()
+}
+
+// isTOML 判断配置文件是否为 TOML 格式
+func isTOML(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".toml")
+}
+
+// loadConfigFile 解析配置文件并合并到 k，文件中的未知配置项输出警告
+func loadConfigFile(k *koanf.Koanf, path string) error {
+	fk := koanf.New(".")
+	if err := fk.Load(file.Provider(path), configParser(path)); err != nil {
+		return err
+	}
+	if unknown := unknownKeys(fk); len(unknown) > 0 {
+		slog.Warn("Unknown config keys", "path", path, "keys", strings.Join(unknown, ", "))
+	}
+	return k.Merge(fk)
+}
+
+// UnknownKeys 返回配置文件中不对应任何配置项的 key（如拼写错误或已移除的配置项）
+func UnknownKeys(path string) ([]string, error) {
+	fk := koanf.New(".")
+	if err := fk.Load(file.Provider(path), configParser(path)); err != nil {
+		return nil, fmt.Errorf("failed to load config file %s: %w", path, err)
+	}
+	return unknownKeys(fk), nil
+}
+
+// unknownKeys 返回 k 中不属于 Config 的 key，按字母排序
+func unknownKeys(k *koanf.Koanf) []string {
+	known := koanf.New(".")
+	_ = known.Load(structs.Provider(DefaultConfig(), "koanf"), nil)
+
+	var unknown []string
+	for _, key := range k.Keys() {
+		if !known.Exists(key) && !underMapKey(known, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// underMapKey 判断 key 是否位于 map 类型的配置项之下（如 otlp.headers.authorization），其中的 key 由用户决定
+func underMapKey(known *koanf.Koanf, key string) bool {
+	for i := strings.LastIndex(key, "."); i > 0; i = strings.LastIndex(key[:i], ".") {
+		v := known.Get(key[:i])
+		if _, nested := v.(map[string]any); v != nil && !nested && reflect.ValueOf(v).Kind() == reflect.Map {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigFlagNames 返回与配置项对应的 CLI flag 名称（如 server.url → --server-url），
+// 映射规则与 applyCLIFlags 相同
+func ConfigFlagNames() map[string]bool {
+	names := make(map[string]bool)
+	configFlagNames(reflect.TypeOf(Config{}), "", names)
+	return names
+}
+
+// configFlagNames 递归收集结构体字段对应的 flag 名称
+func configFlagNames(typ reflect.Type, prefix string, names map[string]bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		key := field.Tag.Get("koanf")
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+			configFlagNames(field.Type, key, names)
+			continue
+		}
+		names[strings.NewReplacer(".", "-", "_", "-").Replace(key)] = true
+	}
+}
+
 // applyCLIFlags 通过反射将用户明确指定的 CLI flags 应用到 koanf 实例
 // 自动根据 Config 结构体的 koanf 标签映射 CLI flag 名称
 // koanf 标签使用 snake_case，CLI flag 使用 kebab-case
@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
@@ -102,6 +103,84 @@ func TestConfigKeysValid(t *testing.T) {
 	}
 }
 
+// TestLoadTOML 验证 .toml 扩展名的配置文件按 TOML 解析
+func TestLoadTOML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `[server]
+url = "http://vm:8428"
+timeout = "5s"
+
+[output]
+format = "json"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+
+	cfg, err := Load(nil, path, "vm-metrics-test")
+	if err != nil {
+		t.Fatalf("加载 TOML 配置失败: %v", err)
+	}
+	if cfg.Server.URL != "http://vm:8428" || cfg.Server.Timeout != 5*time.Second || cfg.Output.Format != "json" {
+		t.Errorf("TOML 配置未正确加载: %+v", cfg)
+	}
+	// 未出现在文件中的配置项保持默认值
+	if cfg.Output.NoHeaders {
+		t.Errorf("未配置的项应保持默认值: %+v", cfg.Output)
+	}
+}
+
+// TestUnknownKeys 验证配置文件中的未知配置项被报告
+func TestUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `server:
+  url: http://vm:8428
+  timeuot: 5s
+colour: red
+otlp:
+  headers:
+    authorization: Bearer t
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+
+	unknown, err := UnknownKeys(path)
+	if err != nil {
+		t.Fatalf("检查未知配置项失败: %v", err)
+	}
+	if want := []string{"colour", "server.timeuot"}; !reflect.DeepEqual(unknown, want) {
+		t.Errorf("未知配置项 = %v, 期望 %v", unknown, want)
+	}
+
+	// 未知配置项只警告，不影响加载
+	if _, err := Load(nil, path, "vm-metrics-test"); err != nil {
+		t.Errorf("存在未知配置项时加载不应失败: %v", err)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("VM_METRICS_TEST_SERVER_PATH_PREFIX", "/victoria")
+	t.Setenv("VM_METRICS_TEST_SERVER_TIMEOUT", "5s")
+	t.Setenv("VM_METRICS_TEST_KAFKA_BROKERS", "kafka-1:9092,kafka-2:9092")
+	// 只对应 CLI flag 的变量不应影响配置
+	t.Setenv("VM_METRICS_TEST_OUTPUT", "x")
+
+	cfg, err := Load(nil, "", "vm-metrics-test")
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if cfg.Server.PathPrefix != "/victoria" || cfg.Server.Timeout != 5*time.Second {
+		t.Errorf("环境变量未生效: %+v", cfg.Server)
+	}
+	if len(cfg.Kafka.Brokers) != 2 || cfg.Kafka.Brokers[1] != "kafka-2:9092" {
+		t.Errorf("列表类型的环境变量应按逗号分隔: %q", cfg.Kafka.Brokers)
+	}
+	if cfg.Output.Format != "table" {
+		t.Errorf("无关的环境变量不应影响配置: %+v", cfg.Output)
+	}
+}
+
 // loadYAMLKeys 加载 YAML 文件并返回所有配置键的扁平化列表
 func loadYAMLKeys(path string) ([]string, error) {
 	k := koanf.New(".")
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// redactedValue 敏感配置项输出时的替代值
+const redactedValue = "******"
+
+// Redact 返回隐藏敏感配置项后的副本，带 secret:"true" 标签且非空的字段替换为 ******
+func Redact(cfg Config) Config {
+	_ = walkSecrets(reflect.ValueOf(&cfg).Elem(), "", func(key string, v reflect.Value) error {
+		if v.String() != "" {
+			v.SetString(redactedValue)
+		}
+		return nil
+	})
+	return cfg
+}
+
+// Encode 按 format (yaml 或 json) 输出配置
+// 配置项按结构体字段顺序输出，key 与配置文件一致；YAML 附带 comment 标签作为注释
+func Encode(w io.Writer, cfg Config, format string) error {
+	node := structNode(reflect.ValueOf(cfg))
+	switch format {
+	case "yaml", "":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(node); err != nil {
+			return fmt.Errorf("failed to encode config: %w", err)
+		}
+		return enc.Close()
+	case "json":
+		var buf bytes.Buffer
+		if err := writeJSONNode(&buf, node); err != nil {
+			return fmt.Errorf("failed to encode config: %w", err)
+		}
+		var out bytes.Buffer
+		if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+			return fmt.Errorf("failed to encode config: %w", err)
+		}
+		out.WriteByte('\n')
+		_, err := out.WriteTo(w)
+		return err
+	default:
+		return fmt.Errorf("unsupported config format: %s", format)
+	}
+}
+
+// structNode 将结构体转换为 YAML 映射节点，只包含带 koanf 标签的字段
+func structNode(v reflect.Value) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key := field.Tag.Get("koanf")
+		if key == "" {
+			continue
+		}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+		valueNode := valueNode(v.Field(i))
+		// 与配置示例文件一致：嵌套结构体的说明在上一行，配置项的说明在行尾
+		if valueNode.Kind == yaml.MappingNode {
+			keyNode.HeadComment = field.Tag.Get("comment")
+		} else {
+			valueNode.LineComment = field.Tag.Get("comment")
+		}
+		node.Content = append(node.Content, keyNode, valueNode)
+	}
+	return node
+}
+
+// valueNode 将单个配置值转换为 YAML 节点
+func valueNode(v reflect.Value) *yaml.Node {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: d.String()}
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return structNode(v)
+	case reflect.Bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v.Bool())}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(v.Int(), 10)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatUint(v.Uint(), 10)}
+	case reflect.Float32, reflect.Float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(v.Float(), 'g', -1, 64)}
+	case reflect.Slice:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for i := 0; i < v.Len(); i++ {
+			node.Content = append(node.Content, valueNode(v.Index(i)))
+		}
+		return node
+	case reflect.Map:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(k)}, valueNode(v.MapIndex(k)))
+		}
+		return node
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(v.Interface())}
+	}
+}
+
+// writeJSONNode 将 structNode 生成的节点写为紧凑 JSON，保持 key 的顺序
+func writeJSONNode(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(node.Content[i].Value)
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeJSONNode(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONNode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		if node.Tag != "!!str" {
+			// 布尔和数字按字面输出
+			buf.WriteString(node.Value)
+			return nil
+		}
+		value, err := json.Marshal(node.Value)
+		if err != nil {
+			return err
+		}
+		buf.Write(value)
+	}
+	return nil
+}
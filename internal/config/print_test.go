@@ -0,0 +1,67 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Auth.User = "admin"
+	cfg.Auth.Password = "secret"
+
+	redacted := Redact(cfg)
+	if redacted.Auth.Password != redactedValue {
+		t.Errorf("密码应被隐藏: %q", redacted.Auth.Password)
+	}
+	if redacted.Auth.Token != "" {
+		t.Errorf("空的敏感配置项应保持为空: %q", redacted.Auth.Token)
+	}
+	if redacted.Auth.User != "admin" {
+		t.Errorf("非敏感配置项不应改变: %q", redacted.Auth.User)
+	}
+	if cfg.Auth.Password != "secret" {
+		t.Errorf("Redact 不应修改原配置")
+	}
+
+	// map 类型的敏感配置项逐个隐藏，原配置共享的 map 不变
+	cfg.OTLP.Headers = map[string]string{"authorization": "Bearer t"}
+	redacted = Redact(cfg)
+	if redacted.OTLP.Headers["authorization"] != redactedValue || cfg.OTLP.Headers["authorization"] != "Bearer t" {
+		t.Errorf("请求头隐藏不正确: %v / %v", redacted.OTLP.Headers, cfg.OTLP.Headers)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	cfg := DefaultConfig()
+
+	var yamlBuf bytes.Buffer
+	if err := Encode(&yamlBuf, cfg, "yaml"); err != nil {
+		t.Fatalf("输出 YAML 失败: %v", err)
+	}
+	out := yamlBuf.String()
+	if !strings.HasPrefix(out, "# 服务器配置\nserver:\n  url: http://localhost:8428") {
+		t.Errorf("YAML 应按字段顺序输出并带注释:\n%s", out)
+	}
+	if !strings.Contains(out, "timeout: 30s") {
+		t.Errorf("时长应输出为字符串:\n%s", out)
+	}
+
+	var jsonBuf bytes.Buffer
+	if err := Encode(&jsonBuf, cfg, "json"); err != nil {
+		t.Fatalf("输出 JSON 失败: %v", err)
+	}
+	var decoded map[string]map[string]any
+	if err := json.Unmarshal(jsonBuf.Bytes(), &decoded); err != nil {
+		t.Fatalf("JSON 无法解析: %v\n%s", err, jsonBuf.String())
+	}
+	if decoded["server"]["timeout"] != "30s" || decoded["tls"]["skip_verify"] != false {
+		t.Errorf("JSON 内容不正确: %v", decoded)
+	}
+
+	if err := Encode(&jsonBuf, cfg, "xml"); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
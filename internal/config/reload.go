@@ -0,0 +1,123 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce 配置文件最后一次变化后等待的时间，编辑器保存时的多次写入只重载一次
+const reloadDebounce = 500 * time.Millisecond
+
+// Reloader 在配置文件变化或收到 SIGHUP 时重新加载配置
+// 新配置加载失败（解析或校验错误）或文件为空时保留当前配置，只输出警告
+type Reloader struct {
+	path     string                  // 监听的配置文件路径，为空时只响应 SIGHUP
+	load     func() (*Config, error) // 重新加载配置，通常为按原参数调用 Load
+	apply    func(*Config)           // 加载成功后应用新配置
+	debounce time.Duration           // 文件变化后等待的时间，期间再次变化则重新计时
+}
+
+// NewReloader 创建配置重载器
+func NewReloader(path string, load func() (*Config, error), apply func(*Config)) *Reloader {
+	if path != "" {
+		path = filepath.Clean(path)
+	}
+	return &Reloader{path: path, load: load, apply: apply, debounce: reloadDebounce}
+}
+
+// Run 监听配置文件和 SIGHUP，阻塞直到 ctx 取消
+// 监听文件所在目录而不是文件本身，编辑器以重命名方式保存时仍能收到变化；
+// 文件变化后等待 debounce 内不再变化才重载，避免读到写了一半的文件；
+// 文件为符号链接时 (如 Kubernetes 挂载的 ConfigMap) 目标的变化不会触发，需发送 SIGHUP
+func (r *Reloader) Run(ctx context.Context) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	// 没有配置文件时 events 和 errs 为 nil，只响应 SIGHUP
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if r.path != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to create config watcher: %w", err)
+		}
+		defer watcher.Close()
+		if err := watcher.Add(filepath.Dir(r.path)); err != nil {
+			return fmt.Errorf("failed to watch config dir: %w", err)
+		}
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	// 文件变化时 (重新) 计时，到期后才重载
+	var pending *time.Timer
+	var fire <-chan time.Time
+	defer func() {
+		if pending != nil {
+			pending.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			// ctx 已取消时 select 仍可能先选中信号，退出过程中不再重载
+			if ctx.Err() == nil {
+				r.reload("signal")
+			}
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) == r.path && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+				if pending == nil {
+					pending = time.NewTimer(r.debounce)
+				} else {
+					pending.Reset(r.debounce)
+				}
+				fire = pending.C
+			}
+		case <-fire:
+			fire = nil
+			if ctx.Err() == nil {
+				r.reload("file")
+			}
+		case err, ok := <-errs:
+			if !ok {
+				return nil
+			}
+			slog.Warn("Config watcher error", "error", err)
+		}
+	}
+}
+
+// reload 加载并应用新配置
+func (r *Reloader) reload(trigger string) {
+	// 空文件会按默认配置加载，停用所有已配置的采集和推送，视为写入未完成
+	if r.path != "" {
+		if data, err := os.ReadFile(r.path); err == nil && len(bytes.TrimSpace(data)) == 0 {
+			slog.Warn("Config file is empty, keeping current config", "path", r.path, "trigger", trigger)
+			return
+		}
+	}
+	// 重新解析敏感配置项的引用，使轮换后的密钥生效
+	secrets.reset()
+	cfg, err := r.load()
+	if err != nil {
+		slog.Warn("Failed to reload config, keeping current config", "path", r.path, "trigger", trigger, "error", err)
+		return
+	}
+	slog.Info("Config reloaded", "path", r.path, "trigger", trigger)
+	r.apply(cfg)
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestReloaderFileChange 验证配置文件变化后重新加载并应用，连续的多次写入只重载一次
+func TestReloaderFileChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("写入配置文件失败: %v", err)
+		}
+	}
+	write("server:\n  url: http://old:8428\n")
+
+	applied := make(chan *Config, 10)
+	r := NewReloader(path, func() (*Config, error) { return Load(nil, path, "vm-metrics-test") }, func(cfg *Config) { applied <- cfg })
+	r.debounce = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run 返回错误: %v", err)
+		}
+	}()
+
+	// 监听在 goroutine 中建立，重复写入直到收到变化；
+	// 写入时先截断文件，等待 debounce 后才加载，不应加载到空文件 (默认配置)
+	deadline := time.After(5 * time.Second)
+wait:
+	for {
+		write("server:\n  url: http://new:8428\n")
+		select {
+		case cfg := <-applied:
+			if cfg.Server.URL != "http://new:8428" {
+				t.Fatalf("加载到不完整的配置: %s", cfg.Server.URL)
+			}
+			break wait
+		case <-time.After(200 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("配置文件变化后未重新加载为新配置")
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+	for len(applied) > 0 {
+		<-applied
+	}
+
+	// 编辑器保存时的多次写入 (含短暂的空文件)
+	for _, content := range []string{"", "server:\n", "server:\n  url: http://newer:8428\n"} {
+		write(content)
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case cfg := <-applied:
+		if cfg.Server.URL != "http://newer:8428" {
+			t.Errorf("应只加载最后写入的配置: %s", cfg.Server.URL)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("配置文件变化后未重新加载")
+	}
+	select {
+	case cfg := <-applied:
+		t.Errorf("连续写入应只重载一次，又加载了 %s", cfg.Server.URL)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// TestReloaderEmptyFile 验证配置文件为空时保留当前配置
+func TestReloaderEmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("\n"), 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	applied := false
+	r := NewReloader(path, func() (*Config, error) { return Load(nil, path, "vm-metrics-test") }, func(*Config) { applied = true })
+	r.reload("test")
+	if applied {
+		t.Error("空配置文件不应被应用")
+	}
+}
+
+// TestReloaderInvalidConfig 验证新配置无法加载时不应用
+func TestReloaderInvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("server: [\n"), 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	applied := false
+	r := NewReloader(path, func() (*Config, error) { return Load(nil, path, "vm-metrics-test") }, func(*Config) { applied = true })
+	r.reload("test")
+	if applied {
+		t.Error("加载失败的配置不应被应用")
+	}
+}
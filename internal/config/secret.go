@@ -0,0 +1,153 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 敏感配置项的引用前缀，值以这些前缀开头时在加载配置时解析，不以明文写在配置文件中
+const (
+	secretEnvScheme  = "env://"  // env://NAME 读取环境变量
+	secretFileScheme = "file://" // file:///path 读取文件内容，去掉结尾换行
+	secretExecScheme = "exec://" // exec://cmd 执行命令（sh -c）并取标准输出，去掉结尾换行
+)
+
+// secretExecTimeout exec:// 命令的超时时间
+const secretExecTimeout = 10 * time.Second
+
+// secrets 进程内共享的解析缓存，exec:// 等引用只在首次加载及 Reloader 重载配置时解析
+var secrets = &secretCache{values: make(map[string]string)}
+
+// secretCache 缓存已解析的引用
+type secretCache struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// resolve 解析引用，结果按引用缓存；非引用的值原样返回
+func (c *secretCache) resolve(ref string) (string, error) {
+	if _, _, ok := parseSecretRef(ref); !ok {
+		return ref, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.values[ref]; ok {
+		return v, nil
+	}
+	v, err := resolveSecretRef(ref)
+	if err != nil {
+		return "", err
+	}
+	c.values[ref] = v
+	return v, nil
+}
+
+// reset 清空缓存，配置重载时调用，使引用重新解析（如轮换后的密钥）
+func (c *secretCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.values)
+}
+
+// parseSecretRef 拆分引用为前缀和目标，不是引用时 ok 为 false
+func parseSecretRef(value string) (scheme, target string, ok bool) {
+	for _, scheme := range []string{secretEnvScheme, secretFileScheme, secretExecScheme} {
+		if strings.HasPrefix(value, scheme) {
+			return scheme, strings.TrimPrefix(value, scheme), true
+		}
+	}
+	return "", "", false
+}
+
+// resolveSecretRef 按前缀读取引用的值
+func resolveSecretRef(ref string) (string, error) {
+	scheme, target, _ := parseSecretRef(ref)
+	if target == "" {
+		return "", fmt.Errorf("empty secret reference %q", ref)
+	}
+	switch scheme {
+	case secretEnvScheme:
+		v, ok := os.LookupEnv(target)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", target)
+		}
+		return v, nil
+	case secretFileScheme:
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), secretExecTimeout)
+		defer cancel()
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "sh", "-c", target)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to run secret command: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+}
+
+// resolveSecrets 解析配置中敏感配置项的引用
+func resolveSecrets(cfg *Config) error {
+	return walkSecrets(reflect.ValueOf(cfg).Elem(), "", func(key string, v reflect.Value) error {
+		resolved, err := secrets.resolve(v.String())
+		if err != nil {
+			return fmt.Errorf("failed to resolve secret %s: %w", key, err)
+		}
+		v.SetString(resolved)
+		return nil
+	})
+}
+
+// walkSecrets 递归遍历结构体中带 secret:"true" 标签的字符串字段及字符串 map 的各个值，key 为 koanf 路径
+func walkSecrets(v reflect.Value, prefix string, fn func(key string, v reflect.Value) error) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key := field.Tag.Get("koanf")
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		fv := v.Field(i)
+		switch {
+		case field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}):
+			if err := walkSecrets(fv, key, fn); err != nil {
+				return err
+			}
+		case field.Tag.Get("secret") == "true" && fv.Kind() == reflect.String:
+			if err := fn(key, fv); err != nil {
+				return err
+			}
+		case field.Tag.Get("secret") == "true" && fv.Kind() == reflect.Map && fv.Type().Elem().Kind() == reflect.String:
+			// map 的值不可寻址，逐个复制处理后写入新的 map，不修改与其他副本共享的原 map
+			if fv.IsNil() {
+				continue
+			}
+			copied := reflect.MakeMapWithSize(fv.Type(), fv.Len())
+			for _, mk := range fv.MapKeys() {
+				value := reflect.New(fv.Type().Elem()).Elem()
+				value.Set(fv.MapIndex(mk))
+				if err := fn(key+"."+mk.String(), value); err != nil {
+					return err
+				}
+				copied.SetMapIndex(mk, value)
+			}
+			fv.Set(copied)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatalf("写入密钥文件失败: %v", err)
+	}
+	t.Setenv("TEST_SECRET_PASSWORD", "env-password")
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"literal", "plain", "plain"},
+		{"env", "env://TEST_SECRET_PASSWORD", "env-password"},
+		{"file", "file://" + tokenFile, "file-token"},
+		{"exec", "exec://echo exec-token", "exec-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secrets.reset()
+			cfg := DefaultConfig()
+			cfg.Auth.Token = tt.value
+			if err := resolveSecrets(&cfg); err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if cfg.Auth.Token != tt.want {
+				t.Errorf("解析结果 = %q, 期望 %q", cfg.Auth.Token, tt.want)
+			}
+		})
+	}
+
+	secrets.reset()
+	cfg := DefaultConfig()
+	cfg.Auth.Password = "env://TEST_SECRET_MISSING"
+	if err := resolveSecrets(&cfg); err == nil || !strings.Contains(err.Error(), "auth.password") {
+		t.Errorf("环境变量不存在时应返回包含配置项的错误: %v", err)
+	}
+}
+
+// TestSecretCache 验证引用解析结果被缓存，重置后重新解析
+func TestSecretCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("写入密钥文件失败: %v", err)
+		}
+	}
+	ref := "file://" + path
+	cache := &secretCache{values: make(map[string]string)}
+
+	write("old")
+	if v, _ := cache.resolve(ref); v != "old" {
+		t.Fatalf("解析结果 = %q, 期望 old", v)
+	}
+	write("new")
+	if v, _ := cache.resolve(ref); v != "old" {
+		t.Errorf("未重置时应使用缓存: %q", v)
+	}
+	cache.reset()
+	if v, _ := cache.resolve(ref); v != "new" {
+		t.Errorf("重置后应重新解析: %q", v)
+	}
+}
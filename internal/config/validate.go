@@ -0,0 +1,729 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/providers/structs"
+	"github.com/knadh/koanf/v2"
+	"github.com/pelletier/go-toml/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+// 取值受限的配置项
+var (
+	validAuthTypes         = []string{"", "basic", "bearer"}
+	validOutputFormats     = []string{"table", "json", "csv", "graph"}
+	validOTLPProtocols     = []string{"grpc", "http/protobuf"}
+	validTemporalities     = []string{"cumulative", "delta"}
+	validGraphiteProto     = []string{"plaintext", "pickle"}
+	validGraphiteTags      = []string{"tags", "path", "drop"}
+	validStatsDFormats     = []string{"statsd", "dogstatsd"}
+	validKafkaFormats      = []string{"json", "protobuf"}
+	validKafkaCodecs       = []string{"none", "gzip", "snappy", "lz4", "zstd"}
+	validKafkaAcks         = []string{"all", "leader", "none"}
+	validKafkaSASL         = []string{"", "plain", "scram-sha-256", "scram-sha-512"}
+	validMQTTSchemes       = []string{"tcp", "ssl", "tls", "ws", "wss"}
+	validNATSSchemes       = []string{"nats", "tls", "ws", "wss"}
+	validContainerRuntimes = []string{"docker", "containerd"}
+	validNTPDaemons        = []string{"auto", "chrony", "ntpd", "none"}
+	validDNSQueryTypes     = []string{"A", "AAAA", "CNAME", "MX", "NS", "PTR", "SOA", "SRV", "TXT"}
+	validDNSProtocols      = []string{"udp", "tcp"}
+	validDatabaseSchemes   = []string{"postgres", "postgresql", "mysql"}
+)
+
+// maxRemoteWriteRetries remote_write 的最大重试次数，等待时间逐次翻倍，更多的重试会使一次推送持续过久
+const maxRemoteWriteRetries = 10
+
+// databaseMetrics 数据库采集器内置的指标族，自定义查询的指标名不能与之相同
+var databaseMetrics = []string{
+	"db_up", "db_ping_seconds", "db_info", "db_connections", "db_connections_max", "db_connections_active",
+	"db_slow_queries", "db_replica", "db_replication_lag_seconds",
+}
+
+// metricNamePattern Prometheus 指标名
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// statusSpecPattern 逗号分隔的 HTTP 状态码、范围或类别，如 200,204、200-399、2xx
+var statusSpecPattern = regexp.MustCompile(`^\s*([1-5]xx|[1-5]\d\d(-[1-5]\d\d)?)\s*(,\s*([1-5]xx|[1-5]\d\d(-[1-5]\d\d)?)\s*)*$`)
+
+// Problem 配置校验发现的问题
+type Problem struct {
+	Path    string // 配置文件路径
+	Line    int    // 所在行号，未知时为 0
+	Key     string // 配置项，如 server.timeout；文件级问题为空
+	Message string
+}
+
+// String 按 <文件>:<行>: <配置项>: <说明> 格式化，与编译器错误格式一致，便于编辑器和 CI 定位；
+// 合并后的配置中发现的问题没有文件位置，格式为 <配置项>: <说明>
+func (p Problem) String() string {
+	if p.Path == "" {
+		if p.Key == "" {
+			return p.Message
+		}
+		return p.Key + ": " + p.Message
+	}
+	loc := p.Path
+	if p.Line > 0 {
+		loc += ":" + strconv.Itoa(p.Line)
+	}
+	if p.Key == "" {
+		return loc + ": " + p.Message
+	}
+	return loc + ": " + p.Key + ": " + p.Message
+}
+
+// Validate 校验配置文件：语法、未知配置项，以及合并默认值后各配置项的取值
+// 返回的 error 只表示文件无法读取，校验发现的问题通过 []Problem 返回
+func Validate(path string) ([]Problem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	fk := koanf.New(".")
+	if err := fk.Load(file.Provider(path), configParser(path)); err != nil {
+		return []Problem{{Path: path, Line: parseErrorLine(err), Message: err.Error()}}, nil
+	}
+
+	v := &validator{path: path, lines: keyLines(path, data)}
+	for _, key := range unknownKeys(fk) {
+		v.add(key, "unknown config key")
+	}
+
+	// 时长在解析为结构体前单独检查，以便定位到具体配置项
+	known := koanf.New(".")
+	_ = known.Load(structs.Provider(DefaultConfig(), "koanf"), nil)
+	for _, key := range fk.Keys() {
+		if _, ok := known.Get(key).(time.Duration); !ok {
+			continue
+		}
+		if _, err := time.ParseDuration(fmt.Sprint(fk.Get(key))); err != nil {
+			v.add(key, fmt.Sprintf("invalid duration %q (use a value such as 30s or 1m)", fmt.Sprint(fk.Get(key))))
+			fk.Delete(key)
+		}
+	}
+
+	if err := known.Merge(fk); err != nil {
+		v.add("", err.Error())
+		return v.problems, nil
+	}
+	var cfg Config
+	if err := known.Unmarshal("", &cfg); err != nil {
+		v.add("", err.Error())
+		return v.problems, nil
+	}
+	v.check(&cfg)
+	return v.problems, nil
+}
+
+// ValidateConfig 校验合并后的配置 (默认值、配置文件、环境变量、flags) 中各配置项的取值，
+// 规则与 Validate 相同，用于检查不经过配置文件的取值；不检查语法和未知配置项，问题不含文件位置
+func ValidateConfig(cfg *Config) []Problem {
+	v := &validator{}
+	v.check(cfg)
+	return v.problems
+}
+
+// validator 收集单个配置文件的校验问题
+type validator struct {
+	path     string
+	lines    map[string]int // 配置项所在行号
+	problems []Problem
+}
+
+// add 记录一个问题，行号取配置项在文件中的位置；文件中未设置该项时取最近的上级表
+func (v *validator) add(key, message string) {
+	line := 0
+	for k := key; k != "" && line == 0; {
+		line = v.lines[k]
+		i := strings.LastIndex(k, ".")
+		if i < 0 {
+			break
+		}
+		k = k[:i]
+	}
+	v.problems = append(v.problems, Problem{Path: v.path, Line: line, Key: key, Message: message})
+}
+
+// check 检查各配置项的取值及配置项之间的依赖
+func (v *validator) check(cfg *Config) {
+	if !isHTTPURL(cfg.Server.URL) {
+		v.add("server.url", fmt.Sprintf("invalid server url %q (expected http://host:port or https://host:port)", cfg.Server.URL))
+	}
+	v.positive("server.timeout", cfg.Server.Timeout)
+
+	if !slices.Contains(validAuthTypes, cfg.Auth.Type) {
+		v.add("auth.type", fmt.Sprintf("unsupported auth type %q (expected basic or bearer)", cfg.Auth.Type))
+	}
+	if cfg.Auth.Type == "basic" && cfg.Auth.User == "" {
+		v.add("auth.user", "user is required for basic auth")
+	}
+	if cfg.Auth.Type == "bearer" && cfg.Auth.Token == "" {
+		v.add("auth.token", "token is required for bearer auth")
+	}
+
+	if (cfg.TLS.Cert == "") != (cfg.TLS.Key == "") {
+		v.add("tls.cert", "tls.cert and tls.key must be set together")
+	}
+
+	if !slices.Contains(validOutputFormats, cfg.Output.Format) {
+		v.add("output.format", fmt.Sprintf("unsupported output format %q (expected %s)", cfg.Output.Format, strings.Join(validOutputFormats, ", ")))
+	}
+
+	if _, _, err := net.SplitHostPort(cfg.Serve.Addr); err != nil {
+		v.add("serve.addr", fmt.Sprintf("invalid listen address %q (expected host:port or :port)", cfg.Serve.Addr))
+	}
+	v.positive("serve.read_timeout", cfg.Serve.ReadTimeout)
+	v.positive("serve.write_timeout", cfg.Serve.WriteTimeout)
+	v.positive("serve.shutdown_timeout", cfg.Serve.ShutdownTimeout)
+
+	v.pattern("collector.diskstats.device_include", cfg.Collector.Diskstats.DeviceInclude)
+	v.pattern("collector.diskstats.device_exclude", cfg.Collector.Diskstats.DeviceExclude)
+	v.pattern("collector.filesystem.fs_type_include", cfg.Collector.Filesystem.FSTypeInclude)
+	v.pattern("collector.filesystem.fs_type_exclude", cfg.Collector.Filesystem.FSTypeExclude)
+	v.pattern("collector.filesystem.mount_point_include", cfg.Collector.Filesystem.MountPointInclude)
+	v.pattern("collector.filesystem.mount_point_exclude", cfg.Collector.Filesystem.MountPointExclude)
+	if cfg.Collector.Filesystem.Enabled {
+		v.positive("collector.filesystem.statfs_timeout", cfg.Collector.Filesystem.StatfsTimeout)
+	}
+	v.pattern("collector.netdev.device_include", cfg.Collector.Netdev.DeviceInclude)
+	v.pattern("collector.netdev.device_exclude", cfg.Collector.Netdev.DeviceExclude)
+	v.pattern("collector.netstat.field_include", cfg.Collector.Netstat.FieldInclude)
+	v.pattern("collector.process.cmdline_include", cfg.Collector.Process.CmdlineInclude)
+	v.pattern("collector.process.cgroup_include", cfg.Collector.Process.CgroupInclude)
+	if cfg.Collector.Process.Enabled {
+		if cfg.Collector.Process.TopN < 0 {
+			v.add("collector.process.top_n", "top n must not be negative")
+		}
+		if cfg.Collector.Process.MaxProcesses <= 0 {
+			v.add("collector.process.max_processes", "max processes must be positive")
+		}
+		if cfg.Collector.Process.CmdlineMaxLength <= 0 {
+			v.add("collector.process.cmdline_max_length", "cmdline max length must be positive")
+		}
+	}
+	v.pattern("collector.cgroup.name_pattern", cfg.Collector.Cgroup.NamePattern)
+	if cfg.Collector.Cgroup.MaxDepth < 0 {
+		v.add("collector.cgroup.max_depth", "max depth must not be negative")
+	}
+	v.pattern("collector.container.label_include", cfg.Collector.Container.LabelInclude)
+	if cfg.Collector.Container.Enabled {
+		if !slices.Contains(validContainerRuntimes, cfg.Collector.Container.Runtime) {
+			v.add("collector.container.runtime", fmt.Sprintf("unsupported container runtime %q (expected %s)", cfg.Collector.Container.Runtime, strings.Join(validContainerRuntimes, ", ")))
+		}
+		v.positive("collector.container.timeout", cfg.Collector.Container.Timeout)
+	}
+	v.pattern("collector.hwmon.chip_include", cfg.Collector.Hwmon.ChipInclude)
+	v.pattern("collector.hwmon.chip_exclude", cfg.Collector.Hwmon.ChipExclude)
+	if cfg.Collector.SMART.Enabled {
+		v.positive("collector.smart.interval", cfg.Collector.SMART.Interval)
+		v.positive("collector.smart.timeout", cfg.Collector.SMART.Timeout)
+		v.unique("collector.smart.devices", cfg.Collector.SMART.Devices, func(device string) string {
+			name, _, _ := strings.Cut(device, ";")
+			return name
+		})
+	}
+	v.pattern("collector.systemd.unit_include", cfg.Collector.Systemd.UnitInclude)
+	v.pattern("collector.systemd.unit_exclude", cfg.Collector.Systemd.UnitExclude)
+	if cfg.Collector.Systemd.Enabled {
+		v.positive("collector.systemd.timeout", cfg.Collector.Systemd.Timeout)
+	}
+	if cfg.Collector.NTP.Enabled {
+		v.oneOf("collector.ntp.daemon", cfg.Collector.NTP.Daemon, validNTPDaemons)
+		v.positive("collector.ntp.timeout", cfg.Collector.NTP.Timeout)
+	}
+	if cfg.Collector.Probe.Enabled {
+		v.positive("collector.probe.interval", cfg.Collector.Probe.Interval)
+		v.positive("collector.probe.timeout", cfg.Collector.Probe.Timeout)
+		if !statusSpecPattern.MatchString(cfg.Collector.Probe.HTTP.ExpectedStatus) {
+			v.add("collector.probe.http.expected_status", fmt.Sprintf("invalid status codes %q (expected a list such as 200,204, 200-399 or 2xx)", cfg.Collector.Probe.HTTP.ExpectedStatus))
+		}
+		for _, target := range cfg.Collector.Probe.HTTP.Targets {
+			rawURL, status, ok := strings.Cut(target, ";")
+			if !isHTTPURL(rawURL) || (ok && !statusSpecPattern.MatchString(status)) {
+				v.add("collector.probe.http.targets", fmt.Sprintf("invalid http probe target %q (expected http(s)://host/path, optionally followed by ;<status codes>)", target))
+			}
+		}
+		// 探测目标作为 target 标签，重复时采集器无法启动
+		v.unique("collector.probe.http.targets", cfg.Collector.Probe.HTTP.Targets, func(target string) string {
+			rawURL, _, _ := strings.Cut(target, ";")
+			return rawURL
+		})
+		ping := cfg.Collector.Probe.Ping
+		for _, target := range ping.ICMPTargets {
+			if host, ok := splitProbeTarget(target); !ok || host == "" {
+				v.add("collector.probe.ping.icmp_targets", fmt.Sprintf("invalid icmp probe target %q (expected host, optionally followed by ;<interval>)", target))
+			}
+		}
+		for _, target := range ping.TCPTargets {
+			host, ok := splitProbeTarget(target)
+			if _, _, err := net.SplitHostPort(host); err != nil || !ok {
+				v.add("collector.probe.ping.tcp_targets", fmt.Sprintf("invalid tcp probe target %q (expected host:port, optionally followed by ;<interval>)", target))
+			}
+		}
+		probeHost := func(target string) string {
+			host, _ := splitProbeTarget(target)
+			return host
+		}
+		v.unique("collector.probe.ping.icmp_targets", ping.ICMPTargets, probeHost)
+		v.unique("collector.probe.ping.tcp_targets", ping.TCPTargets, probeHost)
+		if ping.Count <= 0 {
+			v.add("collector.probe.ping.count", "count must be positive")
+		}
+		v.positive("collector.probe.ping.packet_interval", ping.PacketInterval)
+		dns := cfg.Collector.Probe.DNS
+		v.oneOf("collector.probe.dns.query_type", strings.ToUpper(dns.QueryType), validDNSQueryTypes)
+		v.oneOf("collector.probe.dns.protocol", dns.Protocol, validDNSProtocols)
+		for _, target := range dns.Targets {
+			fields := strings.SplitN(target, ";", 3)
+			if strings.Trim(fields[0], ".") == "" || (len(fields) > 1 && fields[1] != "" && !slices.Contains(validDNSQueryTypes, strings.ToUpper(fields[1]))) {
+				v.add("collector.probe.dns.targets", fmt.Sprintf("invalid dns probe target %q (expected name, optionally followed by ;<query type> and ;<answer|answer...>)", target))
+			}
+		}
+		// 同一域名和查询类型只能出现一次，期望的应答不同也视为重复
+		v.unique("collector.probe.dns.targets", dns.Targets, func(target string) string {
+			fields := strings.SplitN(target, ";", 3)
+			if len(fields) > 1 && fields[1] != "" {
+				return fields[0] + ";" + strings.ToUpper(fields[1])
+			}
+			return fields[0] + ";" + strings.ToUpper(dns.QueryType)
+		})
+	}
+	if db := cfg.Collector.Database; db.Enabled {
+		for _, name := range slices.Sorted(maps.Keys(db.Targets)) {
+			// 引用在校验后才解析，只检查直接写在配置中的 DSN；错误中不包含 DSN，避免泄露密码
+			dsn := db.Targets[name]
+			if _, _, ok := parseSecretRef(dsn); ok {
+				continue
+			}
+			if u, err := url.Parse(dsn); err != nil || !slices.Contains(validDatabaseSchemes, u.Scheme) {
+				v.add("collector.database.targets."+name, "invalid dsn (expected postgres://, postgresql:// or mysql:// url)")
+			}
+		}
+		v.positive("collector.database.timeout", db.Timeout)
+		v.positive("collector.database.slow_query_threshold", db.SlowQueryThreshold)
+		for _, queries := range []struct {
+			key     string
+			queries map[string]string
+		}{
+			{"collector.database.postgres_queries", db.PostgresQueries},
+			{"collector.database.mysql_queries", db.MySQLQueries},
+		} {
+			for _, name := range slices.Sorted(maps.Keys(queries.queries)) {
+				if !metricNamePattern.MatchString(name) {
+					v.add(queries.key+"."+name, fmt.Sprintf("invalid metric name %q", name))
+				} else if slices.Contains(databaseMetrics, name) {
+					v.add(queries.key+"."+name, fmt.Sprintf("metric name %q conflicts with a built-in database metric", name))
+				}
+			}
+		}
+	}
+
+	if cfg.RemoteWrite.URL != "" {
+		if !isHTTPURL(cfg.RemoteWrite.URL) {
+			v.add("remote_write.url", fmt.Sprintf("invalid remote write url %q", cfg.RemoteWrite.URL))
+		}
+		v.positive("remote_write.flush_interval", cfg.RemoteWrite.FlushInterval)
+		v.positive("remote_write.timeout", cfg.RemoteWrite.Timeout)
+		if cfg.RemoteWrite.MaxBatchSize <= 0 {
+			v.add("remote_write.max_batch_size", "max batch size must be positive")
+		}
+		if cfg.RemoteWrite.MaxRetries < 0 || cfg.RemoteWrite.MaxRetries > maxRemoteWriteRetries {
+			v.add("remote_write.max_retries", fmt.Sprintf("max retries %d out of range [0, %d]", cfg.RemoteWrite.MaxRetries, maxRemoteWriteRetries))
+		}
+	}
+
+	if cfg.OTLP.Endpoint != "" {
+		if !slices.Contains(validOTLPProtocols, cfg.OTLP.Protocol) {
+			v.add("otlp.protocol", fmt.Sprintf("unsupported otlp protocol %q (expected %s)", cfg.OTLP.Protocol, strings.Join(validOTLPProtocols, ", ")))
+		}
+		if cfg.OTLP.Protocol == "http/protobuf" && !isHTTPURL(cfg.OTLP.Endpoint) {
+			v.add("otlp.endpoint", fmt.Sprintf("invalid otlp endpoint %q (http/protobuf expects a URL such as http://localhost:4318/v1/metrics)", cfg.OTLP.Endpoint))
+		}
+		if !slices.Contains(validTemporalities, cfg.OTLP.Temporality) {
+			v.add("otlp.temporality", fmt.Sprintf("unsupported temporality %q (expected %s)", cfg.OTLP.Temporality, strings.Join(validTemporalities, ", ")))
+		}
+		v.positive("otlp.interval", cfg.OTLP.Interval)
+		v.positive("otlp.timeout", cfg.OTLP.Timeout)
+	}
+
+	if cfg.InfluxDB.URL != "" {
+		if !isHTTPURL(cfg.InfluxDB.URL) {
+			v.add("influxdb.url", fmt.Sprintf("invalid influxdb url %q", cfg.InfluxDB.URL))
+		}
+		if cfg.InfluxDB.Output != "" {
+			v.add("influxdb.output", "influxdb.url and influxdb.output are mutually exclusive")
+		}
+		if cfg.InfluxDB.Org == "" {
+			v.add("influxdb.org", "org is required when pushing to influxdb")
+		}
+		if cfg.InfluxDB.Bucket == "" {
+			v.add("influxdb.bucket", "bucket is required when pushing to influxdb")
+		}
+		v.positive("influxdb.timeout", cfg.InfluxDB.Timeout)
+	}
+	if cfg.InfluxDB.URL != "" || cfg.InfluxDB.Output != "" {
+		v.positive("influxdb.interval", cfg.InfluxDB.Interval)
+	}
+
+	if cfg.Graphite.Addr != "" {
+		if _, _, err := net.SplitHostPort(cfg.Graphite.Addr); err != nil {
+			v.add("graphite.addr", fmt.Sprintf("invalid carbon address %q (expected host:port)", cfg.Graphite.Addr))
+		}
+		if !slices.Contains(validGraphiteProto, cfg.Graphite.Protocol) {
+			v.add("graphite.protocol", fmt.Sprintf("unsupported graphite protocol %q (expected %s)", cfg.Graphite.Protocol, strings.Join(validGraphiteProto, ", ")))
+		}
+		if !slices.Contains(validGraphiteTags, cfg.Graphite.TagMode) {
+			v.add("graphite.tag_mode", fmt.Sprintf("unsupported tag mode %q (expected %s)", cfg.Graphite.TagMode, strings.Join(validGraphiteTags, ", ")))
+		}
+		if cfg.Graphite.MaxBatchSize <= 0 {
+			v.add("graphite.max_batch_size", "max batch size must be positive")
+		}
+		v.positive("graphite.interval", cfg.Graphite.Interval)
+		v.positive("graphite.timeout", cfg.Graphite.Timeout)
+		v.positive("graphite.retry_backoff", cfg.Graphite.RetryBackoff)
+		if cfg.Graphite.MaxBackoff < cfg.Graphite.RetryBackoff {
+			v.add("graphite.max_backoff", "max backoff must not be less than retry_backoff")
+		}
+	}
+
+	if cfg.StatsD.Addr != "" {
+		if path, ok := strings.CutPrefix(cfg.StatsD.Addr, "unix://"); ok {
+			if path == "" {
+				v.add("statsd.addr", "unix socket path is empty")
+			}
+		} else if _, _, err := net.SplitHostPort(cfg.StatsD.Addr); err != nil {
+			v.add("statsd.addr", fmt.Sprintf("invalid statsd address %q (expected host:port or unix:///path)", cfg.StatsD.Addr))
+		}
+		if !slices.Contains(validStatsDFormats, cfg.StatsD.Format) {
+			v.add("statsd.format", fmt.Sprintf("unsupported statsd format %q (expected %s)", cfg.StatsD.Format, strings.Join(validStatsDFormats, ", ")))
+		}
+		if cfg.StatsD.SampleRate <= 0 || cfg.StatsD.SampleRate > 1 {
+			v.add("statsd.sample_rate", fmt.Sprintf("sample rate %v out of range (0, 1]", cfg.StatsD.SampleRate))
+		}
+		if cfg.StatsD.MaxPacketSize <= 0 {
+			v.add("statsd.max_packet_size", "max packet size must be positive")
+		}
+		v.positive("statsd.interval", cfg.StatsD.Interval)
+	}
+
+	if len(cfg.Kafka.Brokers) > 0 {
+		for _, broker := range cfg.Kafka.Brokers {
+			if _, _, err := net.SplitHostPort(broker); err != nil {
+				v.add("kafka.brokers", fmt.Sprintf("invalid broker address %q (expected host:port)", broker))
+			}
+		}
+		if cfg.Kafka.Topic == "" {
+			v.add("kafka.topic", "topic is required when brokers are set")
+		}
+		v.oneOf("kafka.format", cfg.Kafka.Format, validKafkaFormats)
+		v.oneOf("kafka.compression", cfg.Kafka.Compression, validKafkaCodecs)
+		v.oneOf("kafka.acks", cfg.Kafka.Acks, validKafkaAcks)
+		v.oneOf("kafka.sasl_mechanism", cfg.Kafka.SASLMechanism, validKafkaSASL)
+		if cfg.Kafka.SASLMechanism != "" && cfg.Kafka.SASLUser == "" {
+			v.add("kafka.sasl_user", "user is required for sasl authentication")
+		}
+		if cfg.Kafka.TLSCA != "" && !cfg.Kafka.TLS {
+			v.add("kafka.tls_ca", "tls_ca has no effect unless kafka.tls is enabled")
+		}
+		v.positive("kafka.interval", cfg.Kafka.Interval)
+		v.positive("kafka.timeout", cfg.Kafka.Timeout)
+	}
+
+	if cfg.MQTT.Broker != "" {
+		if u, err := url.Parse(cfg.MQTT.Broker); err != nil || !slices.Contains(validMQTTSchemes, u.Scheme) || u.Host == "" {
+			v.add("mqtt.broker", fmt.Sprintf("invalid mqtt broker %q (expected %s://host:port)", cfg.MQTT.Broker, strings.Join(validMQTTSchemes, "|")))
+		}
+		if cfg.MQTT.Topic == "" {
+			v.add("mqtt.topic", "topic is required when broker is set")
+		} else if _, err := template.New("topic").Parse(cfg.MQTT.Topic); err != nil {
+			v.add("mqtt.topic", fmt.Sprintf("invalid topic template: %v", err))
+		}
+		if cfg.MQTT.QoS != 0 && cfg.MQTT.QoS != 1 {
+			v.add("mqtt.qos", fmt.Sprintf("unsupported qos %d (expected 0 or 1)", cfg.MQTT.QoS))
+		}
+		v.positive("mqtt.interval", cfg.MQTT.Interval)
+		v.positive("mqtt.timeout", cfg.MQTT.Timeout)
+		v.positive("mqtt.max_reconnect_interval", cfg.MQTT.MaxReconnectInterval)
+	}
+
+	if cfg.NATS.URL != "" {
+		for _, server := range strings.Split(cfg.NATS.URL, ",") {
+			if u, err := url.Parse(strings.TrimSpace(server)); err != nil || !slices.Contains(validNATSSchemes, u.Scheme) || u.Host == "" {
+				v.add("nats.url", fmt.Sprintf("invalid nats server %q (expected %s://host:port)", server, strings.Join(validNATSSchemes, "|")))
+			}
+		}
+		if cfg.NATS.Subject == "" {
+			v.add("nats.subject", "subject is required when url is set")
+		} else if _, err := template.New("subject").Parse(cfg.NATS.Subject); err != nil {
+			v.add("nats.subject", fmt.Sprintf("invalid subject template: %v", err))
+		}
+		if cfg.NATS.Stream != "" && !cfg.NATS.JetStream {
+			v.add("nats.stream", "stream has no effect unless nats.jetstream is enabled")
+		}
+		if cfg.NATS.Credentials != "" && cfg.NATS.NKeySeed != "" {
+			v.add("nats.nkey_seed", "credentials and nkey_seed are mutually exclusive")
+		}
+		v.positive("nats.interval", cfg.NATS.Interval)
+		v.positive("nats.timeout", cfg.NATS.Timeout)
+	}
+
+	v.checkPorts(cfg)
+
+	// 只检查引用格式，不读取环境变量、文件或执行命令
+	_ = walkSecrets(reflect.ValueOf(cfg).Elem(), "", func(key string, value reflect.Value) error {
+		if _, target, ok := parseSecretRef(value.String()); ok && target == "" {
+			v.add(key, fmt.Sprintf("empty secret reference %q", value.String()))
+		}
+		return nil
+	})
+}
+
+// pushAddr 推送目标连接的 TCP 地址
+type pushAddr struct {
+	key      string // 配置项
+	protocol string // 应用层协议，同一地址上只能有一种
+	host     string
+	port     string
+}
+
+// checkPorts 检查端口冲突：推送目标指向本进程的监听地址 serve.addr，
+// 或不同协议的推送目标使用同一地址 (如 graphite.addr 与 remote_write.url)；
+// StatsD 使用 UDP 或 Unix 套接字，不参与检查
+func (v *validator) checkPorts(cfg *Config) {
+	var addrs []pushAddr
+	add := func(key, protocol, addr string) {
+		if host, port, err := net.SplitHostPort(strings.TrimSpace(addr)); err == nil && port != "" {
+			addrs = append(addrs, pushAddr{key: key, protocol: protocol, host: strings.ToLower(host), port: port})
+		}
+	}
+	// addURL 未指定端口时使用 scheme 的默认端口
+	addURL := func(key, protocol, rawURL string, defaultPorts map[string]string) {
+		u, err := url.Parse(strings.TrimSpace(rawURL))
+		if err != nil || u.Hostname() == "" {
+			return
+		}
+		port := u.Port()
+		if port == "" {
+			port = defaultPorts[u.Scheme]
+		}
+		add(key, protocol, net.JoinHostPort(u.Hostname(), port))
+	}
+	httpPorts := map[string]string{"http": "80", "https": "443"}
+
+	if cfg.RemoteWrite.URL != "" {
+		addURL("remote_write.url", "http", cfg.RemoteWrite.URL, httpPorts)
+	}
+	if cfg.OTLP.Endpoint != "" {
+		if cfg.OTLP.Protocol == "http/protobuf" {
+			addURL("otlp.endpoint", "http", cfg.OTLP.Endpoint, httpPorts)
+		} else {
+			add("otlp.endpoint", "grpc", cfg.OTLP.Endpoint)
+		}
+	}
+	if cfg.InfluxDB.URL != "" {
+		addURL("influxdb.url", "http", cfg.InfluxDB.URL, httpPorts)
+	}
+	if cfg.Graphite.Addr != "" {
+		add("graphite.addr", "carbon", cfg.Graphite.Addr)
+	}
+	for _, broker := range cfg.Kafka.Brokers {
+		add("kafka.brokers", "kafka", broker)
+	}
+	if cfg.MQTT.Broker != "" {
+		addURL("mqtt.broker", "mqtt", cfg.MQTT.Broker, map[string]string{"tcp": "1883", "ssl": "8883", "tls": "8883", "ws": "80", "wss": "443"})
+	}
+	if cfg.NATS.URL != "" {
+		for _, server := range strings.Split(cfg.NATS.URL, ",") {
+			addURL("nats.url", "nats", server, map[string]string{"nats": "4222", "tls": "4222", "ws": "80", "wss": "443"})
+		}
+	}
+
+	// 监听所有地址时，推送到本机回环地址的同一端口也会连到自己
+	if serveHost, servePort, err := net.SplitHostPort(cfg.Serve.Addr); err == nil {
+		serveHost = strings.ToLower(serveHost)
+		for _, a := range addrs {
+			if a.port == servePort && (a.host == serveHost || (isLoopback(a.host) && (isUnspecified(serveHost) || isLoopback(serveHost)))) {
+				v.add(a.key, fmt.Sprintf("address %s conflicts with serve.addr %q (would push to this process)", net.JoinHostPort(a.host, a.port), cfg.Serve.Addr))
+			}
+		}
+	}
+	reported := make(map[string]bool)
+	for i, a := range addrs {
+		for _, b := range addrs[:i] {
+			if a.host == b.host && a.port == b.port && a.protocol != b.protocol && !reported[a.key+" "+b.key] {
+				reported[a.key+" "+b.key] = true
+				v.add(a.key, fmt.Sprintf("address %s is also used by %s with a different protocol", net.JoinHostPort(a.host, a.port), b.key))
+			}
+		}
+	}
+}
+
+// isLoopback 判断主机是否为 localhost 或回环地址
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return host == "localhost" || (ip != nil && ip.IsLoopback())
+}
+
+// isUnspecified 判断监听地址的主机部分是否表示所有地址 (如 :9101、0.0.0.0:9101)
+func isUnspecified(host string) bool {
+	ip := net.ParseIP(host)
+	return host == "" || (ip != nil && ip.IsUnspecified())
+}
+
+// isHTTPURL 判断是否为带主机的 http/https 地址
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// splitProbeTarget 拆分 ICMP 或 TCP 探测目标，返回主机部分和分号后的探测间隔是否有效 (未指定时有效)
+func splitProbeTarget(target string) (string, bool) {
+	host, interval, ok := strings.Cut(target, ";")
+	if !ok {
+		return host, true
+	}
+	d, err := time.ParseDuration(interval)
+	return host, err == nil && d > 0
+}
+
+// positive 检查时长为正数
+func (v *validator) positive(key string, d time.Duration) {
+	if d <= 0 {
+		v.add(key, "must be positive")
+	}
+}
+
+// unique 检查列表中的名称不重复，name 从元素中取出名称 (如去掉探测目标分号后的选项)；
+// 重复的元素定位到其所在行
+func (v *validator) unique(key string, items []string, name func(string) string) {
+	seen := make(map[string]bool, len(items))
+	for i, item := range items {
+		n := name(item)
+		if seen[n] {
+			v.addItem(key, i, fmt.Sprintf("duplicate name %q", n))
+		}
+		seen[n] = true
+	}
+}
+
+// addItem 记录列表中第 i 个元素的问题，文件中能定位到该元素时取其行号，否则与 add 相同
+func (v *validator) addItem(key string, i int, message string) {
+	if line, ok := v.lines[fmt.Sprintf("%s[%d]", key, i)]; ok {
+		v.problems = append(v.problems, Problem{Path: v.path, Line: line, Key: key, Message: message})
+		return
+	}
+	v.add(key, message)
+}
+
+// pattern 检查正则表达式能否编译
+func (v *validator) pattern(key, expr string) {
+	if _, err := regexp.Compile(expr); err != nil {
+		v.add(key, fmt.Sprintf("invalid regexp: %v", err))
+	}
+}
+
+// oneOf 检查取值在允许的范围内
+func (v *validator) oneOf(key, value string, valid []string) {
+	if !slices.Contains(valid, value) {
+		names := slices.DeleteFunc(slices.Clone(valid), func(s string) bool { return s == "" })
+		v.add(key, fmt.Sprintf("unsupported value %q (expected %s)", value, strings.Join(names, ", ")))
+	}
+}
+
+// parseErrorLine 从解析错误中提取行号，无法提取时返回 0
+func parseErrorLine(err error) int {
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		line, _ := decodeErr.Position()
+		return line
+	}
+	// YAML 的错误信息形如 "yaml: line 3: ..."，行号已包含在信息中
+	return 0
+}
+
+// keyLines 返回配置文件中每个配置项（含上级表）所在的行号
+func keyLines(path string, data []byte) map[string]int {
+	lines := make(map[string]int)
+	if isTOML(path) {
+		tomlKeyLines(data, lines)
+		return lines
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err == nil && len(root.Content) > 0 {
+		yamlKeyLines(root.Content[0], "", lines)
+	}
+	return lines
+}
+
+// yamlKeyLines 递归记录 YAML 映射中各 key 及列表元素的行号
+func yamlKeyLines(node *yaml.Node, prefix string, lines map[string]int) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := prefix + node.Content[i].Value
+		lines[key] = node.Content[i].Line
+		// 列表元素记为 key[i]，供 addItem 定位
+		if value := node.Content[i+1]; value.Kind == yaml.SequenceNode {
+			for j, item := range value.Content {
+				lines[fmt.Sprintf("%s[%d]", key, j)] = item.Line
+			}
+		}
+		yamlKeyLines(node.Content[i+1], key+".", lines)
+	}
+}
+
+var (
+	tomlTableRe = regexp.MustCompile(`^\s*\[\s*([^\]]+?)\s*\]`)
+	tomlKeyRe   = regexp.MustCompile(`^\s*([A-Za-z0-9_.\-"' ]+?)\s*=`)
+)
+
+// tomlKeyLines 逐行扫描 TOML，记录表头和 key = value 所在的行号
+// 只用于定位，语法已由解析器校验，不处理多行字符串等复杂情形
+func tomlKeyLines(data []byte, lines map[string]int) {
+	table := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+		if m := tomlTableRe.FindStringSubmatch(text); m != nil {
+			table = tomlKey(m[1])
+			lines[table] = n
+			continue
+		}
+		if m := tomlKeyRe.FindStringSubmatch(text); m != nil {
+			key := tomlKey(m[1])
+			if table != "" {
+				key = table + "." + key
+			}
+			if _, ok := lines[key]; !ok {
+				lines[key] = n
+			}
+		}
+	}
+}
+
+// tomlKey 去掉 TOML key 各段的引号和空白
+func tomlKey(s string) string {
+	parts := strings.Split(s, ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,371 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string
+	}{
+		{
+			name: "valid",
+			file: "config.yaml",
+			content: `server:
+  url: http://vm:8428
+  timeout: 10s
+`,
+		},
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `server:
+  url: vm:8428
+  timeout: soon
+auth:
+  type: digest
+output:
+  formt: json
+`,
+			want: []string{
+				"config.yaml:7: output.formt: unknown config key",
+				`config.yaml:3: server.timeout: invalid duration "soon" (use a value such as 30s or 1m)`,
+				`config.yaml:2: server.url: invalid server url "vm:8428" (expected http://host:port or https://host:port)`,
+				`config.yaml:5: auth.type: unsupported auth type "digest" (expected basic or bearer)`,
+			},
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `[auth]
+type = "basic"
+
+[tls]
+key = "client.key"
+`,
+			want: []string{
+				"config.toml:1: auth.user: user is required for basic auth",
+				"config.toml:4: tls.cert: tls.cert and tls.key must be set together",
+			},
+		},
+		{
+			name: "influxdb",
+			file: "config.yaml",
+			content: `influxdb:
+  url: http://influx:8086
+  output: metrics.lp
+  org: ops
+`,
+			want: []string{
+				"config.yaml:3: influxdb.output: influxdb.url and influxdb.output are mutually exclusive",
+				"config.yaml:1: influxdb.bucket: bucket is required when pushing to influxdb",
+			},
+		},
+		{
+			name: "graphite",
+			file: "config.toml",
+			content: `[graphite]
+addr = "carbon"
+tag_mode = "labels"
+max_backoff = "100ms"
+`,
+			want: []string{
+				`config.toml:2: graphite.addr: invalid carbon address "carbon" (expected host:port)`,
+				`config.toml:3: graphite.tag_mode: unsupported tag mode "labels" (expected tags, path, drop)`,
+				"config.toml:4: graphite.max_backoff: max backoff must not be less than retry_backoff",
+			},
+		},
+		{
+			name: "statsd",
+			file: "config.yaml",
+			content: `statsd:
+  addr: unix://
+  format: datadog
+  sample_rate: 1.5
+`,
+			want: []string{
+				"config.yaml:2: statsd.addr: unix socket path is empty",
+				`config.yaml:3: statsd.format: unsupported statsd format "datadog" (expected statsd, dogstatsd)`,
+				"config.yaml:4: statsd.sample_rate: sample rate 1.5 out of range (0, 1]",
+			},
+		},
+		{
+			name: "kafka",
+			file: "config.yaml",
+			content: `kafka:
+  brokers: ["kafka-1:9092", "kafka-2"]
+  acks: quorum
+  sasl_mechanism: gssapi
+`,
+			want: []string{
+				`config.yaml:2: kafka.brokers: invalid broker address "kafka-2" (expected host:port)`,
+				"config.yaml:1: kafka.topic: topic is required when brokers are set",
+				`config.yaml:3: kafka.acks: unsupported value "quorum" (expected all, leader, none)`,
+				`config.yaml:4: kafka.sasl_mechanism: unsupported value "gssapi" (expected plain, scram-sha-256, scram-sha-512)`,
+				"config.yaml:1: kafka.sasl_user: user is required for sasl authentication",
+			},
+		},
+		{
+			name: "mqtt",
+			file: "config.yaml",
+			content: `mqtt:
+  broker: localhost:1883
+  topic: "vm/{{.__name__"
+  qos: 2
+`,
+			want: []string{
+				`config.yaml:2: mqtt.broker: invalid mqtt broker "localhost:1883" (expected tcp|ssl|tls|ws|wss://host:port)`,
+				`config.yaml:3: mqtt.topic: invalid topic template: template: topic:1: unclosed action`,
+				"config.yaml:4: mqtt.qos: unsupported qos 2 (expected 0 or 1)",
+			},
+		},
+		{
+			name: "collector",
+			file: "config.yaml",
+			content: `collector:
+  diskstats:
+    device_include: "^(sd|nvme"
+  filesystem:
+    mount_point_exclude: "^/(dev|proc"
+    statfs_timeout: 0s
+  netdev:
+    device_exclude: "^(lo|veth"
+  netstat:
+    field_include: "Tcp_["
+  process:
+    enabled: true
+    top_n: -1
+    max_processes: 0
+  cgroup:
+    max_depth: -1
+  container:
+    enabled: true
+    runtime: podman
+  hwmon:
+    chip_exclude: "^(nvme"
+  smart:
+    enabled: true
+    interval: 0s
+  systemd:
+    unit_include: "^(nginx"
+  ntp:
+    daemon: openntpd
+  probe:
+    enabled: true
+    http:
+      targets: ["https://example.com/health;200-399", "example.com", "http://example.com;ok"]
+      expected_status: 2xx,3
+    ping:
+      icmp_targets: ["10.0.0.1;1m", "example.com;0s"]
+      tcp_targets: ["example.com:443", "example.com"]
+      count: 0
+    dns:
+      targets: ["example.com;AAAA", "example.com;ANY", ";A"]
+      protocol: quic
+`,
+			want: []string{
+				"config.yaml:3: collector.diskstats.device_include: invalid regexp: error parsing regexp: missing closing ): `^(sd|nvme`",
+				"config.yaml:5: collector.filesystem.mount_point_exclude: invalid regexp: error parsing regexp: missing closing ): `^/(dev|proc`",
+				"config.yaml:6: collector.filesystem.statfs_timeout: must be positive",
+				"config.yaml:8: collector.netdev.device_exclude: invalid regexp: error parsing regexp: missing closing ): `^(lo|veth`",
+				"config.yaml:10: collector.netstat.field_include: invalid regexp: error parsing regexp: missing closing ]: `[`",
+				"config.yaml:13: collector.process.top_n: top n must not be negative",
+				"config.yaml:14: collector.process.max_processes: max processes must be positive",
+				"config.yaml:16: collector.cgroup.max_depth: max depth must not be negative",
+				`config.yaml:19: collector.container.runtime: unsupported container runtime "podman" (expected docker, containerd)`,
+				"config.yaml:21: collector.hwmon.chip_exclude: invalid regexp: error parsing regexp: missing closing ): `^(nvme`",
+				"config.yaml:24: collector.smart.interval: must be positive",
+				"config.yaml:26: collector.systemd.unit_include: invalid regexp: error parsing regexp: missing closing ): `^(nginx`",
+				`config.yaml:28: collector.ntp.daemon: unsupported value "openntpd" (expected auto, chrony, ntpd, none)`,
+				`config.yaml:33: collector.probe.http.expected_status: invalid status codes "2xx,3" (expected a list such as 200,204, 200-399 or 2xx)`,
+				`config.yaml:32: collector.probe.http.targets: invalid http probe target "example.com" (expected http(s)://host/path, optionally followed by ;<status codes>)`,
+				`config.yaml:32: collector.probe.http.targets: invalid http probe target "http://example.com;ok" (expected http(s)://host/path, optionally followed by ;<status codes>)`,
+				`config.yaml:35: collector.probe.ping.icmp_targets: invalid icmp probe target "example.com;0s" (expected host, optionally followed by ;<interval>)`,
+				`config.yaml:36: collector.probe.ping.tcp_targets: invalid tcp probe target "example.com" (expected host:port, optionally followed by ;<interval>)`,
+				"config.yaml:37: collector.probe.ping.count: count must be positive",
+				`config.yaml:40: collector.probe.dns.protocol: unsupported value "quic" (expected udp, tcp)`,
+				`config.yaml:39: collector.probe.dns.targets: invalid dns probe target "example.com;ANY" (expected name, optionally followed by ;<query type> and ;<answer|answer...>)`,
+				`config.yaml:39: collector.probe.dns.targets: invalid dns probe target ";A" (expected name, optionally followed by ;<query type> and ;<answer|answer...>)`,
+			},
+		},
+		{
+			name: "database",
+			file: "config.yaml",
+			content: `collector:
+  database:
+    enabled: true
+    targets:
+      app: postgres://monitor:secret@db:5432/app
+      shop: sqlserver://monitor:secret@db:1433
+      legacy: env://LEGACY_DSN
+    slow_query_threshold: 0s
+    mysql_queries:
+      shop-orders: SELECT count(*) AS value FROM orders
+`,
+			want: []string{
+				"config.yaml:6: collector.database.targets.shop: invalid dsn (expected postgres://, postgresql:// or mysql:// url)",
+				"config.yaml:8: collector.database.slow_query_threshold: must be positive",
+				`config.yaml:10: collector.database.mysql_queries.shop-orders: invalid metric name "shop-orders"`,
+			},
+		},
+		{
+			name: "remote_write",
+			file: "config.yaml",
+			content: `remote_write:
+  url: http://vm:8428/api/v1/write
+  max_retries: 64
+`,
+			want: []string{
+				"config.yaml:3: remote_write.max_retries: max retries 64 out of range [0, 10]",
+			},
+		},
+		{
+			name: "duplicate",
+			file: "config.yaml",
+			content: `collector:
+  smart:
+    enabled: true
+    devices:
+      - /dev/sda
+      - /dev/sda;sat
+  probe:
+    enabled: true
+    http:
+      targets:
+        - https://example.com/health
+        - https://example.com/health;200-399
+    ping:
+      icmp_targets: [10.0.0.1, "10.0.0.1;1m"]
+    dns:
+      targets:
+        - example.com
+        - example.com;AAAA
+        - example.com;a;10.0.0.1
+  database:
+    enabled: true
+    postgres_queries:
+      db_up: SELECT 1 AS value
+`,
+			want: []string{
+				`config.yaml:6: collector.smart.devices: duplicate name "/dev/sda"`,
+				`config.yaml:12: collector.probe.http.targets: duplicate name "https://example.com/health"`,
+				`config.yaml:14: collector.probe.ping.icmp_targets: duplicate name "10.0.0.1"`,
+				`config.yaml:19: collector.probe.dns.targets: duplicate name "example.com;A"`,
+				`config.yaml:23: collector.database.postgres_queries.db_up: metric name "db_up" conflicts with a built-in database metric`,
+			},
+		},
+		{
+			name: "ports",
+			file: "config.yaml",
+			content: `serve:
+  addr: :9101
+remote_write:
+  url: http://localhost:9101/api/v1/write
+influxdb:
+  url: http://vm:2003
+  org: ops
+  bucket: metrics
+graphite:
+  addr: vm:2003
+kafka:
+  brokers: ["kafka:9092"]
+  topic: metrics
+mqtt:
+  broker: tcp://kafka:9092
+  topic: metrics
+`,
+			want: []string{
+				`config.yaml:4: remote_write.url: address localhost:9101 conflicts with serve.addr ":9101" (would push to this process)`,
+				"config.yaml:10: graphite.addr: address vm:2003 is also used by influxdb.url with a different protocol",
+				"config.yaml:15: mqtt.broker: address kafka:9092 is also used by kafka.brokers with a different protocol",
+			},
+		},
+		{
+			name: "nats",
+			file: "config.yaml",
+			content: `nats:
+  url: nats://a:4222,b:4222
+  subject: "vm.{{.__collector__"
+  stream: METRICS
+  credentials: /etc/nats/user.creds
+  nkey_seed: /etc/nats/user.nk
+`,
+			want: []string{
+				`config.yaml:2: nats.url: invalid nats server "b:4222" (expected nats|tls|ws|wss://host:port)`,
+				`config.yaml:3: nats.subject: invalid subject template: template: subject:1: unclosed action`,
+				"config.yaml:4: nats.stream: stream has no effect unless nats.jetstream is enabled",
+				"config.yaml:6: nats.nkey_seed: credentials and nkey_seed are mutually exclusive",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("写入配置文件失败: %v", err)
+			}
+
+			problems, err := Validate(path)
+			if err != nil {
+				t.Fatalf("校验配置失败: %v", err)
+			}
+			var got []string
+			for _, p := range problems {
+				rel, _ := filepath.Rel(dir, p.Path)
+				p.Path = rel
+				got = append(got, p.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("校验结果 = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSyntaxError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[server]\nurl = \"http://vm:8428\"\ntimeout = \n"), 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+
+	problems, err := Validate(path)
+	if err != nil {
+		t.Fatalf("校验配置失败: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Line != 3 {
+		t.Errorf("语法错误应定位到第 3 行: %v", problems)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if problems := ValidateConfig(&cfg); len(problems) != 0 {
+		t.Fatalf("默认配置不应有问题: %v", problems)
+	}
+
+	// 来自环境变量或 flags 的取值没有文件位置
+	cfg.RemoteWrite.URL = "http://127.0.0.1:8428/api/v1/write"
+	cfg.RemoteWrite.FlushInterval = 0
+	cfg.RemoteWrite.MaxBatchSize = 0
+	cfg.RemoteWrite.MaxRetries = 11
+	var got []string
+	for _, p := range ValidateConfig(&cfg) {
+		got = append(got, p.String())
+	}
+	want := []string{
+		"remote_write.flush_interval: must be positive",
+		"remote_write.max_batch_size: max batch size must be positive",
+		"remote_write.max_retries: max retries 11 out of range [0, 10]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("校验结果 = %q, 期望 %q", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+// Package exporter 提供将采集的指标推送到外部系统的导出器
+//
+// 拉取方式由 serve 命令的 /metrics 提供；无法被抓取的环境（NAT 之后、短生命周期任务等）
+// 通过导出器按固定间隔采集并主动推送。
+package exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// Exporter 导出器
+type Exporter interface {
+	// Name 导出器名称，用于日志
+	Name() string
+
+	// Export 推送一次采集的结果
+	Export(ctx context.Context, families []*metrics.Family) error
+}
+
+// Run 每隔 interval 采集一次并推送，阻塞直到 ctx 取消
+// 推送失败只输出警告，下个周期继续；ctx 取消后再采集并推送最后一次 (最长 flushTimeout)，
+// 避免丢失最后一个周期的数据，然后关闭实现了 io.Closer 的导出器。
+// 进行中的推送不随 ctx 取消而中断 (由导出器自身的超时限制)，完成后即作为最后一次推送。
+// interval 不为正数时不启动，直接返回错误
+func Run(ctx context.Context, reg *metrics.Registry, exp Exporter, interval, flushTimeout time.Duration) error {
+	if err := checkInterval(exp, interval); err != nil {
+		return err
+	}
+	slog.Info("Exporter started", "exporter", exp.Name(), "interval", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer func() {
+		if c, ok := exp.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				slog.Warn("Failed to close exporter", "exporter", exp.Name(), "error", err)
+			}
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+			defer cancel()
+			if err := exp.Export(flushCtx, reg.Gather(flushCtx)); err != nil {
+				slog.Warn("Final export failed", "exporter", exp.Name(), "error", err)
+			}
+			return nil
+		case <-ticker.C:
+			pushCtx := context.WithoutCancel(ctx)
+			if err := exp.Export(pushCtx, reg.Gather(pushCtx)); err != nil {
+				slog.Warn("Export failed", "exporter", exp.Name(), "error", err)
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// Group 在后台并行运行的一组导出器，Stop 时等待各导出器推送最后一次并关闭
+type Group struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
+	flushTimeout time.Duration
+	wg           sync.WaitGroup
+}
+
+// NewGroup 创建导出器组，ctx 取消或调用 Stop 时停止其中的导出器，最后一次推送最长 flushTimeout
+func NewGroup(ctx context.Context, flushTimeout time.Duration) *Group {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{ctx: ctx, cancel: cancel, flushTimeout: flushTimeout}
+}
+
+// Go 在后台运行导出器，见 Run；interval 不为正数时不启动，返回错误
+func (g *Group) Go(reg *metrics.Registry, exp Exporter, interval time.Duration) error {
+	if err := checkInterval(exp, interval); err != nil {
+		return err
+	}
+	g.wg.Go(func() {
+		if err := Run(g.ctx, reg, exp, interval, g.flushTimeout); err != nil {
+			slog.Warn("Exporter stopped", "exporter", exp.Name(), "error", err)
+		}
+	})
+	return nil
+}
+
+// checkInterval 检查推送间隔，time.NewTicker 在间隔不为正数时 panic
+func checkInterval(exp Exporter, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid %s interval %s: must be positive", exp.Name(), interval)
+	}
+	return nil
+}
+
+// Stop 停止所有导出器，等待最后一次推送和关闭完成，超过 timeout 时返回错误
+func (g *Group) Stop(timeout time.Duration) error {
+	g.cancel()
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out after %s waiting for exporters to stop", timeout)
+	}
+}
+
+// clientTLSConfig 创建客户端 TLS 配置，ca 为空时使用系统证书
+func clientTLSConfig(ca string, skipVerify bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: skipVerify} //nolint:gosec // 用户明确请求跳过验证
+	if ca == "" {
+		return tlsConfig, nil
+	}
+	pem, err := os.ReadFile(ca)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA cert: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("failed to parse CA cert")
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
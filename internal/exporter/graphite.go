@@ -0,0 +1,263 @@
+package exporter
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// Graphite 协议
+const (
+	GraphiteProtocolPlaintext = "plaintext"
+	GraphiteProtocolPickle    = "pickle"
+)
+
+// Graphite 标签的处理方式
+const (
+	GraphiteTagsNative = "tags" // Graphite 1.1 标签: name;k=v;k2=v2
+	GraphiteTagsPath   = "path" // 按标签名排序后追加到路径: name.k.v.k2.v2
+	GraphiteTagsDrop   = "drop" // 丢弃标签，只保留指标名
+)
+
+// Graphite 通过 carbon 的 plaintext 或 pickle 协议推送指标
+// carbon 不可用时丢弃本次数据，并按指数退避延后重连，避免每个周期都阻塞在连接超时上
+type Graphite struct {
+	cfg config.GraphiteConfig
+
+	mu        sync.Mutex
+	conn      net.Conn
+	backoff   time.Duration // 下次连接失败后的等待时间
+	nextDial  time.Time     // 早于此时间不尝试连接
+	connected bool          // 用于只在状态变化时输出日志
+}
+
+// NewGraphite 创建 Graphite 导出器，连接在首次推送时建立；
+// max_batch_size 不为正数时返回错误 (来自 flags 或环境变量的取值不经过 config validate)
+func NewGraphite(cfg config.GraphiteConfig) (*Graphite, error) {
+	if cfg.MaxBatchSize <= 0 {
+		return nil, fmt.Errorf("invalid graphite max batch size %d: must be positive", cfg.MaxBatchSize)
+	}
+	return &Graphite{cfg: cfg, backoff: cfg.RetryBackoff}, nil
+}
+
+// Name 实现 Exporter
+func (e *Graphite) Name() string {
+	return "graphite"
+}
+
+// Close 关闭连接
+func (e *Graphite) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
+
+// Export 实现 Exporter，pickle 协议按 max_batch_size 分批发送
+func (e *Graphite) Export(ctx context.Context, families []*metrics.Family) error {
+	points := graphitePoints(families, e.cfg.Prefix, e.cfg.TagMode, time.Now())
+	if len(points) == 0 {
+		return nil
+	}
+
+	var batches [][]byte
+	if e.cfg.Protocol == GraphiteProtocolPickle {
+		for start := 0; start < len(points); start += e.cfg.MaxBatchSize {
+			end := min(start+e.cfg.MaxBatchSize, len(points))
+			batches = append(batches, encodePickle(points[start:end]))
+		}
+	} else {
+		batches = append(batches, encodePlaintext(points))
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	conn, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	for _, b := range batches {
+		_ = conn.SetWriteDeadline(time.Now().Add(e.cfg.Timeout))
+		if _, err := conn.Write(b); err != nil {
+			_ = conn.Close()
+			e.conn = nil
+			e.fail()
+			return fmt.Errorf("failed to write to carbon %s: %w", e.cfg.Addr, err)
+		}
+	}
+	return nil
+}
+
+// dial 返回现有连接或建立新连接，退避期内直接返回错误
+func (e *Graphite) dial(ctx context.Context) (net.Conn, error) {
+	if e.conn != nil {
+		return e.conn, nil
+	}
+	if wait := time.Until(e.nextDial); wait > 0 {
+		return nil, fmt.Errorf("carbon %s unavailable, next retry in %s", e.cfg.Addr, wait.Round(time.Second))
+	}
+
+	d := net.Dialer{Timeout: e.cfg.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", e.cfg.Addr)
+	if err != nil {
+		e.fail()
+		return nil, fmt.Errorf("failed to connect to carbon %s: %w", e.cfg.Addr, err)
+	}
+	if !e.connected {
+		slog.Info("Connected to carbon", "addr", e.cfg.Addr, "protocol", e.cfg.Protocol)
+	}
+	e.conn, e.connected = conn, true
+	e.backoff, e.nextDial = e.cfg.RetryBackoff, time.Time{}
+	return conn, nil
+}
+
+// fail 记录一次失败，等待时间逐次翻倍直到 max_backoff
+func (e *Graphite) fail() {
+	if e.connected {
+		slog.Warn("Lost connection to carbon", "addr", e.cfg.Addr)
+	}
+	e.connected = false
+	e.nextDial = time.Now().Add(e.backoff)
+	e.backoff = min(e.backoff*2, e.cfg.MaxBackoff)
+}
+
+// graphitePoint Graphite 中的单个数据点
+type graphitePoint struct {
+	path      string
+	value     float64
+	timestamp int64 // 秒
+}
+
+// graphitePoints 将指标族展开为数据点，NaN 和 Inf 被跳过
+func graphitePoints(families []*metrics.Family, prefix, tagMode string, now time.Time) []graphitePoint {
+	var points []graphitePoint
+	for _, f := range families {
+		for _, s := range f.Samples() {
+			if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+				continue
+			}
+			ts := s.Timestamp
+			if ts.IsZero() {
+				ts = now
+			}
+			points = append(points, graphitePoint{
+				path:      graphitePath(prefix, s.Name, s.Labels, tagMode),
+				value:     s.Value,
+				timestamp: ts.Unix(),
+			})
+		}
+	}
+	return points
+}
+
+// graphitePath 按标签处理方式生成指标路径，标签按名称排序，空值标签省略
+func graphitePath(prefix, name string, labels []metrics.Label, tagMode string) string {
+	var sb strings.Builder
+	if prefix != "" {
+		sb.WriteString(strings.TrimSuffix(prefix, ".") + ".")
+	}
+	sb.WriteString(graphiteNode(name))
+
+	sorted := append([]metrics.Label(nil), labels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	for _, l := range sorted {
+		if l.Value == "" {
+			continue
+		}
+		switch tagMode {
+		case GraphiteTagsPath:
+			sb.WriteString("." + graphiteNode(l.Name) + "." + graphiteNode(l.Value))
+		case GraphiteTagsDrop:
+		default:
+			sb.WriteString(";" + graphiteTag(l.Name) + "=" + graphiteTag(l.Value))
+		}
+	}
+	return sb.String()
+}
+
+// graphiteNode 将路径中的一段规范化：点、空白和其他特殊字符替换为下划线
+func graphiteNode(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == ':':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+// graphiteTag 规范化标签名或标签值：分号、等号、空白等在标签语法中有含义，替换为下划线
+func graphiteTag(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ';', '=', '~', '!', '^', ' ', '\t', '\n':
+			return '_'
+		default:
+			return r
+		}
+	}, s)
+}
+
+// encodePlaintext 按 plaintext 协议编码，每行 <路径> <值> <时间戳>
+func encodePlaintext(points []graphitePoint) []byte {
+	var b []byte
+	for _, p := range points {
+		b = append(b, p.path...)
+		b = append(b, ' ')
+		b = strconv.AppendFloat(b, p.value, 'g', -1, 64)
+		b = append(b, ' ')
+		b = strconv.AppendInt(b, p.timestamp, 10)
+		b = append(b, '\n')
+	}
+	return b
+}
+
+// pickle 协议 2 的操作码
+const (
+	pickleProto      = 0x80
+	pickleEmptyList  = ']'
+	pickleMark       = '('
+	pickleBinUnicode = 'X'
+	pickleBinFloat   = 'G'
+	pickleTuple2     = 0x86
+	pickleAppends    = 'e'
+	pickleStop       = '.'
+)
+
+// encodePickle 按 carbon pickle 协议编码：4 字节大端长度 + pickle 序列化的
+//
+//	[(path, (timestamp, value)), ...]
+//
+// 只用到字符串、浮点数、二元组和列表，可被 carbon 的安全反序列化接受
+func encodePickle(points []graphitePoint) []byte {
+	b := []byte{0, 0, 0, 0, pickleProto, 2, pickleEmptyList, pickleMark}
+	for _, p := range points {
+		b = append(b, pickleBinUnicode)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(p.path)))
+		b = append(b, p.path...)
+		b = append(b, pickleBinFloat)
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(float64(p.timestamp)))
+		b = append(b, pickleBinFloat)
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(p.value))
+		b = append(b, pickleTuple2, pickleTuple2)
+	}
+	b = append(b, pickleAppends, pickleStop)
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	return b
+}
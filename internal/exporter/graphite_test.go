@@ -0,0 +1,206 @@
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+func TestGraphitePath(t *testing.T) {
+	labels := []metrics.Label{{Name: "path", Value: "/api v1"}, {Name: "code", Value: "200"}, {Name: "empty", Value: ""}}
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{GraphiteTagsNative, "web.http_requests_total;code=200;path=/api_v1"},
+		{GraphiteTagsPath, "web.http_requests_total.code.200.path._api_v1"},
+		{GraphiteTagsDrop, "web.http_requests_total"},
+	}
+	for _, tt := range tests {
+		if got := graphitePath("web.", "http_requests_total", labels, tt.mode); got != tt.want {
+			t.Errorf("%s: 路径 = %q, 期望 %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestEncodePlaintext(t *testing.T) {
+	got := string(encodePlaintext([]graphitePoint{{"a.b", 1.5, 1700000000}, {"c;k=v", 2, 1700000001}}))
+	want := "a.b 1.5 1700000000\nc;k=v 2 1700000001\n"
+	if got != want {
+		t.Errorf("plaintext = %q, 期望 %q", got, want)
+	}
+}
+
+// pickleTuple 测试中解码出的 (path, (timestamp, value))
+type pickleTuple struct {
+	path      string
+	timestamp float64
+	value     float64
+}
+
+// decodePickle 解码 encodePickle 的输出，只支持其用到的操作码
+func decodePickle(t *testing.T, b []byte) []pickleTuple {
+	t.Helper()
+	if n := binary.BigEndian.Uint32(b); int(n) != len(b)-4 {
+		t.Fatalf("长度前缀 = %d, 实际 %d", n, len(b)-4)
+	}
+	b = b[4:]
+	if b[0] != pickleProto || b[1] != 2 || b[2] != pickleEmptyList || b[3] != pickleMark {
+		t.Fatalf("意外的 pickle 头: %x", b[:4])
+	}
+	b = b[4:]
+	var tuples []pickleTuple
+	for b[0] == pickleBinUnicode {
+		n := binary.LittleEndian.Uint32(b[1:])
+		p := pickleTuple{path: string(b[5 : 5+n])}
+		b = b[5+n:]
+		if b[0] != pickleBinFloat || b[9] != pickleBinFloat || b[18] != pickleTuple2 || b[19] != pickleTuple2 {
+			t.Fatalf("意外的数据点编码: %x", b[:20])
+		}
+		p.timestamp = math.Float64frombits(binary.BigEndian.Uint64(b[1:]))
+		p.value = math.Float64frombits(binary.BigEndian.Uint64(b[10:]))
+		tuples = append(tuples, p)
+		b = b[20:]
+	}
+	if string(b) != string([]byte{pickleAppends, pickleStop}) {
+		t.Fatalf("意外的 pickle 结尾: %x", b)
+	}
+	return tuples
+}
+
+func TestEncodePickle(t *testing.T) {
+	got := decodePickle(t, encodePickle([]graphitePoint{{"a.b", 1.5, 1700000000}, {"中文", -2, 1700000001}}))
+	want := []pickleTuple{{"a.b", 1700000000, 1.5}, {"中文", 1700000001, -2}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("pickle = %v, 期望 %v", got, want)
+	}
+}
+
+// graphiteConfig 返回指向 addr 的测试配置
+func graphiteConfig(addr, protocol string) config.GraphiteConfig {
+	cfg := config.DefaultConfig().Graphite
+	cfg.Addr, cfg.Protocol, cfg.Timeout = addr, protocol, time.Second
+	return cfg
+}
+
+func TestGraphitePickleBatches(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer ln.Close()
+	received := make(chan []byte, 4)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			header := make([]byte, 4)
+			if _, err := io.ReadFull(r, header); err != nil {
+				return
+			}
+			body := make([]byte, binary.BigEndian.Uint32(header))
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			received <- append(header, body...)
+		}
+	}()
+
+	cfg := graphiteConfig(ln.Addr().String(), GraphiteProtocolPickle)
+	cfg.MaxBatchSize = 1
+	e, err := NewGraphite(cfg)
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	defer e.Close()
+	if err := e.Export(context.Background(), testFamilies(1)); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+	for i := range 2 {
+		if batch := decodePickle(t, <-received); len(batch) != 1 {
+			t.Errorf("第 %d 批数据点数 = %d, 期望 1", i+1, len(batch))
+		}
+	}
+}
+
+func TestNewGraphiteInvalidBatchSize(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		cfg := graphiteConfig("127.0.0.1:2004", GraphiteProtocolPickle)
+		cfg.MaxBatchSize = size
+		if _, err := NewGraphite(cfg); err == nil {
+			t.Errorf("max_batch_size 为 %d 时应返回错误", size)
+		}
+	}
+}
+
+func TestGraphiteReconnectBackoff(t *testing.T) {
+	// 先占用端口再关闭，得到一个没有监听的地址
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	cfg := graphiteConfig(addr, GraphiteProtocolPlaintext)
+	cfg.RetryBackoff, cfg.MaxBackoff = time.Hour, 3*time.Hour
+	e, err := NewGraphite(cfg)
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	if err := e.Export(context.Background(), testFamilies(1)); err == nil {
+		t.Fatal("carbon 不可用时应报错")
+	}
+	if e.backoff != 2*time.Hour || time.Until(e.nextDial) <= 0 {
+		t.Fatalf("连接失败后应进入退避: backoff=%s", e.backoff)
+	}
+	e.fail()
+	if e.backoff != 3*time.Hour {
+		t.Errorf("退避时间应不超过 max_backoff: %s", e.backoff)
+	}
+
+	// carbon 恢复后，退避期结束即重连并重置退避时间
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("无法重新监听 %s: %v", addr, err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 4)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	if err := e.Export(context.Background(), testFamilies(1)); err == nil {
+		t.Fatal("退避期内不应尝试连接")
+	}
+	e.nextDial = time.Time{}
+	if err := e.Export(context.Background(), testFamilies(1)); err != nil {
+		t.Fatalf("重连失败: %v", err)
+	}
+	defer e.Close()
+	if e.backoff != time.Hour {
+		t.Errorf("重连成功后应重置退避时间: %s", e.backoff)
+	}
+	if line := <-lines; line == "" {
+		t.Error("未收到数据")
+	}
+}
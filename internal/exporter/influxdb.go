@@ -0,0 +1,161 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+	"github.com/lwmacct/251203-vm-metrics/internal/version"
+)
+
+// InfluxDB 按 InfluxDB 行协议输出指标：设置 url 时推送到 InfluxDB v2 的 /api/v2/write，
+// 否则追加写入 output 指定的文件（- 表示标准输出）
+type InfluxDB struct {
+	client *resty.Client // 为 nil 时写入 out
+	url    string
+	gzip   bool
+	out    io.Writer
+	file   *os.File // 需要关闭的输出文件，标准输出时为 nil
+}
+
+// NewInfluxDB 创建 InfluxDB 导出器
+func NewInfluxDB(cfg config.InfluxDBConfig) (*InfluxDB, error) {
+	e := &InfluxDB{gzip: cfg.Gzip}
+	if cfg.URL == "" {
+		if cfg.Output == "-" {
+			e.out = os.Stdout
+			return e, nil
+		}
+		f, err := os.OpenFile(cfg.Output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open influxdb output %s: %w", cfg.Output, err)
+		}
+		e.out, e.file = f, f
+		return e, nil
+	}
+
+	e.url = strings.TrimSuffix(cfg.URL, "/") + "/api/v2/write"
+	e.client = resty.New().
+		SetTimeout(cfg.Timeout).
+		SetQueryParams(map[string]string{"org": cfg.Org, "bucket": cfg.Bucket, "precision": "ns"}).
+		SetHeader("Content-Type", "text/plain; charset=utf-8").
+		SetHeader("User-Agent", "vm-metrics/"+version.AppVersion).
+		SetDisableWarn(true)
+	if cfg.Token != "" {
+		e.client.SetAuthScheme("Token").SetAuthToken(cfg.Token)
+	}
+	if cfg.Gzip {
+		e.client.SetHeader("Content-Encoding", "gzip")
+	}
+	return e, nil
+}
+
+// Name 实现 Exporter
+func (e *InfluxDB) Name() string {
+	return "influxdb"
+}
+
+// Close 关闭输出文件
+func (e *InfluxDB) Close() error {
+	if e.file == nil {
+		return nil
+	}
+	return e.file.Close()
+}
+
+// Export 实现 Exporter
+func (e *InfluxDB) Export(ctx context.Context, families []*metrics.Family) error {
+	body := appendLineProtocol(nil, families, time.Now())
+	if len(body) == 0 {
+		return nil
+	}
+	if e.client == nil {
+		if _, err := e.out.Write(body); err != nil {
+			return fmt.Errorf("failed to write line protocol: %w", err)
+		}
+		return nil
+	}
+
+	if e.gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return fmt.Errorf("failed to compress line protocol: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("failed to compress line protocol: %w", err)
+		}
+		body = buf.Bytes()
+	}
+	resp, err := e.client.R().SetContext(ctx).SetBody(body).Post(e.url)
+	if err != nil {
+		return fmt.Errorf("influxdb write request failed: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("influxdb write failed: %s: %s", resp.Status(), truncate(resp.String(), 256))
+	}
+	return nil
+}
+
+// appendLineProtocol 将指标族编码为行协议，每个样本一行：
+//
+//	<指标名>,<标签>=<值>,... <类型>=<数值> <纳秒时间戳>
+//
+// 字段名取指标类型 (counter, gauge, histogram)，无类型时为 value，与 Telegraf prometheus 输入 (metric_version=1) 一致；
+// 直方图展开为 _bucket、_sum 和 _count 三个指标名；
+// 行协议不支持 NaN 和 Inf，这类样本被跳过，空值标签按行协议的要求省略
+func appendLineProtocol(b []byte, families []*metrics.Family, now time.Time) []byte {
+	for _, f := range families {
+		field := string(f.Type)
+		if f.Type == metrics.Untyped || field == "" {
+			field = "value"
+		}
+		for _, s := range f.Samples() {
+			if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+				continue
+			}
+			ts := s.Timestamp
+			if ts.IsZero() {
+				ts = now
+			}
+
+			b = append(b, measurementReplacer.Replace(s.Name)...)
+			labels := append([]metrics.Label(nil), s.Labels...)
+			sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+			for _, l := range labels {
+				if l.Value == "" {
+					continue
+				}
+				b = append(b, ',')
+				b = append(b, tagReplacer.Replace(l.Name)...)
+				b = append(b, '=')
+				b = append(b, tagReplacer.Replace(l.Value)...)
+			}
+			b = append(b, ' ')
+			b = append(b, field...)
+			b = append(b, '=')
+			b = strconv.AppendFloat(b, s.Value, 'g', -1, 64)
+			b = append(b, ' ')
+			b = strconv.AppendInt(b, ts.UnixNano(), 10)
+			b = append(b, '\n')
+		}
+	}
+	return b
+}
+
+// 行协议的转义：指标名转义逗号和空格，标签名和标签值另外转义等号；换行无法表示，替换为 \n
+var (
+	measurementReplacer = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+	tagReplacer         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+)
@@ -0,0 +1,121 @@
+package exporter
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+func TestAppendLineProtocol(t *testing.T) {
+	c := &metrics.Family{Name: "requests_total", Type: metrics.Counter}
+	c.Add(3, "path", "/a b", "method", "GET", "empty", "")
+	g := &metrics.Family{Name: "load", Type: metrics.Gauge}
+	g.Add(0.5, "k", "a,b=c")
+	g.Add(math.NaN())
+	u := &metrics.Family{Name: "misc", Type: metrics.Untyped}
+	u.Add(1)
+
+	now := time.Unix(1700000000, 5)
+	got := string(appendLineProtocol(nil, []*metrics.Family{c, g, u}, now))
+	want := `requests_total,method=GET,path=/a\ b counter=3 1700000000000000005
+load,k=a\,b\=c gauge=0.5 1700000000000000005
+misc value=1 1700000000000000005
+`
+	if got != want {
+		t.Errorf("行协议 =\n%s\n期望\n%s", got, want)
+	}
+}
+
+func TestInfluxDBPush(t *testing.T) {
+	var body, query, auth, encoding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/write" {
+			t.Errorf("请求路径 = %s, 期望 /api/v2/write", r.URL.Path)
+		}
+		query, auth, encoding = r.URL.RawQuery, r.Header.Get("Authorization"), r.Header.Get("Content-Encoding")
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("请求体应为 gzip: %v", err)
+			return
+		}
+		b, _ := io.ReadAll(zr)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := config.DefaultConfig().InfluxDB
+	cfg.URL = srv.URL + "/"
+	cfg.Org, cfg.Bucket, cfg.Token = "ops", "metrics", "secret"
+	e, err := NewInfluxDB(cfg)
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	if err := e.Export(context.Background(), testFamilies(1)); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+
+	if query != "bucket=metrics&org=ops&precision=ns" {
+		t.Errorf("查询参数 = %s", query)
+	}
+	if auth != "Token secret" || encoding != "gzip" {
+		t.Errorf("请求头不正确: Authorization=%q Content-Encoding=%q", auth, encoding)
+	}
+	if body == "" {
+		t.Error("请求体为空")
+	}
+}
+
+func TestInfluxDBPushError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"bucket not found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	cfg := config.DefaultConfig().InfluxDB
+	cfg.URL, cfg.Gzip = srv.URL, false
+	e, err := NewInfluxDB(cfg)
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	if err := e.Export(context.Background(), testFamilies(1)); err == nil {
+		t.Error("服务端返回 404 时应报错")
+	}
+}
+
+func TestInfluxDBFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.lp")
+	cfg := config.DefaultConfig().InfluxDB
+	cfg.Output = path
+	e, err := NewInfluxDB(cfg)
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	for range 2 {
+		if err := e.Export(context.Background(), testFamilies(1)); err != nil {
+			t.Fatalf("写入失败: %v", err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("关闭失败: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取输出失败: %v", err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 4 {
+		t.Errorf("两次输出应追加 4 行, 实际 %d 行:\n%s", n, data)
+	}
+}
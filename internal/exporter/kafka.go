@@ -0,0 +1,246 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// Kafka 消息格式
+const (
+	KafkaFormatJSON     = "json"
+	KafkaFormatProtobuf = "protobuf"
+)
+
+// Kafka 将每次采集的结果写入 Kafka topic
+//
+// partition_key 为空时整批作为一条没有 key 的消息；否则按指标名 (__name__) 或指定标签的值分组，
+// 每组一条消息并以分组值作为 key，相同 key 的消息进入同一分区，保证消费方按 key 有序。
+// protobuf 格式为未压缩的 prometheus.WriteRequest，与 remote_write 的消息体相同
+type Kafka struct {
+	client       *kgo.Client
+	format       string
+	partitionKey string
+	timeout      time.Duration
+}
+
+// NewKafka 创建 Kafka 导出器，连接在首次写入时建立
+func NewKafka(cfg config.KafkaConfig) (*Kafka, error) {
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.DefaultProduceTopic(cfg.Topic),
+		kgo.RecordDeliveryTimeout(cfg.Timeout),
+	}
+
+	codec, err := kafkaCompression(cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, kgo.ProducerBatchCompression(codec))
+
+	// 幂等写入要求 acks=all，其他确认级别需要关闭
+	switch cfg.Acks {
+	case "all", "":
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	case "leader":
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()), kgo.DisableIdempotentWrite())
+	case "none":
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()), kgo.DisableIdempotentWrite())
+	default:
+		return nil, fmt.Errorf("unsupported kafka acks: %s", cfg.Acks)
+	}
+
+	switch cfg.SASLMechanism {
+	case "":
+	case "plain":
+		opts = append(opts, kgo.SASL(plain.Auth{User: cfg.SASLUser, Pass: cfg.SASLPassword}.AsMechanism()))
+	case "scram-sha-256":
+		opts = append(opts, kgo.SASL(scram.Auth{User: cfg.SASLUser, Pass: cfg.SASLPassword}.AsSha256Mechanism()))
+	case "scram-sha-512":
+		opts = append(opts, kgo.SASL(scram.Auth{User: cfg.SASLUser, Pass: cfg.SASLPassword}.AsSha512Mechanism()))
+	default:
+		return nil, fmt.Errorf("unsupported kafka sasl mechanism: %s", cfg.SASLMechanism)
+	}
+
+	if cfg.TLS {
+		tlsConfig, err := clientTLSConfig(cfg.TLSCA, cfg.TLSSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	return &Kafka{client: client, format: cfg.Format, partitionKey: cfg.PartitionKey, timeout: cfg.Timeout}, nil
+}
+
+// kafkaCompression 返回压缩算法对应的编码
+func kafkaCompression(name string) (kgo.CompressionCodec, error) {
+	switch name {
+	case "none", "":
+		return kgo.NoCompression(), nil
+	case "gzip":
+		return kgo.GzipCompression(), nil
+	case "snappy":
+		return kgo.SnappyCompression(), nil
+	case "lz4":
+		return kgo.Lz4Compression(), nil
+	case "zstd":
+		return kgo.ZstdCompression(), nil
+	default:
+		return kgo.CompressionCodec{}, fmt.Errorf("unsupported kafka compression: %s", name)
+	}
+}
+
+// Name 实现 Exporter
+func (e *Kafka) Name() string {
+	return "kafka"
+}
+
+// Close 等待缓冲的消息发送完成后关闭连接
+func (e *Kafka) Close() error {
+	e.client.Close()
+	return nil
+}
+
+// Export 实现 Exporter，等待所有消息写入成功或超时
+func (e *Kafka) Export(ctx context.Context, families []*metrics.Family) error {
+	series := toTimeSeries(families, time.Now())
+	if len(series) == 0 {
+		return nil
+	}
+
+	var records []*kgo.Record
+	for _, group := range groupSeries(series, e.partitionKey) {
+		value, err := e.encode(group.series)
+		if err != nil {
+			return err
+		}
+		r := &kgo.Record{Value: value}
+		if e.partitionKey != "" {
+			r.Key = []byte(group.key)
+		}
+		records = append(records, r)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	if err := e.client.ProduceSync(ctx, records...).FirstErr(); err != nil {
+		return fmt.Errorf("failed to produce to kafka: %w", err)
+	}
+	return nil
+}
+
+// encode 按配置的格式编码一组时间序列
+func (e *Kafka) encode(series []timeSeries) ([]byte, error) {
+	if e.format == KafkaFormatProtobuf {
+		return encodeWriteRequest(series), nil
+	}
+	data, err := json.Marshal(jsonBatch(series))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode kafka message: %w", err)
+	}
+	return data, nil
+}
+
+// seriesGroup 分区键相同的一组时间序列
+type seriesGroup struct {
+	key    string
+	series []timeSeries
+}
+
+// groupSeries 按标签的值分组；label 为空时只有一组
+func groupSeries(series []timeSeries, label string) []seriesGroup {
+	if label == "" {
+		return []seriesGroup{{series: series}}
+	}
+	return groupBy(series, func(s timeSeries) string {
+		for _, l := range s.labels {
+			if l.Name == label {
+				return l.Value
+			}
+		}
+		return ""
+	})
+}
+
+// groupBy 按 key 分组，保持首次出现的顺序
+func groupBy(series []timeSeries, key func(timeSeries) string) []seriesGroup {
+	var groups []seriesGroup
+	index := make(map[string]int)
+	for _, s := range series {
+		k := key(s)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, seriesGroup{key: k})
+		}
+		groups[i].series = append(groups[i].series, s)
+	}
+	return groups
+}
+
+// groupByTemplate 按模板渲染的结果分组，模板数据为样本的标签、__name__ 和 __collector__，
+// 标签值先经过 escape 处理；渲染失败时返回第一个错误
+func groupByTemplate(tmpl *template.Template, series []timeSeries, escape func(string) string) ([]seriesGroup, error) {
+	var renderErr error
+	var sb strings.Builder
+	groups := groupBy(series, func(s timeSeries) string {
+		data := make(map[string]string, len(s.labels)+1)
+		for _, l := range s.labels {
+			data[l.Name] = escape(l.Value)
+		}
+		data["__collector__"] = escape(s.collector)
+		sb.Reset()
+		if err := tmpl.Execute(&sb, data); err != nil && renderErr == nil {
+			renderErr = err
+		}
+		return sb.String()
+	})
+	return groups, renderErr
+}
+
+// jsonSample JSON 格式中的单个样本
+type jsonSample struct {
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Value     float64           `json:"value"`
+	Timestamp int64             `json:"timestamp"` // 毫秒
+}
+
+// jsonBatch 转换为 JSON 格式的消息体，JSON 无法表示的 NaN 和 Inf 被跳过
+func jsonBatch(series []timeSeries) map[string][]jsonSample {
+	samples := make([]jsonSample, 0, len(series))
+	for _, s := range series {
+		if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+			continue
+		}
+		sample := jsonSample{Value: s.value, Timestamp: s.timestamp}
+		for _, l := range s.labels {
+			if l.Name == "__name__" {
+				sample.Name = l.Value
+				continue
+			}
+			if sample.Labels == nil {
+				sample.Labels = make(map[string]string)
+			}
+			sample.Labels[l.Name] = l.Value
+		}
+		samples = append(samples, sample)
+	}
+	return map[string][]jsonSample{"metrics": samples}
+}
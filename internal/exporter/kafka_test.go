@@ -0,0 +1,90 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestGroupSeries(t *testing.T) {
+	series := toTimeSeries(testFamilies(1), time.Now())
+	if groups := groupSeries(series, ""); len(groups) != 1 || len(groups[0].series) != 2 {
+		t.Errorf("未设置分区键时应只有一组: %v", groups)
+	}
+
+	groups := groupSeries(series, "__name__")
+	if len(groups) != 2 || groups[0].key != "requests_total" || groups[1].key != "temperature" {
+		t.Errorf("按指标名分组不正确: %v", groups)
+	}
+	groups = groupSeries(series, "code")
+	if len(groups) != 2 || groups[0].key != "200" || groups[1].key != "" {
+		t.Errorf("按标签分组不正确，缺少标签的序列应归入空 key: %v", groups)
+	}
+}
+
+func TestJSONBatch(t *testing.T) {
+	f := &metrics.Family{Name: "up", Type: metrics.Gauge}
+	f.Add(1, "job", "node")
+	f.Add(math.NaN(), "job", "broken")
+	data, err := json.Marshal(jsonBatch(toTimeSeries([]*metrics.Family{f}, time.UnixMilli(1700000000000))))
+	if err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	want := `{"metrics":[{"name":"up","labels":{"job":"node"},"value":1,"timestamp":1700000000000}]}`
+	if string(data) != want {
+		t.Errorf("JSON = %s, 期望 %s", data, want)
+	}
+}
+
+func TestKafkaProduce(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(3, "metrics"))
+	if err != nil {
+		t.Fatalf("启动 Kafka 失败: %v", err)
+	}
+	defer cluster.Close()
+
+	cfg := config.DefaultConfig().Kafka
+	cfg.Brokers, cfg.Topic = cluster.ListenAddrs(), "metrics"
+	cfg.PartitionKey, cfg.Compression, cfg.Timeout = "__name__", "zstd", 10*time.Second
+	e, err := NewKafka(cfg)
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	defer e.Close()
+	if err := e.Export(context.Background(), testFamilies(1)); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	consumer, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...), kgo.ConsumeTopics("metrics"))
+	if err != nil {
+		t.Fatalf("创建消费者失败: %v", err)
+	}
+	defer consumer.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	got := make(map[string]int)
+	for len(got) < 2 && ctx.Err() == nil {
+		consumer.PollFetches(ctx).EachRecord(func(r *kgo.Record) {
+			var batch map[string][]jsonSample
+			if err := json.Unmarshal(r.Value, &batch); err != nil {
+				t.Errorf("解码消息失败: %v", err)
+				return
+			}
+			if name := batch["metrics"][0].Name; name != string(r.Key) {
+				t.Errorf("消息 key = %s, 期望指标名 %s", r.Key, name)
+			}
+			got[string(r.Key)] = len(batch["metrics"])
+		})
+	}
+	if got["requests_total"] != 1 || got["temperature"] != 1 {
+		t.Errorf("收到的消息 = %v", got)
+	}
+}
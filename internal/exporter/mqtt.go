@@ -0,0 +1,132 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// MQTT 将指标发布到 MQTT broker
+//
+// topic 是 Go 模板，数据为样本的标签、__name__（指标名）和 __collector__（采集器名），
+// 如 vm-metrics/{{.__name__}}/{{.device}}；
+// 标签值中的 /、+、# 替换为下划线，避免产生额外的层级或通配符。topic 相同的样本合并为一条消息，
+// 消息体与 Kafka 的 JSON 格式相同。连接断开时由客户端自动重连，期间的数据被丢弃
+type MQTT struct {
+	client  mqtt.Client
+	topic   *template.Template
+	qos     byte
+	retain  bool
+	timeout time.Duration
+}
+
+// NewMQTT 创建 MQTT 导出器，后台连接 broker，不等待连接成功
+func NewMQTT(cfg config.MQTTConfig) (*MQTT, error) {
+	topic, err := template.New("topic").Option("missingkey=zero").Parse(cfg.Topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mqtt topic template: %w", err)
+	}
+
+	clientID := cfg.ClientID
+	if clientID == "" {
+		host, _ := os.Hostname()
+		clientID = "vm-metrics-" + host
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(clientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetConnectTimeout(cfg.Timeout).
+		SetWriteTimeout(cfg.Timeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(cfg.MaxReconnectInterval).
+		SetOnConnectHandler(func(mqtt.Client) {
+			slog.Info("Connected to mqtt broker", "broker", cfg.Broker)
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			slog.Warn("Lost connection to mqtt broker", "broker", cfg.Broker, "error", err)
+		})
+	if cfg.TLSCA != "" || cfg.TLSSkipVerify {
+		tlsConfig, err := clientTLSConfig(cfg.TLSCA, cfg.TLSSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	client := mqtt.NewClient(opts)
+	client.Connect()
+	return &MQTT{client: client, topic: topic, qos: byte(cfg.QoS), retain: cfg.Retain, timeout: cfg.Timeout}, nil
+}
+
+// Name 实现 Exporter
+func (e *MQTT) Name() string {
+	return "mqtt"
+}
+
+// Close 断开连接，最多等待 1 秒让进行中的发布完成
+func (e *MQTT) Close() error {
+	e.client.Disconnect(1000)
+	return nil
+}
+
+// Export 实现 Exporter，QoS 1 时等待 broker 确认
+func (e *MQTT) Export(ctx context.Context, families []*metrics.Family) error {
+	groups, err := e.groupByTopic(toTimeSeries(families, time.Now()))
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+	if !e.client.IsConnectionOpen() {
+		return fmt.Errorf("mqtt broker not connected, dropping %d messages", len(groups))
+	}
+
+	var tokens []mqtt.Token
+	for _, g := range groups {
+		payload, err := json.Marshal(jsonBatch(g.series))
+		if err != nil {
+			return fmt.Errorf("failed to encode mqtt message: %w", err)
+		}
+		tokens = append(tokens, e.client.Publish(g.key, e.qos, e.retain, payload))
+	}
+
+	deadline := time.Now().Add(e.timeout)
+	for _, token := range tokens {
+		select {
+		case <-token.Done():
+		case <-time.After(time.Until(deadline)):
+			return fmt.Errorf("mqtt publish timed out after %s", e.timeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("mqtt publish failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// groupByTopic 渲染每个样本的 topic 并按 topic 分组
+func (e *MQTT) groupByTopic(series []timeSeries) ([]seriesGroup, error) {
+	groups, err := groupByTemplate(e.topic, series, mqttTopicReplacer.Replace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render mqtt topic: %w", err)
+	}
+	return groups, nil
+}
+
+// mqttTopicReplacer 替换标签值中的 topic 层级分隔符和通配符
+var mqttTopicReplacer = strings.NewReplacer("/", "_", "+", "_", "#", "_")
@@ -0,0 +1,148 @@
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// mqttPublish 测试 broker 收到的 PUBLISH
+type mqttPublish struct {
+	topic   string
+	qos     byte
+	retain  bool
+	payload []byte
+}
+
+// serveMQTT 最小的 MQTT 3.1.1 broker：应答 CONNECT、PINGREQ，记录 PUBLISH 并应答 PUBACK
+func serveMQTT(t *testing.T) (string, <-chan mqttPublish) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	published := make(chan mqttPublish, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handleMQTT(conn, published)
+		}
+	}()
+	return "tcp://" + ln.Addr().String(), published
+}
+
+// handleMQTT 处理单个连接上的控制报文
+func handleMQTT(conn net.Conn, published chan<- mqttPublish) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return
+		}
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			_, _ = conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+		case 3: // PUBLISH
+			p := mqttPublish{qos: header >> 1 & 3, retain: header&1 == 1}
+			n := int(binary.BigEndian.Uint16(body))
+			p.topic, body = string(body[2:2+n]), body[2+n:]
+			if p.qos > 0 {
+				_, _ = conn.Write([]byte{0x40, 0x02, body[0], body[1]})
+				body = body[2:]
+			}
+			p.payload = body
+			published <- p
+		case 12: // PINGREQ
+			_, _ = conn.Write([]byte{0xd0, 0x00})
+		case 14: // DISCONNECT
+			return
+		}
+	}
+}
+
+func TestMQTTPublish(t *testing.T) {
+	broker, published := serveMQTT(t)
+
+	cfg := config.DefaultConfig().MQTT
+	cfg.Broker, cfg.Topic, cfg.QoS, cfg.Retain = broker, "vm/{{.__name__}}/{{.code}}", 1, true
+	e, err := NewMQTT(cfg)
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	defer e.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !e.client.IsConnectionOpen() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := e.Export(context.Background(), testFamilies(1)); err != nil {
+		t.Fatalf("发布失败: %v", err)
+	}
+
+	got := make(map[string]mqttPublish)
+	for range 2 {
+		p := <-published
+		got[p.topic] = p
+	}
+	p, ok := got["vm/requests_total/200"]
+	if !ok || p.qos != 1 || !p.retain {
+		t.Fatalf("发布的消息不正确: %+v", got)
+	}
+	var batch map[string][]jsonSample
+	if err := json.Unmarshal(p.payload, &batch); err != nil || batch["metrics"][0].Name != "requests_total" {
+		t.Errorf("消息体不正确: %s", p.payload)
+	}
+	if _, ok := got["vm/temperature/"]; !ok {
+		t.Errorf("缺少标签的样本应渲染为空值: %v", got)
+	}
+}
+
+func TestMQTTTopicSanitize(t *testing.T) {
+	cfg := config.DefaultConfig().MQTT
+	cfg.Broker, cfg.Topic = "tcp://127.0.0.1:1", "fs/{{.mountpoint}}"
+	e, err := NewMQTT(cfg)
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	defer e.Close()
+
+	f := &metrics.Family{Name: "fs_free_bytes", Type: metrics.Gauge}
+	f.Add(1, "mountpoint", "/")
+	f.Add(2, "mountpoint", "/data/#1")
+	f.Add(3, "mountpoint", "/")
+	groups, err := e.groupByTopic(toTimeSeries([]*metrics.Family{f}, time.Now()))
+	if err != nil {
+		t.Fatalf("渲染 topic 失败: %v", err)
+	}
+	if len(groups) != 2 || groups[0].key != "fs/_" || len(groups[0].series) != 2 || groups[1].key != "fs/_data__1" {
+		t.Errorf("topic 分组不正确: %+v", groups)
+	}
+
+	// broker 不可用时丢弃数据并报错，不阻塞
+	if err := e.Export(context.Background(), []*metrics.Family{f}); err == nil {
+		t.Error("未连接时应报错")
+	}
+}
@@ -0,0 +1,161 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATS 将指标发布到 NATS subject，可选通过 JetStream 持久化
+//
+// subject 是 Go 模板，数据为样本的标签、__name__（指标名）和 __collector__（采集器名），
+// 如 vm-metrics.{{.__collector__}}；标签值中的 .、*、> 和空白替换为下划线，避免产生额外的层级或通配符。
+// subject 相同的样本合并为一条消息，消息体与 Kafka 的 JSON 格式相同。
+// 核心 NATS 只保证消息送达服务器；开启 JetStream 时等待 stream 确认写入，subject 需被某个 stream 覆盖。
+// 连接断开时由客户端自动重连，期间的数据被丢弃
+type NATS struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream // 未开启 JetStream 时为 nil
+	stream  string
+	subject *template.Template
+	timeout time.Duration
+}
+
+// NewNATS 创建 NATS 导出器，后台连接服务器，不等待连接成功
+func NewNATS(cfg config.NATSConfig) (*NATS, error) {
+	subject, err := template.New("subject").Option("missingkey=zero").Parse(cfg.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse nats subject template: %w", err)
+	}
+
+	opts := []nats.Option{
+		nats.Name("vm-metrics"),
+		nats.Timeout(cfg.Timeout),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ConnectHandler(func(nc *nats.Conn) {
+			slog.Info("Connected to nats", "server", nc.ConnectedUrlRedacted())
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			slog.Info("Reconnected to nats", "server", nc.ConnectedUrlRedacted())
+		}),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				slog.Warn("Lost connection to nats", "url", cfg.URL, "error", err)
+			}
+		}),
+	}
+	if cfg.Credentials != "" {
+		opts = append(opts, nats.UserCredentials(cfg.Credentials))
+	}
+	if cfg.NKeySeed != "" {
+		opt, err := nats.NkeyOptionFromSeed(cfg.NKeySeed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load nats nkey seed: %w", err)
+		}
+		opts = append(opts, opt)
+	}
+	if cfg.TLSCA != "" || cfg.TLSSkipVerify {
+		tlsConfig, err := clientTLSConfig(cfg.TLSCA, cfg.TLSSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, nats.Secure(tlsConfig))
+	}
+
+	conn, err := nats.Connect(cfg.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	e := &NATS{conn: conn, stream: cfg.Stream, subject: subject, timeout: cfg.Timeout}
+	if cfg.JetStream {
+		if e.js, err = jetstream.New(conn); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to create jetstream context: %w", err)
+		}
+	}
+	return e, nil
+}
+
+// Name 实现 Exporter
+func (e *NATS) Name() string {
+	return "nats"
+}
+
+// Close 发送缓冲的消息后关闭连接
+func (e *NATS) Close() error {
+	e.conn.Close()
+	return nil
+}
+
+// Export 实现 Exporter，核心 NATS 等待服务器收到全部消息，JetStream 等待全部确认
+func (e *NATS) Export(ctx context.Context, families []*metrics.Family) error {
+	groups, err := groupByTemplate(e.subject, toTimeSeries(families, time.Now()), natsSubjectReplacer.Replace)
+	if err != nil {
+		return fmt.Errorf("failed to render nats subject: %w", err)
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+	if !e.conn.IsConnected() {
+		return fmt.Errorf("nats not connected, dropping %d messages", len(groups))
+	}
+
+	payloads := make([][]byte, len(groups))
+	for i, g := range groups {
+		if payloads[i], err = json.Marshal(jsonBatch(g.series)); err != nil {
+			return fmt.Errorf("failed to encode nats message: %w", err)
+		}
+	}
+
+	if e.js == nil {
+		for i, g := range groups {
+			if err := e.conn.Publish(g.key, payloads[i]); err != nil {
+				return fmt.Errorf("failed to publish to nats subject %s: %w", g.key, err)
+			}
+		}
+		if err := e.conn.FlushTimeout(e.timeout); err != nil {
+			return fmt.Errorf("failed to flush nats: %w", err)
+		}
+		return nil
+	}
+
+	var publishOpts []jetstream.PublishOpt
+	if e.stream != "" {
+		publishOpts = append(publishOpts, jetstream.WithExpectStream(e.stream))
+	}
+	futures := make([]jetstream.PubAckFuture, 0, len(groups))
+	for i, g := range groups {
+		future, err := e.js.PublishAsync(g.key, payloads[i], publishOpts...)
+		if err != nil {
+			return fmt.Errorf("failed to publish to jetstream subject %s: %w", g.key, err)
+		}
+		futures = append(futures, future)
+	}
+
+	deadline := time.Now().Add(e.timeout)
+	for _, future := range futures {
+		select {
+		case <-future.Ok():
+		case err := <-future.Err():
+			return fmt.Errorf("jetstream publish to %s failed: %w", future.Msg().Subject, err)
+		case <-time.After(time.Until(deadline)):
+			return fmt.Errorf("jetstream publish timed out after %s", e.timeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// natsSubjectReplacer 替换标签值中的 subject 层级分隔符、通配符和空白
+var natsSubjectReplacer = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_", "\t", "_", "\n", "_")
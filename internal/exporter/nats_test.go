@@ -0,0 +1,164 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// serveNATS 启动开启 JetStream 的进程内 NATS 服务器
+func serveNATS(t *testing.T) *server.Server {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatalf("创建 NATS 服务器失败: %v", err)
+	}
+	go s.Start()
+	t.Cleanup(s.Shutdown)
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS 服务器未就绪")
+	}
+	return s
+}
+
+// natsFamilies 两个采集器的指标，其中一个采集器名含 subject 分隔符
+func natsFamilies() []*metrics.Family {
+	families := testFamilies(10)
+	families[0].Collector = "http"
+	families[1].Collector = "sensors.board"
+	return families
+}
+
+func newTestNATS(t *testing.T, url string, modify func(*config.NATSConfig)) *NATS {
+	t.Helper()
+	cfg := config.DefaultConfig().NATS
+	cfg.URL = url
+	if modify != nil {
+		modify(&cfg)
+	}
+	e, err := NewNATS(cfg)
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+func TestNATSCore(t *testing.T) {
+	s := serveNATS(t)
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatalf("连接 NATS 失败: %v", err)
+	}
+	defer nc.Close()
+	sub, err := nc.SubscribeSync("vm-metrics.>")
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	if err := nc.Flush(); err != nil {
+		t.Fatalf("刷新订阅失败: %v", err)
+	}
+
+	e := newTestNATS(t, s.ClientURL(), nil)
+	if err := e.Export(context.Background(), natsFamilies()); err != nil {
+		t.Fatalf("发布失败: %v", err)
+	}
+
+	got := make(map[string]map[string][]jsonSample)
+	for range 2 {
+		msg, err := sub.NextMsg(5 * time.Second)
+		if err != nil {
+			t.Fatalf("未收到消息: %v", err)
+		}
+		var batch map[string][]jsonSample
+		if err := json.Unmarshal(msg.Data, &batch); err != nil {
+			t.Fatalf("消息不是合法的 JSON: %v", err)
+		}
+		got[msg.Subject] = batch
+	}
+	if s := got["vm-metrics.http"]["metrics"]; len(s) != 1 || s[0].Name != "requests_total" || s[0].Labels["code"] != "200" || s[0].Value != 10 {
+		t.Errorf("http 采集器的消息不符: %+v", got["vm-metrics.http"])
+	}
+	// 采集器名中的点不应产生额外的 subject 层级
+	if s := got["vm-metrics.sensors_board"]["metrics"]; len(s) != 1 || s[0].Name != "temperature" {
+		t.Errorf("sensors 采集器的消息不符: %+v", got)
+	}
+}
+
+func TestNATSJetStream(t *testing.T) {
+	s := serveNATS(t)
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatalf("连接 NATS 失败: %v", err)
+	}
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatalf("创建 JetStream 失败: %v", err)
+	}
+	ctx := context.Background()
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "METRICS", Subjects: []string{"vm-metrics.>"}})
+	if err != nil {
+		t.Fatalf("创建 stream 失败: %v", err)
+	}
+
+	e := newTestNATS(t, s.ClientURL(), func(cfg *config.NATSConfig) {
+		cfg.JetStream = true
+		cfg.Stream = "METRICS"
+	})
+	if err := e.Export(ctx, natsFamilies()); err != nil {
+		t.Fatalf("发布失败: %v", err)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatalf("获取 stream 信息失败: %v", err)
+	}
+	if info.State.Msgs != 2 {
+		t.Errorf("stream 应持久化 2 条消息, 实际 %d", info.State.Msgs)
+	}
+
+	t.Run("stream 不匹配", func(t *testing.T) {
+		e := newTestNATS(t, s.ClientURL(), func(cfg *config.NATSConfig) {
+			cfg.JetStream = true
+			cfg.Stream = "OTHER"
+		})
+		if err := e.Export(ctx, natsFamilies()); err == nil {
+			t.Error("写入的 stream 与期望不同时应返回错误")
+		}
+	})
+
+	t.Run("subject 未被 stream 覆盖", func(t *testing.T) {
+		e := newTestNATS(t, s.ClientURL(), func(cfg *config.NATSConfig) {
+			cfg.JetStream = true
+			cfg.Subject = "other.{{.__collector__}}"
+		})
+		err := e.Export(ctx, natsFamilies())
+		if err == nil || !strings.Contains(err.Error(), "other.http") {
+			t.Errorf("没有 stream 接收时应返回包含 subject 的错误, 实际: %v", err)
+		}
+	})
+}
+
+func TestNATSDisconnected(t *testing.T) {
+	e := newTestNATS(t, "nats://127.0.0.1:1", func(cfg *config.NATSConfig) {
+		cfg.Timeout = 100 * time.Millisecond
+	})
+	if err := e.Export(context.Background(), natsFamilies()); err == nil {
+		t.Error("未连接时应返回错误")
+	}
+}
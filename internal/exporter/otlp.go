@@ -0,0 +1,355 @@
+package exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+	"github.com/lwmacct/251203-vm-metrics/internal/version"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLP 协议
+const (
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http/protobuf"
+)
+
+// OTLP 计数器的聚合时间性
+const (
+	TemporalityCumulative = "cumulative"
+	TemporalityDelta      = "delta"
+)
+
+// OTLP 通过 OpenTelemetry OTLP 协议 (gRPC 或 HTTP/protobuf) 推送指标
+type OTLP struct {
+	send     func(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) error
+	close    func() error
+	resource *resourcepb.Resource
+	delta    bool
+	start    time.Time // 导出器启动时间，作为累计值的起始时间
+
+	mu       sync.Mutex
+	previous map[string]deltaState // delta 模式下各计数器和直方图上次的值
+}
+
+// deltaState 计数器或直方图上次推送时的值和时间
+type deltaState struct {
+	value     float64
+	histogram *metrics.HistogramValue
+	time      time.Time
+}
+
+// NewOTLP 创建 OTLP 导出器
+func NewOTLP(cfg config.OTLPConfig) (*OTLP, error) {
+	e := &OTLP{
+		resource: otlpResource(cfg.ResourceAttributes),
+		delta:    cfg.Temporality == TemporalityDelta,
+		start:    time.Now(),
+		previous: make(map[string]deltaState),
+	}
+
+	switch cfg.Protocol {
+	case OTLPProtocolGRPC, "":
+		creds := credentials.NewTLS(&tls.Config{})
+		if cfg.Insecure {
+			creds = insecure.NewCredentials()
+		}
+		conn, err := grpc.NewClient(cfg.Endpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create otlp grpc client: %w", err)
+		}
+		client := colmetricpb.NewMetricsServiceClient(conn)
+		e.send = func(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) error {
+			ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+			defer cancel()
+			for k, v := range cfg.Headers {
+				ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(k), v)
+			}
+			if _, err := client.Export(ctx, req); err != nil {
+				return fmt.Errorf("otlp export failed: %w", err)
+			}
+			return nil
+		}
+		e.close = conn.Close
+	case OTLPProtocolHTTP:
+		client := resty.New().
+			SetTimeout(cfg.Timeout).
+			SetHeaders(cfg.Headers).
+			SetHeader("Content-Type", "application/x-protobuf").
+			SetHeader("User-Agent", "vm-metrics/"+version.AppVersion).
+			SetDisableWarn(true)
+		e.send = func(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) error {
+			body, err := proto.Marshal(req)
+			if err != nil {
+				return fmt.Errorf("failed to encode otlp request: %w", err)
+			}
+			resp, err := client.R().SetContext(ctx).SetBody(body).Post(cfg.Endpoint)
+			if err != nil {
+				return fmt.Errorf("otlp request failed: %w", err)
+			}
+			if resp.IsError() {
+				return fmt.Errorf("otlp export failed: %s: %s", resp.Status(), truncate(resp.String(), 256))
+			}
+			return nil
+		}
+		e.close = func() error { return nil }
+	default:
+		return nil, fmt.Errorf("unsupported otlp protocol: %s", cfg.Protocol)
+	}
+	return e, nil
+}
+
+// Name 实现 Exporter
+func (e *OTLP) Name() string {
+	return "otlp"
+}
+
+// Close 关闭连接
+func (e *OTLP) Close() error {
+	return e.close()
+}
+
+// Export 实现 Exporter
+func (e *OTLP) Export(ctx context.Context, families []*metrics.Family) error {
+	req := &colmetricpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricpb.ResourceMetrics{{
+			Resource: e.resource,
+			ScopeMetrics: []*metricpb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: "vm-metrics", Version: version.AppVersion},
+				Metrics: e.convert(families, time.Now()),
+			}},
+		}},
+	}
+	return e.send(ctx, req)
+}
+
+// convert 将指标族转换为 OTLP 指标
+// 计数器转换为单调的 Sum，名称去掉 _total 后缀；直方图转换为 Histogram；其他类型转换为 Gauge
+func (e *OTLP) convert(families []*metrics.Family, now time.Time) []*metricpb.Metric {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var out []*metricpb.Metric
+	for _, f := range families {
+		if f.Type == metrics.Histogram {
+			out = append(out, e.histogram(f, now))
+			continue
+		}
+		m := &metricpb.Metric{Name: f.Name, Description: f.Help}
+		var points []*metricpb.NumberDataPoint
+		for _, sample := range f.Metrics {
+			ts := sample.Timestamp
+			if ts.IsZero() {
+				ts = now
+			}
+			p := &metricpb.NumberDataPoint{
+				Attributes:   keyValues(sample.Labels),
+				TimeUnixNano: uint64(ts.UnixNano()),
+				Value:        &metricpb.NumberDataPoint_AsDouble{AsDouble: sample.Value},
+			}
+			if f.Type == metrics.Counter {
+				start := sample.Created
+				if start.IsZero() {
+					start = e.start
+				}
+				if e.delta {
+					p.Value, start = e.deltaValue(f.Name, sample, ts, start)
+				}
+				p.StartTimeUnixNano = uint64(start.UnixNano())
+				if sample.Exemplar != nil {
+					p.Exemplars = []*metricpb.Exemplar{exemplar(sample.Exemplar, ts)}
+				}
+			}
+			points = append(points, p)
+		}
+
+		if f.Type == metrics.Counter {
+			temporality := metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+			if e.delta {
+				temporality = metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+			}
+			m.Name = strings.TrimSuffix(f.Name, "_total")
+			m.Data = &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+				DataPoints:             points,
+				AggregationTemporality: temporality,
+				IsMonotonic:            true,
+			}}
+		} else {
+			m.Data = &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: points}}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// deltaValue 返回计数器自上次推送以来的增量及其起始时间
+// 首次推送时增量为累计值本身；计数器重置（值变小）时以重置后的值作为增量
+func (e *OTLP) deltaValue(name string, sample metrics.Metric, ts, start time.Time) (*metricpb.NumberDataPoint_AsDouble, time.Time) {
+	key := seriesKey(name, sample.Labels)
+	value := sample.Value
+	if prev, ok := e.previous[key]; ok {
+		start = prev.time
+		if value >= prev.value {
+			value -= prev.value
+		}
+	}
+	e.previous[key] = deltaState{value: sample.Value, time: ts}
+	return &metricpb.NumberDataPoint_AsDouble{AsDouble: value}, start
+}
+
+// histogram 转换直方图，调用方需持有锁
+// OTLP 的桶计数是各桶自身的观测次数而非累计值，最后一个桶对应 +Inf
+func (e *OTLP) histogram(f *metrics.Family, now time.Time) *metricpb.Metric {
+	temporality := metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	if e.delta {
+		temporality = metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	}
+
+	var points []*metricpb.HistogramDataPoint
+	for _, sample := range f.Metrics {
+		if sample.Histogram == nil {
+			continue
+		}
+		ts := sample.Timestamp
+		if ts.IsZero() {
+			ts = now
+		}
+		start := sample.Created
+		if start.IsZero() {
+			start = e.start
+		}
+		h := *sample.Histogram
+		if e.delta {
+			h, start = e.deltaHistogram(f.Name, sample, ts, start)
+		}
+
+		p := &metricpb.HistogramDataPoint{
+			Attributes:        keyValues(sample.Labels),
+			StartTimeUnixNano: uint64(start.UnixNano()),
+			TimeUnixNano:      uint64(ts.UnixNano()),
+			Count:             uint64(h.Count),
+			Sum:               &h.Sum,
+		}
+		var cumulative float64
+		for _, b := range h.Buckets {
+			p.ExplicitBounds = append(p.ExplicitBounds, b.UpperBound)
+			p.BucketCounts = append(p.BucketCounts, uint64(b.Count-cumulative))
+			cumulative = b.Count
+		}
+		p.BucketCounts = append(p.BucketCounts, uint64(h.Count-cumulative))
+		points = append(points, p)
+	}
+	return &metricpb.Metric{Name: f.Name, Description: f.Help, Data: &metricpb.Metric_Histogram{Histogram: &metricpb.Histogram{
+		DataPoints:             points,
+		AggregationTemporality: temporality,
+	}}}
+}
+
+// deltaHistogram 返回直方图自上次推送以来的增量及其起始时间
+// 首次推送、直方图重置（计数变小）或桶的划分变化时以当前值作为增量
+func (e *OTLP) deltaHistogram(name string, sample metrics.Metric, ts, start time.Time) (metrics.HistogramValue, time.Time) {
+	key := seriesKey(name, sample.Labels)
+	current := *sample.Histogram
+	h := current
+	if prev, ok := e.previous[key]; ok && prev.histogram != nil {
+		start = prev.time
+		p := prev.histogram
+		if current.Count >= p.Count && len(current.Buckets) == len(p.Buckets) {
+			h.Count -= p.Count
+			h.Sum -= p.Sum
+			h.Buckets = make([]metrics.Bucket, len(current.Buckets))
+			for i, b := range current.Buckets {
+				h.Buckets[i] = metrics.Bucket{UpperBound: b.UpperBound, Count: b.Count - p.Buckets[i].Count}
+			}
+		}
+	}
+	e.previous[key] = deltaState{histogram: &current, time: ts}
+	return h, start
+}
+
+// seriesKey 返回时间序列的唯一标识
+func seriesKey(name string, labels []metrics.Label) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	for _, l := range labels {
+		sb.WriteString("\xff" + l.Name + "\xfe" + l.Value)
+	}
+	return sb.String()
+}
+
+// exemplar 转换示例，trace_id 和 span_id 标签按十六进制解码为链路标识
+func exemplar(ex *metrics.Exemplar, ts time.Time) *metricpb.Exemplar {
+	if !ex.Timestamp.IsZero() {
+		ts = ex.Timestamp
+	}
+	out := &metricpb.Exemplar{
+		TimeUnixNano: uint64(ts.UnixNano()),
+		Value:        &metricpb.Exemplar_AsDouble{AsDouble: ex.Value},
+	}
+	for _, l := range ex.Labels {
+		id, err := hex.DecodeString(l.Value)
+		switch {
+		case l.Name == "trace_id" && err == nil && len(id) == 16:
+			out.TraceId = id
+		case l.Name == "span_id" && err == nil && len(id) == 8:
+			out.SpanId = id
+		default:
+			out.FilteredAttributes = append(out.FilteredAttributes, keyValue(l.Name, l.Value))
+		}
+	}
+	return out
+}
+
+// otlpResource 创建资源，默认包含 service.name 和 host.name，可被配置覆盖
+func otlpResource(attrs map[string]string) *resourcepb.Resource {
+	merged := map[string]string{"service.name": "vm-metrics"}
+	if host, err := os.Hostname(); err == nil {
+		merged["host.name"] = host
+	}
+	for k, v := range attrs {
+		merged[k] = v
+	}
+	keys := make([]string, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := &resourcepb.Resource{}
+	for _, k := range keys {
+		res.Attributes = append(res.Attributes, keyValue(k, merged[k]))
+	}
+	return res
+}
+
+// keyValues 将标签转换为 OTLP 属性
+func keyValues(labels []metrics.Label) []*commonpb.KeyValue {
+	kvs := make([]*commonpb.KeyValue, 0, len(labels))
+	for _, l := range labels {
+		kvs = append(kvs, keyValue(l.Name, l.Value))
+	}
+	return kvs
+}
+
+// keyValue 创建字符串属性
+func keyValue(k, v string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: k, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}}
+}
@@ -0,0 +1,183 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// testFamilies 返回一个计数器和一个仪表
+func testFamilies(counter float64) []*metrics.Family {
+	c := &metrics.Family{Name: "requests_total", Help: "请求数", Type: metrics.Counter}
+	c.Add(counter, "code", "200")
+	g := &metrics.Family{Name: "temperature", Type: metrics.Gauge}
+	g.Add(21.5)
+	return []*metrics.Family{c, g}
+}
+
+func TestOTLPConvert(t *testing.T) {
+	tests := []struct {
+		temporality string
+		wantSecond  float64
+		want        metricpb.AggregationTemporality
+	}{
+		{TemporalityCumulative, 15, metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE},
+		{TemporalityDelta, 5, metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.temporality, func(t *testing.T) {
+			cfg := config.DefaultConfig().OTLP
+			cfg.Protocol = OTLPProtocolHTTP
+			cfg.Temporality = tt.temporality
+			e, err := NewOTLP(cfg)
+			if err != nil {
+				t.Fatalf("创建导出器失败: %v", err)
+			}
+
+			now := time.Now()
+			first := e.convert(testFamilies(10), now)
+			second := e.convert(testFamilies(15), now.Add(time.Minute))
+
+			sum := second[0].GetSum()
+			if second[0].Name != "requests" || sum == nil || !sum.IsMonotonic || sum.AggregationTemporality != tt.want {
+				t.Fatalf("计数器应转换为单调 Sum: %v", second[0])
+			}
+			if got := sum.DataPoints[0].GetAsDouble(); got != tt.wantSecond {
+				t.Errorf("第二次推送的值 = %v, 期望 %v", got, tt.wantSecond)
+			}
+			if tt.temporality == TemporalityDelta && sum.DataPoints[0].StartTimeUnixNano != first[0].GetSum().DataPoints[0].TimeUnixNano {
+				t.Error("delta 的起始时间应为上次推送时间")
+			}
+			if second[1].GetGauge() == nil || second[1].GetGauge().DataPoints[0].GetAsDouble() != 21.5 {
+				t.Errorf("仪表应转换为 Gauge: %v", second[1])
+			}
+		})
+	}
+}
+
+func TestOTLPHistogram(t *testing.T) {
+	histogram := func(fast, total, sum float64) []*metrics.Family {
+		f := &metrics.Family{Name: "latency_seconds", Type: metrics.Histogram}
+		f.AddHistogram(metrics.HistogramValue{Buckets: []metrics.Bucket{{UpperBound: 0.1, Count: fast}}, Count: total, Sum: sum})
+		return []*metrics.Family{f}
+	}
+	for _, temporality := range []string{TemporalityCumulative, TemporalityDelta} {
+		t.Run(temporality, func(t *testing.T) {
+			cfg := config.DefaultConfig().OTLP
+			cfg.Protocol = OTLPProtocolHTTP
+			cfg.Temporality = temporality
+			e, err := NewOTLP(cfg)
+			if err != nil {
+				t.Fatalf("创建导出器失败: %v", err)
+			}
+
+			now := time.Now()
+			e.convert(histogram(3, 5, 1.5), now)
+			out := e.convert(histogram(4, 8, 2), now.Add(time.Minute))
+			h := out[0].GetHistogram()
+			if h == nil || len(h.DataPoints) != 1 {
+				t.Fatalf("直方图应转换为 Histogram: %v", out[0])
+			}
+			p := h.DataPoints[0]
+			// 桶计数为各桶自身的观测次数，delta 时为与上次推送的差值
+			wantCount, wantBuckets, wantSum := uint64(8), []uint64{4, 4}, 2.0
+			if temporality == TemporalityDelta {
+				wantCount, wantBuckets, wantSum = 3, []uint64{1, 2}, 0.5
+			}
+			if p.Count != wantCount || !slices.Equal(p.BucketCounts, wantBuckets) || p.GetSum() != wantSum || !slices.Equal(p.ExplicitBounds, []float64{0.1}) {
+				t.Errorf("数据点 = count %d buckets %v sum %v bounds %v, 期望 %d %v %v", p.Count, p.BucketCounts, p.GetSum(), p.ExplicitBounds, wantCount, wantBuckets, wantSum)
+			}
+		})
+	}
+}
+
+func TestOTLPHTTP(t *testing.T) {
+	var got colmetricpb.ExportMetricsServiceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("请求头不正确: %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := proto.Unmarshal(body, &got); err != nil {
+			t.Errorf("解码失败: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := config.DefaultConfig().OTLP
+	cfg.Protocol = OTLPProtocolHTTP
+	cfg.Endpoint = srv.URL + "/v1/metrics"
+	cfg.Headers = map[string]string{"Authorization": "Bearer t"}
+	cfg.ResourceAttributes = map[string]string{"service.name": "edge", "env": "prod"}
+	e, err := NewOTLP(cfg)
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	if err := e.Export(context.Background(), testFamilies(1)); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+
+	attrs := make(map[string]string)
+	for _, kv := range got.ResourceMetrics[0].Resource.Attributes {
+		attrs[kv.Key] = kv.Value.GetStringValue()
+	}
+	if attrs["service.name"] != "edge" || attrs["env"] != "prod" || attrs["host.name"] == "" {
+		t.Errorf("资源属性不正确: %v", attrs)
+	}
+	if n := len(got.ResourceMetrics[0].ScopeMetrics[0].Metrics); n != 2 {
+		t.Errorf("指标数 = %d, 期望 2", n)
+	}
+}
+
+// metricsService 记录收到的请求的 gRPC 服务
+type metricsService struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+	received chan metadata.MD
+}
+
+func (s *metricsService) Export(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.received <- md
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestOTLPGRPC(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	service := &metricsService{received: make(chan metadata.MD, 1)}
+	srv := grpc.NewServer()
+	colmetricpb.RegisterMetricsServiceServer(srv, service)
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Stop()
+
+	cfg := config.DefaultConfig().OTLP
+	cfg.Endpoint = ln.Addr().String()
+	cfg.Insecure = true
+	cfg.Headers = map[string]string{"X-Token": "abc"}
+	e, err := NewOTLP(cfg)
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	defer e.Close()
+	if err := e.Export(context.Background(), testFamilies(1)); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+	if md := <-service.received; len(md.Get("x-token")) != 1 || md.Get("x-token")[0] != "abc" {
+		t.Errorf("请求头应作为 gRPC metadata 发送: %v", md)
+	}
+}
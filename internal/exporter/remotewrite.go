@@ -0,0 +1,181 @@
+package exporter
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/snappy"
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+	"github.com/lwmacct/251203-vm-metrics/internal/version"
+)
+
+// remoteWriteMaxBackoff 重试等待时间的上限，retry_backoff 本身更长时以 retry_backoff 为准
+const remoteWriteMaxBackoff = time.Minute
+
+// RemoteWrite 通过 Prometheus remote_write 协议 (snappy 压缩的 protobuf) 推送指标
+type RemoteWrite struct {
+	client       *resty.Client
+	url          string
+	maxBatchSize int
+}
+
+// NewRemoteWrite 创建 remote_write 导出器
+// 网络错误、429 和 5xx 按指数退避重试，其他 4xx 视为请求本身有误，不重试；
+// 等待时间逐次翻倍，最长不超过 remoteWriteMaxBackoff；
+// flush_interval 或 max_batch_size 不为正数时返回错误 (命令行参数和环境变量不经过 config validate)
+func NewRemoteWrite(cfg config.RemoteWriteConfig) (*RemoteWrite, error) {
+	if cfg.FlushInterval <= 0 {
+		return nil, fmt.Errorf("invalid remote write flush interval %s: must be positive", cfg.FlushInterval)
+	}
+	if cfg.MaxBatchSize <= 0 {
+		return nil, fmt.Errorf("invalid remote write max batch size %d: must be positive", cfg.MaxBatchSize)
+	}
+	client := resty.New().
+		SetTimeout(cfg.Timeout).
+		SetRetryCount(cfg.MaxRetries).
+		SetRetryWaitTime(cfg.RetryBackoff).
+		SetRetryMaxWaitTime(retryMaxWait(cfg.RetryBackoff, cfg.MaxRetries)).
+		AddRetryCondition(func(resp *resty.Response, err error) bool {
+			return err != nil || resp.StatusCode() == 429 || resp.StatusCode() >= 500
+		}).
+		SetHeader("Content-Type", "application/x-protobuf").
+		SetHeader("Content-Encoding", "snappy").
+		SetHeader("X-Prometheus-Remote-Write-Version", "0.1.0").
+		SetHeader("User-Agent", "vm-metrics/"+version.AppVersion).
+		SetDisableWarn(true)
+
+	// 配置认证
+	switch {
+	case cfg.Token != "":
+		client.SetAuthToken(cfg.Token)
+	case cfg.User != "":
+		client.SetBasicAuth(cfg.User, cfg.Password)
+	}
+
+	return &RemoteWrite{client: client, url: cfg.URL, maxBatchSize: cfg.MaxBatchSize}, nil
+}
+
+// retryMaxWait 返回重试 retries 次时最长的等待时间 backoff*2^retries，
+// 逐次翻倍并在达到 remoteWriteMaxBackoff 后停止，避免移位溢出
+func retryMaxWait(backoff time.Duration, retries int) time.Duration {
+	wait := backoff
+	for i := 0; i < retries && wait < remoteWriteMaxBackoff; i++ {
+		wait *= 2
+	}
+	return max(min(wait, remoteWriteMaxBackoff), backoff)
+}
+
+// Name 实现 Exporter
+func (e *RemoteWrite) Name() string {
+	return "remote_write"
+}
+
+// Export 实现 Exporter，样本按 maxBatchSize 分批发送
+func (e *RemoteWrite) Export(ctx context.Context, families []*metrics.Family) error {
+	series := toTimeSeries(families, time.Now())
+	for start := 0; start < len(series); start += e.maxBatchSize {
+		end := min(start+e.maxBatchSize, len(series))
+		if err := e.send(ctx, series[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send 发送一批样本
+func (e *RemoteWrite) send(ctx context.Context, series []timeSeries) error {
+	resp, err := e.client.R().
+		SetContext(ctx).
+		SetBody(snappy.Encode(nil, encodeWriteRequest(series))).
+		Post(e.url)
+	if err != nil {
+		return fmt.Errorf("remote write request failed: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("remote write failed: %s: %s", resp.Status(), truncate(resp.String(), 256))
+	}
+	return nil
+}
+
+// timeSeries remote_write 中的单个时间序列，每次推送只含一个样本
+type timeSeries struct {
+	labels    []metrics.Label // 含 __name__，按名称排序
+	value     float64
+	timestamp int64  // 毫秒
+	collector string // 产生该序列的采集器，不写入标签
+}
+
+// toTimeSeries 将指标族展开为时间序列，直方图展开为 _bucket、_sum 和 _count，未指定采样时间的样本使用 now
+func toTimeSeries(families []*metrics.Family, now time.Time) []timeSeries {
+	var series []timeSeries
+	for _, f := range families {
+		for _, s := range f.Samples() {
+			labels := append([]metrics.Label{{Name: "__name__", Value: s.Name}}, s.Labels...)
+			sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+			ts := s.Timestamp
+			if ts.IsZero() {
+				ts = now
+			}
+			series = append(series, timeSeries{labels: labels, value: s.Value, timestamp: ts.UnixMilli(), collector: f.Collector})
+		}
+	}
+	return series
+}
+
+// protobuf 线格式的类型
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// encodeWriteRequest 按 prometheus.WriteRequest 编码：
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []timeSeries) []byte {
+	var buf, ts, msg []byte
+	for _, s := range series {
+		ts = ts[:0]
+		for _, l := range s.labels {
+			msg = appendBytesField(msg[:0], 1, []byte(l.Name))
+			msg = appendBytesField(msg, 2, []byte(l.Value))
+			ts = appendBytesField(ts, 1, msg)
+		}
+		msg = appendTag(msg[:0], 1, wireFixed64)
+		msg = binary.LittleEndian.AppendUint64(msg, math.Float64bits(s.value))
+		msg = appendTag(msg, 2, wireVarint)
+		msg = binary.AppendUvarint(msg, uint64(s.timestamp))
+		ts = appendBytesField(ts, 2, msg)
+		buf = appendBytesField(buf, 1, ts)
+	}
+	return buf
+}
+
+// appendTag 追加字段编号和线格式类型
+func appendTag(b []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+// appendBytesField 追加长度前缀的字段（字符串或嵌套消息）
+func appendBytesField(b []byte, field int, data []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// truncate 截断过长的响应内容，用于错误信息
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,201 @@
+package exporter
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// decodedSeries 测试中解码出的时间序列
+type decodedSeries struct {
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+// decodeWriteRequest 解码 WriteRequest，只支持 encodeWriteRequest 用到的字段
+func decodeWriteRequest(t *testing.T, b []byte) []decodedSeries {
+	t.Helper()
+	var series []decodedSeries
+	for _, ts := range readFields(t, b)[1] {
+		s := decodedSeries{labels: make(map[string]string)}
+		fields := readFields(t, ts)
+		for _, l := range fields[1] {
+			lf := readFields(t, l)
+			s.labels[string(lf[1][0])] = string(lf[2][0])
+		}
+		sample := fields[2][0]
+		s.value = math.Float64frombits(binary.LittleEndian.Uint64(sample[1:9]))
+		ts, _ := binary.Uvarint(sample[10:])
+		s.timestamp = int64(ts)
+		series = append(series, s)
+	}
+	return series
+}
+
+// readFields 读取长度前缀字段，按字段编号分组
+func readFields(t *testing.T, b []byte) map[int][][]byte {
+	t.Helper()
+	fields := make(map[int][][]byte)
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if tag&7 != wireBytes {
+			t.Fatalf("意外的线格式类型: %d", tag&7)
+		}
+		size, m := binary.Uvarint(b[n:])
+		start := n + m
+		fields[int(tag>>3)] = append(fields[int(tag>>3)], b[start:start+int(size)])
+		b = b[start+int(size):]
+	}
+	return fields
+}
+
+func testConfig(url string) config.RemoteWriteConfig {
+	cfg := config.DefaultConfig().RemoteWrite
+	cfg.URL = url
+	cfg.RetryBackoff = time.Millisecond
+	return cfg
+}
+
+func TestRemoteWriteExport(t *testing.T) {
+	var requests [][]decodedSeries
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("请求头不正确: %v", r.Header)
+		}
+		if user, pass, _ := r.BasicAuth(); user != "u" || pass != "p" {
+			t.Errorf("Basic 认证不正确: %s %s", user, pass)
+		}
+		body, _ := io.ReadAll(r.Body)
+		data, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Errorf("snappy 解压失败: %v", err)
+		}
+		requests = append(requests, decodeWriteRequest(t, data))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	f := &metrics.Family{Name: "up", Type: metrics.Gauge}
+	f.Add(1, "job", "a")
+	f.Add(0.5, "job", "b")
+	f.Metrics = append(f.Metrics, metrics.Metric{Value: 2, Timestamp: time.UnixMilli(1700000000000)})
+
+	cfg := testConfig(srv.URL)
+	cfg.MaxBatchSize = 2
+	cfg.User, cfg.Password = "u", "p"
+	e, err := NewRemoteWrite(cfg)
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	if err := e.Export(context.Background(), []*metrics.Family{f}); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+
+	if len(requests) != 2 || len(requests[0]) != 2 || len(requests[1]) != 1 {
+		t.Fatalf("应分两批发送 2+1 个样本: %+v", requests)
+	}
+	first := requests[0][0]
+	if first.labels["__name__"] != "up" || first.labels["job"] != "a" || first.value != 1 {
+		t.Errorf("第一个样本不正确: %+v", first)
+	}
+	if last := requests[1][0]; last.value != 2 || last.timestamp != 1700000000000 {
+		t.Errorf("指定的采样时间应保留: %+v", last)
+	}
+}
+
+func TestRemoteWriteRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantHits int32
+	}{
+		{"5xx 重试", http.StatusServiceUnavailable, 3},
+		{"4xx 不重试", http.StatusBadRequest, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			cfg := testConfig(srv.URL)
+			cfg.MaxRetries = 2
+			f := &metrics.Family{Name: "up", Type: metrics.Gauge}
+			f.Add(1)
+			e, err := NewRemoteWrite(cfg)
+			if err != nil {
+				t.Fatalf("创建导出器失败: %v", err)
+			}
+			if err := e.Export(context.Background(), []*metrics.Family{f}); err == nil {
+				t.Error("推送失败时应返回错误")
+			}
+			if hits.Load() != tt.wantHits {
+				t.Errorf("请求次数 = %d, 期望 %d", hits.Load(), tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestRetryMaxWait(t *testing.T) {
+	tests := []struct {
+		backoff time.Duration
+		retries int
+		want    time.Duration
+	}{
+		{time.Second, 0, time.Second},
+		{time.Second, 3, 8 * time.Second},
+		{time.Second, 10, remoteWriteMaxBackoff},
+		// 直接移位会溢出为负数
+		{time.Second, 64, remoteWriteMaxBackoff},
+		{2 * time.Minute, 3, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := retryMaxWait(tt.backoff, tt.retries); got != tt.want {
+			t.Errorf("retryMaxWait(%v, %d) = %v, 期望 %v", tt.backoff, tt.retries, got, tt.want)
+		}
+	}
+}
+
+func TestNewRemoteWriteInvalid(t *testing.T) {
+	cfg := testConfig("http://127.0.0.1:8428/api/v1/write")
+	cfg.FlushInterval = 0
+	if _, err := NewRemoteWrite(cfg); err == nil {
+		t.Error("flush_interval 为 0 时应返回错误")
+	}
+	cfg = testConfig("http://127.0.0.1:8428/api/v1/write")
+	cfg.MaxBatchSize = 0
+	if _, err := NewRemoteWrite(cfg); err == nil {
+		t.Error("max_batch_size 为 0 时应返回错误")
+	}
+}
+
+func TestRunInvalidInterval(t *testing.T) {
+	e, err := NewRemoteWrite(testConfig("http://127.0.0.1:8428/api/v1/write"))
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	if err := Run(context.Background(), metrics.NewRegistry(), e, 0, time.Second); err == nil {
+		t.Error("interval 为 0 时应返回错误而不是 panic")
+	}
+	g := NewGroup(context.Background(), time.Second)
+	if err := g.Go(metrics.NewRegistry(), e, -time.Second); err == nil {
+		t.Error("interval 为负数时应返回错误")
+	}
+	if err := g.Stop(time.Second); err != nil {
+		t.Errorf("停止失败: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// StatsD 格式
+const (
+	StatsDFormatStatsD    = "statsd"
+	StatsDFormatDogStatsD = "dogstatsd"
+)
+
+// statsdUnixPrefix addr 以此开头时通过 Unix 数据报套接字发送
+const statsdUnixPrefix = "unix://"
+
+// StatsD 通过 UDP 或 Unix 数据报套接字按 StatsD / DogStatsD 格式发送指标
+//
+// StatsD 的计数器是增量，因此计数器发送与上次采集的差值，首次采集只记录基线；
+// 直方图展开后的 _bucket、_sum 和 _count 同样按计数器发送；
+// 仪表发送当前值；名称以 _duration_seconds 结尾的仪表作为计时 (|ms) 发送。
+// 采样率小于 1 时计数器和计时按该概率发送并附带 |@rate，由服务端还原，仪表总是发送。
+// StatsD 格式不支持标签，标签按 Graphite 路径的方式追加到名称中；DogStatsD 格式以 |#k:v 发送标签
+type StatsD struct {
+	cfg    config.StatsDConfig
+	random func() float64 // 采样用的随机数，测试时替换
+
+	mu       sync.Mutex
+	conn     net.Conn
+	previous map[string]float64 // 各计数器上次的值
+}
+
+// NewStatsD 创建 StatsD 导出器，连接在首次发送时建立
+func NewStatsD(cfg config.StatsDConfig) *StatsD {
+	return &StatsD{cfg: cfg, random: rand.Float64, previous: make(map[string]float64)}
+}
+
+// Name 实现 Exporter
+func (e *StatsD) Name() string {
+	return "statsd"
+}
+
+// Close 关闭连接
+func (e *StatsD) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
+
+// Export 实现 Exporter，多行合并到不超过 max_packet_size 的数据报中发送
+func (e *StatsD) Export(ctx context.Context, families []*metrics.Family) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	lines := e.lines(families)
+	if len(lines) == 0 {
+		return nil
+	}
+	if e.conn == nil {
+		network, addr := "udp", e.cfg.Addr
+		if path, ok := strings.CutPrefix(addr, statsdUnixPrefix); ok {
+			network, addr = "unixgram", path
+		}
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			return fmt.Errorf("failed to connect to statsd %s: %w", e.cfg.Addr, err)
+		}
+		e.conn = conn
+	}
+
+	for _, packet := range packLines(lines, e.cfg.MaxPacketSize) {
+		if _, err := e.conn.Write(packet); err != nil {
+			_ = e.conn.Close()
+			e.conn = nil
+			return fmt.Errorf("failed to send to statsd %s: %w", e.cfg.Addr, err)
+		}
+	}
+	return nil
+}
+
+// lines 将指标族转换为 StatsD 行，调用方需持有锁
+func (e *StatsD) lines(families []*metrics.Family) []string {
+	var lines []string
+	for _, f := range families {
+		for _, s := range f.Samples() {
+			if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+				continue
+			}
+			value, typ, sampled := s.Value, "g", false
+			switch {
+			case f.Type == metrics.Counter || f.Type == metrics.Histogram:
+				key := seriesKey(s.Name, s.Labels)
+				prev, ok := e.previous[key]
+				e.previous[key] = s.Value
+				if !ok {
+					continue
+				}
+				if s.Value >= prev {
+					value -= prev
+				}
+				typ, sampled = "c", true
+			case strings.HasSuffix(f.Name, "_duration_seconds"):
+				value, typ, sampled = s.Value*1000, "ms", true
+			}
+
+			if sampled && e.cfg.SampleRate < 1 && e.random() >= e.cfg.SampleRate {
+				continue
+			}
+			lines = append(lines, e.line(s.Name, s.Labels, value, typ, sampled))
+		}
+	}
+	return lines
+}
+
+// line 按配置的格式生成一行
+func (e *StatsD) line(name string, labels []metrics.Label, value float64, typ string, sampled bool) string {
+	tagMode := GraphiteTagsPath
+	if e.cfg.Format == StatsDFormatDogStatsD {
+		tagMode = GraphiteTagsDrop
+	}
+	var sb strings.Builder
+	// 冒号在 Graphite 路径中合法，但在 StatsD 中是名称和值的分隔符
+	sb.WriteString(strings.ReplaceAll(graphitePath(e.cfg.Prefix, name, labels, tagMode), ":", "_"))
+	sb.WriteString(":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + typ)
+	if sampled && e.cfg.SampleRate < 1 {
+		sb.WriteString("|@" + strconv.FormatFloat(e.cfg.SampleRate, 'f', -1, 64))
+	}
+	if e.cfg.Format == StatsDFormatDogStatsD {
+		first := true
+		for _, l := range labels {
+			if l.Value == "" {
+				continue
+			}
+			if first {
+				sb.WriteString("|#")
+				first = false
+			} else {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(dogstatsdTag(l.Name) + ":" + dogstatsdTag(l.Value))
+		}
+	}
+	return sb.String()
+}
+
+// dogstatsdTag 替换标签中与 DogStatsD 语法冲突的字符
+func dogstatsdTag(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ',', '|', '#', '\n':
+			return '_'
+		default:
+			return r
+		}
+	}, s)
+}
+
+// packLines 将多行用换行连接为不超过 size 字节的数据报，超长的单行单独发送
+func packLines(lines []string, size int) [][]byte {
+	var packets [][]byte
+	var packet []byte
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+1+len(line) > size {
+			packets = append(packets, packet)
+			packet = nil
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		packets = append(packets, packet)
+	}
+	return packets
+}
@@ -0,0 +1,138 @@
+package exporter
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lwmacct/251203-vm-metrics/internal/config"
+	"github.com/lwmacct/251203-vm-metrics/internal/metrics"
+)
+
+// statsdFamilies 返回计数器、仪表和耗时各一个
+func statsdFamilies(counter float64) []*metrics.Family {
+	c := &metrics.Family{Name: "requests_total", Type: metrics.Counter}
+	c.Add(counter, "code", "200", "host", "web:80")
+	g := &metrics.Family{Name: "temperature", Type: metrics.Gauge}
+	g.Add(21.5)
+	d := &metrics.Family{Name: "scrape_duration_seconds", Type: metrics.Gauge}
+	d.Add(0.25)
+	return []*metrics.Family{c, g, d}
+}
+
+func TestStatsDLines(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{StatsDFormatStatsD, []string{
+			"app.requests_total.code.200.host.web_80:5|c",
+			"app.temperature:21.5|g",
+			"app.scrape_duration_seconds:250|ms",
+		}},
+		{StatsDFormatDogStatsD, []string{
+			"app.requests_total:5|c|#code:200,host:web:80",
+			"app.temperature:21.5|g",
+			"app.scrape_duration_seconds:250|ms",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			cfg := config.DefaultConfig().StatsD
+			cfg.Format, cfg.Prefix = tt.format, "app"
+			e := NewStatsD(cfg)
+
+			// 首次采集只记录计数器基线
+			first := e.lines(statsdFamilies(10))
+			if len(first) != 2 {
+				t.Errorf("首次采集不应发送计数器: %q", first)
+			}
+			if got := e.lines(statsdFamilies(15)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("行 = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsDSampling(t *testing.T) {
+	cfg := config.DefaultConfig().StatsD
+	cfg.SampleRate = 0.5
+	e := NewStatsD(cfg)
+	e.lines(statsdFamilies(10))
+
+	// 随机数不小于采样率时丢弃计数器和计时，仪表总是发送
+	e.random = func() float64 { return 0.7 }
+	if got := e.lines(statsdFamilies(15)); !reflect.DeepEqual(got, []string{"temperature:21.5|g"}) {
+		t.Errorf("未命中采样时 = %q", got)
+	}
+	e.random = func() float64 { return 0.2 }
+	want := []string{
+		"requests_total.code.200.host.web_80:5|c|@0.5",
+		"temperature:21.5|g",
+		"scrape_duration_seconds:250|ms|@0.5",
+	}
+	if got := e.lines(statsdFamilies(20)); !reflect.DeepEqual(got, want) {
+		t.Errorf("命中采样时 = %q, 期望 %q", got, want)
+	}
+}
+
+func TestPackLines(t *testing.T) {
+	got := packLines([]string{"aaaa", "bbbb", "cccccccccc", "dd"}, 9)
+	want := []string{"aaaa\nbbbb", "cccccccccc", "dd"}
+	if len(got) != len(want) {
+		t.Fatalf("数据报数 = %d, 期望 %d", len(got), len(want))
+	}
+	for i := range want {
+		if string(got[i]) != want[i] {
+			t.Errorf("第 %d 个数据报 = %q, 期望 %q", i+1, got[i], want[i])
+		}
+	}
+}
+
+func TestStatsDSend(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听 UDP 失败: %v", err)
+	}
+	defer udp.Close()
+	sock := filepath.Join(t.TempDir(), "dsd.sock")
+	unix, err := net.ListenPacket("unixgram", sock)
+	if err != nil {
+		t.Fatalf("监听 Unix 套接字失败: %v", err)
+	}
+	defer unix.Close()
+
+	tests := []struct {
+		name string
+		addr string
+		conn net.PacketConn
+	}{
+		{"udp", udp.LocalAddr().String(), udp},
+		{"unixgram", "unix://" + sock, unix},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig().StatsD
+			cfg.Addr, cfg.Format = tt.addr, StatsDFormatDogStatsD
+			e := NewStatsD(cfg)
+			defer e.Close()
+			if err := e.Export(context.Background(), statsdFamilies(1)); err != nil {
+				t.Fatalf("发送失败: %v", err)
+			}
+
+			buf := make([]byte, 2048)
+			_ = tt.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			n, _, err := tt.conn.ReadFrom(buf)
+			if err != nil {
+				t.Fatalf("接收失败: %v", err)
+			}
+			if got := string(buf[:n]); !strings.Contains(got, "temperature:21.5|g") {
+				t.Errorf("数据报 = %q", got)
+			}
+		})
+	}
+}
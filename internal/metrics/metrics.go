@@ -0,0 +1,146 @@
+// Package metrics 提供指标采集的数据模型、注册表和输出格式
+//
+// 采集器（Collector）每次被调用时返回当前的指标族（Family），
+// 注册表（Registry）并发调用所有采集器并合并结果，再由输出层按 Prometheus 文本格式等输出。
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+// Namespace 本工具输出的指标名称前缀
+const Namespace = "vm_metrics"
+
+// Type 指标类型
+type Type string
+
+// 指标类型，与 Prometheus 文本格式的 # TYPE 一致
+const (
+	Counter   Type = "counter"
+	Gauge     Type = "gauge"
+	Histogram Type = "histogram"
+	Untyped   Type = "untyped"
+)
+
+// Label 标签
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Metric 指标族中的单个时间序列
+type Metric struct {
+	Labels    []Label
+	Value     float64
+	Timestamp time.Time       // 采样时间，零值表示由抓取方决定
+	Created   time.Time       // 计数器开始计数的时间，仅 OpenMetrics 输出 (_created)
+	Exemplar  *Exemplar       // 计数器的示例，仅 OpenMetrics 输出
+	Histogram *HistogramValue // 直方图的桶、计数和总和，仅 Histogram 类型使用，此时忽略 Value
+}
+
+// HistogramValue 累计直方图
+type HistogramValue struct {
+	Buckets []Bucket // 按上界升序，不含 +Inf 桶
+	Count   float64  // 观测次数，即 +Inf 桶的计数
+	Sum     float64  // 观测值之和
+}
+
+// Bucket 直方图的桶
+type Bucket struct {
+	UpperBound float64
+	Count      float64 // 小于等于上界的观测次数（累计值）
+}
+
+// Exemplar 关联到某次观测的示例，通常携带 trace_id 以便从指标跳转到链路
+type Exemplar struct {
+	Labels    []Label
+	Value     float64
+	Timestamp time.Time // 零值表示不输出时间戳
+}
+
+// Family 指标族：名称、说明和类型相同的一组时间序列
+type Family struct {
+	Name      string // 完整名称，计数器以 _total 结尾
+	Help      string
+	Type      Type
+	Metrics   []Metric
+	Collector string // 产生该指标族的采集器，由 Registry.Gather 填写；注册表自身的指标为 ScrapeCollector
+}
+
+// NewFamily 创建指标族，name 不含命名空间前缀
+func NewFamily(name, help string, typ Type) *Family {
+	return &Family{Name: Namespace + "_" + name, Help: help, Type: typ}
+}
+
+// Add 添加一个时间序列，labels 为交替的标签名和标签值
+func (f *Family) Add(value float64, labels ...string) {
+	f.Metrics = append(f.Metrics, Metric{Value: value, Labels: pairs(labels)})
+}
+
+// AddHistogram 添加一个直方图时间序列，labels 同 Add
+func (f *Family) AddHistogram(h HistogramValue, labels ...string) {
+	f.Metrics = append(f.Metrics, Metric{Histogram: &h, Labels: pairs(labels)})
+}
+
+// pairs 将交替的标签名和标签值转换为标签
+func pairs(labels []string) []Label {
+	var out []Label
+	for i := 0; i+1 < len(labels); i += 2 {
+		out = append(out, Label{Name: labels[i], Value: labels[i+1]})
+	}
+	return out
+}
+
+// Sample 展开后的单个样本
+type Sample struct {
+	Name      string
+	Labels    []Label
+	Value     float64
+	Timestamp time.Time
+}
+
+// Samples 将指标族展开为样本，供只支持单值样本的输出格式使用：
+// 直方图的每个时间序列展开为 <name>_bucket (带 le 标签，含 +Inf)、<name>_sum 和 <name>_count，
+// 其他类型每个时间序列一个样本
+func (f *Family) Samples() []Sample {
+	var samples []Sample
+	for _, m := range f.Metrics {
+		if f.Type == Histogram {
+			samples = append(samples, m.histogramSamples(f.Name)...)
+			continue
+		}
+		samples = append(samples, Sample{Name: f.Name, Labels: m.Labels, Value: m.Value, Timestamp: m.Timestamp})
+	}
+	return samples
+}
+
+// histogramSamples 展开直方图时间序列
+func (m Metric) histogramSamples(name string) []Sample {
+	h := m.Histogram
+	if h == nil {
+		h = &HistogramValue{}
+	}
+	samples := make([]Sample, 0, len(h.Buckets)+3)
+	bucket := func(le string, count float64) {
+		labels := append(append(make([]Label, 0, len(m.Labels)+1), m.Labels...), Label{Name: "le", Value: le})
+		samples = append(samples, Sample{Name: name + "_bucket", Labels: labels, Value: count, Timestamp: m.Timestamp})
+	}
+	for _, b := range h.Buckets {
+		bucket(formatFloat(b.UpperBound), b.Count)
+	}
+	bucket("+Inf", h.Count)
+	return append(samples,
+		Sample{Name: name + "_sum", Labels: m.Labels, Value: h.Sum, Timestamp: m.Timestamp},
+		Sample{Name: name + "_count", Labels: m.Labels, Value: h.Count, Timestamp: m.Timestamp},
+	)
+}
+
+// Collector 采集器
+type Collector interface {
+	// Name 采集器名称，在注册表中唯一，也用作采集状态指标的 collector 标签
+	Name() string
+
+	// Collect 采集当前的指标，ctx 取消时应尽快返回
+	Collect(ctx context.Context) ([]*Family, error)
+}
@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// staticCollector 返回固定指标的采集器
+type staticCollector struct {
+	name     string
+	families []*Family
+	err      error
+}
+
+func (c *staticCollector) Name() string { return c.name }
+
+func (c *staticCollector) Collect(ctx context.Context) ([]*Family, error) {
+	return c.families, c.err
+}
+
+func TestWriteText(t *testing.T) {
+	f := &Family{Name: "test_total", Help: "说明\\带 \n 换行", Type: Counter}
+	f.Add(1, "path", `C:\dir "x"`)
+	f.Add(math.Inf(1))
+	f.Metrics = append(f.Metrics, Metric{Value: 0.5, Timestamp: time.UnixMilli(1700000000123)})
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, []*Family{f}); err != nil {
+		t.Fatalf("输出失败: %v", err)
+	}
+	want := `# HELP test_total 说明\\带 \n 换行
+# TYPE test_total counter
+test_total{path="C:\\dir \"x\""} 1
+test_total +Inf
+test_total 0.5 1700000000123
+`
+	if buf.String() != want {
+		t.Errorf("输出 =\n%s\n期望\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteTextHistogram(t *testing.T) {
+	h := NewFamily("latency_seconds", "延迟", Histogram)
+	h.AddHistogram(HistogramValue{
+		Buckets: []Bucket{{UpperBound: 0.1, Count: 3}, {UpperBound: 1, Count: 5}},
+		Count:   6,
+		Sum:     2.5,
+	}, "op", "read")
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, []*Family{h}); err != nil {
+		t.Fatalf("输出失败: %v", err)
+	}
+	want := `# HELP vm_metrics_latency_seconds 延迟
+# TYPE vm_metrics_latency_seconds histogram
+vm_metrics_latency_seconds_bucket{op="read",le="0.1"} 3
+vm_metrics_latency_seconds_bucket{op="read",le="1"} 5
+vm_metrics_latency_seconds_bucket{op="read",le="+Inf"} 6
+vm_metrics_latency_seconds_sum{op="read"} 2.5
+vm_metrics_latency_seconds_count{op="read"} 6
+`
+	if buf.String() != want {
+		t.Errorf("输出 =\n%s\n期望\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := WriteOpenMetrics(&buf, []*Family{h}); err != nil {
+		t.Fatalf("输出失败: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "# TYPE vm_metrics_latency_seconds histogram\n") ||
+		!strings.Contains(buf.String(), `vm_metrics_latency_seconds_bucket{op="read",le="+Inf"} 6`+"\n") {
+		t.Errorf("OpenMetrics 应按直方图输出:\n%s", buf.String())
+	}
+}
+
+func TestRegistryGather(t *testing.T) {
+	a := NewFamily("a", "A", Gauge)
+	a.Add(1, "src", "one")
+	a2 := NewFamily("a", "A", Gauge)
+	a2.Add(2, "src", "two")
+	b := NewFamily("b", "B", Gauge)
+	b.Add(3)
+
+	reg := NewRegistry()
+	for _, c := range []Collector{
+		&staticCollector{name: "one", families: []*Family{b, a}},
+		&staticCollector{name: "two", families: []*Family{a2}},
+		&staticCollector{name: "broken", err: errors.New("boom")},
+	} {
+		if err := reg.Register(c); err != nil {
+			t.Fatalf("注册失败: %v", err)
+		}
+	}
+	if err := reg.Register(&staticCollector{name: "one"}); err == nil {
+		t.Error("重复注册应返回错误")
+	}
+
+	families := reg.Gather(context.Background())
+	var names []string
+	for _, f := range families {
+		names = append(names, f.Name)
+	}
+	want := "vm_metrics_a vm_metrics_b vm_metrics_scrape_collector_duration_seconds vm_metrics_scrape_collector_success"
+	if strings.Join(names, " ") != want {
+		t.Fatalf("指标族 = %v, 期望 %s", names, want)
+	}
+	if len(families[0].Metrics) != 2 {
+		t.Errorf("同名指标族应合并: %+v", families[0].Metrics)
+	}
+	if families[1].Collector != "one" || families[3].Collector != ScrapeCollector {
+		t.Errorf("指标族应记录产生它的采集器: %s, %s", families[1].Collector, families[3].Collector)
+	}
+	for _, m := range families[3].Metrics {
+		if want := map[string]float64{"one": 1, "two": 1, "broken": 0}[m.Labels[0].Value]; m.Value != want {
+			t.Errorf("采集器 %s 的 success = %v, 期望 %v", m.Labels[0].Value, m.Value, want)
+		}
+	}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	c := &Family{Name: "requests_total", Help: `请求 "数"`, Type: Counter}
+	c.Metrics = []Metric{{
+		Labels:   []Label{{Name: "code", Value: "200"}},
+		Value:    42,
+		Created:  time.UnixMilli(1700000000000),
+		Exemplar: &Exemplar{Labels: []Label{{Name: "trace_id", Value: "abc"}}, Value: 1, Timestamp: time.UnixMilli(1700000000500)},
+	}}
+	u := &Family{Name: "temperature", Type: Untyped}
+	u.Add(21.5)
+
+	var buf bytes.Buffer
+	if err := WriteOpenMetrics(&buf, []*Family{c, u}); err != nil {
+		t.Fatalf("输出失败: %v", err)
+	}
+	want := `# TYPE requests counter
+# HELP requests 请求 \"数\"
+requests_total{code="200"} 42 # {trace_id="abc"} 1 1700000000.5
+requests_created{code="200"} 1700000000
+# TYPE temperature unknown
+temperature 21.5
+# EOF
+`
+	if buf.String() != want {
+		t.Errorf("输出 =\n%s\n期望\n%s", buf.String(), want)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   Format
+	}{
+		{"", FormatText},
+		{"text/plain;version=0.0.4", FormatText},
+		{"application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1", FormatOpenMetrics},
+		{"application/openmetrics-text;q=0.3,text/plain;q=0.8", FormatText},
+		{"application/json", FormatText},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.accept); got != tt.want {
+			t.Errorf("Negotiate(%q) = %v, 期望 %v", tt.accept, got, tt.want)
+		}
+	}
+}
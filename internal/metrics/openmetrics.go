@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenMetricsContentType OpenMetrics 文本格式的 Content-Type
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Format 指标输出格式
+type Format int
+
+const (
+	// FormatText Prometheus 文本格式 (0.0.4)，不支持 Accept 协商时的默认格式
+	FormatText Format = iota
+	// FormatOpenMetrics OpenMetrics 1.0 文本格式
+	FormatOpenMetrics
+)
+
+// Negotiate 按 Accept 请求头选择输出格式
+// 选择 q 值最高的受支持类型，相同时按出现顺序；没有受支持的类型时回退到 Prometheus 文本格式，兼容旧的抓取方
+func Negotiate(accept string) Format {
+	format, best := FormatText, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		var candidate Format
+		switch mediaType {
+		case "application/openmetrics-text":
+			candidate = FormatOpenMetrics
+		case "text/plain", "*/*":
+			candidate = FormatText
+		default:
+			continue
+		}
+		if q > best {
+			format, best = candidate, q
+		}
+	}
+	return format
+}
+
+// ContentType 返回格式对应的 Content-Type
+func (f Format) ContentType() string {
+	if f == FormatOpenMetrics {
+		return OpenMetricsContentType
+	}
+	return TextContentType
+}
+
+// Write 按格式输出指标族
+func (f Format) Write(w io.Writer, families []*Family) error {
+	if f == FormatOpenMetrics {
+		return WriteOpenMetrics(w, families)
+	}
+	return WriteText(w, families)
+}
+
+// WriteOpenMetrics 按 OpenMetrics 1.0 文本格式输出指标族，以 # EOF 结束
+// 计数器的族名不含 _total 后缀，样本为 <name>_total，并输出 _created 和示例；
+// 时间戳以秒为单位，untyped 输出为 unknown
+func WriteOpenMetrics(w io.Writer, families []*Family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		name, typ := f.Name, string(f.Type)
+		switch f.Type {
+		case Counter:
+			name = strings.TrimSuffix(f.Name, "_total")
+		case Untyped:
+			typ = "unknown"
+		}
+		bw.WriteString("# TYPE " + name + " " + typ + "\n")
+		if f.Help != "" {
+			bw.WriteString("# HELP " + name + " " + escapeLabelValue(f.Help) + "\n")
+		}
+		for _, m := range f.Metrics {
+			if f.Type == Histogram {
+				for _, s := range m.histogramSamples(name) {
+					bw.WriteString(s.Name)
+					writeLabels(bw, s.Labels)
+					bw.WriteString(" " + formatFloat(s.Value))
+					if !s.Timestamp.IsZero() {
+						bw.WriteString(" " + formatSeconds(s.Timestamp))
+					}
+					bw.WriteString("\n")
+				}
+				continue
+			}
+			sample := name
+			if f.Type == Counter {
+				sample += "_total"
+			}
+			bw.WriteString(sample)
+			writeLabels(bw, m.Labels)
+			bw.WriteString(" " + formatFloat(m.Value))
+			if !m.Timestamp.IsZero() {
+				bw.WriteString(" " + formatSeconds(m.Timestamp))
+			}
+			if f.Type == Counter && m.Exemplar != nil {
+				bw.WriteString(" # ")
+				bw.WriteByte('{')
+				for i, l := range m.Exemplar.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(l.Name + `="` + escapeLabelValue(l.Value) + `"`)
+				}
+				bw.WriteString("} " + formatFloat(m.Exemplar.Value))
+				if !m.Exemplar.Timestamp.IsZero() {
+					bw.WriteString(" " + formatSeconds(m.Exemplar.Timestamp))
+				}
+			}
+			bw.WriteString("\n")
+			if f.Type == Counter && !m.Created.IsZero() {
+				bw.WriteString(name + "_created")
+				writeLabels(bw, m.Labels)
+				bw.WriteString(" " + formatSeconds(m.Created) + "\n")
+			}
+		}
+	}
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
+
+// formatSeconds 将时间格式化为 Unix 秒，保留毫秒精度
+func formatSeconds(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ScrapeCollector 注册表自身指标 (scrape_collector_*) 的采集器名称
+const ScrapeCollector = "scrape"
+
+// Registry 采集器注册表
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+}
+
+// NewRegistry 创建空的注册表
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register 注册采集器，名称重复时返回错误
+func (r *Registry) Register(c Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.collectors {
+		if existing.Name() == c.Name() {
+			return fmt.Errorf("collector %s already registered", c.Name())
+		}
+	}
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+// Collectors 返回已注册的采集器
+func (r *Registry) Collectors() []Collector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Collector(nil), r.collectors...)
+}
+
+// Gather 并发调用所有采集器，按名称合并并排序指标族
+// 单个采集器失败不影响其他采集器，失败情况体现在 scrape_collector_success 指标中并输出警告
+func (r *Registry) Gather(ctx context.Context) []*Family {
+	collectors := r.Collectors()
+
+	type result struct {
+		families []*Family
+		duration time.Duration
+		err      error
+	}
+	results := make([]result, len(collectors))
+	var wg sync.WaitGroup
+	for i, c := range collectors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			families, err := c.Collect(ctx)
+			results[i] = result{families: families, duration: time.Since(start), err: err}
+		}()
+	}
+	wg.Wait()
+
+	duration := NewFamily("scrape_collector_duration_seconds", "采集器耗时", Gauge)
+	success := NewFamily("scrape_collector_success", "采集器是否成功", Gauge)
+	var all []*Family
+	for i, c := range collectors {
+		res := results[i]
+		duration.Add(res.duration.Seconds(), "collector", c.Name())
+		if res.err != nil {
+			slog.Warn("Collector failed", "collector", c.Name(), "error", res.err)
+			success.Add(0, "collector", c.Name())
+			continue
+		}
+		success.Add(1, "collector", c.Name())
+		for _, f := range res.families {
+			if f != nil && f.Collector == "" {
+				f.Collector = c.Name()
+			}
+		}
+		all = append(all, res.families...)
+	}
+	duration.Collector, success.Collector = ScrapeCollector, ScrapeCollector
+	all = append(all, duration, success)
+	return mergeFamilies(all)
+}
+
+// mergeFamilies 合并同名指标族并按名称排序，空的指标族被丢弃
+func mergeFamilies(families []*Family) []*Family {
+	byName := make(map[string]*Family)
+	var merged []*Family
+	for _, f := range families {
+		if f == nil || len(f.Metrics) == 0 {
+			continue
+		}
+		if existing, ok := byName[f.Name]; ok {
+			existing.Metrics = append(existing.Metrics, f.Metrics...)
+			continue
+		}
+		copied := *f
+		copied.Metrics = append([]Metric(nil), f.Metrics...)
+		byName[f.Name] = &copied
+		merged = append(merged, &copied)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+	return merged
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TextContentType Prometheus 文本格式的 Content-Type
+const TextContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteText 按 Prometheus 文本格式 (0.0.4) 输出指标族
+func WriteText(w io.Writer, families []*Family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		if f.Help != "" {
+			bw.WriteString("# HELP " + f.Name + " " + escapeHelp(f.Help) + "\n")
+		}
+		bw.WriteString("# TYPE " + f.Name + " " + string(f.Type) + "\n")
+		for _, s := range f.Samples() {
+			bw.WriteString(s.Name)
+			writeLabels(bw, s.Labels)
+			bw.WriteString(" " + formatFloat(s.Value))
+			if !s.Timestamp.IsZero() {
+				bw.WriteString(" " + strconv.FormatInt(s.Timestamp.UnixMilli(), 10))
+			}
+			bw.WriteString("\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// writeLabels 输出 {name="value",...}，没有标签时不输出
+func writeLabels(bw *bufio.Writer, labels []Label) {
+	if len(labels) == 0 {
+		return
+	}
+	bw.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		bw.WriteString(l.Name + `="` + escapeLabelValue(l.Value) + `"`)
+	}
+	bw.WriteByte('}')
+}
+
+// formatFloat 按 Prometheus 的写法格式化数值
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// escapeHelp 转义 HELP 中的反斜杠和换行
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// escapeLabelValue 转义标签值中的反斜杠、双引号和换行
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}